@@ -8,16 +8,25 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"sync"
 	"syscall"
 	"time"
 
 	"github.com/gorilla/mux"
+	"github.com/huberts90/restful-api/internal/cache"
 	"github.com/huberts90/restful-api/internal/config"
+	"github.com/huberts90/restful-api/internal/events"
+	"github.com/huberts90/restful-api/internal/gql"
+	"github.com/huberts90/restful-api/internal/grpcserver"
 	"github.com/huberts90/restful-api/internal/handler"
+	"github.com/huberts90/restful-api/internal/jobs"
 	"github.com/huberts90/restful-api/internal/logger"
 	"github.com/huberts90/restful-api/internal/middleware"
+	"github.com/huberts90/restful-api/internal/ratelimit"
 	"github.com/huberts90/restful-api/internal/storage"
+	"github.com/huberts90/restful-api/internal/webhook"
 	_ "github.com/lib/pq" // PostgreSQL driver
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"go.uber.org/zap"
 )
 
@@ -39,33 +48,129 @@ func main() {
 	defer zapLogger.Sync() // nolint: errcheck
 
 	// Set up the database
-	store, err := storage.NewPostgresStore(cfg.Postgres, zapLogger)
+	pgStore, err := storage.NewPostgresStore(cfg.Postgres, zapLogger)
 	if err != nil {
 		zapLogger.Fatal("Failed to connect to database", zap.Error(err))
 	}
+	store := storage.NewResilientStore(pgStore, cfg.Resilience, zapLogger)
+
+	// Set up the user cache
+	var userStore storage.Storer = store
+	if cfg.Cache.Backend != "none" {
+		var userCache cache.Cache = cache.NewLRU(cfg.Cache.Size)
+		if cfg.Cache.Backend == "redis" {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			userCache, err = cache.NewRedis(ctx, cfg.Cache.Redis)
+			cancel()
+			if err != nil {
+				zapLogger.Fatal("Failed to connect to cache", zap.Error(err))
+			}
+		}
+		userStore = storage.NewCachedStore(store, userCache, cfg.Cache.TTL, zapLogger)
+	}
+
 	// @MENTION_ME: always try to close resources
-	_ = store.Close()
+	defer func() {
+		if err := userStore.Close(); err != nil {
+			zapLogger.Warn("Failed to close database connection", zap.Error(err))
+		}
+	}()
 
 	zapLogger.Info("Successfully connected to database")
 
+	// Files of background jobs, such as uploads and exports, are kept in a directory shared with the workers
+	if err := os.MkdirAll(cfg.Jobs.Dir, 0o750); err != nil {
+		zapLogger.Fatal("Failed to create jobs directory", zap.Error(err))
+	}
+
 	// Create router
 	router := mux.NewRouter()
 
-	// Apply logging middleware
+	// Set up crash reports for post-mortems
+	var crashReporter *middleware.CrashReporter
+	if cfg.Server.CrashReportDir != "" {
+		crashReporter, err = middleware.NewCrashReporter(cfg.Server.CrashReportDir, cfg.Server.CrashReportsPerHour)
+		if err != nil {
+			zapLogger.Fatal("Failed to set up crash reports", zap.Error(err))
+		}
+	}
+
+	// Apply middlewares
+	// @MENTION_ME: order matters - recovery sits inside logging so that recovered panics are logged as 500s
+	// Compression sits between them, so that logging counts the bytes sent and recovered panics are compressed too
+	router.Use(middleware.RequestIDMiddleware())
+	router.Use(middleware.ClientIPMiddleware(cfg.Server.TrustedProxies))
 	router.Use(middleware.LoggingMiddleware(zapLogger))
+	if cfg.Compression.Enabled {
+		router.Use(middleware.CompressionMiddleware(cfg.Compression, zapLogger))
+	}
+	router.Use(middleware.RecoveryMiddleware(zapLogger, crashReporter))
 
 	// Public routes (no authentication required)
-	router.HandleFunc("/healthz", func(w http.ResponseWriter, _ *http.Request) {
-		w.WriteHeader(http.StatusOK)
-	}).Methods(http.MethodGet)
+	healthHandler := handler.NewHealthHandler(zapLogger)
+	healthHandler.AddCheck("database", func(ctx context.Context) error {
+		return pgStore.DB().PingContext(ctx)
+	})
+	healthHandler.AddCheck("circuit_breaker", func(context.Context) error {
+		if store.State() == storage.CircuitOpen {
+			return storage.ErrCircuitOpen
+		}
+		return nil
+	})
+	healthHandler.RegisterRoutes(router)
+	router.Handle("/metrics", promhttp.Handler()).Methods(http.MethodGet)
 
 	// Create API subrouter with authentication
 	// TODO: apiRouter.Use(authMiddleware.Middleware())
 	apiRouter := router.PathPrefix("/api").Subrouter()
 
+	// Shed load before any other work is done, health and metrics routes are not limited
+	if cfg.Concurrency.Enabled {
+		apiRouter.Use(middleware.ConcurrencyLimitMiddleware(middleware.NewAdaptiveLimiter(cfg.Concurrency), zapLogger))
+	}
+
+	// Apply rate limiting to the API routes only
+	var pgBuckets *ratelimit.PostgresStore
+	if cfg.RateLimit.Enabled {
+		var buckets ratelimit.Store = ratelimit.NewMemoryStore()
+		if cfg.RateLimit.Backend == "postgres" {
+			pgBuckets = ratelimit.NewPostgresStore(pgStore.DB())
+			buckets = pgBuckets
+		}
+		apiRouter.Use(middleware.RateLimitMiddleware(buckets, cfg.RateLimit, zapLogger))
+	}
+
+	// Let the store route reads of clients that just wrote to the primary and audit who made changes
+	apiRouter.Use(middleware.ReadYourWritesMiddleware())
+	apiRouter.Use(middleware.AuditMiddleware())
+
 	// Register handlers
-	userHandler := handler.NewUserHandler(store, zapLogger)
+	broadcaster := events.NewBroadcaster(cfg.EventStream.MaxConnections, cfg.EventStream.Buffer)
+	eventHandler := handler.NewEventHandler(broadcaster, pgStore, cfg.EventStream, zapLogger)
+	eventHandler.RegisterRoutes(apiRouter)
+	userHandler := handler.NewUserHandler(userStore, zapLogger, handler.WithBatchConfig(cfg.Batch), handler.WithVersionConfig(cfg.Versions), handler.WithCacheConfig(cfg.HTTPCache), handler.WithBodyLimits(cfg.BodyLimits))
 	userHandler.RegisterRoutes(apiRouter)
+	importHandler := handler.NewImportHandler(userStore, pgStore, cfg.Import, cfg.Jobs, zapLogger)
+	importHandler.RegisterRoutes(apiRouter)
+	exportHandler := handler.NewExportHandler(userStore, pgStore, cfg.Export, zapLogger)
+	exportHandler.RegisterRoutes(apiRouter)
+	jobHandler := handler.NewJobHandler(pgStore, cfg.Jobs, zapLogger)
+	jobHandler.RegisterRoutes(apiRouter)
+	webhookHandler := handler.NewWebhookHandler(pgStore, cfg.Webhooks, cfg.BodyLimits, zapLogger)
+	webhookHandler.RegisterRoutes(apiRouter)
+	graphqlServer, err := gql.NewServer(userStore, cache.NewLRU(cfg.GraphQL.PersistedQueries), cfg.GraphQL, zapLogger)
+	if err != nil {
+		zapLogger.Fatal("Failed to build GraphQL schema", zap.Error(err))
+	}
+	graphqlHandler := handler.NewGraphQLHandler(graphqlServer, cfg.BodyLimits, zapLogger)
+	graphqlHandler.RegisterRoutes(apiRouter)
+
+	// Serve SCIM provisioning outside of the API routes, identity providers authenticate with their own token
+	scimRouter := router.PathPrefix(handler.SCIMPathPrefix).Subrouter()
+	scimRouter.Use(middleware.ReadYourWritesMiddleware())
+	scimRouter.Use(middleware.AuditMiddleware())
+	scimHandler := handler.NewSCIMHandler(userStore, cfg.SCIM, cfg.BodyLimits, zapLogger)
+	scimHandler.RegisterRoutes(scimRouter)
 
 	// Create and configure the server
 	server := &http.Server{
@@ -75,6 +180,98 @@ func main() {
 		WriteTimeout: 15 * time.Second,
 		IdleTimeout:  120 * time.Second,
 	}
+	// Event streams never end on their own, close them so that shutdown does not wait for them
+	server.RegisterOnShutdown(func() {
+		_ = broadcaster.Close()
+	})
+
+	// Set up event publishing, in-process subscribers are served whichever publisher relays the outbox
+	eventBus := events.NewInProcess()
+	var publisher events.Publisher
+	if cfg.Events.Publisher != "none" {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		publisher, err = events.NewPublisher(ctx, cfg.Events, eventBus)
+		cancel()
+		if err != nil {
+			zapLogger.Fatal("Failed to set up event publisher", zap.Error(err))
+		}
+		if publisher != events.Publisher(eventBus) {
+			publisher = events.NewMulti(eventBus, publisher)
+		}
+	}
+
+	// Feed the event stream from Postgres notifications, so that every instance sees every event,
+	// or from the in-process publisher
+	var listener *storage.EventListener
+	if cfg.EventStream.Source == "notify" {
+		listener, err = storage.NewEventListener(cfg.Postgres, zapLogger)
+		if err != nil {
+			zapLogger.Fatal("Failed to set up event listener", zap.Error(err))
+		}
+	} else {
+		eventBus.Subscribe(broadcaster.Publish)
+	}
+
+	// Deliver events to the registered webhooks
+	var dispatcher *webhook.Dispatcher
+	if cfg.Webhooks.Enabled {
+		dispatcher = webhook.NewDispatcher(pgStore, cfg.Webhooks, zapLogger)
+		eventBus.Subscribe(dispatcher.HandleEvent)
+	}
+
+	// Start background jobs, they are stopped along with the server
+	bgCtx, stopBackground := context.WithCancel(context.Background())
+	var background sync.WaitGroup
+	// The relay runs without a publisher too, the outbox must still be pruned
+	relay := events.NewRelay(pgStore, publisher, cfg.Events, zapLogger)
+	background.Add(1)
+	go func() {
+		defer background.Done()
+		relay.Run(bgCtx)
+	}()
+	if pgBuckets != nil {
+		background.Add(1)
+		go func() {
+			defer background.Done()
+			pgBuckets.RunPruner(bgCtx, cfg.RateLimit.RefillTime(), zapLogger)
+		}()
+	}
+	if listener != nil {
+		background.Add(1)
+		go func() {
+			defer background.Done()
+			listener.Run(bgCtx, broadcaster.Publish)
+		}()
+	}
+	if dispatcher != nil {
+		background.Add(1)
+		go func() {
+			defer background.Done()
+			dispatcher.Run(bgCtx)
+		}()
+	}
+	if cfg.Postgres.PurgeInterval > 0 {
+		purger := storage.NewPurger(userStore, cfg.Postgres.SoftDeleteRetention, cfg.Postgres.PurgeInterval, zapLogger)
+		background.Add(1)
+		go func() {
+			defer background.Done()
+			purger.Run(bgCtx)
+		}()
+	}
+
+	if cfg.Jobs.Workers > 0 {
+		runner := jobs.NewRunner(pgStore, cfg.Jobs, zapLogger)
+		jobs.RegisterUserJobs(runner, userStore, jobs.UserConfig{
+			Import:         cfg.Import,
+			Export:         cfg.Export,
+			PurgeRetention: cfg.Postgres.SoftDeleteRetention,
+		}, zapLogger)
+		background.Add(1)
+		go func() {
+			defer background.Done()
+			runner.Run(bgCtx)
+		}()
+	}
 
 	// Start the server in a goroutine
 	go func() {
@@ -84,6 +281,18 @@ func main() {
 		}
 	}()
 
+	// Serve the gRPC API on its own port, from the same store as the REST API
+	var grpcServer *grpcserver.Server
+	if cfg.GRPC.Port > 0 {
+		grpcServer = grpcserver.NewServer(userStore, cfg.GRPC, zapLogger)
+		go func() {
+			zapLogger.Info("Starting gRPC server", zap.Int("port", cfg.GRPC.Port))
+			if err := grpcServer.ListenAndServe(); err != nil {
+				zapLogger.Fatal("Failed to start gRPC server", zap.Error(err))
+			}
+		}()
+	}
+
 	// Set up graceful shutdown
 	// @MENTION_ME: buffered channel does not block the sender until receiver reads from the channels
 	quit := make(chan os.Signal, 1)
@@ -96,9 +305,24 @@ func main() {
 	defer cancel()
 
 	// Attempt to gracefully shut down the server
+	if grpcServer != nil {
+		grpcServer.Shutdown(ctx)
+	}
 	if err := server.Shutdown(ctx); err != nil {
 		zapLogger.Fatal("Server forced to shutdown", zap.Error(err))
 	}
+	stopBackground()
+	background.Wait()
+	if publisher != nil {
+		if err := publisher.Close(); err != nil {
+			zapLogger.Warn("Failed to close event publisher", zap.Error(err))
+		}
+	}
+	if listener != nil {
+		if err := listener.Close(); err != nil {
+			zapLogger.Warn("Failed to close event listener", zap.Error(err))
+		}
+	}
 
 	zapLogger.Info("Server exited gracefully")
 }
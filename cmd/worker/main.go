@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/huberts90/restful-api/internal/cache"
+	"github.com/huberts90/restful-api/internal/config"
+	"github.com/huberts90/restful-api/internal/jobs"
+	"github.com/huberts90/restful-api/internal/logger"
+	"github.com/huberts90/restful-api/internal/storage"
+	_ "github.com/lib/pq" // PostgreSQL driver
+	"go.uber.org/zap"
+)
+
+// The worker runs background jobs apart from the API, which can then leave them to it with JOBS_WORKERS=0
+func main() {
+	// Load configuration from
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		log.Fatalf("Failed to load configuration: %v", err)
+	}
+
+	// Set up the logger
+	zapLogger, err := logger.NewLogger(cfg.IsProd)
+	if err != nil {
+		log.Fatalf("Failed to initialize logger: %v", err)
+	}
+	defer zapLogger.Sync() // nolint: errcheck
+
+	if cfg.Jobs.Workers < 1 {
+		zapLogger.Fatal("JOBS_WORKERS must be positive for the worker")
+	}
+	if err := os.MkdirAll(cfg.Jobs.Dir, 0o750); err != nil {
+		zapLogger.Fatal("Failed to create jobs directory", zap.Error(err))
+	}
+
+	// Set up the database
+	pgStore, err := storage.NewPostgresStore(cfg.Postgres, zapLogger)
+	if err != nil {
+		zapLogger.Fatal("Failed to connect to database", zap.Error(err))
+	}
+	store := storage.NewResilientStore(pgStore, cfg.Resilience, zapLogger)
+
+	// Changes made by jobs are invalidated in a shared cache, an in-process one only lives in the API
+	var userStore storage.Storer = store
+	if cfg.Cache.Backend == "redis" {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		userCache, err := cache.NewRedis(ctx, cfg.Cache.Redis)
+		cancel()
+		if err != nil {
+			zapLogger.Fatal("Failed to connect to cache", zap.Error(err))
+		}
+		userStore = storage.NewCachedStore(store, userCache, cfg.Cache.TTL, zapLogger)
+	}
+	defer func() {
+		if err := userStore.Close(); err != nil {
+			zapLogger.Warn("Failed to close database connection", zap.Error(err))
+		}
+	}()
+
+	runner := jobs.NewRunner(pgStore, cfg.Jobs, zapLogger)
+	jobs.RegisterUserJobs(runner, userStore, jobs.UserConfig{
+		Import:         cfg.Import,
+		Export:         cfg.Export,
+		PurgeRetention: cfg.Postgres.SoftDeleteRetention,
+	}, zapLogger)
+
+	// Run until a signal arrives, the jobs still running are then put back in the queue
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	zapLogger.Info("Starting worker", zap.Int("workers", cfg.Jobs.Workers))
+	runner.Run(ctx)
+	zapLogger.Info("Worker exited gracefully")
+}
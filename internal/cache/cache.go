@@ -0,0 +1,27 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrMiss is returned when a key is not in the cache
+var ErrMiss = errors.New("cache miss")
+
+// Cache is a byte-oriented key-value cache with expiring entries
+// Entries set with a TTL of zero or less do not expire. Implementations must be safe for concurrent use
+type Cache interface {
+	Get(ctx context.Context, key string) ([]byte, error)
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	Delete(ctx context.Context, key string) error
+	Close() error
+}
+
+// Config holds the cache configuration
+type Config struct {
+	Backend string // "none", "memory" or "redis"
+	TTL     time.Duration
+	Size    int // capacity of the in-memory cache
+	Redis   RedisConfig
+}
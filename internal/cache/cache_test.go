@@ -0,0 +1,194 @@
+package cache
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLRU(t *testing.T) {
+	ctx := context.Background()
+	c := NewLRU(2)
+	now := time.Date(2023, 1, 1, 12, 0, 0, 0, time.UTC)
+	c.now = func() time.Time { return now }
+
+	require.NoError(t, c.Set(ctx, "a", []byte("1"), time.Minute))
+	require.NoError(t, c.Set(ctx, "b", []byte("2"), time.Minute))
+
+	// Reading "a" makes "b" the least recently used entry
+	got, err := c.Get(ctx, "a")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("1"), got)
+
+	require.NoError(t, c.Set(ctx, "c", []byte("3"), time.Second))
+	_, err = c.Get(ctx, "b")
+	assert.ErrorIs(t, err, ErrMiss)
+	assert.Equal(t, 2, c.Len())
+
+	// Expired entries are misses
+	now = now.Add(2 * time.Second)
+	_, err = c.Get(ctx, "c")
+	assert.ErrorIs(t, err, ErrMiss)
+
+	require.NoError(t, c.Delete(ctx, "a"))
+	_, err = c.Get(ctx, "a")
+	assert.ErrorIs(t, err, ErrMiss)
+
+	// Entries without a TTL do not expire
+	require.NoError(t, c.Set(ctx, "d", []byte("4"), 0))
+	now = now.Add(24 * time.Hour)
+	got, err = c.Get(ctx, "d")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("4"), got)
+}
+
+// fakeRedis is a local stand-in for a Redis server supporting the commands used by the client
+type fakeRedis struct {
+	listener net.Listener
+	accepted atomic.Int32
+	mu       sync.Mutex
+	data     map[string]string
+}
+
+func startFakeRedis(t *testing.T) *fakeRedis {
+	t.Helper()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	srv := &fakeRedis{listener: l, data: make(map[string]string)}
+	t.Cleanup(func() { l.Close() })
+
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			srv.accepted.Add(1)
+			go srv.serve(conn)
+		}
+	}()
+	return srv
+}
+
+func (s *fakeRedis) serve(conn net.Conn) {
+	defer conn.Close()
+	rd := bufio.NewReader(conn)
+	for {
+		line, err := readLine(rd)
+		if err != nil {
+			return
+		}
+		n, _ := strconv.Atoi(line[1:])
+		args := make([]string, n)
+		for i := range args {
+			if _, err := readLine(rd); err != nil {
+				return
+			}
+			if args[i], err = readLine(rd); err != nil {
+				return
+			}
+		}
+
+		s.mu.Lock()
+		var reply string
+		switch strings.ToUpper(args[0]) {
+		case "PING":
+			reply = "+PONG\r\n"
+		case "GET":
+			if v, ok := s.data[args[1]]; ok {
+				reply = "$" + strconv.Itoa(len(v)) + "\r\n" + v + "\r\n"
+			} else {
+				reply = "$-1\r\n"
+			}
+		case "SET":
+			// Like Redis, expiry times must be positive
+			if len(args) == 5 && args[4] <= "0" {
+				reply = "-ERR invalid expire time in 'set' command\r\n"
+				break
+			}
+			s.data[args[1]] = args[2]
+			reply = "+OK\r\n"
+		case "DEL":
+			delete(s.data, args[1])
+			reply = ":1\r\n"
+		default:
+			reply = "-ERR unknown command\r\n"
+		}
+		s.mu.Unlock()
+
+		if _, err := conn.Write([]byte(reply)); err != nil {
+			return
+		}
+	}
+}
+
+func TestRedis(t *testing.T) {
+	srv := startFakeRedis(t)
+	ctx := context.Background()
+
+	c, err := NewRedis(ctx, RedisConfig{Addr: srv.listener.Addr().String(), PoolSize: 2, DialTimeout: time.Second})
+	require.NoError(t, err)
+	defer c.Close()
+
+	_, err = c.Get(ctx, "user:1")
+	assert.ErrorIs(t, err, ErrMiss)
+
+	require.NoError(t, c.Set(ctx, "user:1", []byte(`{"ID":1}`), time.Minute))
+	got, err := c.Get(ctx, "user:1")
+	require.NoError(t, err)
+	assert.Equal(t, []byte(`{"ID":1}`), got)
+
+	require.NoError(t, c.Delete(ctx, "user:1"))
+	_, err = c.Get(ctx, "user:1")
+	assert.ErrorIs(t, err, ErrMiss)
+
+	// TTLs under a millisecond are rounded up, and entries without a TTL do not expire
+	require.NoError(t, c.Set(ctx, "user:2", []byte(`{"ID":2}`), 500*time.Microsecond))
+	require.NoError(t, c.Set(ctx, "user:3", []byte(`{"ID":3}`), 0))
+	got, err = c.Get(ctx, "user:3")
+	require.NoError(t, err)
+	assert.Equal(t, []byte(`{"ID":3}`), got)
+
+	// Error replies are surfaced without breaking the connection
+	_, err = c.do(ctx, "FLUSHALL")
+	assert.EqualError(t, err, "redis: ERR unknown command")
+	_, err = c.Get(ctx, "user:1")
+	assert.ErrorIs(t, err, ErrMiss)
+}
+
+func TestRedis_PoolSize(t *testing.T) {
+	srv := startFakeRedis(t)
+	ctx := context.Background()
+
+	c, err := NewRedis(ctx, RedisConfig{Addr: srv.listener.Addr().String(), PoolSize: 1, DialTimeout: time.Second})
+	require.NoError(t, err)
+	defer c.Close()
+
+	// With the only connection taken, commands wait for it rather than dial another
+	rc, err := c.get(ctx)
+	require.NoError(t, err)
+	waitCtx, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+	defer cancel()
+	_, err = c.Get(waitCtx, "user:1")
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := c.Get(ctx, "user:1")
+		done <- err
+	}()
+	time.Sleep(10 * time.Millisecond)
+	c.put(rc)
+	assert.ErrorIs(t, <-done, ErrMiss)
+	assert.Equal(t, int32(1), srv.accepted.Load())
+}
@@ -0,0 +1,261 @@
+package cache
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"time"
+)
+
+// RedisConfig holds the configuration for connecting to a Redis-compatible server
+type RedisConfig struct {
+	Addr        string
+	Password    string
+	DB          int
+	PoolSize    int
+	DialTimeout time.Duration
+}
+
+// redisError is an error reply sent by the server
+type redisError string
+
+func (e redisError) Error() string {
+	return "redis: " + string(e)
+}
+
+type redisConn struct {
+	conn net.Conn
+	rd   *bufio.Reader
+}
+
+// Redis is a minimal client speaking the Redis serialization protocol (RESP)
+// It only implements the handful of commands the cache needs, which keeps the dependency tree small.
+// At most PoolSize connections are open at once, further commands wait for one to be free
+type Redis struct {
+	cfg  RedisConfig
+	pool chan *redisConn
+	// conns holds a slot for every open connection
+	conns chan struct{}
+}
+
+// NewRedis creates a new Redis cache and checks that the server is reachable
+func NewRedis(ctx context.Context, cfg RedisConfig) (*Redis, error) {
+	c := &Redis{
+		cfg:   cfg,
+		pool:  make(chan *redisConn, cfg.PoolSize),
+		conns: make(chan struct{}, cfg.PoolSize),
+	}
+
+	if _, err := c.do(ctx, "PING"); err != nil {
+		return nil, fmt.Errorf("failed to ping redis: %w", err)
+	}
+	return c, nil
+}
+
+// Get implements the Cache interface
+func (c *Redis) Get(ctx context.Context, key string) ([]byte, error) {
+	reply, err := c.do(ctx, "GET", key)
+	if err != nil {
+		return nil, err
+	}
+	if reply == nil {
+		return nil, ErrMiss
+	}
+	value, ok := reply.([]byte)
+	if !ok {
+		return nil, fmt.Errorf("redis: unexpected reply %T to GET", reply)
+	}
+	return value, nil
+}
+
+// Set implements the Cache interface
+func (c *Redis) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	if ttl <= 0 {
+		_, err := c.do(ctx, "SET", key, string(value))
+		return err
+	}
+	// Redis rejects a PX of zero, so TTLs under a millisecond are rounded up
+	_, err := c.do(ctx, "SET", key, string(value), "PX", strconv.FormatInt(max(ttl.Milliseconds(), 1), 10))
+	return err
+}
+
+// Delete implements the Cache interface
+func (c *Redis) Delete(ctx context.Context, key string) error {
+	_, err := c.do(ctx, "DEL", key)
+	return err
+}
+
+// Close implements the Cache interface
+func (c *Redis) Close() error {
+	for {
+		select {
+		case rc := <-c.pool:
+			c.discard(rc)
+		default:
+			return nil
+		}
+	}
+}
+
+// do sends a command and reads its reply
+// Replies are nil, string, int64 or []byte, error replies are returned as errors
+func (c *Redis) do(ctx context.Context, args ...string) (interface{}, error) {
+	rc, err := c.get(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	reply, err := rc.roundTrip(ctx, args)
+	var replyErr redisError
+	if err != nil && !errors.As(err, &replyErr) {
+		// The connection state is unknown after an I/O error
+		c.discard(rc)
+		return nil, err
+	}
+
+	c.put(rc)
+	return reply, err
+}
+
+// get takes a connection from the pool or dials a new one
+// When PoolSize connections are open, it waits for one to be returned as long as ctx allows
+func (c *Redis) get(ctx context.Context) (*redisConn, error) {
+	select {
+	case rc := <-c.pool:
+		return rc, nil
+	default:
+	}
+
+	select {
+	case rc := <-c.pool:
+		return rc, nil
+	case c.conns <- struct{}{}:
+	case <-ctx.Done():
+		return nil, fmt.Errorf("failed to get a redis connection: %w", ctx.Err())
+	}
+
+	rc, err := c.dial(ctx)
+	if err != nil {
+		<-c.conns
+		return nil, err
+	}
+	return rc, nil
+}
+
+// dial opens a new connection, authenticated and on the configured database
+func (c *Redis) dial(ctx context.Context) (*redisConn, error) {
+	dialer := net.Dialer{Timeout: c.cfg.DialTimeout}
+	conn, err := dialer.DialContext(ctx, "tcp", c.cfg.Addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to redis: %w", err)
+	}
+	rc := &redisConn{conn: conn, rd: bufio.NewReader(conn)}
+
+	if c.cfg.Password != "" {
+		if _, err := rc.roundTrip(ctx, []string{"AUTH", c.cfg.Password}); err != nil {
+			_ = conn.Close()
+			return nil, err
+		}
+	}
+	if c.cfg.DB != 0 {
+		if _, err := rc.roundTrip(ctx, []string{"SELECT", strconv.Itoa(c.cfg.DB)}); err != nil {
+			_ = conn.Close()
+			return nil, err
+		}
+	}
+	return rc, nil
+}
+
+// put returns a connection to the pool, closing it if the pool is full
+func (c *Redis) put(rc *redisConn) {
+	select {
+	case c.pool <- rc:
+	default:
+		c.discard(rc)
+	}
+}
+
+// discard closes a connection and frees its slot
+func (c *Redis) discard(rc *redisConn) {
+	_ = rc.conn.Close()
+	<-c.conns
+}
+
+func (rc *redisConn) roundTrip(ctx context.Context, args []string) (interface{}, error) {
+	// A zero deadline, when ctx has none, clears any previous one
+	deadline, _ := ctx.Deadline()
+	if err := rc.conn.SetDeadline(deadline); err != nil {
+		return nil, err
+	}
+
+	if _, err := rc.conn.Write(encodeCommand(args)); err != nil {
+		return nil, err
+	}
+	return readReply(rc.rd)
+}
+
+// encodeCommand encodes a command as a RESP array of bulk strings
+func encodeCommand(args []string) []byte {
+	buf := make([]byte, 0, 64)
+	buf = append(buf, '*')
+	buf = strconv.AppendInt(buf, int64(len(args)), 10)
+	buf = append(buf, '\r', '\n')
+	for _, arg := range args {
+		buf = append(buf, '$')
+		buf = strconv.AppendInt(buf, int64(len(arg)), 10)
+		buf = append(buf, '\r', '\n')
+		buf = append(buf, arg...)
+		buf = append(buf, '\r', '\n')
+	}
+	return buf
+}
+
+// readReply reads a single RESP reply
+func readReply(rd *bufio.Reader) (interface{}, error) {
+	line, err := readLine(rd)
+	if err != nil {
+		return nil, err
+	}
+	if len(line) == 0 {
+		return nil, errors.New("redis: empty reply")
+	}
+
+	switch line[0] {
+	case '+':
+		return line[1:], nil
+	case '-':
+		return nil, redisError(line[1:])
+	case ':':
+		return strconv.ParseInt(line[1:], 10, 64)
+	case '$':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, fmt.Errorf("redis: invalid bulk length: %w", err)
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		data := make([]byte, n+2)
+		if _, err := io.ReadFull(rd, data); err != nil {
+			return nil, err
+		}
+		return data[:n], nil
+	default:
+		return nil, fmt.Errorf("redis: unsupported reply type %q", line[0])
+	}
+}
+
+func readLine(rd *bufio.Reader) (string, error) {
+	line, err := rd.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	if len(line) < 2 || line[len(line)-2] != '\r' {
+		return "", errors.New("redis: malformed reply line")
+	}
+	return line[:len(line)-2], nil
+}
@@ -2,23 +2,60 @@ package config
 
 import (
 	"fmt"
+	"net/netip"
 	"os"
+	"path/filepath"
 	"strconv"
+	"strings"
 	"time"
 
+	"github.com/huberts90/restful-api/internal/cache"
+	"github.com/huberts90/restful-api/internal/events"
+	"github.com/huberts90/restful-api/internal/export"
+	"github.com/huberts90/restful-api/internal/gql"
+	"github.com/huberts90/restful-api/internal/grpcserver"
+	"github.com/huberts90/restful-api/internal/handler"
+	"github.com/huberts90/restful-api/internal/importer"
+	"github.com/huberts90/restful-api/internal/jobs"
+	"github.com/huberts90/restful-api/internal/middleware"
+	"github.com/huberts90/restful-api/internal/ratelimit"
+	"github.com/huberts90/restful-api/internal/scim"
 	"github.com/huberts90/restful-api/internal/storage"
+	"github.com/huberts90/restful-api/internal/webhook"
 )
 
 // Config holds all application configuration
 type Config struct {
-	Server   ServerConfig
-	Postgres storage.PostgresConfig
-	IsProd   bool
+	Server      ServerConfig
+	GRPC        grpcserver.Config
+	Postgres    storage.PostgresConfig
+	Resilience  storage.ResilienceConfig
+	Cache       cache.Config
+	RateLimit   ratelimit.Config
+	Concurrency middleware.ConcurrencyConfig
+	Compression middleware.CompressionConfig
+	Events      events.Config
+	EventStream events.StreamConfig
+	Webhooks    webhook.Config
+	Batch       handler.BatchConfig
+	BodyLimits  handler.BodyLimits
+	Versions    handler.VersionConfig
+	HTTPCache   handler.CacheConfig
+	Import      importer.Config
+	Export      export.Config
+	Jobs        jobs.Config
+	GraphQL     gql.Config
+	SCIM        scim.Config
+	IsProd      bool
 }
 
 // ServerConfig holds the HTTP server configuration
 type ServerConfig struct {
-	Port int
+	Port                int
+	CrashReportDir      string // empty disables crash reports
+	CrashReportsPerHour int
+	// TrustedProxies are the proxies whose X-Forwarded-For header tells the address of the client
+	TrustedProxies []netip.Prefix
 }
 
 // LoadConfig loads configuration from environment variables
@@ -28,6 +65,38 @@ func LoadConfig() (*Config, error) {
 	if err != nil {
 		return nil, fmt.Errorf("invalid SERVER_PORT: %w", err)
 	}
+	crashReportDir := loadEnv("CRASH_REPORT_DIR", "")
+	crashReportsPerHour, err := loadIntEnv("CRASH_REPORTS_PER_HOUR", 10)
+	if err != nil {
+		return nil, fmt.Errorf("invalid CRASH_REPORTS_PER_HOUR: %w", err)
+	}
+	if crashReportsPerHour < 0 {
+		return nil, fmt.Errorf("invalid CRASH_REPORTS_PER_HOUR: must not be negative")
+	}
+	var trustedProxies []netip.Prefix
+	for _, proxy := range loadListEnv("TRUSTED_PROXIES", nil) {
+		prefix, err := parsePrefix(proxy)
+		if err != nil {
+			return nil, fmt.Errorf("invalid TRUSTED_PROXIES: %w", err)
+		}
+		trustedProxies = append(trustedProxies, prefix)
+	}
+
+	// Load gRPC server config
+	grpcPort, err := loadIntEnv("GRPC_PORT", 9090)
+	if err != nil {
+		return nil, fmt.Errorf("invalid GRPC_PORT: %w", err)
+	}
+	if grpcPort < 0 {
+		return nil, fmt.Errorf("invalid GRPC_PORT: must not be negative")
+	}
+	grpcListTimeout, err := loadTimeDurEnv("GRPC_LIST_TIMEOUT", 30*time.Minute)
+	if err != nil {
+		return nil, fmt.Errorf("invalid GRPC_LIST_TIMEOUT: %w", err)
+	}
+	if grpcListTimeout <= 0 {
+		return nil, fmt.Errorf("invalid GRPC_LIST_TIMEOUT: must be positive")
+	}
 
 	// Load database config
 	pgHost := loadEnv("POSTGRES_HOST", "localhost")
@@ -57,12 +126,509 @@ func LoadConfig() (*Config, error) {
 		return nil, fmt.Errorf("invalid CONN_MAX_IDLETIME: %w", err)
 	}
 
+	// Load read replica config
+	var replicaDSNs []string
+	for _, dsn := range strings.Split(loadEnv("POSTGRES_REPLICA_DSNS", ""), ",") {
+		if dsn = strings.TrimSpace(dsn); dsn != "" {
+			replicaDSNs = append(replicaDSNs, dsn)
+		}
+	}
+	replicaCheckInterval, err := loadTimeDurEnv("POSTGRES_REPLICA_CHECK_INTERVAL", 5*time.Second)
+	if err != nil {
+		return nil, fmt.Errorf("invalid POSTGRES_REPLICA_CHECK_INTERVAL: %w", err)
+	}
+	if replicaCheckInterval <= 0 {
+		return nil, fmt.Errorf("invalid POSTGRES_REPLICA_CHECK_INTERVAL: must be positive")
+	}
+	maxReplicaLag, err := loadTimeDurEnv("POSTGRES_MAX_REPLICA_LAG", 2*time.Second)
+	if err != nil {
+		return nil, fmt.Errorf("invalid POSTGRES_MAX_REPLICA_LAG: %w", err)
+	}
+	if maxReplicaLag <= 0 {
+		return nil, fmt.Errorf("invalid POSTGRES_MAX_REPLICA_LAG: must be positive")
+	}
+	readYourWritesWindow, err := loadTimeDurEnv("POSTGRES_READ_YOUR_WRITES_WINDOW", 5*time.Second)
+	if err != nil {
+		return nil, fmt.Errorf("invalid POSTGRES_READ_YOUR_WRITES_WINDOW: %w", err)
+	}
+	if readYourWritesWindow < 0 {
+		return nil, fmt.Errorf("invalid POSTGRES_READ_YOUR_WRITES_WINDOW: must not be negative")
+	}
+
+	// Load soft delete config
+	softDeleteRetention, err := loadTimeDurEnv("SOFT_DELETE_RETENTION", 30*24*time.Hour)
+	if err != nil {
+		return nil, fmt.Errorf("invalid SOFT_DELETE_RETENTION: %w", err)
+	}
+	if softDeleteRetention <= 0 {
+		return nil, fmt.Errorf("invalid SOFT_DELETE_RETENTION: must be positive")
+	}
+	purgeInterval, err := loadTimeDurEnv("PURGE_INTERVAL", 1*time.Hour)
+	if err != nil {
+		return nil, fmt.Errorf("invalid PURGE_INTERVAL: %w", err)
+	}
+
+	// Load storage resilience config
+	retryAttempts, err := loadIntEnv("STORE_RETRY_ATTEMPTS", 3)
+	if err != nil {
+		return nil, fmt.Errorf("invalid STORE_RETRY_ATTEMPTS: %w", err)
+	}
+	if retryAttempts < 1 {
+		return nil, fmt.Errorf("invalid STORE_RETRY_ATTEMPTS: must be positive")
+	}
+	retryBaseDelay, err := loadTimeDurEnv("STORE_RETRY_BASE_DELAY", 20*time.Millisecond)
+	if err != nil {
+		return nil, fmt.Errorf("invalid STORE_RETRY_BASE_DELAY: %w", err)
+	}
+	if retryBaseDelay <= 0 {
+		return nil, fmt.Errorf("invalid STORE_RETRY_BASE_DELAY: must be positive")
+	}
+	retryMaxDelay, err := loadTimeDurEnv("STORE_RETRY_MAX_DELAY", 200*time.Millisecond)
+	if err != nil {
+		return nil, fmt.Errorf("invalid STORE_RETRY_MAX_DELAY: %w", err)
+	}
+	if retryMaxDelay < retryBaseDelay {
+		return nil, fmt.Errorf("invalid STORE_RETRY_MAX_DELAY: must be at least STORE_RETRY_BASE_DELAY")
+	}
+	breakerThreshold, err := loadIntEnv("BREAKER_FAILURE_THRESHOLD", 5)
+	if err != nil {
+		return nil, fmt.Errorf("invalid BREAKER_FAILURE_THRESHOLD: %w", err)
+	}
+	if breakerThreshold < 1 {
+		return nil, fmt.Errorf("invalid BREAKER_FAILURE_THRESHOLD: must be positive")
+	}
+	breakerOpenTimeout, err := loadTimeDurEnv("BREAKER_OPEN_TIMEOUT", 10*time.Second)
+	if err != nil {
+		return nil, fmt.Errorf("invalid BREAKER_OPEN_TIMEOUT: %w", err)
+	}
+	if breakerOpenTimeout <= 0 {
+		return nil, fmt.Errorf("invalid BREAKER_OPEN_TIMEOUT: must be positive")
+	}
+
+	// Load cache config
+	cacheBackend := loadEnv("CACHE_BACKEND", "none")
+	if cacheBackend != "none" && cacheBackend != "memory" && cacheBackend != "redis" {
+		return nil, fmt.Errorf("invalid CACHE_BACKEND: %s", cacheBackend)
+	}
+	cacheTTL, err := loadTimeDurEnv("CACHE_TTL", 1*time.Minute)
+	if err != nil {
+		return nil, fmt.Errorf("invalid CACHE_TTL: %w", err)
+	}
+	if cacheTTL < time.Millisecond {
+		return nil, fmt.Errorf("invalid CACHE_TTL: must be at least 1ms")
+	}
+	cacheSize, err := loadIntEnv("CACHE_SIZE", 10000)
+	if err != nil {
+		return nil, fmt.Errorf("invalid CACHE_SIZE: %w", err)
+	}
+	if cacheSize < 1 {
+		return nil, fmt.Errorf("invalid CACHE_SIZE: must be positive")
+	}
+	redisAddr := loadEnv("REDIS_ADDR", "localhost:6379")
+	redisPassword := loadEnv("REDIS_PASSWORD", "")
+	redisDB, err := loadIntEnv("REDIS_DB", 0)
+	if err != nil {
+		return nil, fmt.Errorf("invalid REDIS_DB: %w", err)
+	}
+	redisPoolSize, err := loadIntEnv("REDIS_POOL_SIZE", 10)
+	if err != nil {
+		return nil, fmt.Errorf("invalid REDIS_POOL_SIZE: %w", err)
+	}
+	if redisPoolSize < 1 {
+		return nil, fmt.Errorf("invalid REDIS_POOL_SIZE: must be positive")
+	}
+
+	// Load rate limiting config
+	rateLimitEnabled, err := loadBoolEnv("RATE_LIMIT_ENABLED", false)
+	if err != nil {
+		return nil, fmt.Errorf("invalid RATE_LIMIT_ENABLED: %w", err)
+	}
+	rateLimitBackend := loadEnv("RATE_LIMIT_BACKEND", "memory")
+	if rateLimitBackend != "memory" && rateLimitBackend != "postgres" {
+		return nil, fmt.Errorf("invalid RATE_LIMIT_BACKEND: %s", rateLimitBackend)
+	}
+	rateLimitDefault, err := ratelimit.ParseLimit(loadEnv("RATE_LIMIT_DEFAULT", "10:20"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid RATE_LIMIT_DEFAULT: %w", err)
+	}
+	rateLimitRules, err := ratelimit.ParseRules(loadEnv("RATE_LIMIT_RULES", ""))
+	if err != nil {
+		return nil, fmt.Errorf("invalid RATE_LIMIT_RULES: %w", err)
+	}
+
+	// Load concurrency limiting config
+	concurrencyEnabled, err := loadBoolEnv("CONCURRENCY_LIMIT_ENABLED", false)
+	if err != nil {
+		return nil, fmt.Errorf("invalid CONCURRENCY_LIMIT_ENABLED: %w", err)
+	}
+	concurrencyInitial, err := loadIntEnv("CONCURRENCY_INITIAL_LIMIT", 20)
+	if err != nil {
+		return nil, fmt.Errorf("invalid CONCURRENCY_INITIAL_LIMIT: %w", err)
+	}
+	concurrencyMin, err := loadIntEnv("CONCURRENCY_MIN_LIMIT", 5)
+	if err != nil {
+		return nil, fmt.Errorf("invalid CONCURRENCY_MIN_LIMIT: %w", err)
+	}
+	concurrencyMax, err := loadIntEnv("CONCURRENCY_MAX_LIMIT", 200)
+	if err != nil {
+		return nil, fmt.Errorf("invalid CONCURRENCY_MAX_LIMIT: %w", err)
+	}
+	if concurrencyMin < 1 || concurrencyMin > concurrencyInitial || concurrencyInitial > concurrencyMax {
+		return nil, fmt.Errorf("invalid concurrency limits: min %d, initial %d, max %d", concurrencyMin, concurrencyInitial, concurrencyMax)
+	}
+	concurrencyLatency, err := loadTimeDurEnv("CONCURRENCY_LATENCY_THRESHOLD", 250*time.Millisecond)
+	if err != nil {
+		return nil, fmt.Errorf("invalid CONCURRENCY_LATENCY_THRESHOLD: %w", err)
+	}
+	if concurrencyLatency <= 0 {
+		return nil, fmt.Errorf("invalid CONCURRENCY_LATENCY_THRESHOLD: must be positive")
+	}
+	concurrencyLongRunning := loadListEnv("CONCURRENCY_LONG_RUNNING_ROUTES", middleware.DefaultLongRunningRoutes)
+	concurrencyExempt := loadListEnv("CONCURRENCY_EXEMPT_ROUTES", middleware.DefaultExemptRoutes)
+
+	// Load response compression config
+	compressionEnabled, err := loadBoolEnv("COMPRESSION_ENABLED", true)
+	if err != nil {
+		return nil, fmt.Errorf("invalid COMPRESSION_ENABLED: %w", err)
+	}
+	compressionMinSize, err := loadIntEnv("COMPRESSION_MIN_SIZE", 1024)
+	if err != nil {
+		return nil, fmt.Errorf("invalid COMPRESSION_MIN_SIZE: %w", err)
+	}
+	if compressionMinSize < 0 {
+		return nil, fmt.Errorf("invalid COMPRESSION_MIN_SIZE: must not be negative")
+	}
+	compressionTypes := loadListEnv("COMPRESSION_CONTENT_TYPES", middleware.DefaultCompressionContentTypes)
+	maxDecompressedSize, err := loadIntEnv("MAX_DECOMPRESSED_REQUEST_SIZE", 32<<20)
+	if err != nil {
+		return nil, fmt.Errorf("invalid MAX_DECOMPRESSED_REQUEST_SIZE: %w", err)
+	}
+	if maxDecompressedSize < 1 {
+		return nil, fmt.Errorf("invalid MAX_DECOMPRESSED_REQUEST_SIZE: must be positive")
+	}
+
+	// Load event publishing config
+	eventsPublisher := loadEnv("EVENTS_PUBLISHER", "inprocess")
+	switch eventsPublisher {
+	case "none", "inprocess", "stdout", "file", "nats", "kafka":
+	default:
+		return nil, fmt.Errorf("invalid EVENTS_PUBLISHER: %s", eventsPublisher)
+	}
+	outboxPollInterval, err := loadTimeDurEnv("OUTBOX_POLL_INTERVAL", 1*time.Second)
+	if err != nil {
+		return nil, fmt.Errorf("invalid OUTBOX_POLL_INTERVAL: %w", err)
+	}
+	if outboxPollInterval <= 0 {
+		return nil, fmt.Errorf("invalid OUTBOX_POLL_INTERVAL: must be positive")
+	}
+	outboxBatchSize, err := loadIntEnv("OUTBOX_BATCH_SIZE", 100)
+	if err != nil {
+		return nil, fmt.Errorf("invalid OUTBOX_BATCH_SIZE: %w", err)
+	}
+	if outboxBatchSize < 1 {
+		return nil, fmt.Errorf("invalid OUTBOX_BATCH_SIZE: must be positive")
+	}
+	outboxRetention, err := loadTimeDurEnv("OUTBOX_RETENTION", 24*time.Hour)
+	if err != nil {
+		return nil, fmt.Errorf("invalid OUTBOX_RETENTION: %w", err)
+	}
+	if outboxRetention <= 0 {
+		return nil, fmt.Errorf("invalid OUTBOX_RETENTION: must be positive")
+	}
+	kafkaTimeout, err := loadTimeDurEnv("KAFKA_TIMEOUT", 5*time.Second)
+	if err != nil {
+		return nil, fmt.Errorf("invalid KAFKA_TIMEOUT: %w", err)
+	}
+	if kafkaTimeout <= 0 {
+		return nil, fmt.Errorf("invalid KAFKA_TIMEOUT: must be positive")
+	}
+
+	// Load event stream config
+	streamSource := loadEnv("SSE_SOURCE", "notify")
+	if streamSource != "notify" && streamSource != "inprocess" {
+		return nil, fmt.Errorf("invalid SSE_SOURCE: %s", streamSource)
+	}
+	streamMaxConnections, err := loadIntEnv("SSE_MAX_CONNECTIONS", 100)
+	if err != nil {
+		return nil, fmt.Errorf("invalid SSE_MAX_CONNECTIONS: %w", err)
+	}
+	if streamMaxConnections < 1 {
+		return nil, fmt.Errorf("invalid SSE_MAX_CONNECTIONS: must be positive")
+	}
+	streamBuffer, err := loadIntEnv("SSE_BUFFER", 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid SSE_BUFFER: %w", err)
+	}
+	if streamBuffer < 1 {
+		return nil, fmt.Errorf("invalid SSE_BUFFER: must be positive")
+	}
+	streamHeartbeat, err := loadTimeDurEnv("SSE_HEARTBEAT", 15*time.Second)
+	if err != nil {
+		return nil, fmt.Errorf("invalid SSE_HEARTBEAT: %w", err)
+	}
+	if streamHeartbeat <= 0 {
+		return nil, fmt.Errorf("invalid SSE_HEARTBEAT: must be positive")
+	}
+	streamReplayLimit, err := loadIntEnv("SSE_REPLAY_LIMIT", 1000)
+	if err != nil {
+		return nil, fmt.Errorf("invalid SSE_REPLAY_LIMIT: %w", err)
+	}
+	if streamReplayLimit < 0 {
+		return nil, fmt.Errorf("invalid SSE_REPLAY_LIMIT: must not be negative")
+	}
+	streamWriteTimeout, err := loadTimeDurEnv("SSE_WRITE_TIMEOUT", 10*time.Second)
+	if err != nil {
+		return nil, fmt.Errorf("invalid SSE_WRITE_TIMEOUT: %w", err)
+	}
+	if streamWriteTimeout <= 0 {
+		return nil, fmt.Errorf("invalid SSE_WRITE_TIMEOUT: must be positive")
+	}
+
+	// Load webhook delivery config
+	webhooksEnabled, err := loadBoolEnv("WEBHOOKS_ENABLED", true)
+	if err != nil {
+		return nil, fmt.Errorf("invalid WEBHOOKS_ENABLED: %w", err)
+	}
+	// In-process subscribers are fed by the relay, which publishes nothing without a publisher
+	if eventsPublisher == "none" && webhooksEnabled {
+		return nil, fmt.Errorf("invalid WEBHOOKS_ENABLED: webhooks get no events with EVENTS_PUBLISHER=none")
+	}
+	if eventsPublisher == "none" && streamSource == "inprocess" {
+		return nil, fmt.Errorf("invalid SSE_SOURCE: the in-process stream gets no events with EVENTS_PUBLISHER=none")
+	}
+	webhookPollInterval, err := loadTimeDurEnv("WEBHOOK_POLL_INTERVAL", 1*time.Second)
+	if err != nil {
+		return nil, fmt.Errorf("invalid WEBHOOK_POLL_INTERVAL: %w", err)
+	}
+	if webhookPollInterval <= 0 {
+		return nil, fmt.Errorf("invalid WEBHOOK_POLL_INTERVAL: must be positive")
+	}
+	webhookTimeout, err := loadTimeDurEnv("WEBHOOK_TIMEOUT", 10*time.Second)
+	if err != nil {
+		return nil, fmt.Errorf("invalid WEBHOOK_TIMEOUT: %w", err)
+	}
+	if webhookTimeout <= 0 {
+		return nil, fmt.Errorf("invalid WEBHOOK_TIMEOUT: must be positive")
+	}
+	webhookMaxAttempts, err := loadIntEnv("WEBHOOK_MAX_ATTEMPTS", 8)
+	if err != nil {
+		return nil, fmt.Errorf("invalid WEBHOOK_MAX_ATTEMPTS: %w", err)
+	}
+	if webhookMaxAttempts < 1 {
+		return nil, fmt.Errorf("invalid WEBHOOK_MAX_ATTEMPTS: must be positive")
+	}
+	webhookBaseDelay, err := loadTimeDurEnv("WEBHOOK_RETRY_BASE_DELAY", 30*time.Second)
+	if err != nil {
+		return nil, fmt.Errorf("invalid WEBHOOK_RETRY_BASE_DELAY: %w", err)
+	}
+	if webhookBaseDelay <= 0 {
+		return nil, fmt.Errorf("invalid WEBHOOK_RETRY_BASE_DELAY: must be positive")
+	}
+	webhookMaxDelay, err := loadTimeDurEnv("WEBHOOK_RETRY_MAX_DELAY", 1*time.Hour)
+	if err != nil {
+		return nil, fmt.Errorf("invalid WEBHOOK_RETRY_MAX_DELAY: %w", err)
+	}
+	if webhookMaxDelay < webhookBaseDelay {
+		return nil, fmt.Errorf("invalid WEBHOOK_RETRY_MAX_DELAY: must be at least WEBHOOK_RETRY_BASE_DELAY")
+	}
+	webhooksAllowPrivateNetworks, err := loadBoolEnv("WEBHOOKS_ALLOW_PRIVATE_NETWORKS", false)
+	if err != nil {
+		return nil, fmt.Errorf("invalid WEBHOOKS_ALLOW_PRIVATE_NETWORKS: %w", err)
+	}
+
+	// Load batch endpoints config
+	batchMaxItems, err := loadIntEnv("BATCH_MAX_ITEMS", handler.DefaultBatchConfig.MaxItems)
+	if err != nil {
+		return nil, fmt.Errorf("invalid BATCH_MAX_ITEMS: %w", err)
+	}
+	if batchMaxItems < 1 {
+		return nil, fmt.Errorf("invalid BATCH_MAX_ITEMS: must be positive")
+	}
+	batchTimeout, err := loadTimeDurEnv("BATCH_TIMEOUT", handler.DefaultBatchConfig.Timeout)
+	if err != nil {
+		return nil, fmt.Errorf("invalid BATCH_TIMEOUT: %w", err)
+	}
+	if batchTimeout <= 0 {
+		return nil, fmt.Errorf("invalid BATCH_TIMEOUT: must be positive")
+	}
+
+	// Load the request body limits
+	maxBodySize, err := loadIntEnv("MAX_REQUEST_BODY_SIZE", int(handler.DefaultBodyLimits.Default))
+	if err != nil {
+		return nil, fmt.Errorf("invalid MAX_REQUEST_BODY_SIZE: %w", err)
+	}
+	if maxBodySize < 1 {
+		return nil, fmt.Errorf("invalid MAX_REQUEST_BODY_SIZE: must be positive")
+	}
+	maxBatchBodySize, err := loadIntEnv("MAX_BATCH_REQUEST_BODY_SIZE", int(handler.DefaultBodyLimits.Batch))
+	if err != nil {
+		return nil, fmt.Errorf("invalid MAX_BATCH_REQUEST_BODY_SIZE: %w", err)
+	}
+	if maxBatchBodySize < 1 {
+		return nil, fmt.Errorf("invalid MAX_BATCH_REQUEST_BODY_SIZE: must be positive")
+	}
+
+	// Load the Cache-Control policies of the user reads
+	httpCache := handler.CacheConfig{
+		User:  loadEnv("HTTP_CACHE_USER", handler.DefaultCacheConfig.User),
+		Users: loadEnv("HTTP_CACHE_USERS", handler.DefaultCacheConfig.Users),
+	}
+
+	// Load the deprecation schedule of the API versions
+	v1Deprecation, err := loadTimeEnv("API_V1_DEPRECATION")
+	if err != nil {
+		return nil, fmt.Errorf("invalid API_V1_DEPRECATION: %w", err)
+	}
+	v1Sunset, err := loadTimeEnv("API_V1_SUNSET")
+	if err != nil {
+		return nil, fmt.Errorf("invalid API_V1_SUNSET: %w", err)
+	}
+	if !v1Sunset.IsZero() && v1Sunset.Before(v1Deprecation) {
+		return nil, fmt.Errorf("invalid API_V1_SUNSET: must not be before API_V1_DEPRECATION")
+	}
+
+	// Load import config
+	importBatchSize, err := loadIntEnv("IMPORT_BATCH_SIZE", 500)
+	if err != nil {
+		return nil, fmt.Errorf("invalid IMPORT_BATCH_SIZE: %w", err)
+	}
+	if importBatchSize < 1 {
+		return nil, fmt.Errorf("invalid IMPORT_BATCH_SIZE: must be positive")
+	}
+	importMaxErrors, err := loadIntEnv("IMPORT_MAX_ERRORS", 1000)
+	if err != nil {
+		return nil, fmt.Errorf("invalid IMPORT_MAX_ERRORS: %w", err)
+	}
+	if importMaxErrors < 1 {
+		return nil, fmt.Errorf("invalid IMPORT_MAX_ERRORS: must be positive")
+	}
+	importTimeout, err := loadTimeDurEnv("IMPORT_TIMEOUT", 10*time.Minute)
+	if err != nil {
+		return nil, fmt.Errorf("invalid IMPORT_TIMEOUT: %w", err)
+	}
+	if importTimeout <= 0 {
+		return nil, fmt.Errorf("invalid IMPORT_TIMEOUT: must be positive")
+	}
+	importMaxUploadSize, err := loadIntEnv("IMPORT_MAX_UPLOAD_SIZE", 100<<20)
+	if err != nil {
+		return nil, fmt.Errorf("invalid IMPORT_MAX_UPLOAD_SIZE: %w", err)
+	}
+	if importMaxUploadSize < 1 {
+		return nil, fmt.Errorf("invalid IMPORT_MAX_UPLOAD_SIZE: must be positive")
+	}
+
+	// Load export config
+	exportTimeout, err := loadTimeDurEnv("EXPORT_TIMEOUT", 30*time.Minute)
+	if err != nil {
+		return nil, fmt.Errorf("invalid EXPORT_TIMEOUT: %w", err)
+	}
+	if exportTimeout <= 0 {
+		return nil, fmt.Errorf("invalid EXPORT_TIMEOUT: must be positive")
+	}
+
+	// Load jobs config
+	jobsWorkers, err := loadIntEnv("JOBS_WORKERS", 2)
+	if err != nil {
+		return nil, fmt.Errorf("invalid JOBS_WORKERS: %w", err)
+	}
+	if jobsWorkers < 0 {
+		return nil, fmt.Errorf("invalid JOBS_WORKERS: must not be negative")
+	}
+	jobsPollInterval, err := loadTimeDurEnv("JOBS_POLL_INTERVAL", 1*time.Second)
+	if err != nil {
+		return nil, fmt.Errorf("invalid JOBS_POLL_INTERVAL: %w", err)
+	}
+	if jobsPollInterval <= 0 {
+		return nil, fmt.Errorf("invalid JOBS_POLL_INTERVAL: must be positive")
+	}
+	jobsLease, err := loadTimeDurEnv("JOBS_LEASE", 30*time.Second)
+	if err != nil {
+		return nil, fmt.Errorf("invalid JOBS_LEASE: %w", err)
+	}
+	if jobsLease <= 0 {
+		return nil, fmt.Errorf("invalid JOBS_LEASE: must be positive")
+	}
+	jobsMaxAttempts, err := loadIntEnv("JOBS_MAX_ATTEMPTS", 3)
+	if err != nil {
+		return nil, fmt.Errorf("invalid JOBS_MAX_ATTEMPTS: %w", err)
+	}
+	if jobsMaxAttempts < 1 {
+		return nil, fmt.Errorf("invalid JOBS_MAX_ATTEMPTS: must be positive")
+	}
+	jobsRetention, err := loadTimeDurEnv("JOBS_RETENTION", 7*24*time.Hour)
+	if err != nil {
+		return nil, fmt.Errorf("invalid JOBS_RETENTION: %w", err)
+	}
+	if jobsRetention <= 0 {
+		return nil, fmt.Errorf("invalid JOBS_RETENTION: must be positive")
+	}
+	jobsDownloadTimeout, err := loadTimeDurEnv("JOBS_DOWNLOAD_TIMEOUT", 30*time.Minute)
+	if err != nil {
+		return nil, fmt.Errorf("invalid JOBS_DOWNLOAD_TIMEOUT: %w", err)
+	}
+	if jobsDownloadTimeout <= 0 {
+		return nil, fmt.Errorf("invalid JOBS_DOWNLOAD_TIMEOUT: must be positive")
+	}
+
+	// Load GraphQL config
+	graphqlMaxDepth, err := loadIntEnv("GRAPHQL_MAX_DEPTH", 10)
+	if err != nil {
+		return nil, fmt.Errorf("invalid GRAPHQL_MAX_DEPTH: %w", err)
+	}
+	if graphqlMaxDepth < 1 {
+		return nil, fmt.Errorf("invalid GRAPHQL_MAX_DEPTH: must be positive")
+	}
+	graphqlMaxComplexity, err := loadIntEnv("GRAPHQL_MAX_COMPLEXITY", 2000)
+	if err != nil {
+		return nil, fmt.Errorf("invalid GRAPHQL_MAX_COMPLEXITY: %w", err)
+	}
+	if graphqlMaxComplexity < 1 {
+		return nil, fmt.Errorf("invalid GRAPHQL_MAX_COMPLEXITY: must be positive")
+	}
+	graphqlTimeout, err := loadTimeDurEnv("GRAPHQL_TIMEOUT", 5*time.Second)
+	if err != nil {
+		return nil, fmt.Errorf("invalid GRAPHQL_TIMEOUT: %w", err)
+	}
+	if graphqlTimeout <= 0 {
+		return nil, fmt.Errorf("invalid GRAPHQL_TIMEOUT: must be positive")
+	}
+	graphqlPersistedQueries, err := loadIntEnv("GRAPHQL_PERSISTED_QUERIES", 1000)
+	if err != nil {
+		return nil, fmt.Errorf("invalid GRAPHQL_PERSISTED_QUERIES: %w", err)
+	}
+	if graphqlPersistedQueries < 1 {
+		return nil, fmt.Errorf("invalid GRAPHQL_PERSISTED_QUERIES: must be positive")
+	}
+	graphqlPersistedQueryTTL, err := loadTimeDurEnv("GRAPHQL_PERSISTED_QUERY_TTL", 24*time.Hour)
+	if err != nil {
+		return nil, fmt.Errorf("invalid GRAPHQL_PERSISTED_QUERY_TTL: %w", err)
+	}
+	if graphqlPersistedQueryTTL <= 0 {
+		return nil, fmt.Errorf("invalid GRAPHQL_PERSISTED_QUERY_TTL: must be positive")
+	}
+
+	// Load SCIM config
+	scimMaxResults, err := loadIntEnv("SCIM_MAX_RESULTS", 100)
+	if err != nil {
+		return nil, fmt.Errorf("invalid SCIM_MAX_RESULTS: %w", err)
+	}
+	if scimMaxResults < 1 || scimMaxResults > 100 {
+		return nil, fmt.Errorf("invalid SCIM_MAX_RESULTS: must be between 1 and 100")
+	}
+
 	// Load environment mode
 	isProd := loadEnv("ENV", "development") == "production"
 
 	return &Config{
 		Server: ServerConfig{
-			Port: port,
+			Port:                port,
+			CrashReportDir:      crashReportDir,
+			CrashReportsPerHour: crashReportsPerHour,
+			TrustedProxies:      trustedProxies,
+		},
+		GRPC: grpcserver.Config{
+			Port:        grpcPort,
+			ListTimeout: grpcListTimeout,
 		},
 		Postgres: storage.PostgresConfig{
 			Host:            pgHost,
@@ -75,6 +641,133 @@ func LoadConfig() (*Config, error) {
 			MaxIdleConns:    maxIdleConns,
 			ConnMaxLifetime: connMaxLifetime,
 			ConnMaxIdleTime: connMaxIdletime,
+
+			ReplicaDSNs:          replicaDSNs,
+			ReplicaCheckInterval: replicaCheckInterval,
+			MaxReplicaLag:        maxReplicaLag,
+			ReadYourWritesWindow: readYourWritesWindow,
+
+			SoftDeleteRetention: softDeleteRetention,
+			PurgeInterval:       purgeInterval,
+		},
+		Resilience: storage.ResilienceConfig{
+			RetryAttempts:    retryAttempts,
+			RetryBaseDelay:   retryBaseDelay,
+			RetryMaxDelay:    retryMaxDelay,
+			FailureThreshold: breakerThreshold,
+			OpenTimeout:      breakerOpenTimeout,
+		},
+		Cache: cache.Config{
+			Backend: cacheBackend,
+			TTL:     cacheTTL,
+			Size:    cacheSize,
+			Redis: cache.RedisConfig{
+				Addr:        redisAddr,
+				Password:    redisPassword,
+				DB:          redisDB,
+				PoolSize:    redisPoolSize,
+				DialTimeout: 1 * time.Second,
+			},
+		},
+		RateLimit: ratelimit.Config{
+			Enabled: rateLimitEnabled,
+			Backend: rateLimitBackend,
+			Default: rateLimitDefault,
+			Rules:   rateLimitRules,
+		},
+		Concurrency: middleware.ConcurrencyConfig{
+			Enabled:           concurrencyEnabled,
+			InitialLimit:      concurrencyInitial,
+			MinLimit:          concurrencyMin,
+			MaxLimit:          concurrencyMax,
+			LatencyThreshold:  concurrencyLatency,
+			BackoffRatio:      0.9,
+			LongRunningRoutes: concurrencyLongRunning,
+			ExemptRoutes:      concurrencyExempt,
+		},
+		Compression: middleware.CompressionConfig{
+			Enabled:             compressionEnabled,
+			MinSize:             compressionMinSize,
+			ContentTypes:        compressionTypes,
+			MaxDecompressedSize: int64(maxDecompressedSize),
+		},
+		Events: events.Config{
+			Publisher: eventsPublisher,
+			FilePath:  loadEnv("EVENTS_FILE", "events.ndjson"),
+			NATS: events.NATSConfig{
+				Addr:          loadEnv("NATS_ADDR", "localhost:4222"),
+				Token:         loadEnv("NATS_TOKEN", ""),
+				SubjectPrefix: loadEnv("NATS_SUBJECT_PREFIX", "events"),
+				DialTimeout:   1 * time.Second,
+			},
+			Kafka: events.KafkaConfig{
+				URL:     loadEnv("KAFKA_PROXY_URL", "http://localhost:8082"),
+				Topic:   loadEnv("KAFKA_TOPIC", "users"),
+				Timeout: kafkaTimeout,
+			},
+			PollInterval: outboxPollInterval,
+			BatchSize:    outboxBatchSize,
+			Retention:    outboxRetention,
+		},
+		EventStream: events.StreamConfig{
+			Source:         streamSource,
+			MaxConnections: streamMaxConnections,
+			Buffer:         streamBuffer,
+			Heartbeat:      streamHeartbeat,
+			ReplayLimit:    streamReplayLimit,
+			WriteTimeout:   streamWriteTimeout,
+		},
+		Webhooks: webhook.Config{
+			Enabled:              webhooksEnabled,
+			PollInterval:         webhookPollInterval,
+			BatchSize:            20,
+			Timeout:              webhookTimeout,
+			MaxAttempts:          webhookMaxAttempts,
+			BaseDelay:            webhookBaseDelay,
+			MaxDelay:             webhookMaxDelay,
+			AllowPrivateNetworks: webhooksAllowPrivateNetworks,
+		},
+		Batch: handler.BatchConfig{
+			MaxItems: batchMaxItems,
+			Timeout:  batchTimeout,
+		},
+		BodyLimits: handler.BodyLimits{
+			Default: int64(maxBodySize),
+			Batch:   int64(maxBatchBodySize),
+		},
+		Versions: handler.VersionConfig{
+			V1Deprecation: v1Deprecation,
+			V1Sunset:      v1Sunset,
+		},
+		HTTPCache: httpCache,
+		Import: importer.Config{
+			BatchSize:     importBatchSize,
+			MaxErrors:     importMaxErrors,
+			Timeout:       importTimeout,
+			MaxUploadSize: int64(importMaxUploadSize),
+		},
+		Export: export.Config{
+			Timeout: exportTimeout,
+		},
+		Jobs: jobs.Config{
+			Workers:         jobsWorkers,
+			PollInterval:    jobsPollInterval,
+			Lease:           jobsLease,
+			MaxAttempts:     jobsMaxAttempts,
+			Retention:       jobsRetention,
+			Dir:             loadEnv("JOBS_DIR", filepath.Join(os.TempDir(), "restful-api-jobs")),
+			DownloadTimeout: jobsDownloadTimeout,
+		},
+		GraphQL: gql.Config{
+			MaxDepth:          graphqlMaxDepth,
+			MaxComplexity:     graphqlMaxComplexity,
+			Timeout:           graphqlTimeout,
+			PersistedQueries:  graphqlPersistedQueries,
+			PersistedQueryTTL: graphqlPersistedQueryTTL,
+		},
+		SCIM: scim.Config{
+			Token:      loadEnv("SCIM_TOKEN", ""),
+			MaxResults: scimMaxResults,
 		},
 		IsProd: isProd,
 	}, nil
@@ -89,6 +782,34 @@ func loadEnv(key, defaultValue string) string {
 	return val
 }
 
+// Helper to parse a network in CIDR notation, or a single address
+func parsePrefix(s string) (netip.Prefix, error) {
+	if strings.Contains(s, "/") {
+		return netip.ParsePrefix(s)
+	}
+	addr, err := netip.ParseAddr(s)
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+	return netip.PrefixFrom(addr, addr.BitLen()), nil
+}
+
+// Helper to load comma separated list environment variables with defaults
+func loadListEnv(key string, defaultValue []string) []string {
+	valStr := loadEnv(key, "")
+	if valStr == "" {
+		return defaultValue
+	}
+
+	var values []string
+	for _, val := range strings.Split(valStr, ",") {
+		if val = strings.TrimSpace(val); val != "" {
+			values = append(values, val)
+		}
+	}
+	return values
+}
+
 // Helper to load integer environment variables with defaults
 func loadIntEnv(key string, defaultValue int) (int, error) {
 	valStr := loadEnv(key, "")
@@ -102,6 +823,15 @@ func loadIntEnv(key string, defaultValue int) (int, error) {
 	return val, nil
 }
 
+// Helper to load boolean environment variables with defaults
+func loadBoolEnv(key string, defaultValue bool) (bool, error) {
+	valStr := loadEnv(key, "")
+	if valStr == "" {
+		return defaultValue, nil
+	}
+	return strconv.ParseBool(valStr)
+}
+
 func loadTimeDurEnv(key string, defaultValue time.Duration) (time.Duration, error) {
 	valStr := loadEnv(key, "")
 	if valStr == "" {
@@ -113,3 +843,12 @@ func loadTimeDurEnv(key string, defaultValue time.Duration) (time.Duration, erro
 	}
 	return val, nil
 }
+
+// Helper to load RFC 3339 time environment variables, zero if they are not set
+func loadTimeEnv(key string) (time.Time, error) {
+	valStr := loadEnv(key, "")
+	if valStr == "" {
+		return time.Time{}, nil
+	}
+	return time.Parse(time.RFC3339, valStr)
+}
@@ -0,0 +1,41 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadConfig_Defaults(t *testing.T) {
+	cfg, err := LoadConfig()
+	require.NoError(t, err)
+	assert.Positive(t, cfg.Postgres.SoftDeleteRetention)
+}
+
+func TestLoadConfig_Invalid(t *testing.T) {
+	tests := []struct {
+		name        string
+		key         string
+		value       string
+		expectedErr string
+	}{
+		{"negative crash reports per hour", "CRASH_REPORTS_PER_HOUR", "-1", "invalid CRASH_REPORTS_PER_HOUR: must not be negative"},
+		{"zero max replica lag", "POSTGRES_MAX_REPLICA_LAG", "0s", "invalid POSTGRES_MAX_REPLICA_LAG: must be positive"},
+		{"negative read your writes window", "POSTGRES_READ_YOUR_WRITES_WINDOW", "-1s", "invalid POSTGRES_READ_YOUR_WRITES_WINDOW: must not be negative"},
+		{"zero soft delete retention", "SOFT_DELETE_RETENTION", "0s", "invalid SOFT_DELETE_RETENTION: must be positive"},
+		{"negative soft delete retention", "SOFT_DELETE_RETENTION", "-1h", "invalid SOFT_DELETE_RETENTION: must be positive"},
+		{"zero concurrency latency threshold", "CONCURRENCY_LATENCY_THRESHOLD", "0s", "invalid CONCURRENCY_LATENCY_THRESHOLD: must be positive"},
+		{"zero outbox retention", "OUTBOX_RETENTION", "0s", "invalid OUTBOX_RETENTION: must be positive"},
+		{"zero kafka timeout", "KAFKA_TIMEOUT", "0s", "invalid KAFKA_TIMEOUT: must be positive"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv(tt.key, tt.value)
+
+			_, err := LoadConfig()
+			assert.EqualError(t, err, tt.expectedErr)
+		})
+	}
+}
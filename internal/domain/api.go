@@ -1,44 +1,59 @@
 package domain
 
 import (
+	"encoding/xml"
 	"github.com/go-playground/validator/v10"
 	"time"
 )
 
 // UserCreate represents the data needed to create a new user
 type UserCreate struct {
-	Email     string `json:"email" validate:"required,email"`
-	FirstName string `json:"first_name" validate:"required,alpha"`
-	LastName  string `json:"last_name" validate:"required,alpha"`
+	Email     string `json:"email" xml:"email" validate:"required,email"`
+	FirstName string `json:"first_name" xml:"first_name" validate:"required,alpha"`
+	LastName  string `json:"last_name" xml:"last_name" validate:"required,alpha"`
 }
 
 func (u UserCreate) Validate() error {
 	return validator.New().Struct(u)
 }
 
+// ToCreate returns the user to create, version 1 of the API takes it as is
+func (u UserCreate) ToCreate() UserCreate {
+	return u
+}
+
 type UserCreateResponse struct {
-	ID int64 `json:"id"`
+	XMLName xml.Name `json:"-" xml:"user"`
+	ID      int64    `json:"id" xml:"id"`
 }
 
 // UserUpdate represents the data that can be updated for a user
 type UserUpdate struct {
-	Email     string `json:"email,omitempty" validate:"omitempty,email"`
-	FirstName string `json:"first_name,omitempty" validate:"omitempty,alpha,min=2"`
-	LastName  string `json:"last_name,omitempty" validate:"omitempty,alpha,min=2"`
+	Email     string `json:"email,omitempty" xml:"email,omitempty" validate:"omitempty,email"`
+	FirstName string `json:"first_name,omitempty" xml:"first_name,omitempty" validate:"omitempty,alpha,min=2"`
+	LastName  string `json:"last_name,omitempty" xml:"last_name,omitempty" validate:"omitempty,alpha,min=2"`
 }
 
 func (u UserUpdate) Validate() error {
 	return validator.New().Struct(u)
 }
 
+// ToUpdate returns the update of a user, version 1 of the API takes it as is
+func (u UserUpdate) ToUpdate() UserUpdate {
+	return u
+}
+
 // UserResponse represents the data sent back to the client
 type UserResponse struct {
-	ID        int64     `json:"id"`
-	Email     string    `json:"email"`
-	FirstName string    `json:"first_name"`
-	LastName  string    `json:"last_name"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	XMLName   xml.Name   `json:"-" xml:"user"`
+	ID        int64      `json:"id" xml:"id"`
+	Email     string     `json:"email" xml:"email"`
+	FirstName string     `json:"first_name" xml:"first_name"`
+	LastName  string     `json:"last_name" xml:"last_name"`
+	CreatedAt time.Time  `json:"created_at" xml:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at" xml:"updated_at"`
+	DeletedAt *time.Time `json:"deleted_at,omitempty" xml:"deleted_at,omitempty"`
+	Links     *UserLinks `json:"_links,omitempty" xml:"links,omitempty"`
 }
 
 // ToResponse converts a User to a UserResponse
@@ -51,15 +66,58 @@ func (u *User) ToResponse() UserResponse {
 		LastName:  u.LastName,
 		CreatedAt: u.CreatedAt,
 		UpdatedAt: u.UpdatedAt,
+		DeletedAt: u.DeletedAt,
 	}
 }
 
 // PaginatedUsersResponse represents the paginated list of users
 // Includes metadata for client-side pagination handling
 type PaginatedUsersResponse struct {
-	Users      []UserResponse `json:"users"`
-	TotalCount int            `json:"total_count"`
-	Page       int            `json:"page"`
-	PageSize   int            `json:"page_size"`
-	TotalPages int            `json:"total_pages"`
+	XMLName    xml.Name       `json:"-" xml:"users"`
+	Users      []UserResponse `json:"users" xml:"user"`
+	TotalCount int            `json:"total_count" xml:"total_count"`
+	Page       int            `json:"page" xml:"page"`
+	PageSize   int            `json:"page_size" xml:"page_size"`
+	TotalPages int            `json:"total_pages" xml:"total_pages"`
+	Links      *PageLinks     `json:"_links,omitempty" xml:"links,omitempty"`
+}
+
+// Link is a link to a resource of the API
+type Link struct {
+	Href string `json:"href" xml:"href,attr"`
+}
+
+// UserLinks are the links of a user
+type UserLinks struct {
+	Self Link `json:"self" xml:"self"`
 }
+
+// PageLinks are the links of a page of a list, to itself and to the other pages
+// There is no previous page on the first page, nor a next page on the last one
+type PageLinks struct {
+	Self  Link  `json:"self" xml:"self"`
+	First Link  `json:"first" xml:"first"`
+	Prev  *Link `json:"prev,omitempty" xml:"prev,omitempty"`
+	Next  *Link `json:"next,omitempty" xml:"next,omitempty"`
+	Last  Link  `json:"last" xml:"last"`
+}
+
+// Problem represents an RFC 7807 problem detail sent back to the client
+// Used whenever a failure needs a machine-readable description
+type Problem struct {
+	XMLName  xml.Name `json:"-" xml:"problem"`
+	Type     string   `json:"type" xml:"type"`
+	Title    string   `json:"title" xml:"title"`
+	Status   int      `json:"status" xml:"status"`
+	Detail   string   `json:"detail,omitempty" xml:"detail,omitempty"`
+	Instance string   `json:"instance,omitempty" xml:"instance,omitempty"`
+}
+
+// ErrorResponse represents an error sent back to the client
+type ErrorResponse struct {
+	XMLName xml.Name `json:"-" xml:"error"`
+	Error   string   `json:"error" xml:"message"`
+}
+
+// ProblemContentType is the media type of a Problem response
+const ProblemContentType = "application/problem+json"
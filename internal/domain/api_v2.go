@@ -0,0 +1,120 @@
+package domain
+
+import (
+	"encoding/xml"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+)
+
+// Version 2 of the API names users by their given and family names, and tells whether they are deleted by their status
+
+// UserStatus tells whether a user is live or soft deleted
+type UserStatus string
+
+const (
+	UserStatusActive  UserStatus = "active"
+	UserStatusDeleted UserStatus = "deleted"
+)
+
+// UserCreateV2 represents the data needed to create a new user in version 2 of the API
+type UserCreateV2 struct {
+	Email      string `json:"email" xml:"email" validate:"required,email"`
+	GivenName  string `json:"given_name" xml:"given_name" validate:"required,alpha"`
+	FamilyName string `json:"family_name" xml:"family_name" validate:"required,alpha"`
+}
+
+func (u UserCreateV2) Validate() error {
+	return validator.New().Struct(u)
+}
+
+// ToCreate converts a UserCreateV2 to the user to create
+func (u UserCreateV2) ToCreate() UserCreate {
+	return UserCreate{Email: u.Email, FirstName: u.GivenName, LastName: u.FamilyName}
+}
+
+// UserUpdateV2 represents the data that can be updated for a user in version 2 of the API
+type UserUpdateV2 struct {
+	Email      string `json:"email,omitempty" xml:"email,omitempty" validate:"omitempty,email"`
+	GivenName  string `json:"given_name,omitempty" xml:"given_name,omitempty" validate:"omitempty,alpha,min=2"`
+	FamilyName string `json:"family_name,omitempty" xml:"family_name,omitempty" validate:"omitempty,alpha,min=2"`
+}
+
+func (u UserUpdateV2) Validate() error {
+	return validator.New().Struct(u)
+}
+
+// ToUpdate converts a UserUpdateV2 to the update of a user
+func (u UserUpdateV2) ToUpdate() UserUpdate {
+	return UserUpdate{Email: u.Email, FirstName: u.GivenName, LastName: u.FamilyName}
+}
+
+// UserBatchUpdateV2 represents the update of one user within a batch in version 2 of the API
+type UserBatchUpdateV2 struct {
+	ID int64 `json:"id" xml:"id" validate:"required,gt=0"`
+	UserUpdateV2
+}
+
+func (u UserBatchUpdateV2) Validate() error {
+	return validator.New().Struct(u)
+}
+
+// ToBatchUpdate converts a UserBatchUpdateV2 to the update of a user within a batch
+func (u UserBatchUpdateV2) ToBatchUpdate() UserBatchUpdate {
+	return UserBatchUpdate{ID: u.ID, UserUpdate: u.ToUpdate()}
+}
+
+// BatchCreateRequestV2 represents the users to create in one request in version 2 of the API
+type BatchCreateRequestV2 struct {
+	Mode  BatchMode      `json:"mode" xml:"mode"`
+	Items []UserCreateV2 `json:"items" xml:"item"`
+}
+
+// BatchUpdateRequestV2 represents the users to update in one request in version 2 of the API
+type BatchUpdateRequestV2 struct {
+	Mode  BatchMode           `json:"mode" xml:"mode"`
+	Items []UserBatchUpdateV2 `json:"items" xml:"item"`
+}
+
+// UserResponseV2 represents the data sent back to the client in version 2 of the API
+type UserResponseV2 struct {
+	XMLName    xml.Name   `json:"-" xml:"user"`
+	ID         int64      `json:"id" xml:"id"`
+	Email      string     `json:"email" xml:"email"`
+	GivenName  string     `json:"given_name" xml:"given_name"`
+	FamilyName string     `json:"family_name" xml:"family_name"`
+	Status     UserStatus `json:"status" xml:"status"`
+	CreatedAt  time.Time  `json:"created_at" xml:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at" xml:"updated_at"`
+	DeletedAt  *time.Time `json:"deleted_at,omitempty" xml:"deleted_at,omitempty"`
+	Links      *UserLinks `json:"_links,omitempty" xml:"links,omitempty"`
+}
+
+// ToResponseV2 converts a User to a UserResponseV2
+func (u *User) ToResponseV2() UserResponseV2 {
+	status := UserStatusActive
+	if u.DeletedAt != nil {
+		status = UserStatusDeleted
+	}
+	return UserResponseV2{
+		ID:         u.ID,
+		Email:      u.Email,
+		GivenName:  u.FirstName,
+		FamilyName: u.LastName,
+		Status:     status,
+		CreatedAt:  u.CreatedAt,
+		UpdatedAt:  u.UpdatedAt,
+		DeletedAt:  u.DeletedAt,
+	}
+}
+
+// PaginatedUsersResponseV2 represents the paginated list of users in version 2 of the API
+type PaginatedUsersResponseV2 struct {
+	XMLName    xml.Name         `json:"-" xml:"users"`
+	Users      []UserResponseV2 `json:"users" xml:"user"`
+	TotalCount int              `json:"total_count" xml:"total_count"`
+	Page       int              `json:"page" xml:"page"`
+	PageSize   int              `json:"page_size" xml:"page_size"`
+	TotalPages int              `json:"total_pages" xml:"total_pages"`
+	Links      *PageLinks       `json:"_links,omitempty" xml:"links,omitempty"`
+}
@@ -0,0 +1,69 @@
+package domain
+
+import (
+	"encoding/xml"
+	"sort"
+	"time"
+)
+
+// AuditOperation names a user mutation recorded in the audit trail
+type AuditOperation string
+
+const (
+	AuditCreate  AuditOperation = "create"
+	AuditUpdate  AuditOperation = "update"
+	AuditDelete  AuditOperation = "delete"
+	AuditRestore AuditOperation = "restore"
+)
+
+// FieldChange holds the value of a field before and after a mutation
+// A nil value means the field was not set
+type FieldChange struct {
+	Old *string `json:"old" xml:"old"`
+	New *string `json:"new" xml:"new"`
+}
+
+// FieldChanges holds the changes of a mutation by the name of the field
+type FieldChanges map[string]FieldChange
+
+// MarshalXML writes the changes as change elements named by a field attribute, sorted by field
+func (c FieldChanges) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	fields := make([]string, 0, len(c))
+	for field := range c {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+
+	if err := e.EncodeToken(start); err != nil {
+		return err
+	}
+	for _, field := range fields {
+		change := xml.StartElement{Name: xml.Name{Local: "change"}, Attr: []xml.Attr{{Name: xml.Name{Local: "field"}, Value: field}}}
+		if err := e.EncodeElement(c[field], change); err != nil {
+			return err
+		}
+	}
+	return e.EncodeToken(start.End())
+}
+
+// AuditRecord represents a single mutation of a user
+type AuditRecord struct {
+	XMLName   xml.Name       `json:"-" xml:"record"`
+	ID        int64          `json:"id" xml:"id"`
+	UserID    int64          `json:"user_id" xml:"user_id"`
+	Actor     string         `json:"actor" xml:"actor"`
+	RequestID string         `json:"request_id" xml:"request_id"`
+	Operation AuditOperation `json:"operation" xml:"operation"`
+	Changes   FieldChanges   `json:"changes" xml:"changes"`
+	CreatedAt time.Time      `json:"created_at" xml:"created_at"`
+}
+
+// PaginatedAuditResponse represents the paginated history of a user
+type PaginatedAuditResponse struct {
+	XMLName    xml.Name      `json:"-" xml:"history"`
+	Records    []AuditRecord `json:"records" xml:"record"`
+	TotalCount int           `json:"total_count" xml:"total_count"`
+	Page       int           `json:"page" xml:"page"`
+	PageSize   int           `json:"page_size" xml:"page_size"`
+	TotalPages int           `json:"total_pages" xml:"total_pages"`
+}
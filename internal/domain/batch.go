@@ -0,0 +1,73 @@
+package domain
+
+import (
+	"encoding/xml"
+	"github.com/go-playground/validator/v10"
+)
+
+// BatchMode selects how a batch reacts to failing items
+type BatchMode string
+
+const (
+	// BatchAtomic applies all items in one transaction, or none of them if any fails
+	BatchAtomic BatchMode = "atomic"
+	// BatchBestEffort applies the items that succeed and reports the others
+	BatchBestEffort BatchMode = "best_effort"
+)
+
+// BatchCreateRequest represents the users to create in one request
+type BatchCreateRequest struct {
+	Mode  BatchMode    `json:"mode" xml:"mode"`
+	Items []UserCreate `json:"items" xml:"item"`
+}
+
+// UserBatchUpdate represents the update of one user within a batch
+type UserBatchUpdate struct {
+	ID int64 `json:"id" xml:"id" validate:"required,gt=0"`
+	UserUpdate
+}
+
+func (u UserBatchUpdate) Validate() error {
+	return validator.New().Struct(u)
+}
+
+// ToBatchUpdate returns the update of a user within a batch, version 1 of the API takes it as is
+func (u UserBatchUpdate) ToBatchUpdate() UserBatchUpdate {
+	return u
+}
+
+// BatchUpdateRequest represents the users to update in one request
+type BatchUpdateRequest struct {
+	Mode  BatchMode         `json:"mode" xml:"mode"`
+	Items []UserBatchUpdate `json:"items" xml:"item"`
+}
+
+// BatchDeleteItem identifies a user to delete within a batch
+type BatchDeleteItem struct {
+	ID int64 `json:"id" xml:"id"`
+}
+
+// BatchDeleteRequest represents the users to delete in one request
+type BatchDeleteRequest struct {
+	Mode  BatchMode         `json:"mode" xml:"mode"`
+	Items []BatchDeleteItem `json:"items" xml:"item"`
+}
+
+// BatchItemResult represents the outcome of one item, in the order of the request
+// Failed items carry a problem detail instead of the ID
+type BatchItemResult struct {
+	XMLName xml.Name `json:"-" xml:"result"`
+	Index   int      `json:"index" xml:"index"`
+	Status  int      `json:"status" xml:"status"`
+	ID      int64    `json:"id,omitempty" xml:"id,omitempty"`
+	Problem *Problem `json:"problem,omitempty" xml:"problem,omitempty"`
+}
+
+// BatchResponse represents the outcome of a batch
+type BatchResponse struct {
+	XMLName   xml.Name          `json:"-" xml:"batch"`
+	Mode      BatchMode         `json:"mode" xml:"mode"`
+	Succeeded int               `json:"succeeded" xml:"succeeded"`
+	Failed    int               `json:"failed" xml:"failed"`
+	Results   []BatchItemResult `json:"results" xml:"result"`
+}
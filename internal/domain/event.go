@@ -0,0 +1,26 @@
+package domain
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// EventType names a user lifecycle event
+type EventType string
+
+const (
+	EventUserCreated  EventType = "user.created"
+	EventUserUpdated  EventType = "user.updated"
+	EventUserDeleted  EventType = "user.deleted"
+	EventUserRestored EventType = "user.restored"
+)
+
+// Event is a user lifecycle event published to downstream services
+// Data holds the user as it was right after the change
+type Event struct {
+	ID         int64           `json:"id"`
+	Type       EventType       `json:"type"`
+	UserID     int64           `json:"user_id"`
+	OccurredAt time.Time       `json:"occurred_at"`
+	Data       json.RawMessage `json:"data"`
+}
@@ -0,0 +1,37 @@
+package domain
+
+// DuplicatePolicy selects what an import does with rows whose email belongs to an existing user
+type DuplicatePolicy string
+
+const (
+	// DuplicateSkip leaves the existing user untouched
+	DuplicateSkip DuplicatePolicy = "skip"
+	// DuplicateFail reports the row as an error
+	DuplicateFail DuplicatePolicy = "fail"
+	// DuplicateUpsert updates the names of the existing user
+	DuplicateUpsert DuplicatePolicy = "upsert"
+)
+
+// ImportError describes a row that could not be imported
+// Line is the line of the file the row starts on, Column is empty if the whole row is at fault
+type ImportError struct {
+	Line   int    `json:"line"`
+	Column string `json:"column,omitempty"`
+	Reason string `json:"reason"`
+}
+
+// ImportReport represents the outcome of an import
+// Errors are capped, Truncated tells whether some were left out
+// Error is set if the import stopped midway, the rows counted so far are stored nonetheless
+type ImportReport struct {
+	Error      string        `json:"error,omitempty"`
+	DryRun     bool          `json:"dry_run"`
+	Rows       int           `json:"rows"`
+	Created    int           `json:"created"`
+	Updated    int           `json:"updated"`
+	Skipped    int           `json:"skipped"`
+	Failed     int           `json:"failed"`
+	Incomplete bool          `json:"incomplete"`
+	Errors     []ImportError `json:"errors"`
+	Truncated  bool          `json:"errors_truncated"`
+}
@@ -0,0 +1,52 @@
+package domain
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// JobStatus is the state of a background job
+type JobStatus string
+
+const (
+	JobQueued    JobStatus = "queued"
+	JobRunning   JobStatus = "running"
+	JobSucceeded JobStatus = "succeeded"
+	JobFailed    JobStatus = "failed"
+	JobCanceled  JobStatus = "canceled"
+)
+
+// Finished reports whether the job will not run anymore
+func (s JobStatus) Finished() bool {
+	return s == JobSucceeded || s == JobFailed || s == JobCanceled
+}
+
+// JobProgress tells how far a job got, in units of its own such as rows or bytes
+// A zero total means that it is not known
+type JobProgress struct {
+	Done  int64 `json:"done"`
+	Total int64 `json:"total"`
+}
+
+// Job represents a long-running operation run by a worker
+type Job struct {
+	ID              int64           `json:"id"`
+	Type            string          `json:"type"`
+	Payload         json.RawMessage `json:"-"` // input of the worker, may hold internal details such as paths
+	Status          JobStatus       `json:"status"`
+	Progress        JobProgress     `json:"progress"`
+	Result          json.RawMessage `json:"result,omitempty"`
+	Error           string          `json:"error,omitempty"`
+	Attempts        int             `json:"attempts"`
+	CancelRequested bool            `json:"cancel_requested"`
+	CreatedAt       time.Time       `json:"created_at"`
+	StartedAt       *time.Time      `json:"started_at,omitempty"`
+	FinishedAt      *time.Time      `json:"finished_at,omitempty"`
+	DownloadURL     string          `json:"download_url,omitempty"`
+}
+
+// JobFile describes the file produced by a job, jobs producing one include it in their result
+type JobFile struct {
+	FileName    string `json:"file_name"`
+	ContentType string `json:"content_type"`
+}
@@ -15,4 +15,5 @@ type User struct {
 	LastName  string
 	CreatedAt time.Time
 	UpdatedAt time.Time
+	DeletedAt *time.Time // nil for live users
 }
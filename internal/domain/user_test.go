@@ -2,6 +2,7 @@ package domain
 
 import (
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -138,3 +139,30 @@ func TestUser_ToResponse(t *testing.T) {
 	assert.Equal(t, user.FirstName, response.FirstName)
 	assert.Equal(t, user.LastName, response.LastName)
 }
+
+func TestUser_ToResponseV2(t *testing.T) {
+	user := User{
+		ID:        1,
+		Email:     "test@example.com",
+		FirstName: "John",
+		LastName:  "Doe",
+	}
+
+	response := user.ToResponseV2()
+
+	assert.Equal(t, user.FirstName, response.GivenName)
+	assert.Equal(t, user.LastName, response.FamilyName)
+	assert.Equal(t, UserStatusActive, response.Status)
+
+	deletedAt := time.Now()
+	user.DeletedAt = &deletedAt
+	assert.Equal(t, UserStatusDeleted, user.ToResponseV2().Status)
+}
+
+func TestUserCreateV2Validate(t *testing.T) {
+	valid := UserCreateV2{Email: "test@example.com", GivenName: "John", FamilyName: "Doe"}
+	assert.NoError(t, valid.Validate())
+	assert.Equal(t, UserCreate{Email: "test@example.com", FirstName: "John", LastName: "Doe"}, valid.ToCreate())
+
+	assert.Error(t, UserCreateV2{Email: "test@example.com", GivenName: "John"}.Validate())
+}
@@ -0,0 +1,63 @@
+package domain
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+)
+
+// Webhook is a subscription of a partner to user lifecycle events
+// An empty event filter subscribes to all events
+type Webhook struct {
+	ID        int64       `json:"id"`
+	URL       string      `json:"url"`
+	Events    []EventType `json:"events"`
+	Secret    string      `json:"-"` // only used to sign deliveries, never sent back
+	CreatedAt time.Time   `json:"created_at"`
+}
+
+// WebhookCreate represents the data needed to register a webhook
+type WebhookCreate struct {
+	URL    string      `json:"url" validate:"required,http_url,max=2048"`
+	Events []EventType `json:"events" validate:"dive,oneof=user.created user.updated user.deleted user.restored"`
+	Secret string      `json:"secret" validate:"required,min=16,max=256"`
+}
+
+func (w WebhookCreate) Validate() error {
+	return validator.New().Struct(w)
+}
+
+// DeliveryStatus is the state of a webhook delivery
+type DeliveryStatus string
+
+const (
+	DeliveryPending   DeliveryStatus = "pending"
+	DeliveryDelivered DeliveryStatus = "delivered"
+	DeliveryDead      DeliveryStatus = "dead" // retries are exhausted, only a manual redelivery sends it again
+)
+
+// WebhookDelivery represents the delivery of an event to a webhook
+type WebhookDelivery struct {
+	ID             int64           `json:"id"`
+	WebhookID      int64           `json:"webhook_id"`
+	EventID        int64           `json:"event_id"`
+	EventType      EventType       `json:"event_type"`
+	Payload        json.RawMessage `json:"payload"`
+	Status         DeliveryStatus  `json:"status"`
+	Attempts       int             `json:"attempts"`
+	NextAttemptAt  time.Time       `json:"next_attempt_at"`
+	LastStatusCode *int            `json:"last_status_code,omitempty"`
+	LastError      *string         `json:"last_error,omitempty"`
+	CreatedAt      time.Time       `json:"created_at"`
+	DeliveredAt    *time.Time      `json:"delivered_at,omitempty"`
+}
+
+// PaginatedDeliveriesResponse represents the paginated delivery log of a webhook
+type PaginatedDeliveriesResponse struct {
+	Deliveries []WebhookDelivery `json:"deliveries"`
+	TotalCount int               `json:"total_count"`
+	Page       int               `json:"page"`
+	PageSize   int               `json:"page_size"`
+	TotalPages int               `json:"total_pages"`
+}
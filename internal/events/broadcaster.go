@@ -0,0 +1,134 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/huberts90/restful-api/internal/domain"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var ErrTooManySubscribers = errors.New("too many subscribers")
+
+var (
+	subscribersGauge = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "events_stream_subscribers",
+		Help: "Number of subscribers to the event stream",
+	})
+	subscribersDroppedTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "events_stream_subscribers_dropped_total",
+		Help: "Number of event stream subscribers dropped for not keeping up",
+	})
+)
+
+// StreamConfig holds the configuration of the event stream
+type StreamConfig struct {
+	Source         string // "notify" for Postgres LISTEN/NOTIFY or "inprocess" for the in-process publisher
+	MaxConnections int
+	Buffer         int           // events buffered per subscriber before it is dropped
+	Heartbeat      time.Duration // interval of keep-alive comments on idle streams
+	ReplayLimit    int           // events replayed at most when a client resumes
+	WriteTimeout   time.Duration // time a client has to accept an event
+}
+
+// Broadcaster fans events out to a bounded number of subscribers
+// A subscriber that does not keep up is dropped rather than slowing down the others
+type Broadcaster struct {
+	mu             sync.Mutex
+	subs           map[*Subscription]struct{}
+	maxSubscribers int
+	buffer         int
+}
+
+// Subscription receives the events of a Broadcaster
+type Subscription struct {
+	b       *Broadcaster
+	events  chan domain.Event
+	dropped bool
+}
+
+// NewBroadcaster creates a new Broadcaster
+func NewBroadcaster(maxSubscribers, buffer int) *Broadcaster {
+	return &Broadcaster{
+		subs:           make(map[*Subscription]struct{}),
+		maxSubscribers: maxSubscribers,
+		buffer:         buffer,
+	}
+}
+
+// Subscribe adds a subscriber, ErrTooManySubscribers is returned once the limit is reached
+func (b *Broadcaster) Subscribe() (*Subscription, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if len(b.subs) >= b.maxSubscribers {
+		return nil, ErrTooManySubscribers
+	}
+
+	sub := &Subscription{b: b, events: make(chan domain.Event, b.buffer)}
+	b.subs[sub] = struct{}{}
+	subscribersGauge.Inc()
+	return sub, nil
+}
+
+// Publish sends an event to all subscribers without blocking
+// It implements the Publisher interface and never fails
+func (b *Broadcaster) Publish(_ context.Context, event domain.Event) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for sub := range b.subs {
+		select {
+		case sub.events <- event:
+		default:
+			sub.dropped = true
+			b.remove(sub)
+			subscribersDroppedTotal.Inc()
+		}
+	}
+	return nil
+}
+
+// Close implements the Publisher interface, it ends all subscriptions
+func (b *Broadcaster) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for sub := range b.subs {
+		b.remove(sub)
+	}
+	return nil
+}
+
+// remove ends a subscription
+// The caller must hold the lock
+func (b *Broadcaster) remove(sub *Subscription) {
+	if _, ok := b.subs[sub]; !ok {
+		return
+	}
+	delete(b.subs, sub)
+	close(sub.events)
+	subscribersGauge.Dec()
+}
+
+// Events returns the channel of events, it is closed when the subscription ends
+func (s *Subscription) Events() <-chan domain.Event {
+	return s.events
+}
+
+// Dropped reports whether the subscription ended because the subscriber did not keep up
+func (s *Subscription) Dropped() bool {
+	s.b.mu.Lock()
+	defer s.b.mu.Unlock()
+	return s.dropped
+}
+
+// Close ends the subscription
+func (s *Subscription) Close() {
+	s.b.mu.Lock()
+	defer s.b.mu.Unlock()
+	s.b.remove(s)
+}
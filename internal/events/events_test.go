@@ -0,0 +1,280 @@
+package events
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/huberts90/restful-api/internal/domain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testEvent(id, userID int64, eventType domain.EventType) domain.Event {
+	return domain.Event{
+		ID:         id,
+		Type:       eventType,
+		UserID:     userID,
+		OccurredAt: time.Date(2023, 1, 1, 12, 0, 0, 0, time.UTC),
+		Data:       json.RawMessage(`{"id":` + strconv.FormatInt(userID, 10) + `}`),
+	}
+}
+
+func TestInProcess(t *testing.T) {
+	ctx := context.Background()
+	bus := NewInProcess()
+
+	var got []int64
+	unsubscribe := bus.Subscribe(func(_ context.Context, event domain.Event) error {
+		got = append(got, event.ID)
+		return nil
+	})
+	errHandler := errors.New("handler failed")
+	unsubscribeFailing := bus.Subscribe(func(context.Context, domain.Event) error {
+		return errHandler
+	})
+
+	// A failing subscriber fails the publish so that the event is published again
+	assert.ErrorIs(t, bus.Publish(ctx, testEvent(1, 1, domain.EventUserCreated)), errHandler)
+	unsubscribeFailing()
+	assert.NoError(t, bus.Publish(ctx, testEvent(2, 1, domain.EventUserUpdated)))
+
+	unsubscribe()
+	assert.NoError(t, bus.Publish(ctx, testEvent(3, 1, domain.EventUserDeleted)))
+	assert.Equal(t, []int64{1, 2}, got)
+}
+
+func TestWriter(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("ndjson", func(t *testing.T) {
+		var buf bytes.Buffer
+		w := NewWriter(&buf)
+		require.NoError(t, w.Publish(ctx, testEvent(1, 1, domain.EventUserCreated)))
+		require.NoError(t, w.Publish(ctx, testEvent(2, 1, domain.EventUserUpdated)))
+		require.NoError(t, w.Close())
+
+		lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+		require.Len(t, lines, 2)
+		var event domain.Event
+		require.NoError(t, json.Unmarshal([]byte(lines[1]), &event))
+		assert.Equal(t, domain.EventUserUpdated, event.Type)
+		assert.JSONEq(t, `{"id":1}`, string(event.Data))
+	})
+
+	t.Run("file appends", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "events.ndjson")
+		for i := int64(1); i <= 2; i++ {
+			w, err := NewFile(path)
+			require.NoError(t, err)
+			require.NoError(t, w.Publish(ctx, testEvent(i, 1, domain.EventUserCreated)))
+			require.NoError(t, w.Close())
+		}
+
+		data, err := os.ReadFile(path)
+		require.NoError(t, err)
+		assert.Equal(t, 2, strings.Count(string(data), "\n"))
+	})
+}
+
+// fakeNATS is a local stand-in for a NATS server recording published messages
+type fakeNATS struct {
+	listener net.Listener
+	mu       sync.Mutex
+	connect  string
+	messages []natsMessage
+	reject   bool
+}
+
+type natsMessage struct {
+	subject string
+	headers string
+	payload string
+}
+
+func startFakeNATS(t *testing.T) *fakeNATS {
+	t.Helper()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	srv := &fakeNATS{listener: l}
+	t.Cleanup(func() { l.Close() })
+
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go srv.serve(conn)
+		}
+	}()
+	return srv
+}
+
+func (s *fakeNATS) serve(conn net.Conn) {
+	defer conn.Close()
+	rd := bufio.NewReader(conn)
+	if _, err := conn.Write([]byte(`INFO {"server_id":"fake","headers":true}` + "\r\n")); err != nil {
+		return
+	}
+
+	for {
+		line, err := rd.ReadString('\n')
+		if err != nil {
+			return
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+
+		var reply string
+		switch fields[0] {
+		case "CONNECT":
+			s.mu.Lock()
+			s.connect = strings.TrimSpace(strings.TrimPrefix(line, "CONNECT"))
+			s.mu.Unlock()
+		case "PING":
+			reply = "PONG\r\n"
+		case "HPUB":
+			hdrLen, _ := strconv.Atoi(fields[2])
+			totalLen, _ := strconv.Atoi(fields[3])
+			data := make([]byte, totalLen+2)
+			if _, err := io.ReadFull(rd, data); err != nil {
+				return
+			}
+			s.mu.Lock()
+			if s.reject {
+				reply = "-ERR 'Permissions Violation for Publish'\r\n"
+			} else {
+				s.messages = append(s.messages, natsMessage{
+					subject: fields[1],
+					headers: string(data[:hdrLen]),
+					payload: string(data[hdrLen:totalLen]),
+				})
+			}
+			s.mu.Unlock()
+		}
+		if reply != "" {
+			if _, err := conn.Write([]byte(reply)); err != nil {
+				return
+			}
+		}
+	}
+}
+
+func TestNATS(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	srv := startFakeNATS(t)
+	p, err := NewNATS(ctx, NATSConfig{
+		Addr:          srv.listener.Addr().String(),
+		Token:         "secret",
+		SubjectPrefix: "events",
+		DialTimeout:   time.Second,
+	})
+	require.NoError(t, err)
+	defer p.Close()
+
+	require.NoError(t, p.Publish(ctx, testEvent(7, 3, domain.EventUserDeleted)))
+
+	srv.mu.Lock()
+	assert.Contains(t, srv.connect, `"auth_token":"secret"`)
+	assert.Contains(t, srv.connect, `"headers":true`)
+	require.Len(t, srv.messages, 1)
+	msg := srv.messages[0]
+	srv.reject = true
+	srv.mu.Unlock()
+
+	assert.Equal(t, "events.user.deleted", msg.subject)
+	assert.Contains(t, msg.headers, "Nats-Msg-Id: 7\r\n")
+	var event domain.Event
+	require.NoError(t, json.Unmarshal([]byte(msg.payload), &event))
+	assert.Equal(t, int64(3), event.UserID)
+
+	// Errors reported by the server fail the publish, and the next one reconnects
+	assert.ErrorContains(t, p.Publish(ctx, testEvent(8, 3, domain.EventUserRestored)), "Permissions Violation")
+	srv.mu.Lock()
+	srv.reject = false
+	srv.mu.Unlock()
+	assert.NoError(t, p.Publish(ctx, testEvent(8, 3, domain.EventUserRestored)))
+}
+
+func TestKafka(t *testing.T) {
+	var body kafkaRecords
+	fail := false
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/topics/users", r.URL.Path)
+		assert.Equal(t, "application/vnd.kafka.json.v2+json", r.Header.Get("Content-Type"))
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+
+		w.Header().Set("Content-Type", "application/vnd.kafka.v2+json")
+		if fail {
+			_, _ = w.Write([]byte(`{"offsets":[{"partition":null,"offset":null,"error_code":50002,"error":"leader not available"}]}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"offsets":[{"partition":0,"offset":42,"error_code":null,"error":null}]}`))
+	}))
+	defer srv.Close()
+
+	p := NewKafka(KafkaConfig{URL: srv.URL, Topic: "users", Timeout: time.Second})
+	defer p.Close()
+
+	require.NoError(t, p.Publish(context.Background(), testEvent(5, 9, domain.EventUserCreated)))
+	require.Len(t, body.Records, 1)
+	// Records are keyed by user ID to keep the events of a user in one partition
+	assert.Equal(t, "9", body.Records[0].Key)
+	assert.Equal(t, int64(5), body.Records[0].Value.ID)
+
+	fail = true
+	assert.ErrorContains(t, p.Publish(context.Background(), testEvent(6, 9, domain.EventUserUpdated)), "leader not available")
+}
+
+func TestBroadcaster(t *testing.T) {
+	ctx := context.Background()
+	b := NewBroadcaster(2, 1)
+
+	fast, err := b.Subscribe()
+	require.NoError(t, err)
+	slow, err := b.Subscribe()
+	require.NoError(t, err)
+	_, err = b.Subscribe()
+	assert.ErrorIs(t, err, ErrTooManySubscribers)
+
+	require.NoError(t, b.Publish(ctx, testEvent(1, 1, domain.EventUserCreated)))
+	assert.Equal(t, int64(1), (<-fast.Events()).ID)
+
+	// The slow subscriber still has the first event buffered and is dropped
+	require.NoError(t, b.Publish(ctx, testEvent(2, 1, domain.EventUserUpdated)))
+	assert.Equal(t, int64(2), (<-fast.Events()).ID)
+	assert.True(t, slow.Dropped())
+	assert.Equal(t, int64(1), (<-slow.Events()).ID)
+	_, ok := <-slow.Events()
+	assert.False(t, ok)
+
+	// Dropping freed a slot
+	again, err := b.Subscribe()
+	require.NoError(t, err)
+	again.Close()
+	slow.Close()
+
+	require.NoError(t, b.Close())
+	_, ok = <-fast.Events()
+	assert.False(t, ok)
+	assert.False(t, fast.Dropped())
+}
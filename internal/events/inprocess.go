@@ -0,0 +1,64 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/huberts90/restful-api/internal/domain"
+)
+
+// Handler processes a published event
+type Handler func(ctx context.Context, event domain.Event) error
+
+// InProcess publishes events to handlers subscribed within the same process
+type InProcess struct {
+	mu       sync.RWMutex
+	handlers map[int]Handler
+	nextID   int
+}
+
+// NewInProcess creates a new InProcess publisher without subscribers
+func NewInProcess() *InProcess {
+	return &InProcess{handlers: make(map[int]Handler)}
+}
+
+// Subscribe registers a handler for all events and returns a function removing it
+func (p *InProcess) Subscribe(handler Handler) func() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	id := p.nextID
+	p.nextID++
+	p.handlers[id] = handler
+
+	return func() {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		delete(p.handlers, id)
+	}
+}
+
+// Publish implements the Publisher interface
+// Handlers run in turn, the event is published again to all of them if any fails
+func (p *InProcess) Publish(ctx context.Context, event domain.Event) error {
+	p.mu.RLock()
+	handlers := make([]Handler, 0, len(p.handlers))
+	for _, h := range p.handlers {
+		handlers = append(handlers, h)
+	}
+	p.mu.RUnlock()
+
+	var errs []error
+	for _, h := range handlers {
+		if err := h(ctx, event); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Close implements the Publisher interface
+func (p *InProcess) Close() error {
+	return nil
+}
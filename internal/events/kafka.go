@@ -0,0 +1,102 @@
+package events
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/huberts90/restful-api/internal/domain"
+)
+
+// KafkaConfig holds the configuration for publishing to Kafka through a REST proxy
+type KafkaConfig struct {
+	URL     string // base URL of a Kafka REST Proxy v2 compatible endpoint, e.g. Confluent REST Proxy or Redpanda
+	Topic   string
+	Timeout time.Duration
+}
+
+// kafkaRecords is the body of a produce request
+type kafkaRecords struct {
+	Records []kafkaRecord `json:"records"`
+}
+
+type kafkaRecord struct {
+	Key   string       `json:"key"`
+	Value domain.Event `json:"value"`
+}
+
+// kafkaOffsets is the body of a produce response, with an error per record that failed
+type kafkaOffsets struct {
+	Offsets []struct {
+		Error *string `json:"error"`
+	} `json:"offsets"`
+}
+
+// Kafka publishes events to a Kafka topic through the REST proxy API
+// Records are keyed by user ID, which keeps the events of a user in one partition and thus in order
+type Kafka struct {
+	cfg    KafkaConfig
+	client *http.Client
+}
+
+// NewKafka creates a new Kafka publisher
+func NewKafka(cfg KafkaConfig) *Kafka {
+	return &Kafka{
+		cfg:    cfg,
+		client: &http.Client{Timeout: cfg.Timeout},
+	}
+}
+
+// Publish implements the Publisher interface
+func (p *Kafka) Publish(ctx context.Context, event domain.Event) error {
+	body, err := json.Marshal(kafkaRecords{
+		Records: []kafkaRecord{{Key: strconv.FormatInt(event.UserID, 10), Value: event}},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to encode event: %w", err)
+	}
+
+	endpoint, err := url.JoinPath(p.cfg.URL, "topics", p.cfg.Topic)
+	if err != nil {
+		return fmt.Errorf("invalid kafka proxy url: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/vnd.kafka.json.v2+json")
+	req.Header.Set("Accept", "application/vnd.kafka.v2+json")
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to produce event: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to produce event: kafka proxy returned %s", resp.Status)
+	}
+
+	var offsets kafkaOffsets
+	if err := json.NewDecoder(resp.Body).Decode(&offsets); err != nil {
+		return fmt.Errorf("failed to decode produce response: %w", err)
+	}
+	for _, offset := range offsets.Offsets {
+		if offset.Error != nil {
+			return fmt.Errorf("failed to produce event: %s", *offset.Error)
+		}
+	}
+	return nil
+}
+
+// Close implements the Publisher interface
+func (p *Kafka) Close() error {
+	p.client.CloseIdleConnections()
+	return nil
+}
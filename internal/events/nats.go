@@ -0,0 +1,192 @@
+package events
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/huberts90/restful-api/internal/domain"
+)
+
+// NATSConfig holds the configuration for publishing to a NATS server
+type NATSConfig struct {
+	Addr          string
+	Token         string
+	SubjectPrefix string // events are published on <prefix>.<event type>, e.g. events.user.created
+	DialTimeout   time.Duration
+}
+
+// NATS is a minimal publisher speaking the NATS client protocol
+// Each publish is followed by a PING, and the PONG confirms that the server processed it
+// The event ID is sent as the Nats-Msg-Id header so that JetStream drops redelivered duplicates
+type NATS struct {
+	cfg NATSConfig
+
+	mu   sync.Mutex
+	conn net.Conn
+	rd   *bufio.Reader
+}
+
+// NewNATS creates a new NATS publisher and checks that the server is reachable
+func NewNATS(ctx context.Context, cfg NATSConfig) (*NATS, error) {
+	p := &NATS{cfg: cfg}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err := p.connect(ctx); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// Publish implements the Publisher interface
+func (p *NATS) Publish(ctx context.Context, event domain.Event) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to encode event: %w", err)
+	}
+
+	headers := "NATS/1.0\r\n" +
+		"Nats-Msg-Id: " + strconv.FormatInt(event.ID, 10) + "\r\n" +
+		"User-Id: " + strconv.FormatInt(event.UserID, 10) + "\r\n\r\n"
+	subject := p.cfg.SubjectPrefix + "." + string(event.Type)
+
+	msg := make([]byte, 0, len(subject)+len(headers)+len(payload)+64)
+	msg = fmt.Appendf(msg, "HPUB %s %d %d\r\n", subject, len(headers), len(headers)+len(payload))
+	msg = append(msg, headers...)
+	msg = append(msg, payload...)
+	msg = append(msg, "\r\nPING\r\n"...)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.conn == nil {
+		if err := p.connect(ctx); err != nil {
+			return err
+		}
+	}
+
+	err = p.roundTrip(ctx, msg)
+	if err != nil {
+		// The connection state is unknown after an error
+		p.closeConn()
+	}
+	return err
+}
+
+// Close implements the Publisher interface
+func (p *NATS) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.closeConn()
+	return nil
+}
+
+// connect dials the server and completes the handshake
+// The caller must hold the lock
+func (p *NATS) connect(ctx context.Context) error {
+	dialer := net.Dialer{Timeout: p.cfg.DialTimeout}
+	conn, err := dialer.DialContext(ctx, "tcp", p.cfg.Addr)
+	if err != nil {
+		return fmt.Errorf("failed to connect to nats: %w", err)
+	}
+	p.conn = conn
+	p.rd = bufio.NewReader(conn)
+
+	if err := p.handshake(ctx); err != nil {
+		p.closeConn()
+		return err
+	}
+	return nil
+}
+
+func (p *NATS) handshake(ctx context.Context) error {
+	if err := p.setDeadline(ctx); err != nil {
+		return err
+	}
+
+	line, err := p.readLine()
+	if err != nil {
+		return err
+	}
+	if !strings.HasPrefix(line, "INFO ") {
+		return fmt.Errorf("nats: unexpected greeting %q", line)
+	}
+
+	options, err := json.Marshal(map[string]interface{}{
+		"verbose":    false,
+		"pedantic":   false,
+		"headers":    true,
+		"name":       "restful-api",
+		"lang":       "go",
+		"auth_token": p.cfg.Token,
+	})
+	if err != nil {
+		return err
+	}
+
+	msg := append([]byte("CONNECT "), options...)
+	msg = append(msg, "\r\nPING\r\n"...)
+	return p.roundTrip(ctx, msg)
+}
+
+// roundTrip writes msg, which must end with a PING, and waits for the matching PONG
+// The caller must hold the lock
+func (p *NATS) roundTrip(ctx context.Context, msg []byte) error {
+	if err := p.setDeadline(ctx); err != nil {
+		return err
+	}
+	if _, err := p.conn.Write(msg); err != nil {
+		return err
+	}
+
+	for {
+		line, err := p.readLine()
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case line == "PONG":
+			return nil
+		case line == "PING":
+			if _, err := p.conn.Write([]byte("PONG\r\n")); err != nil {
+				return err
+			}
+		case strings.HasPrefix(line, "-ERR"):
+			return errors.New("nats: " + strings.Trim(strings.TrimPrefix(line, "-ERR"), " '"))
+		default:
+			// +OK and INFO updates need no action
+		}
+	}
+}
+
+func (p *NATS) setDeadline(ctx context.Context) error {
+	// A zero deadline, when ctx has none, clears any previous one
+	deadline, _ := ctx.Deadline()
+	return p.conn.SetDeadline(deadline)
+}
+
+func (p *NATS) readLine() (string, error) {
+	line, err := p.rd.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// closeConn closes the current connection, the next publish dials a new one
+// The caller must hold the lock
+func (p *NATS) closeConn() {
+	if p.conn != nil {
+		_ = p.conn.Close()
+		p.conn = nil
+	}
+}
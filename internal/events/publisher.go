@@ -0,0 +1,78 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/huberts90/restful-api/internal/domain"
+)
+
+// Publisher delivers user lifecycle events to downstream services
+// Publish must only return once the event is handed over, as the relay marks it as published afterwards
+type Publisher interface {
+	Publish(ctx context.Context, event domain.Event) error
+	Close() error
+}
+
+// Config holds the event publishing configuration
+type Config struct {
+	Publisher    string // "none", "inprocess", "stdout", "file", "nats" or "kafka"
+	FilePath     string // NDJSON file the "file" publisher appends to
+	NATS         NATSConfig
+	Kafka        KafkaConfig
+	PollInterval time.Duration // how often the relay looks for pending events
+	BatchSize    int
+	Retention    time.Duration // how long published events are kept in the outbox
+}
+
+// NewPublisher creates the publisher selected in the configuration
+// The in-process publisher is always created, so that it can be subscribed to regardless
+func NewPublisher(ctx context.Context, cfg Config, bus *InProcess) (Publisher, error) {
+	switch cfg.Publisher {
+	case "inprocess":
+		return bus, nil
+	case "stdout":
+		return NewWriter(os.Stdout), nil
+	case "file":
+		return NewFile(cfg.FilePath)
+	case "nats":
+		return NewNATS(ctx, cfg.NATS)
+	case "kafka":
+		return NewKafka(cfg.Kafka), nil
+	default:
+		return nil, fmt.Errorf("unsupported publisher: %s", cfg.Publisher)
+	}
+}
+
+// Multi publishes events through several publishers in turn
+// An event failing on any of them is published again to all, which at-least-once delivery allows
+type Multi struct {
+	publishers []Publisher
+}
+
+// NewMulti creates a new Multi publisher
+func NewMulti(publishers ...Publisher) *Multi {
+	return &Multi{publishers: publishers}
+}
+
+// Publish implements the Publisher interface
+func (p *Multi) Publish(ctx context.Context, event domain.Event) error {
+	for _, publisher := range p.publishers {
+		if err := publisher.Publish(ctx, event); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close implements the Publisher interface
+func (p *Multi) Close() error {
+	var errs []error
+	for _, publisher := range p.publishers {
+		errs = append(errs, publisher.Close())
+	}
+	return errors.Join(errs...)
+}
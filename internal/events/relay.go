@@ -0,0 +1,119 @@
+package events
+
+import (
+	"context"
+	"time"
+
+	"github.com/huberts90/restful-api/internal/domain"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"go.uber.org/zap"
+)
+
+// relayTimeout bounds a single relay round
+const relayTimeout = 30 * time.Second
+
+var (
+	eventsPublishedTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "events_published_total",
+		Help: "Number of published user lifecycle events by type",
+	}, []string{"type"})
+	eventsPublishFailuresTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "events_publish_failures_total",
+		Help: "Number of failed attempts to publish a user lifecycle event",
+	})
+)
+
+// Outbox is the transactional outbox the relay reads events from
+type Outbox interface {
+	PublishOutbox(ctx context.Context, limit int, publish func(context.Context, domain.Event) error) (int, error)
+	PruneOutbox(ctx context.Context, before time.Time, unpublished bool) (int64, error)
+}
+
+// Relay periodically publishes the pending events of the outbox and prunes the published ones
+// Events are delivered at least once, in the order they were written. Without a publisher the events
+// are only kept for the event stream, and they are pruned once written longer ago than the retention
+type Relay struct {
+	outbox    Outbox
+	publisher Publisher
+	cfg       Config
+	logger    *zap.Logger
+}
+
+// NewRelay creates a new Relay, publisher may be nil
+func NewRelay(outbox Outbox, publisher Publisher, cfg Config, logger *zap.Logger) *Relay {
+	return &Relay{
+		outbox:    outbox,
+		publisher: publisher,
+		cfg:       cfg,
+		logger:    logger,
+	}
+}
+
+// Run relays events every poll interval and prunes published ones every hour until ctx is done
+func (r *Relay) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.cfg.PollInterval)
+	defer ticker.Stop()
+	pruneTicker := time.NewTicker(time.Hour)
+	defer pruneTicker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if r.publisher != nil {
+				r.RelayOnce(ctx)
+			}
+		case <-pruneTicker.C:
+			r.PruneOnce(ctx)
+		}
+	}
+}
+
+// RelayOnce publishes pending events until the outbox is drained or publishing fails
+// It returns the number of published events
+func (r *Relay) RelayOnce(ctx context.Context) int {
+	total := 0
+	for {
+		n, err := r.relayBatch(ctx)
+		total += n
+		if err != nil {
+			r.logger.Error("failed to relay events", zap.Error(err), zap.Int("published", n))
+			return total
+		}
+		if n < r.cfg.BatchSize {
+			return total
+		}
+	}
+}
+
+func (r *Relay) relayBatch(ctx context.Context) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, relayTimeout)
+	defer cancel()
+
+	return r.outbox.PublishOutbox(ctx, r.cfg.BatchSize, func(ctx context.Context, event domain.Event) error {
+		if err := r.publisher.Publish(ctx, event); err != nil {
+			eventsPublishFailuresTotal.Inc()
+			return err
+		}
+		eventsPublishedTotal.WithLabelValues(string(event.Type)).Inc()
+		return nil
+	})
+}
+
+// PruneOnce deletes the events published longer ago than the retention period
+func (r *Relay) PruneOnce(ctx context.Context) int64 {
+	ctx, cancel := context.WithTimeout(ctx, relayTimeout)
+	defer cancel()
+
+	pruned, err := r.outbox.PruneOutbox(ctx, time.Now().Add(-r.cfg.Retention), r.publisher == nil)
+	if err != nil {
+		r.logger.Error("failed to prune outbox", zap.Error(err))
+		return 0
+	}
+	if pruned > 0 {
+		r.logger.Info("pruned events", zap.Int64("count", pruned), zap.Duration("retention", r.cfg.Retention))
+	}
+	return pruned
+}
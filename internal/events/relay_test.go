@@ -0,0 +1,90 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/huberts90/restful-api/internal/domain"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+// fakeOutbox is an in-memory outbox handing out pending events in batches
+type fakeOutbox struct {
+	pending     []domain.Event
+	pruned      time.Time
+	unpublished bool
+}
+
+func (o *fakeOutbox) PublishOutbox(ctx context.Context, limit int, publish func(context.Context, domain.Event) error) (int, error) {
+	n := 0
+	for n < limit && n < len(o.pending) {
+		if err := publish(ctx, o.pending[n]); err != nil {
+			o.pending = o.pending[n:]
+			return n, err
+		}
+		n++
+	}
+	o.pending = o.pending[n:]
+	return n, nil
+}
+
+func (o *fakeOutbox) PruneOutbox(_ context.Context, before time.Time, unpublished bool) (int64, error) {
+	o.pruned, o.unpublished = before, unpublished
+	return 0, nil
+}
+
+// flakyPublisher records published events and fails on the given event IDs once
+type flakyPublisher struct {
+	published []int64
+	failOnce  map[int64]bool
+}
+
+func (p *flakyPublisher) Publish(_ context.Context, event domain.Event) error {
+	if p.failOnce[event.ID] {
+		delete(p.failOnce, event.ID)
+		return errors.New("broker unavailable")
+	}
+	p.published = append(p.published, event.ID)
+	return nil
+}
+
+func (p *flakyPublisher) Close() error {
+	return nil
+}
+
+func TestRelay(t *testing.T) {
+	ctx := context.Background()
+
+	outbox := &fakeOutbox{}
+	for i := int64(1); i <= 5; i++ {
+		outbox.pending = append(outbox.pending, testEvent(i, i%2, domain.EventUserCreated))
+	}
+	publisher := &flakyPublisher{failOnce: map[int64]bool{4: true}}
+	relay := NewRelay(outbox, publisher, Config{BatchSize: 2, Retention: time.Hour}, zap.NewNop())
+
+	// Full batches are relayed until publishing fails
+	assert.Equal(t, 3, relay.RelayOnce(ctx))
+	assert.Equal(t, []int64{1, 2, 3}, publisher.published)
+
+	// The failed event is retried first, which keeps the order
+	assert.Equal(t, 2, relay.RelayOnce(ctx))
+	assert.Equal(t, []int64{1, 2, 3, 4, 5}, publisher.published)
+	assert.Equal(t, 0, relay.RelayOnce(ctx))
+
+	relay.PruneOnce(ctx)
+	assert.WithinDuration(t, time.Now().Add(-time.Hour), outbox.pruned, time.Minute)
+	assert.False(t, outbox.unpublished)
+}
+
+func TestRelay_WithoutPublisher(t *testing.T) {
+	outbox := &fakeOutbox{}
+	relay := NewRelay(outbox, nil, Config{BatchSize: 2, Retention: time.Hour}, zap.NewNop())
+
+	// Nothing ever publishes the events, so they are pruned by age
+	relay.PruneOnce(context.Background())
+	assert.WithinDuration(t, time.Now().Add(-time.Hour), outbox.pruned, time.Minute)
+	assert.True(t, outbox.unpublished)
+}
@@ -0,0 +1,57 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/huberts90/restful-api/internal/domain"
+)
+
+// Writer publishes events as newline-delimited JSON (NDJSON)
+type Writer struct {
+	mu     sync.Mutex
+	w      io.Writer
+	closer io.Closer
+}
+
+// NewWriter creates a new Writer publishing to w, which is left open on Close
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{w: w}
+}
+
+// NewFile creates a new Writer appending to the file at path
+func NewFile(path string) (*Writer, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open event file: %w", err)
+	}
+	return &Writer{w: f, closer: f}, nil
+}
+
+// Publish implements the Publisher interface
+func (p *Writer) Publish(_ context.Context, event domain.Event) error {
+	line, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to encode event: %w", err)
+	}
+	line = append(line, '\n')
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	// A single write keeps lines whole when several processes append to the same file
+	_, err = p.w.Write(line)
+	return err
+}
+
+// Close implements the Publisher interface
+func (p *Writer) Close() error {
+	if p.closer == nil {
+		return nil
+	}
+	return p.closer.Close()
+}
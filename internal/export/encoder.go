@@ -0,0 +1,187 @@
+package export
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/huberts90/restful-api/internal/domain"
+	"github.com/huberts90/restful-api/internal/storage"
+	"github.com/parquet-go/parquet-go"
+)
+
+// rowGroupSize bounds the rows a Parquet writer buffers before writing them out
+const rowGroupSize = 10_000
+
+// Config holds the configuration of exports
+type Config struct {
+	Timeout time.Duration // time to write a whole export
+}
+
+// Format is the file format of an export
+type Format string
+
+const (
+	CSV     Format = "csv"
+	NDJSON  Format = "ndjson"
+	Parquet Format = "parquet"
+)
+
+// ParseFormat validates the name of a format, CSV is used if it is empty
+func ParseFormat(name string) (Format, error) {
+	switch f := Format(name); f {
+	case "":
+		return CSV, nil
+	case CSV, NDJSON, Parquet:
+		return f, nil
+	default:
+		return "", fmt.Errorf("invalid format: %v, must be one of csv, ndjson or parquet", name)
+	}
+}
+
+// ContentType returns the media type of the format
+func (f Format) ContentType() string {
+	switch f {
+	case NDJSON:
+		return "application/x-ndjson"
+	case Parquet:
+		return "application/vnd.apache.parquet"
+	default:
+		return "text/csv"
+	}
+}
+
+// Filename returns the name of an export file in the format
+func (f Format) Filename() string {
+	return "users." + string(f)
+}
+
+// Encoder writes users to a file one at a time
+// Close writes out whatever is buffered, it does not close the underlying writer
+type Encoder interface {
+	Encode(user domain.User) error
+	Close() error
+}
+
+// NewEncoder returns an encoder of the format writing to w
+func NewEncoder(f Format, w io.Writer) Encoder {
+	switch f {
+	case NDJSON:
+		bw := bufio.NewWriter(w)
+		return &ndjsonEncoder{w: bw, enc: json.NewEncoder(bw)}
+	case Parquet:
+		return &parquetEncoder{w: parquet.NewGenericWriter[parquetUser](w, parquet.MaxRowsPerRowGroup(rowGroupSize))}
+	default:
+		cw := csv.NewWriter(w)
+		_ = cw.Write(csvHeader) // errors are reported by the writer when it flushes
+		return &csvEncoder{w: cw}
+	}
+}
+
+// Exporter defines the storage users are exported from
+type Exporter interface {
+	ExportUsers(ctx context.Context, fn func(domain.User) error, opts ...storage.ReadOption) error
+}
+
+// Write exports the users of the store to w in the format and returns the number of users written
+func Write(ctx context.Context, store Exporter, f Format, w io.Writer, opts ...storage.ReadOption) (int, error) {
+	enc := NewEncoder(f, w)
+	rows := 0
+	err := store.ExportUsers(ctx, func(user domain.User) error {
+		rows++
+		return enc.Encode(user)
+	}, opts...)
+	if err != nil {
+		return rows, err
+	}
+
+	return rows, enc.Close()
+}
+
+var csvHeader = []string{"id", "email", "first_name", "last_name", "created_at", "updated_at", "deleted_at"}
+
+type csvEncoder struct {
+	w *csv.Writer
+}
+
+func (e *csvEncoder) Encode(user domain.User) error {
+	deletedAt := ""
+	if user.DeletedAt != nil {
+		deletedAt = user.DeletedAt.UTC().Format(time.RFC3339Nano)
+	}
+	return e.w.Write([]string{
+		strconv.FormatInt(user.ID, 10),
+		EscapeCSVCell(user.Email),
+		EscapeCSVCell(user.FirstName),
+		EscapeCSVCell(user.LastName),
+		user.CreatedAt.UTC().Format(time.RFC3339Nano),
+		user.UpdatedAt.UTC().Format(time.RFC3339Nano),
+		deletedAt,
+	})
+}
+
+// EscapeCSVCell prefixes a text cell that a spreadsheet would take for a formula with a quote
+// Emails such as =HYPERLINK(...)@example.com are valid, and must not run when the file is opened
+func EscapeCSVCell(s string) string {
+	if s != "" && strings.ContainsRune("=+-@\t\r", rune(s[0])) {
+		return "'" + s
+	}
+	return s
+}
+
+func (e *csvEncoder) Close() error {
+	e.w.Flush()
+	return e.w.Error()
+}
+
+type ndjsonEncoder struct {
+	w   *bufio.Writer
+	enc *json.Encoder
+}
+
+func (e *ndjsonEncoder) Encode(user domain.User) error {
+	return e.enc.Encode(user.ToResponse())
+}
+
+func (e *ndjsonEncoder) Close() error {
+	return e.w.Flush()
+}
+
+// parquetUser is the schema of a Parquet export
+// Times are nanosecond timestamps, the only unit a nullable time can be written in
+type parquetUser struct {
+	ID        int64      `parquet:"id"`
+	Email     string     `parquet:"email"`
+	FirstName string     `parquet:"first_name"`
+	LastName  string     `parquet:"last_name"`
+	CreatedAt time.Time  `parquet:"created_at"`
+	UpdatedAt time.Time  `parquet:"updated_at"`
+	DeletedAt *time.Time `parquet:"deleted_at,optional"`
+}
+
+type parquetEncoder struct {
+	w *parquet.GenericWriter[parquetUser]
+}
+
+func (e *parquetEncoder) Encode(user domain.User) error {
+	_, err := e.w.Write([]parquetUser{{
+		ID:        user.ID,
+		Email:     user.Email,
+		FirstName: user.FirstName,
+		LastName:  user.LastName,
+		CreatedAt: user.CreatedAt,
+		UpdatedAt: user.UpdatedAt,
+		DeletedAt: user.DeletedAt,
+	}})
+	return err
+}
+
+func (e *parquetEncoder) Close() error {
+	return e.w.Close()
+}
@@ -0,0 +1,132 @@
+package export
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/huberts90/restful-api/internal/domain"
+	"github.com/huberts90/restful-api/internal/storage"
+	"github.com/parquet-go/parquet-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	created   = time.Date(2023, 1, 1, 12, 0, 0, 0, time.UTC)
+	deleted   = time.Date(2023, 2, 1, 12, 0, 0, 0, time.UTC)
+	testUsers = []domain.User{
+		{ID: 1, Email: "ann@example.com", FirstName: "Ann", LastName: "Lee, Jr.", CreatedAt: created, UpdatedAt: created},
+		{ID: 2, Email: "bob@example.com", FirstName: "Bob", LastName: "Ray", CreatedAt: created, UpdatedAt: deleted, DeletedAt: &deleted},
+	}
+)
+
+// fakeExporter hands out its users, then fails with err if set
+type fakeExporter struct {
+	users []domain.User
+	err   error
+}
+
+func (f *fakeExporter) ExportUsers(_ context.Context, fn func(domain.User) error, _ ...storage.ReadOption) error {
+	for _, user := range f.users {
+		if err := fn(user); err != nil {
+			return err
+		}
+	}
+	return f.err
+}
+
+func TestParseFormat(t *testing.T) {
+	for name, want := range map[string]Format{"": CSV, "csv": CSV, "ndjson": NDJSON, "parquet": Parquet} {
+		got, err := ParseFormat(name)
+		require.NoError(t, err)
+		assert.Equal(t, want, got)
+	}
+
+	_, err := ParseFormat("xlsx")
+	assert.Error(t, err)
+}
+
+func TestWrite_CSV(t *testing.T) {
+	var buf bytes.Buffer
+	rows, err := Write(context.Background(), &fakeExporter{users: testUsers}, CSV, &buf)
+
+	require.NoError(t, err)
+	assert.Equal(t, 2, rows)
+	assert.Equal(t, "id,email,first_name,last_name,created_at,updated_at,deleted_at\n"+
+		`1,ann@example.com,Ann,"Lee, Jr.",2023-01-01T12:00:00Z,2023-01-01T12:00:00Z,`+"\n"+
+		"2,bob@example.com,Bob,Ray,2023-01-01T12:00:00Z,2023-02-01T12:00:00Z,2023-02-01T12:00:00Z\n", buf.String())
+}
+
+func TestWrite_CSVFormulas(t *testing.T) {
+	users := []domain.User{{
+		ID:        3,
+		Email:     `=HYPERLINK("https://evil.example.com")@x.com`,
+		FirstName: "+cmd",
+		LastName:  "-Smith",
+		CreatedAt: testUsers[0].CreatedAt,
+		UpdatedAt: testUsers[0].UpdatedAt,
+	}}
+
+	var buf bytes.Buffer
+	_, err := Write(context.Background(), &fakeExporter{users: users}, CSV, &buf)
+
+	require.NoError(t, err)
+	assert.Equal(t, "id,email,first_name,last_name,created_at,updated_at,deleted_at\n"+
+		`3,"'=HYPERLINK(""https://evil.example.com"")@x.com",'+cmd,'-Smith,2023-01-01T12:00:00Z,2023-01-01T12:00:00Z,`+"\n", buf.String())
+}
+
+func TestEscapeCSVCell(t *testing.T) {
+	for _, cell := range []string{"=1+1", "+1", "-1", "@SUM(A1)", "\tx", "\rx"} {
+		assert.Equal(t, "'"+cell, EscapeCSVCell(cell))
+	}
+	for _, cell := range []string{"", "ann@example.com", "Lee, Jr.", "a=b"} {
+		assert.Equal(t, cell, EscapeCSVCell(cell))
+	}
+}
+
+func TestWrite_NDJSON(t *testing.T) {
+	var buf bytes.Buffer
+	rows, err := Write(context.Background(), &fakeExporter{users: testUsers}, NDJSON, &buf)
+
+	require.NoError(t, err)
+	assert.Equal(t, 2, rows)
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	require.Len(t, lines, 2)
+	assert.Contains(t, lines[0], `"email":"ann@example.com"`)
+	assert.Contains(t, lines[1], `"deleted_at":"2023-02-01T12:00:00Z"`)
+}
+
+func TestWrite_Parquet(t *testing.T) {
+	var buf bytes.Buffer
+	rows, err := Write(context.Background(), &fakeExporter{users: testUsers}, Parquet, &buf)
+	require.NoError(t, err)
+	assert.Equal(t, 2, rows)
+
+	reader := parquet.NewGenericReader[parquetUser](bytes.NewReader(buf.Bytes()))
+	defer reader.Close()
+	got := make([]parquetUser, 3)
+	n, err := reader.Read(got)
+	if !errors.Is(err, io.EOF) {
+		require.NoError(t, err)
+	}
+
+	require.Equal(t, 2, n)
+	assert.Equal(t, "Lee, Jr.", got[0].LastName)
+	assert.Nil(t, got[0].DeletedAt)
+	assert.Equal(t, int64(2), got[1].ID)
+	require.NotNil(t, got[1].DeletedAt)
+	assert.True(t, deleted.Equal(*got[1].DeletedAt))
+}
+
+func TestWrite_StoreError(t *testing.T) {
+	errStore := errors.New("connection reset")
+	rows, err := Write(context.Background(), &fakeExporter{users: testUsers[:1], err: errStore}, CSV, io.Discard)
+
+	assert.ErrorIs(t, err, errStore)
+	assert.Equal(t, 1, rows)
+}
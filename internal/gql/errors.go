@@ -0,0 +1,60 @@
+package gql
+
+import (
+	"context"
+	"errors"
+
+	"github.com/huberts90/restful-api/internal/storage"
+)
+
+// Error codes reported in the extensions of errors, as Apollo clients expect them
+const (
+	CodeBadUserInput           = "BAD_USER_INPUT"
+	CodeNotFound               = "NOT_FOUND"
+	CodeConflict               = "CONFLICT"
+	CodeUnavailable            = "UNAVAILABLE"
+	CodeTimeout                = "TIMEOUT"
+	CodeInternal               = "INTERNAL_SERVER_ERROR"
+	CodeQueryTooComplex        = "QUERY_TOO_COMPLEX"
+	CodeOperationNotAllowed    = "OPERATION_NOT_ALLOWED"
+	CodePersistedQueryNotFound = "PERSISTED_QUERY_NOT_FOUND"
+	CodePersistedQueryInvalid  = "PERSISTED_QUERY_INVALID"
+)
+
+// Error is an error shown to clients, with a code in its extensions
+type Error struct {
+	Code    string
+	Message string
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// Extensions implements gqlerrors.ExtendedError
+func (e *Error) Extensions() map[string]interface{} {
+	return map[string]interface{}{"code": e.Code}
+}
+
+func newError(code, message string) *Error {
+	return &Error{Code: code, Message: message}
+}
+
+// storeError maps an error of the store to an error shown to clients, the message of unexpected errors is not leaked
+func storeError(err error, message string) *Error {
+
+	switch {
+	case errors.Is(err, storage.ErrUserNotFound):
+		return newError(CodeNotFound, "User not found")
+	case errors.Is(err, storage.ErrDuplicateEmail):
+		return newError(CodeConflict, "Email already exists")
+	case errors.Is(err, storage.ErrInvalidID), errors.Is(err, storage.ErrInvalidPageSize):
+		return newError(CodeBadUserInput, err.Error())
+	case errors.Is(err, storage.ErrCircuitOpen):
+		return newError(CodeUnavailable, message)
+	case errors.Is(err, context.DeadlineExceeded):
+		return newError(CodeTimeout, message)
+	default:
+		return newError(CodeInternal, message)
+	}
+}
@@ -0,0 +1,193 @@
+package gql
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/gqlerrors"
+	"github.com/graphql-go/graphql/language/ast"
+	"github.com/graphql-go/graphql/language/location"
+	"github.com/graphql-go/graphql/language/parser"
+	"github.com/graphql-go/graphql/language/source"
+	"github.com/huberts90/restful-api/internal/cache"
+	"github.com/huberts90/restful-api/internal/storage"
+	"go.uber.org/zap"
+)
+
+// persistedQueryPrefix namespaces persisted queries in a cache shared with other data
+const persistedQueryPrefix = "graphql:query:"
+
+// Config holds the configuration of the GraphQL endpoint
+type Config struct {
+	MaxDepth          int
+	MaxComplexity     int
+	Timeout           time.Duration // time to execute an operation
+	PersistedQueries  int           // capacity of the persisted query cache
+	PersistedQueryTTL time.Duration
+}
+
+// Request is a GraphQL request, in the format shared by the GET and POST transports
+type Request struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName"`
+	Variables     map[string]interface{} `json:"variables"`
+	Extensions    RequestExtensions      `json:"extensions"`
+}
+
+// RequestExtensions holds the extensions of a request that are supported
+type RequestExtensions struct {
+	PersistedQuery *PersistedQuery `json:"persistedQuery,omitempty"`
+}
+
+// PersistedQuery identifies a query by its hash, following the automatic persisted queries protocol
+// A client sends the hash alone first, and the query along with it if the hash is not known yet
+type PersistedQuery struct {
+	Version    int    `json:"version"`
+	SHA256Hash string `json:"sha256Hash"`
+}
+
+// Server executes GraphQL requests against the store
+type Server struct {
+	schema  graphql.Schema
+	store   storage.Storer
+	queries cache.Cache
+	cfg     Config
+	logger  *zap.Logger
+}
+
+// NewServer creates a new Server with the given dependencies
+// Persisted queries are kept in the given cache
+func NewServer(store storage.Storer, queries cache.Cache, cfg Config, logger *zap.Logger) (*Server, error) {
+	schema, err := newSchema(store, logger)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Server{
+		schema:  schema,
+		store:   store,
+		queries: queries,
+		cfg:     cfg,
+		logger:  logger,
+	}, nil
+}
+
+// Do executes a request, errors are reported in the result
+// Read-only requests, such as those sent with GET, may not run mutations
+func (s *Server) Do(ctx context.Context, req Request, readOnly bool) *graphql.Result {
+	query, err := s.resolveQuery(ctx, req)
+	if err != nil {
+		return ErrorResult(err)
+	}
+
+	doc, err := parser.Parse(parser.ParseParams{
+		Source: source.NewSource(&source.Source{Body: []byte(query), Name: "GraphQL request"}),
+	})
+	if err != nil {
+		return &graphql.Result{Errors: gqlerrors.FormatErrors(err)}
+	}
+
+	validation := graphql.ValidateDocument(&s.schema, doc, nil)
+	if !validation.IsValid {
+		return &graphql.Result{Errors: validation.Errors}
+	}
+
+	op, err := operation(doc, req.OperationName)
+	if err != nil {
+		return ErrorResult(err)
+	}
+	if readOnly && op.Operation != ast.OperationTypeQuery {
+		return ErrorResult(newError(CodeOperationNotAllowed, "Mutations must be sent with POST"))
+	}
+	if err := checkLimits(doc, op, req.Variables, s.cfg.MaxDepth, s.cfg.MaxComplexity); err != nil {
+		return ErrorResult(err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, s.cfg.Timeout)
+	defer cancel()
+
+	return graphql.Execute(graphql.ExecuteParams{
+		Schema:        s.schema,
+		AST:           doc,
+		OperationName: req.OperationName,
+		Args:          req.Variables,
+		Context:       withLoader(ctx, s.store),
+	})
+}
+
+// resolveQuery returns the query of a request, looking it up or persisting it by its hash if one is given
+func (s *Server) resolveQuery(ctx context.Context, req Request) (string, error) {
+	pq := req.Extensions.PersistedQuery
+	if pq == nil {
+		if req.Query == "" {
+			return "", newError(CodeBadUserInput, "Missing query")
+		}
+		return req.Query, nil
+	}
+	if pq.Version != 1 {
+		return "", newError(CodePersistedQueryInvalid, "Unsupported persisted query version")
+	}
+
+	key := persistedQueryPrefix + pq.SHA256Hash
+	if req.Query == "" {
+		data, err := s.queries.Get(ctx, key)
+		if err != nil {
+			if !errors.Is(err, cache.ErrMiss) {
+				s.logger.Warn("failed to read persisted query", zap.Error(err))
+			}
+			return "", newError(CodePersistedQueryNotFound, "PersistedQueryNotFound")
+		}
+		return string(data), nil
+	}
+
+	sum := sha256.Sum256([]byte(req.Query))
+	if hex.EncodeToString(sum[:]) != pq.SHA256Hash {
+		return "", newError(CodePersistedQueryInvalid, "Provided sha256Hash does not match the query")
+	}
+	if err := s.queries.Set(ctx, key, []byte(req.Query), s.cfg.PersistedQueryTTL); err != nil {
+		// The query can still be run, the client sends it again when the hash is not found
+		s.logger.Warn("failed to persist query", zap.Error(err))
+	}
+	return req.Query, nil
+}
+
+// operation returns the operation of a document to execute
+func operation(doc *ast.Document, name string) (*ast.OperationDefinition, error) {
+	var op *ast.OperationDefinition
+	for _, def := range doc.Definitions {
+		candidate, ok := def.(*ast.OperationDefinition)
+		if !ok {
+			continue
+		}
+		if name == "" {
+			if op != nil {
+				return nil, newError(CodeBadUserInput, "Must provide operation name if query contains multiple operations")
+			}
+			op = candidate
+		} else if candidate.Name != nil && candidate.Name.Value == name {
+			op = candidate
+		}
+	}
+
+	if op == nil {
+		return nil, newError(CodeBadUserInput, "Unknown operation")
+	}
+	return op, nil
+}
+
+// ErrorResult returns a result made of a single error
+func ErrorResult(err error) *graphql.Result {
+	formatted := gqlerrors.FormattedError{
+		Message:   err.Error(),
+		Locations: []location.SourceLocation{},
+	}
+	var gqlErr *Error
+	if errors.As(err, &gqlErr) {
+		formatted.Extensions = gqlErr.Extensions()
+	}
+	return &graphql.Result{Errors: []gqlerrors.FormattedError{formatted}}
+}
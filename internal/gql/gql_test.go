@@ -0,0 +1,202 @@
+package gql
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/graphql-go/graphql"
+	"github.com/huberts90/restful-api/internal/cache"
+	"github.com/huberts90/restful-api/internal/domain"
+	"github.com/huberts90/restful-api/internal/logger"
+	"github.com/huberts90/restful-api/internal/storage"
+	storagemocks "github.com/huberts90/restful-api/internal/storage/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+var testConfig = Config{
+	MaxDepth:          5,
+	MaxComplexity:     100,
+	Timeout:           time.Second,
+	PersistedQueryTTL: time.Hour,
+}
+
+func setupTest(t *testing.T) (*storagemocks.MockStorer, *Server) {
+	t.Helper()
+
+	mockStore := storagemocks.NewMockStorer(t)
+	server, err := NewServer(mockStore, cache.NewLRU(10), testConfig, logger.NewNoOpLogger())
+	require.NoError(t, err)
+	return mockStore, server
+}
+
+// data returns the data of a result as JSON, after checking that it has no errors
+func data(t *testing.T, result *graphql.Result) string {
+	t.Helper()
+
+	require.Empty(t, result.Errors)
+	out, err := json.Marshal(result.Data)
+	require.NoError(t, err)
+	return string(out)
+}
+
+// errorCode returns the code of the only error of a result
+func errorCode(t *testing.T, result *graphql.Result) interface{} {
+	t.Helper()
+
+	require.Len(t, result.Errors, 1)
+	return result.Errors[0].Extensions["code"]
+}
+
+func TestUser_BatchesLookups(t *testing.T) {
+	mockStore, server := setupTest(t)
+
+	now := time.Date(2023, 1, 1, 12, 0, 0, 0, time.UTC)
+	mockStore.On("GetUsersByIDs", mock.Anything, []int64{1, 2}).Return([]domain.User{
+		{ID: 1, Email: "ann@example.com", FirstName: "Ann", LastName: "Lee", CreatedAt: now, UpdatedAt: now},
+	}, nil).Once()
+
+	result := server.Do(context.Background(), Request{
+		Query: `{ a: user(id: 1) { id email firstName createdAt deletedAt } b: user(id: 2) { id } c: user(id: 1) { lastName } }`,
+	}, true)
+
+	assert.JSONEq(t, `{
+		"a": {"id": "1", "email": "ann@example.com", "firstName": "Ann", "createdAt": "2023-01-01T12:00:00Z", "deletedAt": null},
+		"b": null,
+		"c": {"lastName": "Lee"}
+	}`, data(t, result))
+}
+
+func TestUsers_Connection(t *testing.T) {
+	mockStore, server := setupTest(t)
+
+	mockStore.On("ListUsersAfter", mock.Anything, int64(1), 2).Return(&storage.UserPage{
+		Users:      []domain.User{{ID: 2}, {ID: 3}},
+		TotalCount: 5,
+		HasMore:    true,
+	}, nil)
+
+	result := server.Do(context.Background(), Request{
+		Query:     `query Page($after: String) { users(first: 2, after: $after) { edges { cursor node { id } } pageInfo { hasNextPage hasPreviousPage endCursor } totalCount } }`,
+		Variables: map[string]interface{}{"after": encodeCursor(1)},
+	}, true)
+
+	assert.JSONEq(t, `{"users": {
+		"edges": [{"cursor": "`+encodeCursor(2)+`", "node": {"id": "2"}}, {"cursor": "`+encodeCursor(3)+`", "node": {"id": "3"}}],
+		"pageInfo": {"hasNextPage": true, "hasPreviousPage": true, "endCursor": "`+encodeCursor(3)+`"},
+		"totalCount": 5
+	}}`, data(t, result))
+}
+
+func TestMutations(t *testing.T) {
+	mockStore, server := setupTest(t)
+
+	userCreate := domain.UserCreate{Email: "ann@example.com", FirstName: "Ann", LastName: "Lee"}
+	mockStore.On("CreateUser", mock.Anything, userCreate).Return(int64(7), nil)
+	mockStore.On("GetUserByID", mock.Anything, int64(7)).Return(&domain.User{ID: 7, Email: userCreate.Email}, nil)
+	mockStore.On("UpdateUser", mock.Anything, int64(8), domain.UserUpdate{LastName: "Ray"}).Return(storage.ErrUserNotFound)
+
+	result := server.Do(context.Background(), Request{
+		Query: `mutation { createUser(input: {email: "ann@example.com", firstName: "Ann", lastName: "Lee"}) { id email } }`,
+	}, false)
+	assert.JSONEq(t, `{"createUser": {"id": "7", "email": "ann@example.com"}}`, data(t, result))
+
+	result = server.Do(context.Background(), Request{
+		Query: `mutation { updateUser(id: 8, input: {lastName: "Ray"}) { id } }`,
+	}, false)
+	assert.Equal(t, CodeNotFound, errorCode(t, result))
+
+	// The input is validated like the body of the REST API
+	result = server.Do(context.Background(), Request{
+		Query: `mutation { createUser(input: {email: "ann", firstName: "Ann", lastName: "Lee"}) { id } }`,
+	}, false)
+	assert.Equal(t, CodeBadUserInput, errorCode(t, result))
+
+	// Mutations are not run by read-only requests
+	result = server.Do(context.Background(), Request{
+		Query: `mutation { deleteUser(id: 7) }`,
+	}, true)
+	assert.Equal(t, CodeOperationNotAllowed, errorCode(t, result))
+}
+
+func TestLimits(t *testing.T) {
+	_, server := setupTest(t)
+
+	// Each fragment spreads the previous one twice, the query selects 2^40 fields
+	var fragments strings.Builder
+	fragments.WriteString(`fragment f0 on User { id }`)
+	for i := 1; i <= 40; i++ {
+		fmt.Fprintf(&fragments, ` fragment f%d on User { ...f%d ...f%d }`, i, i-1, i-1)
+	}
+
+	tests := []struct {
+		name        string
+		query       string
+		wantMessage string
+	}{
+		{
+			// Fields selected through fragments count as if they were inline
+			name:        "too deep",
+			query:       `{ users { ...page } } fragment page on UserConnection { edges { node { ...names } } } fragment names on User { id }`,
+			wantMessage: "Query depth 4 exceeds the maximum of 3",
+		},
+		{
+			name:        "too complex",
+			query:       `{ users(first: 50) { totalCount pageInfo { endCursor } } }`,
+			wantMessage: "Query complexity 151 exceeds the maximum of 100",
+		},
+		{
+			name:        "too complex through variables",
+			query:       `query Page($first: Int) { users(first: $first) { totalCount pageInfo { endCursor } } }`,
+			wantMessage: "Query complexity 151 exceeds the maximum of 100",
+		},
+		{
+			name:        "fragments spread exponentially",
+			query:       `{ user(id: 1) { ...f40 } } ` + fragments.String(),
+			wantMessage: "Query complexity 2147483647 exceeds the maximum of 100",
+		},
+	}
+
+	server.cfg.MaxDepth = 3
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := server.Do(context.Background(), Request{
+				Query:     tt.query,
+				Variables: map[string]interface{}{"first": float64(50)},
+			}, true)
+			assert.Equal(t, CodeQueryTooComplex, errorCode(t, result))
+			assert.Equal(t, tt.wantMessage, result.Errors[0].Message)
+		})
+	}
+}
+
+func TestPersistedQueries(t *testing.T) {
+	mockStore, server := setupTest(t)
+	mockStore.On("GetUsersByIDs", mock.Anything, []int64{1}).Return([]domain.User{{ID: 1}}, nil)
+
+	query := `{ user(id: 1) { id } }`
+	sum := sha256.Sum256([]byte(query))
+	extensions := RequestExtensions{PersistedQuery: &PersistedQuery{Version: 1, SHA256Hash: hex.EncodeToString(sum[:])}}
+
+	// The hash alone is not known yet
+	result := server.Do(context.Background(), Request{Extensions: extensions}, true)
+	assert.Equal(t, CodePersistedQueryNotFound, errorCode(t, result))
+
+	// The query is persisted along with its hash, and found by the hash afterwards
+	result = server.Do(context.Background(), Request{Query: query, Extensions: extensions}, true)
+	assert.JSONEq(t, `{"user": {"id": "1"}}`, data(t, result))
+
+	result = server.Do(context.Background(), Request{Extensions: extensions}, true)
+	assert.JSONEq(t, `{"user": {"id": "1"}}`, data(t, result))
+
+	// A hash that does not match the query is rejected
+	result = server.Do(context.Background(), Request{Query: `{ users { totalCount } }`, Extensions: extensions}, true)
+	assert.Equal(t, CodePersistedQueryInvalid, errorCode(t, result))
+}
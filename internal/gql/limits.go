@@ -0,0 +1,128 @@
+package gql
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/graphql-go/graphql/language/ast"
+)
+
+// maxCost bounds the complexity measured, so that sums and products of costs never overflow
+const maxCost = math.MaxInt32
+
+// limits measures how deep and how costly an operation is before it is executed
+// Every field costs 1, and the cost of the fields selected within a page is multiplied by its size
+type limits struct {
+	fragments map[string]*ast.FragmentDefinition
+	variables map[string]interface{}
+	visiting  map[string]bool
+	measured  map[string]measurement
+}
+
+// measurement is the depth and the complexity of a fragment
+type measurement struct {
+	depth      int
+	complexity int
+}
+
+// checkLimits rejects an operation nested deeper than maxDepth or costing more than maxComplexity
+// Introspection fields are not counted, so that tools can always read the schema
+func checkLimits(doc *ast.Document, op *ast.OperationDefinition, variables map[string]interface{}, maxDepth, maxComplexity int) error {
+	l := &limits{
+		fragments: make(map[string]*ast.FragmentDefinition),
+		variables: variables,
+		visiting:  make(map[string]bool),
+		measured:  make(map[string]measurement),
+	}
+	for _, def := range doc.Definitions {
+		if fragment, ok := def.(*ast.FragmentDefinition); ok {
+			l.fragments[fragment.Name.Value] = fragment
+		}
+	}
+
+	depth, complexity := l.measure(op.SelectionSet)
+	if depth > maxDepth {
+		return newError(CodeQueryTooComplex, fmt.Sprintf("Query depth %d exceeds the maximum of %d", depth, maxDepth))
+	}
+	if complexity > maxComplexity {
+		return newError(CodeQueryTooComplex, fmt.Sprintf("Query complexity %d exceeds the maximum of %d", complexity, maxComplexity))
+	}
+	return nil
+}
+
+// measure returns the depth and the complexity of a selection set
+// Fragments are measured once however often they are spread, otherwise fragments spreading
+// each other twice would take exponential time to measure
+func (l *limits) measure(set *ast.SelectionSet) (int, int) {
+	if set == nil {
+		return 0, 0
+	}
+
+	maxDepth, complexity := 0, 0
+	for _, selection := range set.Selections {
+		var depth, cost int
+		switch s := selection.(type) {
+		case *ast.Field:
+			if strings.HasPrefix(s.Name.Value, "__") {
+				continue
+			}
+			childDepth, childCost := l.measure(s.SelectionSet)
+			depth = childDepth + 1
+			cost = min(1+childCost*l.pageSize(s), maxCost)
+		case *ast.InlineFragment:
+			depth, cost = l.measure(s.SelectionSet)
+		case *ast.FragmentSpread:
+			if m, ok := l.measured[s.Name.Value]; ok {
+				depth, cost = m.depth, m.complexity
+				break
+			}
+			// Cycles are rejected by validation, the guard only keeps the walk finite
+			fragment := l.fragments[s.Name.Value]
+			if fragment == nil || l.visiting[s.Name.Value] {
+				continue
+			}
+			l.visiting[s.Name.Value] = true
+			depth, cost = l.measure(fragment.SelectionSet)
+			l.visiting[s.Name.Value] = false
+			l.measured[s.Name.Value] = measurement{depth: depth, complexity: cost}
+		}
+
+		maxDepth = max(maxDepth, depth)
+		complexity = min(complexity+cost, maxCost)
+	}
+	return maxDepth, complexity
+}
+
+// pageSize returns the number of items a field selects, 1 unless it is a page
+// Sizes above the maximum are rejected when the field is resolved, they count as the maximum
+func (l *limits) pageSize(field *ast.Field) int {
+	if field.Name.Value != "users" {
+		return 1
+	}
+
+	for _, arg := range field.Arguments {
+		if arg.Name.Value != "first" {
+			continue
+		}
+		switch v := arg.Value.(type) {
+		case *ast.IntValue:
+			if n, err := strconv.Atoi(v.Value); err == nil && n > 0 {
+				return min(n, maxPageSize)
+			}
+		case *ast.Variable:
+			switch n := l.variables[v.Name.Value].(type) {
+			case float64:
+				if n > 0 {
+					return int(min(n, maxPageSize))
+				}
+			case int:
+				if n > 0 {
+					return min(n, maxPageSize)
+				}
+			}
+		}
+	}
+	return defaultPageSize
+}
@@ -0,0 +1,115 @@
+package gql
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	"github.com/huberts90/restful-api/internal/domain"
+	"github.com/huberts90/restful-api/internal/storage"
+)
+
+// maxBatchSize bounds the IDs a loader reads in one query
+const maxBatchSize = 100
+
+type loaderKey struct{}
+
+// userLoader batches the lookups of users by ID made while a request is resolved
+// Resolvers register the IDs they need and return thunks, the executor then calls the thunks
+// and the first one reads every registered ID at once. Users read are kept for the rest of the request
+type userLoader struct {
+	store storage.Storer
+
+	mu      sync.Mutex
+	pending map[bool]*userBatch // batches not read yet, by whether they include deleted users
+	loaded  map[userKey]*userBatch
+}
+
+type userKey struct {
+	id             int64
+	includeDeleted bool
+}
+
+// userBatch is a set of IDs read in a single query
+type userBatch struct {
+	ids            []int64
+	includeDeleted bool
+	once           sync.Once
+	users          map[int64]*domain.User
+	err            error
+}
+
+func newUserLoader(store storage.Storer) *userLoader {
+	return &userLoader{
+		store:   store,
+		pending: make(map[bool]*userBatch),
+		loaded:  make(map[userKey]*userBatch),
+	}
+}
+
+// withLoader stores a new loader in the context of a request
+func withLoader(ctx context.Context, store storage.Storer) context.Context {
+	return context.WithValue(ctx, loaderKey{}, newUserLoader(store))
+}
+
+func loaderFromContext(ctx context.Context) *userLoader {
+	return ctx.Value(loaderKey{}).(*userLoader)
+}
+
+// Load registers the ID in the pending batch and returns a function reading the batch
+// The user is nil if it does not exist
+func (l *userLoader) Load(ctx context.Context, id int64, includeDeleted bool) func() (*domain.User, error) {
+	key := userKey{id: id, includeDeleted: includeDeleted}
+
+	l.mu.Lock()
+	b, ok := l.loaded[key]
+	if !ok {
+		b = l.pending[includeDeleted]
+		if b == nil || len(b.ids) >= maxBatchSize {
+			b = &userBatch{includeDeleted: includeDeleted}
+			l.pending[includeDeleted] = b
+		}
+		b.ids = append(b.ids, id)
+		l.loaded[key] = b
+	}
+	l.mu.Unlock()
+
+	return func() (*domain.User, error) {
+		l.read(ctx, b)
+		if b.err != nil {
+			return nil, b.err
+		}
+		return b.users[id], nil
+	}
+}
+
+// read reads the users of a batch, once
+func (l *userLoader) read(ctx context.Context, b *userBatch) {
+	b.once.Do(func() {
+		// IDs registered from now on go into a new batch
+		l.mu.Lock()
+		if l.pending[b.includeDeleted] == b {
+			delete(l.pending, b.includeDeleted)
+		}
+		l.mu.Unlock()
+
+		// The executor resolves fields in no particular order, sorting keeps the queries of a request stable
+		sort.Slice(b.ids, func(i, j int) bool { return b.ids[i] < b.ids[j] })
+
+		var opts []storage.ReadOption
+		if b.includeDeleted {
+			opts = append(opts, storage.IncludeDeleted())
+		}
+
+		users, err := l.store.GetUsersByIDs(ctx, b.ids, opts...)
+		if err != nil {
+			b.err = err
+			return
+		}
+
+		b.users = make(map[int64]*domain.User, len(users))
+		for i := range users {
+			b.users[users[i].ID] = &users[i]
+		}
+	})
+}
@@ -0,0 +1,464 @@
+package gql
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/graphql-go/graphql"
+	"github.com/huberts90/restful-api/internal/domain"
+	"github.com/huberts90/restful-api/internal/storage"
+	"go.uber.org/zap"
+)
+
+// Pages of the users connection hold up to maxPageSize users
+const (
+	defaultPageSize = 10
+	maxPageSize     = 100
+)
+
+// cursorPrefix is prepended to user IDs before they are encoded into opaque cursors
+const cursorPrefix = "user:"
+
+var (
+	timeType    = reflect.TypeOf(time.Time{})
+	timePtrType = reflect.TypeOf(&time.Time{})
+)
+
+// resolver resolves the fields of the schema against the store
+type resolver struct {
+	store  storage.Storer
+	logger *zap.Logger
+}
+
+// newSchema builds the schema, the user types are generated from the domain types
+// so that they follow the REST API as it evolves
+func newSchema(store storage.Storer, logger *zap.Logger) (graphql.Schema, error) {
+	r := &resolver{store: store, logger: logger}
+
+	userFields, err := objectFields(reflect.TypeOf(domain.UserResponse{}))
+	if err != nil {
+		return graphql.Schema{}, err
+	}
+	createFields, err := inputFields(reflect.TypeOf(domain.UserCreate{}))
+	if err != nil {
+		return graphql.Schema{}, err
+	}
+	updateFields, err := inputFields(reflect.TypeOf(domain.UserUpdate{}))
+	if err != nil {
+		return graphql.Schema{}, err
+	}
+
+	user := graphql.NewObject(graphql.ObjectConfig{
+		Name:        "User",
+		Description: "A user of the system",
+		Fields:      userFields,
+	})
+	pageInfo := graphql.NewObject(graphql.ObjectConfig{
+		Name: "PageInfo",
+		Fields: graphql.Fields{
+			"hasNextPage":     {Type: graphql.NewNonNull(graphql.Boolean)},
+			"hasPreviousPage": {Type: graphql.NewNonNull(graphql.Boolean)},
+			"startCursor":     {Type: graphql.String},
+			"endCursor":       {Type: graphql.String},
+		},
+	})
+	userEdge := graphql.NewObject(graphql.ObjectConfig{
+		Name: "UserEdge",
+		Fields: graphql.Fields{
+			"cursor": {Type: graphql.NewNonNull(graphql.String)},
+			"node":   {Type: graphql.NewNonNull(user)},
+		},
+	})
+	userConnection := graphql.NewObject(graphql.ObjectConfig{
+		Name: "UserConnection",
+		Fields: graphql.Fields{
+			"edges":      {Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(userEdge)))},
+			"pageInfo":   {Type: graphql.NewNonNull(pageInfo)},
+			"totalCount": {Type: graphql.NewNonNull(graphql.Int)},
+		},
+	})
+	userCreateInput := graphql.NewInputObject(graphql.InputObjectConfig{
+		Name:   "UserCreateInput",
+		Fields: createFields,
+	})
+	userUpdateInput := graphql.NewInputObject(graphql.InputObjectConfig{
+		Name:        "UserUpdateInput",
+		Description: "Fields left out are not changed",
+		Fields:      updateFields,
+	})
+
+	idArg := &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.ID)}
+	includeDeletedArg := &graphql.ArgumentConfig{Type: graphql.Boolean, DefaultValue: false}
+
+	query := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Query",
+		Fields: graphql.Fields{
+			"user": {
+				Type:        user,
+				Description: "A user by ID, null if it does not exist",
+				Args:        graphql.FieldConfigArgument{"id": idArg, "includeDeleted": includeDeletedArg},
+				Resolve:     r.user,
+			},
+			"users": {
+				Type:        graphql.NewNonNull(userConnection),
+				Description: "Users in ID order, a page at a time",
+				Args: graphql.FieldConfigArgument{
+					"first":          {Type: graphql.Int, DefaultValue: defaultPageSize},
+					"after":          {Type: graphql.String},
+					"includeDeleted": includeDeletedArg,
+				},
+				Resolve: r.users,
+			},
+		},
+	})
+	mutation := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Mutation",
+		Fields: graphql.Fields{
+			"createUser": {
+				Type:    graphql.NewNonNull(user),
+				Args:    graphql.FieldConfigArgument{"input": {Type: graphql.NewNonNull(userCreateInput)}},
+				Resolve: r.createUser,
+			},
+			"updateUser": {
+				Type:    graphql.NewNonNull(user),
+				Args:    graphql.FieldConfigArgument{"id": idArg, "input": {Type: graphql.NewNonNull(userUpdateInput)}},
+				Resolve: r.updateUser,
+			},
+			"deleteUser": {
+				Type:        graphql.NewNonNull(graphql.ID),
+				Description: "Soft deletes a user and returns its ID",
+				Args:        graphql.FieldConfigArgument{"id": idArg},
+				Resolve:     r.deleteUser,
+			},
+			"restoreUser": {
+				Type:    graphql.NewNonNull(user),
+				Args:    graphql.FieldConfigArgument{"id": idArg},
+				Resolve: r.restoreUser,
+			},
+		},
+	})
+
+	return graphql.NewSchema(graphql.SchemaConfig{Query: query, Mutation: mutation})
+}
+
+// connection is a page of the users connection
+type connection struct {
+	Edges      []edge   `json:"edges"`
+	PageInfo   pageInfo `json:"pageInfo"`
+	TotalCount int      `json:"totalCount"`
+}
+
+type edge struct {
+	Cursor string              `json:"cursor"`
+	Node   domain.UserResponse `json:"node"`
+}
+
+type pageInfo struct {
+	HasNextPage     bool    `json:"hasNextPage"`
+	HasPreviousPage bool    `json:"hasPreviousPage"`
+	StartCursor     *string `json:"startCursor"`
+	EndCursor       *string `json:"endCursor"`
+}
+
+// user resolves a user by ID through the loader, so that the users of a request are read at once
+func (r *resolver) user(p graphql.ResolveParams) (interface{}, error) {
+	id, err := parseID(p.Args["id"])
+	if err != nil {
+		return nil, err
+	}
+	includeDeleted, _ := p.Args["includeDeleted"].(bool)
+
+	load := loaderFromContext(p.Context).Load(p.Context, id, includeDeleted)
+	return func() (interface{}, error) {
+		user, err := load()
+		if err != nil {
+			return nil, r.fail(err, "Failed to get user", zap.Int64("id", id))
+		}
+		if user == nil {
+			return nil, nil
+		}
+		return user.ToResponse(), nil
+	}, nil
+}
+
+// users resolves a page of the users connection, cursors are the IDs of the users
+func (r *resolver) users(p graphql.ResolveParams) (interface{}, error) {
+	first, _ := p.Args["first"].(int)
+	if first < 1 || first > maxPageSize {
+		return nil, newError(CodeBadUserInput, fmt.Sprintf("first must be between 1 and %d", maxPageSize))
+	}
+
+	var afterID int64
+	if after, ok := p.Args["after"].(string); ok {
+		var err error
+		if afterID, err = decodeCursor(after); err != nil {
+			return nil, err
+		}
+	}
+
+	var opts []storage.ReadOption
+	if includeDeleted, _ := p.Args["includeDeleted"].(bool); includeDeleted {
+		opts = append(opts, storage.IncludeDeleted())
+	}
+
+	page, err := r.store.ListUsersAfter(p.Context, afterID, first, opts...)
+	if err != nil {
+		return nil, r.fail(err, "Failed to list users")
+	}
+
+	conn := connection{
+		Edges:      make([]edge, len(page.Users)),
+		TotalCount: page.TotalCount,
+		PageInfo: pageInfo{
+			HasNextPage:     page.HasMore,
+			HasPreviousPage: afterID > 0,
+		},
+	}
+	for i, user := range page.Users {
+		conn.Edges[i] = edge{Cursor: encodeCursor(user.ID), Node: user.ToResponse()}
+	}
+	if len(conn.Edges) > 0 {
+		conn.PageInfo.StartCursor = &conn.Edges[0].Cursor
+		conn.PageInfo.EndCursor = &conn.Edges[len(conn.Edges)-1].Cursor
+	}
+
+	return conn, nil
+}
+
+// createUser creates a user and returns it
+func (r *resolver) createUser(p graphql.ResolveParams) (interface{}, error) {
+	var userCreate domain.UserCreate
+	if err := decodeInput(p.Args["input"], &userCreate); err != nil {
+		return nil, err
+	}
+	if err := userCreate.Validate(); err != nil {
+		return nil, newError(CodeBadUserInput, err.Error())
+	}
+
+	id, err := r.store.CreateUser(p.Context, userCreate)
+	if err != nil {
+		return nil, r.fail(err, "Failed to create user", zap.String("email", userCreate.Email))
+	}
+
+	return r.reload(p.Context, id)
+}
+
+// updateUser updates the fields of a user that are given and returns it
+func (r *resolver) updateUser(p graphql.ResolveParams) (interface{}, error) {
+	id, err := parseID(p.Args["id"])
+	if err != nil {
+		return nil, err
+	}
+
+	var userUpdate domain.UserUpdate
+	if err := decodeInput(p.Args["input"], &userUpdate); err != nil {
+		return nil, err
+	}
+	if err := userUpdate.Validate(); err != nil {
+		return nil, newError(CodeBadUserInput, err.Error())
+	}
+
+	if err := r.store.UpdateUser(p.Context, id, userUpdate); err != nil {
+		return nil, r.fail(err, "Failed to update user", zap.Int64("id", id))
+	}
+
+	return r.reload(p.Context, id)
+}
+
+// deleteUser soft deletes a user
+func (r *resolver) deleteUser(p graphql.ResolveParams) (interface{}, error) {
+	id, err := parseID(p.Args["id"])
+	if err != nil {
+		return nil, err
+	}
+
+	if err := r.store.DeleteUser(p.Context, id); err != nil {
+		return nil, r.fail(err, "Failed to delete user", zap.Int64("id", id))
+	}
+
+	return strconv.FormatInt(id, 10), nil
+}
+
+// restoreUser brings back a soft deleted user and returns it
+func (r *resolver) restoreUser(p graphql.ResolveParams) (interface{}, error) {
+	id, err := parseID(p.Args["id"])
+	if err != nil {
+		return nil, err
+	}
+
+	if err := r.store.RestoreUser(p.Context, id); err != nil {
+		return nil, r.fail(err, "Failed to restore user", zap.Int64("id", id))
+	}
+
+	return r.reload(p.Context, id)
+}
+
+// reload reads a user back after a mutation
+func (r *resolver) reload(ctx context.Context, id int64) (interface{}, error) {
+	user, err := r.store.GetUserByID(ctx, id)
+	if err != nil {
+		return nil, r.fail(err, "Failed to get user", zap.Int64("id", id))
+	}
+	return user.ToResponse(), nil
+}
+
+// fail maps an error of the store to an error shown to clients and logs the unexpected ones
+func (r *resolver) fail(err error, message string, fields ...zap.Field) error {
+	gqlErr := storeError(err, message)
+	if gqlErr.Code == CodeInternal {
+		r.logger.Error(message, append(fields, zap.Error(err))...)
+	}
+	return gqlErr
+}
+
+// parseID parses the ID of a user given as an argument
+func parseID(arg interface{}) (int64, error) {
+	s, _ := arg.(string)
+	id, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || id <= 0 {
+		return 0, newError(CodeBadUserInput, "Invalid user ID")
+	}
+	return id, nil
+}
+
+func encodeCursor(id int64) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(cursorPrefix + strconv.FormatInt(id, 10)))
+}
+
+func decodeCursor(cursor string) (int64, error) {
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err == nil {
+		if s, ok := strings.CutPrefix(string(data), cursorPrefix); ok {
+			if id, err := strconv.ParseInt(s, 10, 64); err == nil && id > 0 {
+				return id, nil
+			}
+		}
+	}
+	return 0, newError(CodeBadUserInput, "Invalid cursor")
+}
+
+// fieldName returns the GraphQL name of a struct field, the camel case form of its JSON name
+// Fields without a JSON name are left out
+func fieldName(f reflect.StructField) string {
+	name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+	// Hypermedia fields such as _links only make sense in the REST API
+	if name == "" || name == "-" || strings.HasPrefix(name, "_") {
+		return ""
+	}
+
+	parts := strings.Split(name, "_")
+	for i := 1; i < len(parts); i++ {
+		if parts[i] != "" {
+			parts[i] = strings.ToUpper(parts[i][:1]) + parts[i][1:]
+		}
+	}
+	return strings.Join(parts, "")
+}
+
+// scalarType returns the GraphQL type of a struct field, ID fields are IDs and pointers are nullable
+func scalarType(f reflect.StructField) (graphql.Type, bool, error) {
+	if f.Name == "ID" {
+		return graphql.ID, false, nil
+	}
+
+	switch f.Type {
+	case timeType:
+		return graphql.DateTime, false, nil
+	case timePtrType:
+		return graphql.DateTime, true, nil
+	}
+
+	switch f.Type.Kind() {
+	case reflect.String:
+		return graphql.String, false, nil
+	case reflect.Bool:
+		return graphql.Boolean, false, nil
+	case reflect.Int, reflect.Int32, reflect.Int64:
+		return graphql.Int, false, nil
+	default:
+		return nil, false, fmt.Errorf("unsupported type %v of field %v", f.Type, f.Name)
+	}
+}
+
+// objectFields generates the fields of an object type from a domain type
+func objectFields(t reflect.Type) (graphql.Fields, error) {
+	fields := graphql.Fields{}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name := fieldName(f)
+		if name == "" {
+			continue
+		}
+
+		typ, nullable, err := scalarType(f)
+		if err != nil {
+			return nil, err
+		}
+		if !nullable {
+			typ = graphql.NewNonNull(typ)
+		}
+
+		index := i
+		fields[name] = &graphql.Field{
+			Type: typ.(graphql.Output),
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				return reflect.Indirect(reflect.ValueOf(p.Source)).Field(index).Interface(), nil
+			},
+		}
+	}
+	return fields, nil
+}
+
+// inputFields generates the fields of an input type from a domain type
+// Fields the domain type requires are non-null
+func inputFields(t reflect.Type) (graphql.InputObjectConfigFieldMap, error) {
+	fields := graphql.InputObjectConfigFieldMap{}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name := fieldName(f)
+		if name == "" {
+			continue
+		}
+
+		typ, _, err := scalarType(f)
+		if err != nil {
+			return nil, err
+		}
+		if strings.Contains(f.Tag.Get("validate"), "required") {
+			typ = graphql.NewNonNull(typ)
+		}
+
+		fields[name] = &graphql.InputObjectFieldConfig{Type: typ.(graphql.Input)}
+	}
+	return fields, nil
+}
+
+// decodeInput decodes an input object into the domain type it was generated from
+func decodeInput(arg interface{}, dst interface{}) error {
+	input, _ := arg.(map[string]interface{})
+
+	t := reflect.TypeOf(dst).Elem()
+	values := make(map[string]interface{}, len(input))
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if v, ok := input[fieldName(f)]; ok && v != nil {
+			jsonName, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+			values[jsonName] = v
+		}
+	}
+
+	data, err := json.Marshal(values)
+	if err == nil {
+		err = json.Unmarshal(data, dst)
+	}
+	if err != nil {
+		return newError(CodeBadUserInput, "Invalid input")
+	}
+	return nil
+}
@@ -0,0 +1,201 @@
+package grpcserver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"runtime/debug"
+	"time"
+
+	"github.com/google/uuid"
+	usersv1 "github.com/huberts90/restful-api/gen/users/v1"
+	"github.com/huberts90/restful-api/internal/storage"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
+)
+
+// requestIDMetadata is the metadata key used to propagate the request ID, like the X-Request-ID header
+const requestIDMetadata = "x-request-id"
+
+// Config holds the configuration of the gRPC server
+type Config struct {
+	Port        int           // 0 disables the gRPC server
+	ListTimeout time.Duration // time to stream every user
+}
+
+// Server serves the gRPC API along with the health and reflection services
+type Server struct {
+	server *grpc.Server
+	health *health.Server
+	cfg    Config
+	logger *zap.Logger
+}
+
+// NewServer creates a gRPC server with the user service backed by the store
+func NewServer(store storage.Storer, cfg Config, logger *zap.Logger) *Server {
+	server := grpc.NewServer(
+		grpc.ChainUnaryInterceptor(unaryInterceptor(logger)),
+		grpc.ChainStreamInterceptor(streamInterceptor(logger)),
+	)
+	usersv1.RegisterUserServiceServer(server, NewUserServer(store, cfg, logger))
+
+	healthServer := health.NewServer()
+	healthServer.SetServingStatus(usersv1.UserService_ServiceDesc.ServiceName, healthpb.HealthCheckResponse_SERVING)
+	healthpb.RegisterHealthServer(server, healthServer)
+	reflection.Register(server)
+
+	return &Server{
+		server: server,
+		health: healthServer,
+		cfg:    cfg,
+		logger: logger,
+	}
+}
+
+// ListenAndServe listens on the configured port and serves until the server is shut down
+func (s *Server) ListenAndServe() error {
+	lis, err := net.Listen("tcp", fmt.Sprintf(":%d", s.cfg.Port))
+	if err != nil {
+		return fmt.Errorf("failed to listen: %w", err)
+	}
+	return s.Serve(lis)
+}
+
+// Serve serves connections accepted by the listener until the server is shut down
+func (s *Server) Serve(lis net.Listener) error {
+	if err := s.server.Serve(lis); err != nil && !errors.Is(err, grpc.ErrServerStopped) {
+		return err
+	}
+	return nil
+}
+
+// Shutdown reports the services as not serving and waits for the calls in flight to finish
+// Calls still running when the context is done, such as long streams, are canceled
+func (s *Server) Shutdown(ctx context.Context) {
+	s.health.Shutdown()
+
+	done := make(chan struct{})
+	go func() {
+		s.server.GracefulStop()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		s.server.Stop()
+		<-done
+	}
+}
+
+// unaryInterceptor gives unary calls what the HTTP middlewares give requests
+func unaryInterceptor(logger *zap.Logger) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp interface{}, err error) {
+		ctx, requestID := callContext(ctx)
+		start := time.Now()
+		defer func() {
+			if p := recover(); p != nil {
+				err = recovered(logger, p, info.FullMethod, requestID)
+			}
+			logCall(logger, info.FullMethod, requestID, start, err)
+		}()
+
+		return handler(ctx, req)
+	}
+}
+
+// streamInterceptor gives streaming calls what the HTTP middlewares give requests
+func streamInterceptor(logger *zap.Logger) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) (err error) {
+		ctx, requestID := callContext(ss.Context())
+		start := time.Now()
+		defer func() {
+			if p := recover(); p != nil {
+				err = recovered(logger, p, info.FullMethod, requestID)
+			}
+			logCall(logger, info.FullMethod, requestID, start, err)
+		}()
+
+		return handler(srv, &serverStream{ServerStream: ss, ctx: ctx})
+	}
+}
+
+// serverStream overrides the context of a stream
+type serverStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *serverStream) Context() context.Context {
+	return s.ctx
+}
+
+// callContext assigns a request ID to a call and identifies its client to the storage layer,
+// so that reads follow the writes of the client and mutations are audited
+// An incoming request ID is reused so that logs can be aggregated across services
+func callContext(ctx context.Context) (context.Context, string) {
+	var requestID string
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get(requestIDMetadata); len(values) > 0 && validRequestID(values[0]) {
+			requestID = values[0]
+		}
+	}
+	if requestID == "" {
+		requestID = uuid.NewString()
+	}
+	_ = grpc.SetHeader(ctx, metadata.Pairs(requestIDMetadata, requestID))
+
+	client := "ip:unknown"
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		host, _, err := net.SplitHostPort(p.Addr.String())
+		if err != nil {
+			host = p.Addr.String()
+		}
+		client = "ip:" + host
+	}
+
+	ctx = storage.WithClient(ctx, client)
+	ctx = storage.WithAuditInfo(ctx, storage.AuditInfo{Actor: client, RequestID: requestID})
+	return ctx, requestID
+}
+
+// validRequestID reports whether a client supplied request ID is safe to log and echo back
+func validRequestID(id string) bool {
+	if id == "" || len(id) > 128 {
+		return false
+	}
+	for _, c := range id {
+		if c < '!' || c > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+// recovered logs a panic of a call and turns it into an Internal status
+func recovered(logger *zap.Logger, p interface{}, method, requestID string) error {
+	logger.Error("panic recovered",
+		zap.Any("panic", p),
+		zap.String("request_id", requestID),
+		zap.String("method", method),
+		zap.ByteString("stack", debug.Stack()),
+	)
+	return status.Error(codes.Internal, "Internal server error")
+}
+
+func logCall(logger *zap.Logger, method, requestID string, start time.Time, err error) {
+	logger.Info("gRPC call",
+		zap.String("request_id", requestID),
+		zap.String("method", method),
+		zap.String("code", status.Code(err).String()),
+		zap.Duration("duration", time.Since(start)),
+	)
+}
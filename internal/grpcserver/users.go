@@ -0,0 +1,180 @@
+package grpcserver
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	usersv1 "github.com/huberts90/restful-api/gen/users/v1"
+	"github.com/huberts90/restful-api/internal/domain"
+	"github.com/huberts90/restful-api/internal/storage"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// UserServer implements the user service on top of the store
+// Requests are validated by the same domain types as the REST API
+type UserServer struct {
+	usersv1.UnimplementedUserServiceServer
+	store  storage.Storer
+	cfg    Config
+	logger *zap.Logger
+}
+
+// NewUserServer creates a new UserServer with the given dependencies
+func NewUserServer(store storage.Storer, cfg Config, logger *zap.Logger) *UserServer {
+	return &UserServer{
+		store:  store,
+		cfg:    cfg,
+		logger: logger,
+	}
+}
+
+// CreateUser creates a new user
+func (s *UserServer) CreateUser(ctx context.Context, req *usersv1.CreateUserRequest) (*usersv1.CreateUserResponse, error) {
+	userCreate := domain.UserCreate{
+		Email:     req.GetEmail(),
+		FirstName: req.GetFirstName(),
+		LastName:  req.GetLastName(),
+	}
+	if err := userCreate.Validate(); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 500*time.Millisecond)
+	defer cancel()
+
+	userID, err := s.store.CreateUser(ctx, userCreate)
+	if err != nil {
+		return nil, s.storeError(err, "Failed to create user", zap.String("email", userCreate.Email))
+	}
+
+	return &usersv1.CreateUserResponse{Id: userID}, nil
+}
+
+// GetUser retrieves a user by ID
+func (s *UserServer) GetUser(ctx context.Context, req *usersv1.GetUserRequest) (*usersv1.GetUserResponse, error) {
+	if req.GetId() <= 0 {
+		return nil, status.Error(codes.InvalidArgument, "Invalid user ID")
+	}
+
+	var opts []storage.ReadOption
+	if req.GetIncludeDeleted() {
+		opts = append(opts, storage.IncludeDeleted())
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 300*time.Millisecond)
+	defer cancel()
+
+	user, err := s.store.GetUserByID(ctx, req.GetId(), opts...)
+	if err != nil {
+		return nil, s.storeError(err, "Failed to get user", zap.Int64("id", req.GetId()))
+	}
+
+	return &usersv1.GetUserResponse{User: toProto(user)}, nil
+}
+
+// UpdateUser updates the fields of a user that are set
+func (s *UserServer) UpdateUser(ctx context.Context, req *usersv1.UpdateUserRequest) (*usersv1.UpdateUserResponse, error) {
+	if req.GetId() <= 0 {
+		return nil, status.Error(codes.InvalidArgument, "Invalid user ID")
+	}
+
+	userUpdate := domain.UserUpdate{
+		Email:     req.GetEmail(),
+		FirstName: req.GetFirstName(),
+		LastName:  req.GetLastName(),
+	}
+	if err := userUpdate.Validate(); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 500*time.Millisecond)
+	defer cancel()
+
+	if err := s.store.UpdateUser(ctx, req.GetId(), userUpdate); err != nil {
+		return nil, s.storeError(err, "Failed to update user", zap.Int64("id", req.GetId()))
+	}
+
+	return &usersv1.UpdateUserResponse{}, nil
+}
+
+// DeleteUser soft deletes a user by ID
+func (s *UserServer) DeleteUser(ctx context.Context, req *usersv1.DeleteUserRequest) (*usersv1.DeleteUserResponse, error) {
+	if req.GetId() <= 0 {
+		return nil, status.Error(codes.InvalidArgument, "Invalid user ID")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 300*time.Millisecond)
+	defer cancel()
+
+	if err := s.store.DeleteUser(ctx, req.GetId()); err != nil {
+		return nil, s.storeError(err, "Failed to delete user", zap.Int64("id", req.GetId()))
+	}
+
+	return &usersv1.DeleteUserResponse{}, nil
+}
+
+// ListUsers streams every user in ID order
+// Users are read like an export, so the stream is a consistent snapshot however long it takes
+func (s *UserServer) ListUsers(req *usersv1.ListUsersRequest, stream usersv1.UserService_ListUsersServer) error {
+	var opts []storage.ReadOption
+	if req.GetIncludeDeleted() {
+		opts = append(opts, storage.IncludeDeleted())
+	}
+
+	ctx, cancel := context.WithTimeout(stream.Context(), s.cfg.ListTimeout)
+	defer cancel()
+
+	// Errors of the stream are returned as they are, they already carry a status
+	var sendErr error
+	err := s.store.ExportUsers(ctx, func(user domain.User) error {
+		sendErr = stream.Send(&usersv1.ListUsersResponse{User: toProto(&user)})
+		return sendErr
+	}, opts...)
+	if err != nil {
+		if sendErr != nil {
+			return sendErr
+		}
+		return s.storeError(err, "Failed to list users")
+	}
+
+	return nil
+}
+
+// storeError maps an error of the store to a status, the message of unexpected errors is not leaked
+func (s *UserServer) storeError(err error, message string, fields ...zap.Field) error {
+	switch {
+	case errors.Is(err, storage.ErrUserNotFound):
+		return status.Error(codes.NotFound, "User not found")
+	case errors.Is(err, storage.ErrDuplicateEmail):
+		return status.Error(codes.AlreadyExists, "Email already exists")
+	case errors.Is(err, storage.ErrCircuitOpen):
+		return status.Error(codes.Unavailable, message)
+	case errors.Is(err, context.DeadlineExceeded):
+		return status.Error(codes.DeadlineExceeded, message)
+	case errors.Is(err, context.Canceled):
+		return status.Error(codes.Canceled, message)
+	}
+
+	s.logger.Error(message, append(fields, zap.Error(err))...)
+	return status.Error(codes.Internal, message)
+}
+
+// toProto converts a User to its protobuf message
+func toProto(u *domain.User) *usersv1.User {
+	user := &usersv1.User{
+		Id:        u.ID,
+		Email:     u.Email,
+		FirstName: u.FirstName,
+		LastName:  u.LastName,
+		CreatedAt: timestamppb.New(u.CreatedAt),
+		UpdatedAt: timestamppb.New(u.UpdatedAt),
+	}
+	if u.DeletedAt != nil {
+		user.DeletedAt = timestamppb.New(*u.DeletedAt)
+	}
+	return user
+}
@@ -0,0 +1,215 @@
+package grpcserver
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	usersv1 "github.com/huberts90/restful-api/gen/users/v1"
+	"github.com/huberts90/restful-api/internal/domain"
+	"github.com/huberts90/restful-api/internal/logger"
+	"github.com/huberts90/restful-api/internal/storage"
+	storagemocks "github.com/huberts90/restful-api/internal/storage/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// setupTest serves the gRPC API over an in-memory connection and returns a client connected to it
+func setupTest(t *testing.T) (*storagemocks.MockStorer, *grpc.ClientConn) {
+	t.Helper()
+
+	mockStore := storagemocks.NewMockStorer(t)
+	server := NewServer(mockStore, Config{ListTimeout: time.Minute}, logger.NewNoOpLogger())
+
+	lis := bufconn.Listen(1 << 20)
+	go func() {
+		_ = server.Serve(lis)
+	}()
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	require.NoError(t, err)
+
+	t.Cleanup(func() {
+		_ = conn.Close()
+		server.Shutdown(context.Background())
+	})
+	return mockStore, conn
+}
+
+func TestCreateUser(t *testing.T) {
+	mockStore, conn := setupTest(t)
+	client := usersv1.NewUserServiceClient(conn)
+
+	userCreate := domain.UserCreate{Email: "john.doe@example.com", FirstName: "John", LastName: "Doe"}
+	mockStore.On("CreateUser", mock.Anything, userCreate).Return(int64(1), nil)
+
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "x-request-id", "req-1")
+	var header metadata.MD
+	resp, err := client.CreateUser(ctx, &usersv1.CreateUserRequest{
+		Email:     userCreate.Email,
+		FirstName: userCreate.FirstName,
+		LastName:  userCreate.LastName,
+	}, grpc.Header(&header))
+
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), resp.GetId())
+	// The request ID of the client is echoed back
+	assert.Equal(t, []string{"req-1"}, header.Get("x-request-id"))
+}
+
+func TestUserService_Errors(t *testing.T) {
+	mockStore, conn := setupTest(t)
+	client := usersv1.NewUserServiceClient(conn)
+
+	mockStore.On("CreateUser", mock.Anything, mock.Anything).Return(int64(0), storage.ErrDuplicateEmail)
+	mockStore.On("GetUserByID", mock.Anything, int64(404)).Return(nil, storage.ErrUserNotFound)
+	mockStore.On("UpdateUser", mock.Anything, int64(7), mock.Anything).Return(storage.ErrCircuitOpen)
+	mockStore.On("DeleteUser", mock.Anything, int64(7)).Return(errors.New("connection reset"))
+
+	tests := []struct {
+		name     string
+		call     func() error
+		wantCode codes.Code
+	}{
+		{
+			name: "invalid email",
+			call: func() error {
+				_, err := client.CreateUser(context.Background(), &usersv1.CreateUserRequest{Email: "john", FirstName: "John", LastName: "Doe"})
+				return err
+			},
+			wantCode: codes.InvalidArgument,
+		},
+		{
+			name: "duplicate email",
+			call: func() error {
+				_, err := client.CreateUser(context.Background(), &usersv1.CreateUserRequest{Email: "john@example.com", FirstName: "John", LastName: "Doe"})
+				return err
+			},
+			wantCode: codes.AlreadyExists,
+		},
+		{
+			name: "invalid ID",
+			call: func() error {
+				_, err := client.GetUser(context.Background(), &usersv1.GetUserRequest{})
+				return err
+			},
+			wantCode: codes.InvalidArgument,
+		},
+		{
+			name: "user not found",
+			call: func() error {
+				_, err := client.GetUser(context.Background(), &usersv1.GetUserRequest{Id: 404})
+				return err
+			},
+			wantCode: codes.NotFound,
+		},
+		{
+			name: "invalid update",
+			call: func() error {
+				_, err := client.UpdateUser(context.Background(), &usersv1.UpdateUserRequest{Id: 7, FirstName: "J"})
+				return err
+			},
+			wantCode: codes.InvalidArgument,
+		},
+		{
+			name: "circuit open",
+			call: func() error {
+				_, err := client.UpdateUser(context.Background(), &usersv1.UpdateUserRequest{Id: 7, FirstName: "Johnny"})
+				return err
+			},
+			wantCode: codes.Unavailable,
+		},
+		{
+			name: "internal error",
+			call: func() error {
+				_, err := client.DeleteUser(context.Background(), &usersv1.DeleteUserRequest{Id: 7})
+				return err
+			},
+			wantCode: codes.Internal,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.call()
+			assert.Equal(t, tt.wantCode, status.Code(err))
+		})
+	}
+}
+
+func TestGetUser(t *testing.T) {
+	mockStore, conn := setupTest(t)
+	client := usersv1.NewUserServiceClient(conn)
+
+	now := time.Date(2023, 1, 1, 12, 0, 0, 0, time.UTC)
+	mockStore.On("GetUserByID", mock.Anything, int64(1), mock.Anything).Return(&domain.User{
+		ID:        1,
+		Email:     "john.doe@example.com",
+		FirstName: "John",
+		LastName:  "Doe",
+		CreatedAt: now,
+		UpdatedAt: now,
+		DeletedAt: &now,
+	}, nil)
+
+	resp, err := client.GetUser(context.Background(), &usersv1.GetUserRequest{Id: 1, IncludeDeleted: true})
+
+	require.NoError(t, err)
+	assert.Equal(t, "john.doe@example.com", resp.GetUser().GetEmail())
+	assert.Equal(t, now, resp.GetUser().GetCreatedAt().AsTime())
+	assert.Equal(t, now, resp.GetUser().GetDeletedAt().AsTime())
+}
+
+func TestListUsers(t *testing.T) {
+	mockStore, conn := setupTest(t)
+	client := usersv1.NewUserServiceClient(conn)
+
+	mockStore.On("ExportUsers", mock.Anything, mock.Anything).
+		Run(func(args mock.Arguments) {
+			fn := args.Get(1).(func(domain.User) error)
+			for id := int64(1); id <= 3; id++ {
+				require.NoError(t, fn(domain.User{ID: id}))
+			}
+		}).
+		Return(nil)
+
+	stream, err := client.ListUsers(context.Background(), &usersv1.ListUsersRequest{})
+	require.NoError(t, err)
+
+	var ids []int64
+	for {
+		resp, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		require.NoError(t, err)
+		ids = append(ids, resp.GetUser().GetId())
+	}
+	assert.Equal(t, []int64{1, 2, 3}, ids)
+}
+
+func TestHealth(t *testing.T) {
+	_, conn := setupTest(t)
+	client := healthpb.NewHealthClient(conn)
+
+	resp, err := client.Check(context.Background(), &healthpb.HealthCheckRequest{Service: usersv1.UserService_ServiceDesc.ServiceName})
+
+	require.NoError(t, err)
+	assert.Equal(t, healthpb.HealthCheckResponse_SERVING, resp.GetStatus())
+}
@@ -0,0 +1,246 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// BodyLimits holds the largest request bodies the handlers read, in bytes
+type BodyLimits struct {
+	Default int64 // bodies of a single resource or query
+	Batch   int64 // bodies of the batch endpoints
+}
+
+// DefaultBodyLimits is used unless the handlers are given other limits
+var DefaultBodyLimits = BodyLimits{
+	Default: 1 << 20,
+	Batch:   10 << 20,
+}
+
+// WithBodyLimits sets the largest request bodies the user routes read
+func WithBodyLimits(limits BodyLimits) UserHandlerOption {
+	return func(h *UserHandler) {
+		h.limits = limits
+	}
+}
+
+// unsupportedMediaTypeError is a request body in a media type the handler cannot read
+type unsupportedMediaTypeError struct {
+	mediaType string
+	supported string
+}
+
+func (e *unsupportedMediaTypeError) Error() string {
+	return fmt.Sprintf("%s %s, requests can be sent in %s", errUnsupportedMediaType, e.mediaType, e.supported)
+}
+
+func (e *unsupportedMediaTypeError) Is(target error) bool {
+	return target == errUnsupportedMediaType
+}
+
+// Helper function to decode a JSON request body of at most limit bytes, for the routes that only speak JSON
+// Bodies without a Content-Type are read as JSON, and every media type with the +json suffix is JSON.
+// Standards such as SCIM let clients send attributes the server ignores, so they allow unknown fields
+func decodeJSONBody(w http.ResponseWriter, r *http.Request, v interface{}, limit int64, allowUnknownFields bool) error {
+	if contentType := r.Header.Get("Content-Type"); contentType != "" && !isJSONMediaType(contentType) {
+		return &unsupportedMediaTypeError{mediaType: contentType, supported: "JSON"}
+	}
+	return decodeJSON(http.MaxBytesReader(w, r.Body, limit), v, allowUnknownFields)
+}
+
+// Helper function to tell whether a Content-Type is JSON
+func isJSONMediaType(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	return mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+}
+
+// Helper function to decode a body holding a single JSON value
+// The errors tell which field or offset of the body failed, reads past the limit of the body fail with an *http.MaxBytesError
+func decodeJSON(r io.Reader, v interface{}, allowUnknownFields bool) error {
+	dec := json.NewDecoder(r)
+	if !allowUnknownFields {
+		dec.DisallowUnknownFields()
+	}
+	if err := dec.Decode(v); err != nil {
+		return jsonError(err)
+	}
+
+	// Anything but whitespace after the value is rejected, so that a body is never read in part
+	offset := dec.InputOffset()
+	if _, err := dec.Token(); !errors.Is(err, io.EOF) {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			return err
+		}
+		return fmt.Errorf("unexpected data after the JSON value at offset %d", offset)
+	}
+	return nil
+}
+
+// Helper function to decode a body holding a single MessagePack value of known fields only
+// The same rules as for JSON apply, so that a body is accepted or rejected whatever its media type
+func decodeMsgpack(r io.Reader, v interface{}) error {
+	dec := msgpack.NewDecoder(r)
+	dec.SetCustomStructTag("json")
+	dec.DisallowUnknownFields(true)
+	if err := dec.Decode(v); err != nil {
+		return msgpackError(err)
+	}
+
+	if _, err := dec.PeekCode(); !errors.Is(err, io.EOF) {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			return err
+		}
+		return errors.New("unexpected data after the MessagePack value")
+	}
+	return nil
+}
+
+// Helper function to describe an error of the MessagePack decoder
+func msgpackError(err error) error {
+	var maxBytesErr *http.MaxBytesError
+	switch {
+	case errors.As(err, &maxBytesErr):
+		return err
+	case errors.Is(err, io.EOF):
+		return errors.New("body is empty")
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		return errors.New("body ends in the middle of the MessagePack value")
+	case strings.HasPrefix(err.Error(), "msgpack: unknown field "):
+		return fmt.Errorf("unknown field %s", strings.TrimPrefix(err.Error(), "msgpack: unknown field "))
+	}
+	return fmt.Errorf("malformed MessagePack: %s", strings.TrimPrefix(err.Error(), "msgpack: "))
+}
+
+// Helper function to decode a body holding a single XML document
+// Anything but whitespace, comments and processing instructions after the root element is rejected
+func decodeXML(r io.Reader, v interface{}) error {
+	dec := xml.NewDecoder(r)
+	if err := dec.Decode(v); err != nil {
+		return xmlError(err)
+	}
+
+	for {
+		offset := dec.InputOffset()
+		token, err := dec.Token()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return xmlError(err)
+		}
+		switch token := token.(type) {
+		case xml.Comment, xml.ProcInst:
+			continue
+		case xml.CharData:
+			if len(bytes.TrimSpace(token)) == 0 {
+				continue
+			}
+		}
+		return fmt.Errorf("unexpected data after the XML document at offset %d", offset)
+	}
+}
+
+// Helper function to describe an error of the XML decoder by the line it failed at
+func xmlError(err error) error {
+	var (
+		maxBytesErr *http.MaxBytesError
+		syntaxErr   *xml.SyntaxError
+	)
+	switch {
+	case errors.As(err, &maxBytesErr):
+		return err
+	case errors.Is(err, io.EOF):
+		return errors.New("body is empty")
+	case errors.As(err, &syntaxErr):
+		return fmt.Errorf("malformed XML on line %d: %s", syntaxErr.Line, syntaxErr.Msg)
+	}
+	return err
+}
+
+// Helper function to describe an error of the JSON decoder by the field or offset it failed at
+func jsonError(err error) error {
+	var (
+		maxBytesErr *http.MaxBytesError
+		syntaxErr   *json.SyntaxError
+		typeErr     *json.UnmarshalTypeError
+	)
+	switch {
+	case errors.As(err, &maxBytesErr):
+		return err
+	case errors.Is(err, io.EOF):
+		return errors.New("body is empty")
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		return errors.New("body ends in the middle of the JSON value")
+	case errors.As(err, &syntaxErr):
+		return fmt.Errorf("malformed JSON at offset %d: %s", syntaxErr.Offset, strings.TrimPrefix(syntaxErr.Error(), "json: "))
+	case errors.As(err, &typeErr) && typeErr.Field != "":
+		return fmt.Errorf("field %q must be %s, not %s, at offset %d", typeErr.Field, jsonTypeName(typeErr.Type), typeErr.Value, typeErr.Offset)
+	case errors.As(err, &typeErr):
+		return fmt.Errorf("body must be %s, not %s", jsonTypeName(typeErr.Type), typeErr.Value)
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		// The decoder has no error type for unknown fields, its message names the field
+		return fmt.Errorf("unknown field %s", strings.TrimPrefix(err.Error(), "json: unknown field "))
+	}
+	return err
+}
+
+// Helper function to name the JSON type a Go type is decoded from
+func jsonTypeName(t reflect.Type) string {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.String:
+		return "a string"
+	case reflect.Bool:
+		return "a boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return "an integer"
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "a non-negative integer"
+	case reflect.Float32, reflect.Float64:
+		return "a number"
+	case reflect.Slice, reflect.Array:
+		return "an array"
+	case reflect.Struct, reflect.Map:
+		if t == timeType {
+			return "an RFC 3339 time"
+		}
+		return "an object"
+	}
+	return "a " + t.String()
+}
+
+// Helper function to pick the status code and message of a request body that cannot be decoded
+// Bodies in a media type the handler cannot read get a 415, bodies over the limit a 413, and the
+// others a 400 with the given message followed by what failed
+func decodeErrorStatus(err error, message string) (int, string) {
+	var (
+		unsupported *unsupportedMediaTypeError
+		maxBytesErr *http.MaxBytesError
+	)
+	switch {
+	case errors.As(err, &unsupported):
+		return http.StatusUnsupportedMediaType, fmt.Sprintf("Unsupported media type %s, requests can be sent in %s", unsupported.mediaType, unsupported.supported)
+	case errors.Is(err, errUnsupportedMediaType):
+		return http.StatusUnsupportedMediaType, "Unsupported media type"
+	case errors.As(err, &maxBytesErr):
+		return http.StatusRequestEntityTooLarge, fmt.Sprintf("Request body too large, the limit is %d bytes", maxBytesErr.Limit)
+	}
+	return http.StatusBadRequest, message + ": " + err.Error()
+}
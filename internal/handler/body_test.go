@@ -0,0 +1,183 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/huberts90/restful-api/internal/domain"
+	"github.com/huberts90/restful-api/internal/logger"
+	"github.com/huberts90/restful-api/internal/storage"
+	storagemocks "github.com/huberts90/restful-api/internal/storage/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+func TestDecodeJSON(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    string
+		wantErr string
+	}{
+		{name: "valid", body: `{"email":"test@example.com","first_name":"John"}`},
+		{name: "trailing whitespace", body: "{\"email\":\"test@example.com\"}\n"},
+		{name: "empty", body: "", wantErr: "body is empty"},
+		{name: "truncated", body: `{"email":"test@example.com"`, wantErr: "body ends in the middle of the JSON value"},
+		{name: "malformed", body: `{"email":}`, wantErr: "malformed JSON at offset 10: invalid character '}' looking for beginning of value"},
+		{name: "unknown field", body: `{"email":"test@example.com","nickname":"Jo"}`, wantErr: `unknown field "nickname"`},
+		{name: "wrong type", body: `{"email":"test@example.com","first_name":42}`, wantErr: `field "first_name" must be a string, not number, at offset 43`},
+		{name: "not an object", body: `["test@example.com"]`, wantErr: "body must be an object, not array"},
+		{name: "second value", body: `{"email":"a@example.com"} {"email":"b@example.com"}`, wantErr: "unexpected data after the JSON value at offset 25"},
+		{name: "trailing garbage", body: `{"email":"test@example.com"}garbage`, wantErr: "unexpected data after the JSON value at offset 28"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var userCreate domain.UserCreate
+			err := decodeJSON(strings.NewReader(tt.body), &userCreate, false)
+
+			if tt.wantErr == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			assert.Equal(t, tt.wantErr, err.Error())
+		})
+	}
+}
+
+func TestDecodeJSON_AllowUnknownFields(t *testing.T) {
+	var userCreate domain.UserCreate
+	require.NoError(t, decodeJSON(strings.NewReader(`{"email":"test@example.com","nickname":"Jo"}`), &userCreate, true))
+	assert.Equal(t, "test@example.com", userCreate.Email)
+}
+
+func TestDecodeMsgpack(t *testing.T) {
+	encode := func(values ...interface{}) string {
+		var buf bytes.Buffer
+		for _, v := range values {
+			require.NoError(t, msgpack.NewEncoder(&buf).Encode(v))
+		}
+		return buf.String()
+	}
+	valid := map[string]string{"email": "test@example.com", "first_name": "John"}
+
+	tests := []struct {
+		name    string
+		body    string
+		wantErr string
+	}{
+		{name: "valid", body: encode(valid)},
+		{name: "empty", body: "", wantErr: "body is empty"},
+		{name: "truncated", body: encode(valid)[:10], wantErr: "body ends in the middle of the MessagePack value"},
+		{name: "unknown field", body: encode(map[string]string{"email": "test@example.com", "nickname": "Jo"}), wantErr: `unknown field "nickname"`},
+		{name: "second value", body: encode(valid, valid), wantErr: "unexpected data after the MessagePack value"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var userCreate domain.UserCreate
+			err := decodeMsgpack(strings.NewReader(tt.body), &userCreate)
+
+			if tt.wantErr == "" {
+				require.NoError(t, err)
+				assert.Equal(t, "test@example.com", userCreate.Email)
+				return
+			}
+			require.Error(t, err)
+			assert.Equal(t, tt.wantErr, err.Error())
+		})
+	}
+}
+
+func TestDecodeXML(t *testing.T) {
+	valid := `<UserCreate><email>test@example.com</email></UserCreate>`
+
+	tests := []struct {
+		name    string
+		body    string
+		wantErr string
+	}{
+		{name: "valid", body: xml.Header + valid},
+		{name: "trailing whitespace and comments", body: valid + "\n<!-- end -->\n"},
+		{name: "empty", body: "", wantErr: "body is empty"},
+		{name: "malformed", body: "<UserCreate><email>", wantErr: "malformed XML on line 1: unexpected EOF"},
+		{name: "second document", body: valid + valid, wantErr: "unexpected data after the XML document at offset 56"},
+		{name: "trailing garbage", body: valid + "garbage", wantErr: "unexpected data after the XML document at offset 56"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var userCreate domain.UserCreate
+			err := decodeXML(strings.NewReader(tt.body), &userCreate)
+
+			if tt.wantErr == "" {
+				require.NoError(t, err)
+				assert.Equal(t, "test@example.com", userCreate.Email)
+				return
+			}
+			require.Error(t, err)
+			assert.Equal(t, tt.wantErr, err.Error())
+		})
+	}
+}
+
+func TestUserHandler_RequestBodies(t *testing.T) {
+	valid := `{"email":"test@example.com","first_name":"John","last_name":"Doe"}`
+	// A batch of ids is over the default limit of the tests and within their batch limit
+	ids := make([]int64, 20)
+	items := make([]string, len(ids))
+	results := make([]storage.BatchResult, len(ids))
+	for i := range ids {
+		ids[i] = int64(i + 1)
+		items[i] = fmt.Sprintf(`{"id":%d}`, ids[i])
+		results[i] = storage.BatchResult{ID: ids[i]}
+	}
+
+	tests := []struct {
+		name        string
+		path        string
+		contentType string
+		body        string
+		wantCode    int
+		wantError   string
+	}{
+		{name: "valid", path: "/api/users", contentType: "application/json", body: valid, wantCode: http.StatusCreated},
+		{name: "over the limit", path: "/api/users", contentType: "application/json", body: `{"email":"` + strings.Repeat("a", 256) + `"}`, wantCode: http.StatusRequestEntityTooLarge, wantError: "Request body too large, the limit is 128 bytes"},
+		{name: "batch over the default limit", path: "/api/users:batchDelete", contentType: "application/json", body: `{"items":[` + strings.Join(items, ",") + `]}`, wantCode: http.StatusOK},
+		{name: "form", path: "/api/users", contentType: "application/x-www-form-urlencoded", body: "email=test@example.com", wantCode: http.StatusUnsupportedMediaType, wantError: "Unsupported media type application/x-www-form-urlencoded, requests can be sent in JSON, MessagePack or XML"},
+		{name: "unknown field", path: "/api/users", contentType: "application/json", body: `{"email":"test@example.com","admin":true}`, wantCode: http.StatusBadRequest, wantError: `Invalid request: unknown field "admin"`},
+		{name: "trailing garbage", path: "/api/users", contentType: "application/json", body: valid + "x", wantCode: http.StatusBadRequest, wantError: "Invalid request: unexpected data after the JSON value at offset 66"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockStore := storagemocks.NewMockStorer(t)
+			mockStore.On("CreateUser", mock.Anything, mock.Anything).Return(int64(1), nil).Maybe()
+			mockStore.On("DeleteUsers", mock.Anything, ids, mock.Anything).Return(results, nil).Maybe()
+			router := mux.NewRouter()
+			NewUserHandler(mockStore, logger.NewNoOpLogger(), WithBodyLimits(BodyLimits{Default: 128, Batch: 1024})).
+				RegisterRoutes(router.PathPrefix("/api").Subrouter())
+
+			req := httptest.NewRequest(http.MethodPost, tt.path, bytes.NewBufferString(tt.body))
+			req.Header.Set("Content-Type", tt.contentType)
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+
+			assert.Equal(t, tt.wantCode, rr.Code, rr.Body.String())
+			if tt.wantError != "" {
+				var response domain.ErrorResponse
+				require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &response))
+				assert.Equal(t, tt.wantError, response.Error)
+			}
+		})
+	}
+}
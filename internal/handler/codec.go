@@ -0,0 +1,323 @@
+package handler
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/huberts90/restful-api/internal/export"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// errUnsupportedMediaType is returned when a request body is in a media type the handler cannot read
+var errUnsupportedMediaType = errors.New("unsupported media type")
+
+// codec encodes responses and decodes requests in a media type
+type codec struct {
+	mediaType string
+	aliases   []string
+	encode    func(w io.Writer, v interface{}) error
+	decode    func(r io.Reader, v interface{}) error // nil if requests cannot be sent in the media type
+}
+
+var (
+	jsonCodec = &codec{
+		mediaType: "application/json",
+		encode: func(w io.Writer, v interface{}) error {
+			data, err := json.Marshal(v)
+			if err != nil {
+				return err
+			}
+			_, err = w.Write(data)
+			return err
+		},
+		decode: func(r io.Reader, v interface{}) error { return decodeJSON(r, v, false) },
+	}
+	msgpackCodec = &codec{
+		mediaType: "application/x-msgpack",
+		aliases:   []string{"application/msgpack", "application/vnd.msgpack"},
+		encode: func(w io.Writer, v interface{}) error {
+			enc := msgpack.NewEncoder(w)
+			enc.SetCustomStructTag("json")
+			return enc.Encode(v)
+		},
+		decode: decodeMsgpack,
+	}
+	xmlCodec = &codec{
+		mediaType: "application/xml",
+		aliases:   []string{"text/xml"},
+		encode: func(w io.Writer, v interface{}) error {
+			if _, err := io.WriteString(w, xml.Header); err != nil {
+				return err
+			}
+			return xml.NewEncoder(w).Encode(v)
+		},
+		decode: decodeXML,
+	}
+	csvCodec = &codec{
+		mediaType: "text/csv",
+		encode:    encodeCSV,
+	}
+)
+
+// codecs are the media types the user routes speak, in order of preference when a client accepts several
+var codecs = []*codec{jsonCodec, msgpackCodec, xmlCodec, csvCodec}
+
+// Helper function to find the codec of a media type, the vendor media types of the API versions are JSON
+// Returns nil if no codec speaks it
+func codecFor(mediaType string) *codec {
+	mediaType, _, _ = strings.Cut(mediaType, ";")
+	mediaType = strings.ToLower(strings.TrimSpace(mediaType))
+	if versionMediaType.MatchString(mediaType) {
+		return jsonCodec
+	}
+
+	for _, c := range codecs {
+		if c.mediaType == mediaType {
+			return c
+		}
+		for _, alias := range c.aliases {
+			if alias == mediaType {
+				return c
+			}
+		}
+	}
+	return nil
+}
+
+// Helper function to pick the codec of a response from the Accept header, along with the media type to respond with
+// The quality of a codec is the one of the most specific media range matching it, and the codec of the highest
+// quality wins, the most specifically asked for and then the first of the registry if several do.
+// Returns nil if no codec is acceptable
+func negotiate(accept []string) (*codec, string) {
+	if len(accept) == 0 {
+		return jsonCodec, jsonCodec.mediaType
+	}
+
+	// Exact media types are more specific than type/*, which is more specific than */*
+	type match struct {
+		specificity int
+		quality     float64
+		mediaType   string
+	}
+	matches := make([]match, len(codecs))
+
+	for _, header := range accept {
+		for _, mediaRange := range strings.Split(header, ",") {
+			mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(mediaRange))
+			if err != nil {
+				continue
+			}
+			quality := 1.0
+			if q, ok := params["q"]; ok {
+				if quality, err = strconv.ParseFloat(q, 64); err != nil {
+					continue
+				}
+			}
+
+			for i, c := range codecs {
+				m := match{quality: quality, mediaType: c.mediaType}
+				switch {
+				case codecFor(mediaType) == c:
+					m.specificity = 3
+					// Respond in the vendor media type asked for, so that it tells the version
+					if versionMediaType.MatchString(mediaType) {
+						m.mediaType = mediaType
+					}
+				case mediaType == strings.Split(c.mediaType, "/")[0]+"/*":
+					m.specificity = 2
+				case mediaType == "*/*":
+					m.specificity = 1
+				default:
+					continue
+				}
+				if m.specificity > matches[i].specificity || (m.specificity == matches[i].specificity && m.quality > matches[i].quality) {
+					matches[i] = m
+				}
+			}
+		}
+	}
+
+	best := -1
+	for i, m := range matches {
+		if m.specificity == 0 || m.quality <= 0 {
+			continue
+		}
+		if best < 0 || m.quality > matches[best].quality || (m.quality == matches[best].quality && m.specificity > matches[best].specificity) {
+			best = i
+		}
+	}
+	if best < 0 {
+		return nil, ""
+	}
+	return codecs[best], matches[best].mediaType
+}
+
+// Helper function to decode a request body of at most limit bytes by its Content-Type, JSON if it has none
+// Returns errUnsupportedMediaType if the body is in a media type requests cannot be sent in, and an
+// *http.MaxBytesError if it is over the limit. Bodies hold a single value, of known fields only in JSON
+// and MessagePack
+func decodeBody(w http.ResponseWriter, r *http.Request, v interface{}, limit int64) error {
+	c := jsonCodec
+	if contentType := r.Header.Get("Content-Type"); contentType != "" {
+		c = codecFor(contentType)
+		if c == nil || c.decode == nil {
+			return &unsupportedMediaTypeError{mediaType: contentType, supported: "JSON, MessagePack or XML"}
+		}
+	}
+	return c.decode(http.MaxBytesReader(w, r.Body, limit), v)
+}
+
+// csvColumn is a column of a CSV response, the field at index holds its values
+type csvColumn struct {
+	name  string
+	index []int
+}
+
+// encodeCSV writes a response as CSV, with a header of the JSON names of the fields
+// The items of a list response are the rows, other responses have a single row
+func encodeCSV(w io.Writer, v interface{}) error {
+	rows := reflect.Indirect(reflect.ValueOf(v))
+	if !rows.IsValid() {
+		rows = reflect.ValueOf([]interface{}{})
+	}
+	if rows.Kind() == reflect.Struct {
+		rows = csvRows(rows)
+	}
+	if rows.Kind() != reflect.Slice {
+		rows = reflect.ValueOf([]interface{}{rows.Interface()})
+	}
+
+	rowType := rows.Type().Elem()
+	for rowType.Kind() == reflect.Ptr {
+		rowType = rowType.Elem()
+	}
+	columns := []csvColumn{{name: "value"}}
+	if rowType.Kind() == reflect.Struct && rowType != timeType {
+		columns = csvColumns(rowType, "", nil)
+	}
+
+	cw := csv.NewWriter(w)
+	header := make([]string, len(columns))
+	for i, column := range columns {
+		header[i] = column.name
+	}
+	if err := cw.Write(header); err != nil {
+		return err
+	}
+
+	record := make([]string, len(columns))
+	for i := 0; i < rows.Len(); i++ {
+		row := rows.Index(i)
+		for j, column := range columns {
+			record[j] = csvCell(row, column.index)
+		}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// Helper function to find the rows of a struct, the items of its first list of structs, or the struct itself
+func csvRows(v reflect.Value) reflect.Value {
+	for i := 0; i < v.NumField(); i++ {
+		f := v.Field(i)
+		if f.Kind() != reflect.Slice || !v.Type().Field(i).IsExported() {
+			continue
+		}
+		elem := f.Type().Elem()
+		for elem.Kind() == reflect.Ptr {
+			elem = elem.Elem()
+		}
+		if elem.Kind() == reflect.Struct && elem != timeType {
+			return f
+		}
+	}
+
+	rows := reflect.MakeSlice(reflect.SliceOf(v.Type()), 1, 1)
+	rows.Index(0).Set(v)
+	return rows
+}
+
+// Helper function to list the columns of a struct, the fields of nested structs are prefixed with the name of the struct
+func csvColumns(t reflect.Type, prefix string, index []int) []csvColumn {
+	var columns []csvColumn
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+
+		fieldIndex := append(append([]int{}, index...), i)
+		fieldType := f.Type
+		for fieldType.Kind() == reflect.Ptr {
+			fieldType = fieldType.Elem()
+		}
+		switch {
+		case fieldType.Kind() == reflect.Struct && fieldType != timeType && f.Anonymous:
+			columns = append(columns, csvColumns(fieldType, prefix, fieldIndex)...)
+		case fieldType.Kind() == reflect.Struct && fieldType != timeType:
+			columns = append(columns, csvColumns(fieldType, prefix+name+".", fieldIndex)...)
+		default:
+			columns = append(columns, csvColumn{name: prefix + name, index: fieldIndex})
+		}
+	}
+	return columns
+}
+
+// Helper function to format the field at index of a row, empty if it is nil
+// Times are formatted as RFC 3339, and lists and maps as JSON
+func csvCell(v reflect.Value, index []int) string {
+	for _, i := range index {
+		for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+			if v.IsNil() {
+				return ""
+			}
+			v = v.Elem()
+		}
+		v = v.Field(i)
+	}
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return ""
+		}
+		v = v.Elem()
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		return export.EscapeCSVCell(v.String())
+	case reflect.Bool, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Float32, reflect.Float64:
+		return fmt.Sprint(v.Interface())
+	}
+	if t, ok := v.Interface().(time.Time); ok {
+		return t.Format(time.RFC3339Nano)
+	}
+	data, err := json.Marshal(v.Interface())
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
@@ -0,0 +1,203 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/huberts90/restful-api/internal/domain"
+	"github.com/huberts90/restful-api/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+func TestNegotiate(t *testing.T) {
+	tests := []struct {
+		accept        string
+		wantMediaType string
+	}{
+		{accept: "", wantMediaType: "application/json"},
+		{accept: "*/*", wantMediaType: "application/json"},
+		{accept: "text/csv", wantMediaType: "text/csv"},
+		{accept: "text/*", wantMediaType: "text/csv"},
+		{accept: "application/xml;q=0.9, application/x-msgpack", wantMediaType: "application/x-msgpack"},
+		{accept: "application/msgpack", wantMediaType: "application/x-msgpack"},
+		{accept: "text/xml", wantMediaType: "application/xml"},
+		{accept: "application/*;q=0.5, text/csv;q=0.8", wantMediaType: "text/csv"},
+		{accept: "*/*;q=0.1, application/json;q=0", wantMediaType: "application/x-msgpack"},
+		{accept: "application/vnd.users.v2+json", wantMediaType: "application/vnd.users.v2+json"},
+		{accept: "image/png", wantMediaType: ""},
+		{accept: "text/csv;q=0", wantMediaType: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.accept, func(t *testing.T) {
+			var accept []string
+			if tt.accept != "" {
+				accept = []string{tt.accept}
+			}
+
+			c, mediaType := negotiate(accept)
+
+			assert.Equal(t, tt.wantMediaType, mediaType)
+			assert.Equal(t, tt.wantMediaType == "", c == nil)
+		})
+	}
+}
+
+func TestEncodeCSV(t *testing.T) {
+	created := time.Date(2023, 1, 1, 12, 0, 0, 0, time.UTC)
+	users := []domain.User{
+		{ID: 1, Email: "ann@example.com", FirstName: "Ann", LastName: "Lee", CreatedAt: created, UpdatedAt: created},
+		{ID: 2, Email: "bob@example.com", FirstName: "Bob", LastName: "Stone, Jr", CreatedAt: created, UpdatedAt: created},
+		{ID: 3, Email: "=cmd@example.com", FirstName: "+Eve", LastName: "@Eve", CreatedAt: created, UpdatedAt: created},
+	}
+	list := newAPIVersions(VersionConfig{})[0].users(userList{users: users, path: "/api/users", totalCount: 3, totalPages: 1, page: 1, pageSize: 10})
+
+	var buf bytes.Buffer
+	require.NoError(t, encodeCSV(&buf, list))
+
+	assert.Equal(t, "id,email,first_name,last_name,created_at,updated_at,deleted_at,_links.self.href\n"+
+		"1,ann@example.com,Ann,Lee,2023-01-01T12:00:00Z,2023-01-01T12:00:00Z,,/api/users/1\n"+
+		"2,bob@example.com,Bob,\"Stone, Jr\",2023-01-01T12:00:00Z,2023-01-01T12:00:00Z,,/api/users/2\n"+
+		// Cells a spreadsheet would run as formulas are quoted
+		"3,'=cmd@example.com,'+Eve,'@Eve,2023-01-01T12:00:00Z,2023-01-01T12:00:00Z,,/api/users/3\n", buf.String())
+
+	buf.Reset()
+	require.NoError(t, encodeCSV(&buf, domain.ErrorResponse{Error: "User not found"}))
+	assert.Equal(t, "error\nUser not found\n", buf.String())
+}
+
+func TestUserHandler_ResponseFormats(t *testing.T) {
+	user := &domain.User{ID: 1, Email: "test@example.com", FirstName: "John", LastName: "Doe"}
+
+	t.Run("xml", func(t *testing.T) {
+		mockStore, router := setupVersionedRouter(t, VersionConfig{})
+		mockStore.On("GetUserByID", mock.Anything, int64(1)).Return(user, nil)
+
+		req := httptest.NewRequest(http.MethodGet, "/api/users/1", nil)
+		req.Header.Set("Accept", "application/xml")
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+
+		require.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, "application/xml", rr.Header().Get("Content-Type"))
+		assert.Contains(t, rr.Header().Values("Vary"), "Accept")
+
+		var got domain.UserResponse
+		require.NoError(t, xml.Unmarshal(rr.Body.Bytes(), &got))
+		assert.Equal(t, "user", got.XMLName.Local)
+		assert.Equal(t, "John", got.FirstName)
+	})
+
+	t.Run("msgpack", func(t *testing.T) {
+		mockStore, router := setupVersionedRouter(t, VersionConfig{})
+		mockStore.On("GetUserByID", mock.Anything, int64(1)).Return(user, nil)
+
+		req := httptest.NewRequest(http.MethodGet, "/api/v2/users/1", nil)
+		req.Header.Set("Accept", "application/x-msgpack")
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+
+		require.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, "application/x-msgpack", rr.Header().Get("Content-Type"))
+
+		var got map[string]interface{}
+		require.NoError(t, msgpack.Unmarshal(rr.Body.Bytes(), &got))
+		assert.Equal(t, "John", got["given_name"])
+	})
+
+	t.Run("error payload", func(t *testing.T) {
+		mockStore, router := setupVersionedRouter(t, VersionConfig{})
+		mockStore.On("GetUserByID", mock.Anything, int64(1)).Return(nil, storage.ErrUserNotFound)
+
+		req := httptest.NewRequest(http.MethodGet, "/api/users/1", nil)
+		req.Header.Set("Accept", "application/xml")
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+
+		require.Equal(t, http.StatusNotFound, rr.Code)
+		assert.Equal(t, "application/xml", rr.Header().Get("Content-Type"))
+		assert.Contains(t, rr.Body.String(), "<error><message>User not found</message></error>")
+	})
+
+	t.Run("not acceptable", func(t *testing.T) {
+		_, router := setupVersionedRouter(t, VersionConfig{})
+
+		req := httptest.NewRequest(http.MethodGet, "/api/users/1", nil)
+		req.Header.Set("Accept", "image/png")
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusNotAcceptable, rr.Code)
+		assert.Equal(t, "application/json", rr.Header().Get("Content-Type"))
+	})
+}
+
+func TestUserHandler_RequestFormats(t *testing.T) {
+	userCreate := domain.UserCreate{Email: "test@example.com", FirstName: "John", LastName: "Doe"}
+	packed, err := msgpack.Marshal(map[string]string{"email": "test@example.com", "first_name": "John", "last_name": "Doe"})
+	require.NoError(t, err)
+
+	tests := []struct {
+		name        string
+		contentType string
+		body        []byte
+		wantCode    int
+	}{
+		{name: "json", contentType: "application/json; charset=utf-8", body: []byte(`{"email":"test@example.com","first_name":"John","last_name":"Doe"}`), wantCode: http.StatusCreated},
+		{name: "no content type", body: []byte(`{"email":"test@example.com","first_name":"John","last_name":"Doe"}`), wantCode: http.StatusCreated},
+		{name: "xml", contentType: "application/xml", body: []byte(`<user><email>test@example.com</email><first_name>John</first_name><last_name>Doe</last_name></user>`), wantCode: http.StatusCreated},
+		{name: "msgpack", contentType: "application/x-msgpack", body: packed, wantCode: http.StatusCreated},
+		{name: "csv", contentType: "text/csv", body: []byte("email,first_name,last_name\n"), wantCode: http.StatusUnsupportedMediaType},
+		{name: "unknown", contentType: "application/yaml", body: []byte("email: test@example.com"), wantCode: http.StatusUnsupportedMediaType},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockStore, router := setupVersionedRouter(t, VersionConfig{})
+			if tt.wantCode == http.StatusCreated {
+				mockStore.On("CreateUser", mock.Anything, userCreate).Return(int64(1), nil)
+			}
+
+			req := httptest.NewRequest(http.MethodPost, "/api/users", bytes.NewReader(tt.body))
+			if tt.contentType != "" {
+				req.Header.Set("Content-Type", tt.contentType)
+			}
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+
+			assert.Equal(t, tt.wantCode, rr.Code, rr.Body.String())
+		})
+	}
+}
+
+func TestUserHandler_HistoryXML(t *testing.T) {
+	old, updated := "John", "Johnny"
+	mockStore, router := setupVersionedRouter(t, VersionConfig{})
+	mockStore.On("ListUserHistory", mock.Anything, int64(1), 1, 10).Return([]domain.AuditRecord{{
+		ID:        1,
+		UserID:    1,
+		Operation: domain.AuditUpdate,
+		Changes: domain.FieldChanges{
+			"last_name":  {New: &updated},
+			"first_name": {Old: &old, New: &updated},
+		},
+	}}, 1, nil)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/users/1/history", nil)
+	req.Header.Set("Accept", "text/xml")
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	require.Equal(t, http.StatusOK, rr.Code)
+	body := rr.Body.String()
+	assert.True(t, strings.HasPrefix(body, xml.Header), body)
+	assert.Contains(t, body, `<changes><change field="first_name"><old>John</old><new>Johnny</new></change><change field="last_name"><new>Johnny</new></change></changes>`)
+}
@@ -0,0 +1,125 @@
+package handler
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// CacheConfig holds the Cache-Control policies of the user reads, an empty policy sends no Cache-Control header
+type CacheConfig struct {
+	User  string // GET /users/{id}
+	Users string // GET /users
+}
+
+// DefaultCacheConfig lets caches store the user reads as long as they revalidate them
+var DefaultCacheConfig = CacheConfig{
+	User:  "no-cache",
+	Users: "no-cache",
+}
+
+// WithCacheConfig sets the Cache-Control policies of the user reads
+func WithCacheConfig(cfg CacheConfig) UserHandlerOption {
+	return func(h *UserHandler) {
+		h.cache = cfg
+	}
+}
+
+// conditional tags the successful responses of a read with an ETag and a Cache-Control policy
+// Requests for a representation the client already has, as told by If-None-Match or else by
+// If-Modified-Since against the Last-Modified header of the response, get a 304 without a body.
+// Weak ETags tell responses apart by their content without promising they are byte for byte the same
+func (h *UserHandler) conditional(policy string, weak bool, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		buf := &responseBuffer{header: w.Header(), code: http.StatusOK}
+		next(buf, r)
+
+		if buf.code != http.StatusOK {
+			h.flush(w, buf)
+			return
+		}
+
+		etag := computeETag(buf.body.Bytes(), weak)
+		w.Header().Set("ETag", etag)
+		if policy != "" {
+			w.Header().Set("Cache-Control", policy)
+		}
+
+		if notModified(r, etag, w.Header().Get("Last-Modified")) {
+			w.Header().Del("Content-Type")
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+
+		h.flush(w, buf)
+	}
+}
+
+// Helper function to write a buffered response
+func (h *UserHandler) flush(w http.ResponseWriter, buf *responseBuffer) {
+	w.WriteHeader(buf.code)
+	if _, err := w.Write(buf.body.Bytes()); err != nil {
+		h.logger.Error("failed to write response", zap.Error(err))
+	}
+}
+
+// responseBuffer holds a response until it is known whether the client already has it
+type responseBuffer struct {
+	header http.Header
+	code   int
+	body   bytes.Buffer
+}
+
+func (b *responseBuffer) Header() http.Header { return b.header }
+
+func (b *responseBuffer) WriteHeader(code int) { b.code = code }
+
+func (b *responseBuffer) Write(p []byte) (int, error) { return b.body.Write(p) }
+
+// Helper function to compute the ETag of a response body
+func computeETag(body []byte, weak bool) string {
+	sum := sha256.Sum256(body)
+	etag := `"` + base64.RawURLEncoding.EncodeToString(sum[:16]) + `"`
+	if weak {
+		return "W/" + etag
+	}
+	return etag
+}
+
+// Helper function to evaluate the conditional headers of a read as RFC 9110 orders them
+// If-Modified-Since is ignored when If-None-Match is present
+func notModified(r *http.Request, etag, lastModified string) bool {
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		return etagMatches(inm, etag)
+	}
+
+	ims := r.Header.Get("If-Modified-Since")
+	if ims == "" || lastModified == "" {
+		return false
+	}
+	since, err := http.ParseTime(ims)
+	if err != nil {
+		return false
+	}
+	modified, err := http.ParseTime(lastModified)
+	if err != nil {
+		return false
+	}
+	return !modified.Truncate(time.Second).After(since)
+}
+
+// Helper function to match an ETag against an If-None-Match header with the weak comparison
+func etagMatches(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,127 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/huberts90/restful-api/internal/domain"
+	"github.com/huberts90/restful-api/internal/logger"
+	"github.com/huberts90/restful-api/internal/storage"
+	storagemocks "github.com/huberts90/restful-api/internal/storage/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetUser_ConditionalRequests(t *testing.T) {
+	updated := time.Date(2023, 1, 1, 12, 0, 0, 500, time.UTC)
+	user := &domain.User{ID: 1, Email: "test@example.com", FirstName: "John", LastName: "Doe", CreatedAt: updated, UpdatedAt: updated}
+
+	mockStore := storagemocks.NewMockStorer(t)
+	mockStore.On("GetUserByID", mock.Anything, int64(1)).Return(user, nil)
+	router := mux.NewRouter()
+	NewUserHandler(mockStore, logger.NewNoOpLogger(), WithCacheConfig(CacheConfig{User: "public, max-age=60"})).
+		RegisterRoutes(router.PathPrefix("/api").Subrouter())
+
+	get := func(header, value string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/api/users/1", nil)
+		if header != "" {
+			req.Header.Set(header, value)
+		}
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := get("", "")
+	require.Equal(t, http.StatusOK, rr.Code)
+	etag := rr.Header().Get("ETag")
+	assert.Regexp(t, `^"[A-Za-z0-9_-]+"$`, etag)
+	assert.Equal(t, "public, max-age=60", rr.Header().Get("Cache-Control"))
+	assert.Equal(t, "Sun, 01 Jan 2023 12:00:00 GMT", rr.Header().Get("Last-Modified"))
+
+	tests := []struct {
+		name     string
+		header   string
+		value    string
+		wantCode int
+	}{
+		{name: "matching etag", header: "If-None-Match", value: `"other", ` + etag, wantCode: http.StatusNotModified},
+		{name: "weak match", header: "If-None-Match", value: "W/" + etag, wantCode: http.StatusNotModified},
+		{name: "any etag", header: "If-None-Match", value: "*", wantCode: http.StatusNotModified},
+		{name: "other etag", header: "If-None-Match", value: `"other"`, wantCode: http.StatusOK},
+		{name: "not modified since", header: "If-Modified-Since", value: "Sun, 01 Jan 2023 12:00:00 GMT", wantCode: http.StatusNotModified},
+		{name: "modified since", header: "If-Modified-Since", value: "Sun, 01 Jan 2023 11:59:59 GMT", wantCode: http.StatusOK},
+		{name: "invalid date", header: "If-Modified-Since", value: "yesterday", wantCode: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := get(tt.header, tt.value)
+
+			assert.Equal(t, tt.wantCode, rr.Code)
+			assert.Equal(t, etag, rr.Header().Get("ETag"))
+			if tt.wantCode == http.StatusNotModified {
+				assert.Empty(t, rr.Body.String())
+			}
+		})
+	}
+
+	// If-None-Match wins over If-Modified-Since
+	req := httptest.NewRequest(http.MethodGet, "/api/users/1", nil)
+	req.Header.Set("If-None-Match", `"other"`)
+	req.Header.Set("If-Modified-Since", "Sun, 01 Jan 2023 12:00:00 GMT")
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusOK, rr.Code)
+
+	// Every representation has its own ETag
+	assert.NotEqual(t, etag, get("Accept", "application/xml").Header().Get("ETag"))
+}
+
+func TestListUsers_WeakETag(t *testing.T) {
+	mockStore, router := setupVersionedRouter(t, VersionConfig{})
+	users := []domain.User{{ID: 1, FirstName: "Ann"}}
+	mockStore.On("ListUsers", mock.Anything, 1, 10).Return(users, 1, nil).Once()
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/users", nil))
+	require.Equal(t, http.StatusOK, rr.Code)
+	etag := rr.Header().Get("ETag")
+	assert.True(t, strings.HasPrefix(etag, `W/"`), etag)
+	assert.Equal(t, DefaultCacheConfig.Users, rr.Header().Get("Cache-Control"))
+
+	// The ETag changes with the page contents
+	mockStore.On("ListUsers", mock.Anything, 1, 10).Return([]domain.User{{ID: 1, FirstName: "Anna"}}, 1, nil).Once()
+	req := httptest.NewRequest(http.MethodGet, "/api/users", nil)
+	req.Header.Set("If-None-Match", etag)
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.NotEqual(t, etag, rr.Header().Get("ETag"))
+
+	mockStore.On("ListUsers", mock.Anything, 1, 10).Return(users, 1, nil).Once()
+	req = httptest.NewRequest(http.MethodGet, "/api/users", nil)
+	req.Header.Set("If-None-Match", etag)
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusNotModified, rr.Code)
+}
+
+func TestConditional_Errors(t *testing.T) {
+	mockStore, router := setupVersionedRouter(t, VersionConfig{})
+	mockStore.On("GetUserByID", mock.Anything, int64(1)).Return(nil, storage.ErrUserNotFound)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/users/1", nil)
+	req.Header.Set("If-None-Match", "*")
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+	assert.Empty(t, rr.Header().Get("ETag"))
+	assert.Contains(t, rr.Body.String(), "User not found")
+}
@@ -0,0 +1,175 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/huberts90/restful-api/internal/domain"
+	"github.com/huberts90/restful-api/internal/events"
+	"github.com/huberts90/restful-api/internal/storage"
+	"go.uber.org/zap"
+)
+
+// EventHandler streams user lifecycle events to clients as Server-Sent Events
+type EventHandler struct {
+	responder
+	broadcaster *events.Broadcaster
+	store       storage.EventStorer
+	cfg         events.StreamConfig
+	logger      *zap.Logger
+}
+
+// NewEventHandler creates a new EventHandler with the given dependencies
+func NewEventHandler(broadcaster *events.Broadcaster, store storage.EventStorer, cfg events.StreamConfig, logger *zap.Logger) *EventHandler {
+	return &EventHandler{
+		responder:   responder{logger: logger},
+		broadcaster: broadcaster,
+		store:       store,
+		cfg:         cfg,
+		logger:      logger,
+	}
+}
+
+// RegisterRoutes registers the event stream route with the router
+func (h *EventHandler) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc("/users/events", h.StreamEvents).Methods(http.MethodGet)
+}
+
+// StreamEvents handles GET /users/events requests
+// Clients resuming with Last-Event-ID first get the events they missed, an "overflow" event
+// tells them that more were missed than can be replayed
+func (h *EventHandler) StreamEvents(w http.ResponseWriter, r *http.Request) {
+	lastEventID, err := parseLastEventID(r)
+	if err != nil {
+		h.respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// Subscribing before the replay makes sure that no event falls in between
+	sub, err := h.broadcaster.Subscribe()
+	if err != nil {
+		if errors.Is(err, events.ErrTooManySubscribers) {
+			w.Header().Set("Retry-After", "5")
+			h.respondWithError(w, http.StatusServiceUnavailable, "Too many event streams")
+			return
+		}
+		h.respondWithError(w, http.StatusInternalServerError, "Failed to subscribe to events")
+		return
+	}
+	defer sub.Close()
+
+	var missed []domain.Event
+	if lastEventID > 0 {
+		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+		missed, err = h.store.EventsAfter(ctx, lastEventID, h.cfg.ReplayLimit)
+		cancel()
+		if err != nil {
+			h.logger.Error("Failed to replay events", zap.Error(err), zap.Int64("last_event_id", lastEventID))
+			h.respondWithError(w, storeErrorStatus(err), "Failed to replay events")
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	// Proxies such as nginx must not buffer the stream
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	stream := &eventStream{w: w, rc: http.NewResponseController(w), timeout: h.cfg.WriteTimeout}
+
+	replayed := make(map[int64]bool, len(missed))
+	for _, event := range missed {
+		if err := stream.send(event); err != nil {
+			return
+		}
+		replayed[event.ID] = true
+	}
+	if h.cfg.ReplayLimit > 0 && len(missed) == h.cfg.ReplayLimit {
+		if err := stream.write("event: overflow\ndata: {}\n\n"); err != nil {
+			return
+		}
+	}
+	if err := stream.write(": connected\n\n"); err != nil {
+		return
+	}
+
+	heartbeat := time.NewTicker(h.cfg.Heartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case event, ok := <-sub.Events():
+			if !ok {
+				if sub.Dropped() {
+					// The client reconnects with Last-Event-ID and catches up from the outbox
+					h.logger.Warn("Dropped slow event stream", zap.String("remote_addr", r.RemoteAddr))
+				}
+				return
+			}
+			if replayed[event.ID] {
+				continue
+			}
+			if err := stream.send(event); err != nil {
+				return
+			}
+		case <-heartbeat.C:
+			if err := stream.write(": heartbeat\n\n"); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// eventStream writes Server-Sent Events, each within a write deadline
+type eventStream struct {
+	w       http.ResponseWriter
+	rc      *http.ResponseController
+	timeout time.Duration
+}
+
+func (s *eventStream) send(event domain.Event) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	return s.write(fmt.Sprintf("id: %d\nevent: %s\ndata: %s\n\n", event.ID, event.Type, data))
+}
+
+func (s *eventStream) write(msg string) error {
+	// A client that does not read within the timeout is dropped, the server write timeout does not apply
+	if err := s.rc.SetWriteDeadline(time.Now().Add(s.timeout)); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		return err
+	}
+	if _, err := s.w.Write([]byte(msg)); err != nil {
+		return err
+	}
+	return s.rc.Flush()
+}
+
+// Helper function to parse the ID of the last event a client received
+// EventSource sends it as a header, the query parameter serves clients that cannot set headers
+func parseLastEventID(r *http.Request) (int64, error) {
+	value := r.Header.Get("Last-Event-ID")
+	if value == "" {
+		value = r.URL.Query().Get("last_event_id")
+	}
+	if value == "" {
+		return 0, nil
+	}
+
+	id, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || id < 0 {
+		return 0, fmt.Errorf("invalid Last-Event-ID: %v", value)
+	}
+	return id, nil
+}
@@ -0,0 +1,98 @@
+package handler
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/huberts90/restful-api/internal/domain"
+	"github.com/huberts90/restful-api/internal/events"
+	"github.com/huberts90/restful-api/internal/logger"
+	"github.com/huberts90/restful-api/internal/middleware"
+	storagemocks "github.com/huberts90/restful-api/internal/storage/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStreamEvents(t *testing.T) {
+	mockStore := storagemocks.NewMockEventStorer(t)
+	broadcaster := events.NewBroadcaster(1, 10)
+	cfg := events.StreamConfig{Heartbeat: time.Minute, ReplayLimit: 100, WriteTimeout: time.Second}
+	handler := NewEventHandler(broadcaster, mockStore, cfg, logger.NewNoOpLogger())
+
+	// The stream goes through the logging middleware, whose response wrapper must flush
+	router := mux.NewRouter()
+	router.Use(middleware.LoggingMiddleware(logger.NewNoOpLogger()))
+	handler.RegisterRoutes(router)
+	srv := httptest.NewServer(router)
+	defer srv.Close()
+
+	event := func(id int64, eventType domain.EventType) domain.Event {
+		return domain.Event{ID: id, Type: eventType, UserID: 1, OccurredAt: time.Now().UTC(), Data: json.RawMessage(`{"id":1}`)}
+	}
+	mockStore.On("EventsAfter", mock.Anything, int64(5), 100).Return([]domain.Event{event(6, domain.EventUserUpdated)}, nil)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL+"/users/events", nil)
+	require.NoError(t, err)
+	req.Header.Set("Last-Event-ID", "5")
+
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+
+	rd := bufio.NewReader(resp.Body)
+	readUntil := func(prefix string) []string {
+		var lines []string
+		for {
+			line, err := rd.ReadString('\n')
+			require.NoError(t, err)
+			lines = append(lines, strings.TrimSuffix(line, "\n"))
+			if strings.HasPrefix(line, prefix) {
+				return lines
+			}
+		}
+	}
+
+	// The missed event is replayed before the stream goes live
+	lines := readUntil(": connected")
+	assert.Contains(t, lines, "id: 6")
+	assert.Contains(t, lines, "event: user.updated")
+
+	// A second stream is over the connection cap
+	rr := httptest.NewRecorder()
+	handler.StreamEvents(rr, httptest.NewRequest(http.MethodGet, "/users/events", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, rr.Code)
+	assert.Equal(t, "5", rr.Header().Get("Retry-After"))
+
+	// Live events already replayed are not sent twice
+	require.NoError(t, broadcaster.Publish(ctx, event(6, domain.EventUserUpdated)))
+	require.NoError(t, broadcaster.Publish(ctx, event(7, domain.EventUserDeleted)))
+	lines = readUntil("data: ")
+	assert.Equal(t, []string{"", "id: 7", "event: user.deleted"}, lines[:3])
+
+	var got domain.Event
+	require.NoError(t, json.Unmarshal([]byte(strings.TrimPrefix(lines[3], "data: ")), &got))
+	assert.Equal(t, int64(7), got.ID)
+}
+
+func TestStreamEvents_InvalidLastEventID(t *testing.T) {
+	handler := NewEventHandler(events.NewBroadcaster(1, 1), storagemocks.NewMockEventStorer(t), events.StreamConfig{}, logger.NewNoOpLogger())
+
+	req := httptest.NewRequest(http.MethodGet, "/users/events", nil)
+	req.Header.Set("Last-Event-ID", "abc")
+	rr := httptest.NewRecorder()
+	handler.StreamEvents(rr, req)
+
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+}
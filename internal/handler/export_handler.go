@@ -0,0 +1,109 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/huberts90/restful-api/internal/export"
+	"github.com/huberts90/restful-api/internal/jobs"
+	"github.com/huberts90/restful-api/internal/storage"
+	"go.uber.org/zap"
+)
+
+// ExportHandler handles exports of users to files
+type ExportHandler struct {
+	responder
+	store  export.Exporter
+	queue  storage.JobStorer
+	cfg    export.Config
+	logger *zap.Logger
+}
+
+// NewExportHandler creates a new ExportHandler with the given dependencies
+func NewExportHandler(store export.Exporter, queue storage.JobStorer, cfg export.Config, logger *zap.Logger) *ExportHandler {
+	return &ExportHandler{
+		responder: responder{logger: logger},
+		store:     store,
+		queue:     queue,
+		cfg:       cfg,
+		logger:    logger,
+	}
+}
+
+// RegisterRoutes registers the export route with the router
+func (h *ExportHandler) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc("/users/export", h.ExportUsers).Methods(http.MethodGet)
+}
+
+// ExportUsers handles GET /users/export requests
+// The file is streamed in the response, or written by a background job with async=true
+func (h *ExportHandler) ExportUsers(w http.ResponseWriter, r *http.Request) {
+	format, err := export.ParseFormat(r.URL.Query().Get("format"))
+	if err != nil {
+		h.respondWithError(w, http.StatusBadRequest, "Invalid format, must be one of csv, ndjson or parquet")
+		return
+	}
+
+	opts, err := parseReadOptions(r)
+	if err != nil {
+		h.respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	async, err := parseBoolQuery(r, "async")
+	if err != nil {
+		h.respondWithError(w, http.StatusBadRequest, "Invalid async")
+		return
+	}
+
+	if async {
+		payload := jobs.ExportPayload{Format: format, IncludeDeleted: storage.NewReadOptions(opts...).IncludeDeleted}
+		job, err := h.queue.EnqueueJob(r.Context(), jobs.TypeExportUsers, payload)
+		if err != nil {
+			h.respondWithError(w, storeErrorStatus(err), "Failed to start export")
+			return
+		}
+		h.respondWithJob(w, r, "/users/export", job)
+		return
+	}
+
+	// Exports can take longer than regular requests to write
+	deadline := time.Now().Add(h.cfg.Timeout)
+	_ = http.NewResponseController(w).SetWriteDeadline(deadline)
+	ctx, cancel := context.WithDeadline(r.Context(), deadline)
+	defer cancel()
+
+	w.Header().Set("Content-Type", format.ContentType())
+	w.Header().Set("Content-Disposition", `attachment; filename="`+format.Filename()+`"`)
+
+	cw := &countingWriter{w: w}
+	rows, err := export.Write(ctx, h.store, format, cw, opts...)
+	if err != nil {
+		if cw.n == 0 {
+			h.logger.Error("Failed to export users", zap.Error(err))
+			// The error is not part of the file, so it goes out as JSON rather than in the format of the export
+			w.Header().Del("Content-Disposition")
+			w.Header().Del("Content-Type")
+			h.respondWithError(w, storeErrorStatus(err), "Failed to export users")
+			return
+		}
+
+		// The status was sent with the first bytes, so the client can only tell from the connection being cut
+		h.logger.Error("Failed to export users midway", zap.Error(err), zap.Int("rows", rows), zap.Int64("bytes", cw.n))
+		panic(http.ErrAbortHandler)
+	}
+}
+
+// countingWriter counts the bytes written through it
+type countingWriter struct {
+	w http.ResponseWriter
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,128 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/huberts90/restful-api/internal/domain"
+	"github.com/huberts90/restful-api/internal/export"
+	"github.com/huberts90/restful-api/internal/jobs"
+	"github.com/huberts90/restful-api/internal/logger"
+	"github.com/huberts90/restful-api/internal/storage"
+	storagemocks "github.com/huberts90/restful-api/internal/storage/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// exportUsers makes a mocked store export the given users
+func exportUsers(users ...domain.User) func(mock.Arguments) {
+	return func(args mock.Arguments) {
+		fn := args.Get(1).(func(domain.User) error)
+		for _, user := range users {
+			_ = fn(user)
+		}
+	}
+}
+
+func TestExportUsers(t *testing.T) {
+	mockStore := storagemocks.NewMockStorer(t)
+	handler := NewExportHandler(mockStore, storagemocks.NewMockJobStorer(t), export.Config{Timeout: time.Minute}, logger.NewNoOpLogger())
+
+	user := domain.User{ID: 1, Email: "ann@example.com", FirstName: "Ann", LastName: "Lee"}
+	mockStore.On("ExportUsers", mock.Anything, mock.Anything, mock.Anything).Run(exportUsers(user)).Return(nil)
+
+	req := httptest.NewRequest(http.MethodGet, "/users/export?format=ndjson&include_deleted=true", nil)
+	rr := httptest.NewRecorder()
+	handler.ExportUsers(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "application/x-ndjson", rr.Header().Get("Content-Type"))
+	assert.Equal(t, `attachment; filename="users.ndjson"`, rr.Header().Get("Content-Disposition"))
+	assert.Contains(t, rr.Body.String(), `"email":"ann@example.com"`)
+	assert.Len(t, mockStore.Calls[0].Arguments, 3, "include_deleted should be passed to the store")
+}
+
+func TestExportUsers_Errors(t *testing.T) {
+	tests := []struct {
+		name       string
+		query      string
+		storeErr   error
+		wantStatus int
+		wantError  string
+	}{
+		{name: "unknown format", query: "?format=xlsx", wantStatus: http.StatusBadRequest},
+		{name: "invalid async", query: "?async=sometimes", wantStatus: http.StatusBadRequest},
+		{name: "store error", storeErr: storage.ErrCircuitOpen, wantStatus: http.StatusServiceUnavailable, wantError: "Failed to export users"},
+		{name: "store error in parquet", query: "?format=parquet", storeErr: storage.ErrCircuitOpen, wantStatus: http.StatusServiceUnavailable, wantError: "Failed to export users"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockStore := storagemocks.NewMockStorer(t)
+			handler := NewExportHandler(mockStore, storagemocks.NewMockJobStorer(t), export.Config{Timeout: time.Minute}, logger.NewNoOpLogger())
+			if tt.storeErr != nil {
+				mockStore.On("ExportUsers", mock.Anything, mock.Anything).Return(tt.storeErr)
+			}
+
+			req := httptest.NewRequest(http.MethodGet, "/users/export"+tt.query, nil)
+			rr := httptest.NewRecorder()
+			handler.ExportUsers(rr, req)
+
+			assert.Equal(t, tt.wantStatus, rr.Code)
+			assert.Empty(t, rr.Header().Get("Content-Disposition"))
+			// Errors go out as JSON whatever the format asked for
+			assert.Equal(t, "application/json", rr.Header().Get("Content-Type"))
+			var body domain.ErrorResponse
+			require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &body))
+			if tt.wantError != "" {
+				assert.Equal(t, tt.wantError, body.Error)
+			}
+		})
+	}
+}
+
+func TestExportUsers_Async(t *testing.T) {
+	mockQueue := storagemocks.NewMockJobStorer(t)
+	handler := NewExportHandler(storagemocks.NewMockStorer(t), mockQueue, export.Config{Timeout: time.Minute}, logger.NewNoOpLogger())
+
+	payload := jobs.ExportPayload{Format: export.Parquet, IncludeDeleted: true}
+	mockQueue.On("EnqueueJob", mock.Anything, jobs.TypeExportUsers, payload).
+		Return(&domain.Job{ID: 9, Type: jobs.TypeExportUsers, Status: domain.JobQueued}, nil)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/users/export?format=parquet&include_deleted=true&async=true", nil)
+	rr := httptest.NewRecorder()
+	handler.ExportUsers(rr, req)
+
+	assert.Equal(t, http.StatusAccepted, rr.Code)
+	assert.Equal(t, "/api/jobs/9", rr.Header().Get("Location"))
+
+	var job domain.Job
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &job))
+	assert.Equal(t, domain.JobQueued, job.Status)
+}
+
+func TestExportUsers_AbortMidway(t *testing.T) {
+	mockStore := storagemocks.NewMockStorer(t)
+	handler := NewExportHandler(mockStore, storagemocks.NewMockJobStorer(t), export.Config{Timeout: time.Minute}, logger.NewNoOpLogger())
+
+	// Enough users to flush the buffer of the encoder before the store fails
+	mockStore.On("ExportUsers", mock.Anything, mock.Anything).Return(func(_ context.Context, fn func(domain.User) error, _ ...storage.ReadOption) error {
+		for i := int64(1); i <= 1000; i++ {
+			_ = fn(domain.User{ID: i, Email: "user@example.com", FirstName: "Ann", LastName: "Lee"})
+		}
+		return errors.New("connection reset")
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/users/export", nil)
+	rr := httptest.NewRecorder()
+	assert.PanicsWithValue(t, http.ErrAbortHandler, func() {
+		handler.ExportUsers(rr, req)
+	})
+	assert.Equal(t, http.StatusOK, rr.Code)
+}
@@ -0,0 +1,116 @@
+package handler
+
+import (
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/huberts90/restful-api/internal/storage"
+)
+
+// fieldset is the fields of the users a client asked for with ?fields=, nil for all of them
+type fieldset struct {
+	names   map[string]bool
+	columns []string
+}
+
+// Helper function to parse the ?fields= query parameter against the fields of the users of a version
+// Returns an error naming the first field the version does not have
+func (v *apiVersion) parseFields(r *http.Request) (*fieldset, error) {
+	if !r.URL.Query().Has("fields") {
+		return nil, nil
+	}
+
+	f := &fieldset{names: make(map[string]bool)}
+	for _, name := range strings.Split(r.URL.Query().Get("fields"), ",") {
+		name = strings.TrimSpace(name)
+		column, ok := v.columns[name]
+		if !ok {
+			return nil, fmt.Errorf("invalid fields: unknown field %q", name)
+		}
+		if !f.names[name] {
+			f.names[name] = true
+			f.columns = append(f.columns, column)
+		}
+	}
+	return f, nil
+}
+
+// readOptions narrows the reads of the store to the columns the fields come from
+func (f *fieldset) readOptions() []storage.ReadOption {
+	if f == nil {
+		return nil
+	}
+	return []storage.ReadOption{storage.SelectColumns(f.columns...)}
+}
+
+// user keeps the fields asked for of a user response
+func (f *fieldset) user(response interface{}) interface{} {
+	if f == nil {
+		return response
+	}
+	v := reflect.ValueOf(response)
+	return copyFields(v, f.projectType(v.Type())).Interface()
+}
+
+// list keeps the fields asked for of the users of a list response, the other fields of the list are kept
+func (f *fieldset) list(response interface{}) interface{} {
+	if f == nil {
+		return response
+	}
+	v := reflect.ValueOf(response)
+	t := v.Type()
+
+	fields := make([]reflect.StructField, t.NumField())
+	items := -1
+	for i := range fields {
+		fields[i] = t.Field(i)
+		if items < 0 && fields[i].Type.Kind() == reflect.Slice && fields[i].Type.Elem().Kind() == reflect.Struct {
+			items = i
+			fields[i].Type = reflect.SliceOf(f.projectType(fields[i].Type.Elem()))
+		}
+	}
+	if items < 0 {
+		return response
+	}
+
+	projected := reflect.New(reflect.StructOf(fields)).Elem()
+	for i := range fields {
+		if i != items {
+			projected.Field(i).Set(v.Field(i))
+			continue
+		}
+		users := reflect.MakeSlice(fields[i].Type, v.Field(i).Len(), v.Field(i).Len())
+		for j := 0; j < users.Len(); j++ {
+			users.Index(j).Set(copyFields(v.Field(i).Index(j), fields[i].Type.Elem()))
+		}
+		projected.Field(i).Set(users)
+	}
+	return projected.Interface()
+}
+
+// projectType returns a struct type with the fields asked for of a user response type, in the same order
+// The XML name of the type is kept so that the projection encodes as the user it comes from, and so are
+// the links of the user
+func (f *fieldset) projectType(t reflect.Type) reflect.Type {
+	var fields []reflect.StructField
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if f.names[name] || name == "_links" || field.Type == reflect.TypeOf(xml.Name{}) {
+			fields = append(fields, field)
+		}
+	}
+	return reflect.StructOf(fields)
+}
+
+// Helper function to copy the fields of a user response that a projected type has
+func copyFields(v reflect.Value, t reflect.Type) reflect.Value {
+	projected := reflect.New(t).Elem()
+	for i := 0; i < t.NumField(); i++ {
+		projected.Field(i).Set(v.FieldByName(t.Field(i).Name))
+	}
+	return projected
+}
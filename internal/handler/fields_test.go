@@ -0,0 +1,87 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/huberts90/restful-api/internal/domain"
+	"github.com/huberts90/restful-api/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// readOptions matches read options that select the given columns
+func readOptions(includeDeleted bool, columns ...string) interface{} {
+	return mock.MatchedBy(func(opt storage.ReadOption) bool {
+		o := storage.NewReadOptions(opt)
+		return o.IncludeDeleted == includeDeleted && assert.ObjectsAreEqual(columns, o.Columns)
+	})
+}
+
+func TestListUsers_Fields(t *testing.T) {
+	created := time.Date(2023, 1, 1, 12, 0, 0, 0, time.UTC)
+	users := []domain.User{
+		{ID: 1, FirstName: "Ann"},
+		{ID: 2, FirstName: "Bob", CreatedAt: created},
+	}
+
+	mockStore, router := setupVersionedRouter(t, VersionConfig{})
+	mockStore.On("ListUsers", mock.Anything, 1, 10, readOptions(false, "first_name", "id")).Return(users, 2, nil)
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/users?fields=first_name,id", nil))
+
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	// The fields keep the order of the full response whatever the order they are asked for in, and the links are kept
+	assert.True(t, strings.HasPrefix(rr.Body.String(), `{"users":[{"id":1,"first_name":"Ann","_links":{"self":{"href":"/api/users/1"}}},{"id":2,"first_name":"Bob",`), rr.Body.String())
+
+	// Projections encode in every media type
+	mockStore, router = setupVersionedRouter(t, VersionConfig{})
+	mockStore.On("ListUsers", mock.Anything, 1, 10, readOptions(false, "first_name", "id")).Return(users, 2, nil)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/users?fields=first_name,id", nil)
+	req.Header.Set("Accept", "text/csv")
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	assert.Equal(t, "id,first_name,_links.self.href\n1,Ann,/api/users/1\n2,Bob,/api/users/2\n", rr.Body.String())
+}
+
+func TestGetUser_Fields(t *testing.T) {
+	deleted := time.Date(2023, 1, 1, 12, 0, 0, 0, time.UTC)
+	mockStore, router := setupVersionedRouter(t, VersionConfig{})
+	mockStore.On("GetUserByID", mock.Anything, int64(1), readOptions(true), readOptions(false, "first_name", "deleted_at")).
+		Return(&domain.User{ID: 1, FirstName: "Ann", DeletedAt: &deleted}, nil)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v2/users/1?include_deleted=true&fields=given_name,status", nil)
+	req.Header.Set("Accept", "application/xml")
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	assert.Contains(t, rr.Body.String(), `<user><given_name>Ann</given_name><status>deleted</status><links><self href="/api/v2/users/1"></self></links></user>`)
+}
+
+func TestFields_Unknown(t *testing.T) {
+	for _, path := range []string{
+		"/api/users?fields=id,password",
+		"/api/users/1?fields=",
+		"/api/v1/users?fields=given_name",
+		"/api/v2/users/1?fields=first_name",
+	} {
+		t.Run(path, func(t *testing.T) {
+			_, router := setupVersionedRouter(t, VersionConfig{})
+
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, path, nil))
+
+			assert.Equal(t, http.StatusBadRequest, rr.Code)
+			assert.Contains(t, rr.Body.String(), "unknown field")
+		})
+	}
+}
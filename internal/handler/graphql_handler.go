@@ -0,0 +1,73 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/huberts90/restful-api/internal/gql"
+	"go.uber.org/zap"
+)
+
+// GraphQLHandler handles GraphQL requests over HTTP
+type GraphQLHandler struct {
+	responder
+	server *gql.Server
+	limits BodyLimits
+	logger *zap.Logger
+}
+
+// NewGraphQLHandler creates a new GraphQLHandler with the given dependencies
+func NewGraphQLHandler(server *gql.Server, limits BodyLimits, logger *zap.Logger) *GraphQLHandler {
+	return &GraphQLHandler{
+		responder: responder{logger: logger},
+		server:    server,
+		limits:    limits,
+		logger:    logger,
+	}
+}
+
+// RegisterRoutes registers the GraphQL routes with the router
+func (h *GraphQLHandler) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc("/graphql", h.Query).Methods(http.MethodGet, http.MethodPost)
+}
+
+// Query handles GraphQL requests, sent as a JSON body or, for queries only, as query parameters
+// Errors of the operation are reported in the result, the status is only an error when the request cannot be read
+func (h *GraphQLHandler) Query(w http.ResponseWriter, r *http.Request) {
+	var req gql.Request
+	readOnly := r.Method == http.MethodGet
+
+	if readOnly {
+		if err := parseGraphQLQuery(r, &req); err != nil {
+			h.respondWithJSON(w, http.StatusBadRequest, gql.ErrorResult(err))
+			return
+		}
+	} else if err := decodeJSONBody(w, r, &req, h.limits.Default, false); err != nil {
+		code, message := decodeErrorStatus(err, "Invalid request body")
+		h.respondWithJSON(w, code, gql.ErrorResult(&gql.Error{Code: gql.CodeBadUserInput, Message: message}))
+		return
+	}
+
+	h.respondWithJSON(w, http.StatusOK, h.server.Do(r.Context(), req, readOnly))
+}
+
+// Helper function to read a GraphQL request from the query parameters
+// The variables and the extensions are JSON encoded
+func parseGraphQLQuery(r *http.Request, req *gql.Request) error {
+	query := r.URL.Query()
+	req.Query = query.Get("query")
+	req.OperationName = query.Get("operationName")
+
+	if v := query.Get("variables"); v != "" {
+		if err := json.Unmarshal([]byte(v), &req.Variables); err != nil {
+			return &gql.Error{Code: gql.CodeBadUserInput, Message: "Invalid variables"}
+		}
+	}
+	if v := query.Get("extensions"); v != "" {
+		if err := json.Unmarshal([]byte(v), &req.Extensions); err != nil {
+			return &gql.Error{Code: gql.CodeBadUserInput, Message: "Invalid extensions"}
+		}
+	}
+	return nil
+}
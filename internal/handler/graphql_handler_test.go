@@ -0,0 +1,80 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/huberts90/restful-api/internal/cache"
+	"github.com/huberts90/restful-api/internal/domain"
+	"github.com/huberts90/restful-api/internal/gql"
+	"github.com/huberts90/restful-api/internal/logger"
+	storagemocks "github.com/huberts90/restful-api/internal/storage/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestGraphQLHandler(t *testing.T) (*storagemocks.MockStorer, *GraphQLHandler) {
+	mockStore := storagemocks.NewMockStorer(t)
+	cfg := gql.Config{MaxDepth: 10, MaxComplexity: 1000, Timeout: time.Second, PersistedQueryTTL: time.Hour}
+	server, err := gql.NewServer(mockStore, cache.NewLRU(10), cfg, logger.NewNoOpLogger())
+	require.NoError(t, err)
+	return mockStore, NewGraphQLHandler(server, DefaultBodyLimits, logger.NewNoOpLogger())
+}
+
+func TestGraphQL(t *testing.T) {
+	mockStore, handler := newTestGraphQLHandler(t)
+	mockStore.On("GetUsersByIDs", mock.Anything, []int64{1}).Return([]domain.User{{ID: 1, Email: "ann@example.com"}}, nil)
+
+	t.Run("POST", func(t *testing.T) {
+		body := `{"query": "query One($id: ID!) { user(id: $id) { email } }", "variables": {"id": "1"}}`
+		req := httptest.NewRequest(http.MethodPost, "/graphql", strings.NewReader(body))
+		rr := httptest.NewRecorder()
+		handler.Query(rr, req)
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.JSONEq(t, `{"data": {"user": {"email": "ann@example.com"}}}`, rr.Body.String())
+	})
+
+	t.Run("GET", func(t *testing.T) {
+		query := url.Values{"query": {`{ user(id: 1) { email } }`}}
+		req := httptest.NewRequest(http.MethodGet, "/graphql?"+query.Encode(), nil)
+		rr := httptest.NewRecorder()
+		handler.Query(rr, req)
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.JSONEq(t, `{"data": {"user": {"email": "ann@example.com"}}}`, rr.Body.String())
+	})
+}
+
+func TestGraphQL_BadRequests(t *testing.T) {
+	_, handler := newTestGraphQLHandler(t)
+
+	tests := []struct {
+		name       string
+		method     string
+		target     string
+		body       string
+		wantStatus int
+		wantCode   string
+	}{
+		{name: "invalid body", method: http.MethodPost, target: "/graphql", body: "{", wantStatus: http.StatusBadRequest, wantCode: gql.CodeBadUserInput},
+		{name: "invalid variables", method: http.MethodGet, target: "/graphql?query=%7Bx%7D&variables=nope", wantStatus: http.StatusBadRequest, wantCode: gql.CodeBadUserInput},
+		{name: "mutation over GET", method: http.MethodGet, target: "/graphql?query=" + url.QueryEscape(`mutation { deleteUser(id: 1) }`), wantStatus: http.StatusOK, wantCode: gql.CodeOperationNotAllowed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.target, strings.NewReader(tt.body))
+			rr := httptest.NewRecorder()
+			handler.Query(rr, req)
+
+			assert.Equal(t, tt.wantStatus, rr.Code)
+			assert.Contains(t, rr.Body.String(), `"code":"`+tt.wantCode+`"`)
+		})
+	}
+}
@@ -0,0 +1,82 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"go.uber.org/zap"
+)
+
+// HealthCheck reports whether a dependency is ready to serve traffic
+type HealthCheck func(ctx context.Context) error
+
+type namedCheck struct {
+	name  string
+	check HealthCheck
+}
+
+// HealthResponse represents the readiness status sent back to the client
+type HealthResponse struct {
+	Status string            `json:"status"`
+	Checks map[string]string `json:"checks"`
+}
+
+// HealthHandler handles liveness and readiness probes
+type HealthHandler struct {
+	checks []namedCheck
+	logger *zap.Logger
+}
+
+// NewHealthHandler creates a new HealthHandler
+func NewHealthHandler(logger *zap.Logger) *HealthHandler {
+	return &HealthHandler{
+		logger: logger,
+	}
+}
+
+// AddCheck registers a dependency check used by the readiness probe
+func (h *HealthHandler) AddCheck(name string, check HealthCheck) {
+	h.checks = append(h.checks, namedCheck{name: name, check: check})
+}
+
+// RegisterRoutes registers the probe routes with the router
+func (h *HealthHandler) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc("/healthz", h.Live).Methods(http.MethodGet)
+	router.HandleFunc("/readyz", h.Ready).Methods(http.MethodGet)
+}
+
+// Live reports that the process is up
+func (h *HealthHandler) Live(w http.ResponseWriter, _ *http.Request) {
+	w.WriteHeader(http.StatusOK)
+}
+
+// Ready reports whether all dependencies are ready, so that load balancers stop sending traffic otherwise
+func (h *HealthHandler) Ready(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 1*time.Second)
+	defer cancel()
+
+	code := http.StatusOK
+	response := HealthResponse{
+		Status: "ready",
+		Checks: make(map[string]string, len(h.checks)),
+	}
+	for _, c := range h.checks {
+		if err := c.check(ctx); err != nil {
+			h.logger.Warn("readiness check failed", zap.String("check", c.name), zap.Error(err))
+			code = http.StatusServiceUnavailable
+			response.Status = "unavailable"
+			response.Checks[c.name] = err.Error()
+			continue
+		}
+		response.Checks[c.name] = "ok"
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		h.logger.Error("failed to write response", zap.Error(err))
+	}
+}
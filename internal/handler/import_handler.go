@@ -0,0 +1,189 @@
+package handler
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/huberts90/restful-api/internal/domain"
+	"github.com/huberts90/restful-api/internal/importer"
+	"github.com/huberts90/restful-api/internal/jobs"
+	"github.com/huberts90/restful-api/internal/storage"
+	"go.uber.org/zap"
+)
+
+// ImportHandler handles bulk imports of users from files
+type ImportHandler struct {
+	responder
+	importer *importer.Importer
+	queue    storage.JobStorer
+	cfg      importer.Config
+	jobs     jobs.Config
+	logger   *zap.Logger
+}
+
+// NewImportHandler creates a new ImportHandler with the given dependencies
+// Files imported in the background are uploaded to the directory of the jobs
+func NewImportHandler(store importer.Store, queue storage.JobStorer, cfg importer.Config, jobsCfg jobs.Config, logger *zap.Logger) *ImportHandler {
+	return &ImportHandler{
+		responder: responder{logger: logger},
+		importer:  importer.New(store, cfg, logger),
+		queue:     queue,
+		cfg:       cfg,
+		jobs:      jobsCfg,
+		logger:    logger,
+	}
+}
+
+// RegisterRoutes registers the import route with the router
+func (h *ImportHandler) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc("/users/import", h.ImportUsers).Methods(http.MethodPost)
+}
+
+// ImportUsers handles POST /users/import requests with a text/csv or application/x-ndjson body
+// The report lists the rows that failed, as JSON or as a CSV download if the client accepts text/csv
+// With async=true the file is imported by a background job, whose result is the report
+func (h *ImportHandler) ImportUsers(w http.ResponseWriter, r *http.Request) {
+	dryRun, err := parseBoolQuery(r, "dry_run")
+	if err != nil {
+		h.respondWithError(w, http.StatusBadRequest, "Invalid dry_run")
+		return
+	}
+	async, err := parseBoolQuery(r, "async")
+	if err != nil {
+		h.respondWithError(w, http.StatusBadRequest, "Invalid async")
+		return
+	}
+
+	policy := domain.DuplicatePolicy(r.URL.Query().Get("on_duplicate"))
+	switch policy {
+	case "":
+		policy = domain.DuplicateFail
+	case domain.DuplicateSkip, domain.DuplicateFail, domain.DuplicateUpsert:
+	default:
+		h.respondWithError(w, http.StatusBadRequest, "Invalid on_duplicate, must be one of skip, fail or upsert")
+		return
+	}
+
+	// Files can take longer than regular requests to upload and store
+	deadline := time.Now().Add(h.cfg.Timeout)
+	rc := http.NewResponseController(w)
+	_ = rc.SetReadDeadline(deadline)
+	_ = rc.SetWriteDeadline(deadline)
+
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if async {
+		h.startImport(w, r, jobs.ImportPayload{MediaType: mediaType, Policy: policy, DryRun: dryRun})
+		return
+	}
+
+	reader, err := importer.NewReader(mediaType, r.Body)
+	if errors.Is(err, importer.ErrUnsupportedMediaType) {
+		h.respondWithError(w, http.StatusUnsupportedMediaType, "Content-Type must be text/csv or application/x-ndjson")
+		return
+	}
+	if err != nil {
+		h.respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	ctx, cancel := context.WithDeadline(r.Context(), deadline)
+	defer cancel()
+
+	status := http.StatusOK
+	report, err := h.importer.Import(ctx, reader, importer.Options{Policy: policy, DryRun: dryRun})
+	if err != nil {
+		if errors.Is(err, importer.ErrInvalidFile) {
+			status = http.StatusBadRequest
+			report.Error = err.Error()
+		} else {
+			h.logger.Error("Failed to import users", zap.Error(err), zap.Int("rows", report.Rows))
+			status = storeErrorStatus(err)
+			report.Error = "Failed to import users"
+		}
+	}
+
+	if strings.Contains(r.Header.Get("Accept"), "text/csv") {
+		h.respondWithCSVReport(w, status, report)
+		return
+	}
+	h.respondWithData(w, status, report)
+}
+
+// startImport uploads the file to the directory of the jobs and queues a job importing it
+func (h *ImportHandler) startImport(w http.ResponseWriter, r *http.Request, payload jobs.ImportPayload) {
+	if !importer.SupportsMediaType(payload.MediaType) {
+		h.respondWithError(w, http.StatusUnsupportedMediaType, "Content-Type must be text/csv or application/x-ndjson")
+		return
+	}
+
+	file, err := os.CreateTemp(h.jobs.Dir, jobs.UploadPattern)
+	if err != nil {
+		h.logger.Error("Failed to create upload file", zap.Error(err))
+		h.respondWithError(w, http.StatusInternalServerError, "Failed to start import")
+		return
+	}
+	payload.File = file.Name()
+
+	_, err = io.Copy(file, http.MaxBytesReader(w, r.Body, h.cfg.MaxUploadSize))
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		_ = os.Remove(payload.File)
+		h.respondWithError(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("Request body too large, the limit is %d bytes", maxBytesErr.Limit))
+		return
+	}
+	if err != nil {
+		_ = os.Remove(payload.File)
+		h.logger.Error("Failed to upload file", zap.Error(err))
+		h.respondWithError(w, http.StatusBadRequest, "Failed to upload file")
+		return
+	}
+
+	job, err := h.queue.EnqueueJob(r.Context(), jobs.TypeImportUsers, payload)
+	if err != nil {
+		_ = os.Remove(payload.File)
+		h.respondWithError(w, storeErrorStatus(err), "Failed to start import")
+		return
+	}
+	h.respondWithJob(w, r, "/users/import", job)
+}
+
+// respondWithCSVReport writes the errors of a report as a CSV download, the totals go in headers
+func (h *ImportHandler) respondWithCSVReport(w http.ResponseWriter, status int, report *domain.ImportReport) {
+	w.Header().Set("Content-Type", "text/csv")
+	w.Header().Set("Content-Disposition", `attachment; filename="import-report.csv"`)
+	w.Header().Set("X-Import-Dry-Run", strconv.FormatBool(report.DryRun))
+	w.Header().Set("X-Import-Rows", strconv.Itoa(report.Rows))
+	w.Header().Set("X-Import-Created", strconv.Itoa(report.Created))
+	w.Header().Set("X-Import-Updated", strconv.Itoa(report.Updated))
+	w.Header().Set("X-Import-Skipped", strconv.Itoa(report.Skipped))
+	w.Header().Set("X-Import-Failed", strconv.Itoa(report.Failed))
+	w.Header().Set("X-Import-Incomplete", strconv.FormatBool(report.Incomplete))
+	w.Header().Set("X-Import-Errors-Truncated", strconv.FormatBool(report.Truncated))
+	if report.Error != "" {
+		w.Header().Set("X-Import-Error", report.Error)
+	}
+	w.WriteHeader(status)
+
+	cw := csv.NewWriter(w)
+	_ = cw.Write([]string{"line", "column", "reason"})
+	for _, e := range report.Errors {
+		_ = cw.Write([]string{strconv.Itoa(e.Line), e.Column, e.Reason})
+	}
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		h.logger.Error("failed to write import report", zap.Error(err))
+	}
+}
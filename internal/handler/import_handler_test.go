@@ -0,0 +1,137 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/huberts90/restful-api/internal/domain"
+	"github.com/huberts90/restful-api/internal/importer"
+	"github.com/huberts90/restful-api/internal/jobs"
+	"github.com/huberts90/restful-api/internal/logger"
+	"github.com/huberts90/restful-api/internal/storage"
+	storagemocks "github.com/huberts90/restful-api/internal/storage/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+var testImportConfig = importer.Config{BatchSize: 100, MaxErrors: 100, Timeout: time.Minute, MaxUploadSize: 1024}
+
+func TestImportUsers(t *testing.T) {
+	mockStore := storagemocks.NewMockStorer(t)
+	handler := NewImportHandler(mockStore, nil, testImportConfig, jobs.Config{}, logger.NewNoOpLogger())
+
+	valid := domain.UserCreate{Email: "ann@example.com", FirstName: "Ann", LastName: "Lee"}
+	mockStore.On("ImportUsers", mock.Anything, []domain.UserCreate{valid}, domain.DuplicateSkip, true).
+		Return([]storage.ImportResult{{ID: 7, Outcome: storage.ImportCreated}}, nil)
+
+	body := "email,first_name,last_name\nann@example.com,Ann,Lee\nbob,Bob,Ray\n"
+	req := httptest.NewRequest(http.MethodPost, "/users/import?dry_run=true&on_duplicate=skip", strings.NewReader(body))
+	req.Header.Set("Content-Type", "text/csv; charset=utf-8")
+	rr := httptest.NewRecorder()
+	handler.ImportUsers(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+
+	var report domain.ImportReport
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &report))
+	assert.True(t, report.DryRun)
+	assert.Equal(t, 2, report.Rows)
+	assert.Equal(t, 1, report.Created)
+	assert.Equal(t, []domain.ImportError{{Line: 3, Column: "email", Reason: "is not a valid email address"}}, report.Errors)
+}
+
+func TestImportUsers_CSVReport(t *testing.T) {
+	handler := NewImportHandler(storagemocks.NewMockStorer(t), nil, testImportConfig, jobs.Config{}, logger.NewNoOpLogger())
+
+	body := `{"email": "bob", "first_name": "Bob", "last_name": "Ray"}` + "\n"
+	req := httptest.NewRequest(http.MethodPost, "/users/import", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/x-ndjson")
+	req.Header.Set("Accept", "text/csv")
+	rr := httptest.NewRecorder()
+	handler.ImportUsers(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, `attachment; filename="import-report.csv"`, rr.Header().Get("Content-Disposition"))
+	assert.Equal(t, "1", rr.Header().Get("X-Import-Failed"))
+	assert.Equal(t, "line,column,reason\n1,email,is not a valid email address\n", rr.Body.String())
+}
+
+func TestImportUsers_BadRequests(t *testing.T) {
+	handler := NewImportHandler(storagemocks.NewMockStorer(t), nil, testImportConfig, jobs.Config{}, logger.NewNoOpLogger())
+
+	tests := []struct {
+		name        string
+		query       string
+		contentType string
+		body        string
+		wantStatus  int
+	}{
+		{name: "unsupported content type", contentType: "application/json", body: "{}", wantStatus: http.StatusUnsupportedMediaType},
+		{name: "unknown duplicate policy", query: "?on_duplicate=merge", contentType: "text/csv", wantStatus: http.StatusBadRequest},
+		{name: "invalid dry run", query: "?dry_run=maybe", contentType: "text/csv", wantStatus: http.StatusBadRequest},
+		{name: "missing column", contentType: "text/csv", body: "email\nann@example.com\n", wantStatus: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/users/import"+tt.query, strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", tt.contentType)
+			rr := httptest.NewRecorder()
+			handler.ImportUsers(rr, req)
+
+			assert.Equal(t, tt.wantStatus, rr.Code)
+		})
+	}
+}
+
+func TestImportUsers_Async(t *testing.T) {
+	mockQueue := storagemocks.NewMockJobStorer(t)
+	jobsCfg := jobs.Config{Dir: t.TempDir()}
+	handler := NewImportHandler(storagemocks.NewMockStorer(t), mockQueue, testImportConfig, jobsCfg, logger.NewNoOpLogger())
+
+	body := "email,first_name,last_name\nann@example.com,Ann,Lee\n"
+	var payload jobs.ImportPayload
+	mockQueue.On("EnqueueJob", mock.Anything, jobs.TypeImportUsers, mock.Anything).
+		Run(func(args mock.Arguments) { payload = args.Get(2).(jobs.ImportPayload) }).
+		Return(&domain.Job{ID: 5, Type: jobs.TypeImportUsers, Status: domain.JobQueued}, nil)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/users/import?async=true&on_duplicate=upsert", strings.NewReader(body))
+	req.Header.Set("Content-Type", "text/csv")
+	rr := httptest.NewRecorder()
+	handler.ImportUsers(rr, req)
+
+	assert.Equal(t, http.StatusAccepted, rr.Code)
+	assert.Equal(t, "/api/jobs/5", rr.Header().Get("Location"))
+	assert.Equal(t, "text/csv", payload.MediaType)
+	assert.Equal(t, domain.DuplicateUpsert, payload.Policy)
+
+	uploaded, err := os.ReadFile(payload.File)
+	require.NoError(t, err)
+	assert.Equal(t, body, string(uploaded))
+	assert.Equal(t, jobsCfg.Dir, filepath.Dir(payload.File))
+}
+
+func TestImportUsers_AsyncTooLarge(t *testing.T) {
+	jobsCfg := jobs.Config{Dir: t.TempDir()}
+	handler := NewImportHandler(storagemocks.NewMockStorer(t), storagemocks.NewMockJobStorer(t), testImportConfig, jobsCfg, logger.NewNoOpLogger())
+
+	body := "email,first_name,last_name\n" + strings.Repeat("ann@example.com,Ann,Lee\n", 100)
+	req := httptest.NewRequest(http.MethodPost, "/api/users/import?async=true", strings.NewReader(body))
+	req.Header.Set("Content-Type", "text/csv")
+	rr := httptest.NewRecorder()
+	handler.ImportUsers(rr, req)
+
+	assert.Equal(t, http.StatusRequestEntityTooLarge, rr.Code)
+	assert.Contains(t, rr.Body.String(), "Request body too large, the limit is 1024 bytes")
+	// The partial upload is not left behind
+	uploads, err := filepath.Glob(filepath.Join(jobsCfg.Dir, jobs.UploadPattern))
+	require.NoError(t, err)
+	assert.Empty(t, uploads)
+}
@@ -0,0 +1,172 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/huberts90/restful-api/internal/domain"
+	"github.com/huberts90/restful-api/internal/jobs"
+	"github.com/huberts90/restful-api/internal/storage"
+	"go.uber.org/zap"
+)
+
+// JobHandler handles HTTP requests related to background jobs
+type JobHandler struct {
+	responder
+	store  storage.JobStorer
+	cfg    jobs.Config
+	logger *zap.Logger
+}
+
+// NewJobHandler creates a new JobHandler with the given dependencies
+func NewJobHandler(store storage.JobStorer, cfg jobs.Config, logger *zap.Logger) *JobHandler {
+	return &JobHandler{
+		responder: responder{logger: logger},
+		store:     store,
+		cfg:       cfg,
+		logger:    logger,
+	}
+}
+
+// RegisterRoutes registers all the job-related routes with the router
+func (h *JobHandler) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc("/jobs/{id:[0-9]+}", h.GetJob).Methods(http.MethodGet)
+	router.HandleFunc("/jobs/{id:[0-9]+}/cancel", h.CancelJob).Methods(http.MethodPost)
+	router.HandleFunc("/jobs/{id:[0-9]+}/download", h.DownloadJobFile).Methods(http.MethodGet)
+	router.HandleFunc("/users:purge", h.PurgeUsers).Methods(http.MethodPost)
+}
+
+// GetJob handles GET /jobs/{id} requests
+func (h *JobHandler) GetJob(w http.ResponseWriter, r *http.Request) {
+	job, ok := h.getJob(w, r)
+	if !ok {
+		return
+	}
+
+	if job.Status == domain.JobSucceeded && jobFile(job).FileName != "" {
+		job.DownloadURL = r.URL.Path + "/download"
+	}
+	h.respondWithData(w, http.StatusOK, job)
+}
+
+// CancelJob handles POST /jobs/{id}/cancel requests
+// Queued jobs are canceled right away, running ones shortly after
+func (h *JobHandler) CancelJob(w http.ResponseWriter, r *http.Request) {
+	id, err := parseIDVar(r, "id")
+	if err != nil {
+		h.respondWithError(w, http.StatusBadRequest, "Invalid job ID")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 1*time.Second)
+	defer cancel()
+
+	job, err := h.store.CancelJob(ctx, id)
+	switch {
+	case errors.Is(err, storage.ErrJobNotFound):
+		h.respondWithError(w, http.StatusNotFound, "Job not found")
+	case errors.Is(err, storage.ErrJobFinished):
+		h.respondWithError(w, http.StatusConflict, "Job already finished")
+	case err != nil:
+		h.logger.Error("Failed to cancel job", zap.Error(err), zap.Int64("id", id))
+		h.respondWithError(w, storeErrorStatus(err), "Failed to cancel job")
+	default:
+		h.respondWithData(w, http.StatusOK, job)
+	}
+}
+
+// DownloadJobFile handles GET /jobs/{id}/download requests for the file produced by a job
+// Range requests are supported, so that large downloads can be resumed
+func (h *JobHandler) DownloadJobFile(w http.ResponseWriter, r *http.Request) {
+	job, ok := h.getJob(w, r)
+	if !ok {
+		return
+	}
+
+	if job.Status != domain.JobSucceeded {
+		h.respondWithError(w, http.StatusConflict, "Job is "+string(job.Status))
+		return
+	}
+	file := jobFile(job)
+	if file.FileName == "" {
+		h.respondWithError(w, http.StatusNotFound, "Job has no file")
+		return
+	}
+
+	f, err := os.Open(h.cfg.FilePath(job.ID))
+	if err != nil {
+		h.logger.Error("Failed to open job file", zap.Error(err), zap.Int64("id", job.ID))
+		h.respondWithError(w, http.StatusNotFound, "Job file not found")
+		return
+	}
+	defer f.Close()
+
+	// Files of jobs are large by design, they take longer than regular requests to write
+	_ = http.NewResponseController(w).SetWriteDeadline(time.Now().Add(h.cfg.DownloadTimeout))
+
+	w.Header().Set("Content-Type", file.ContentType)
+	w.Header().Set("Content-Disposition", `attachment; filename="`+file.FileName+`"`)
+	http.ServeContent(w, r, file.FileName, *job.FinishedAt, f)
+}
+
+// PurgeUsers handles POST /users:purge requests
+// The users soft deleted for longer than the retention are purged by a background job
+func (h *JobHandler) PurgeUsers(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 1*time.Second)
+	defer cancel()
+
+	job, err := h.store.EnqueueJob(ctx, jobs.TypePurgeUsers, struct{}{})
+	if err != nil {
+		h.respondWithError(w, storeErrorStatus(err), "Failed to start purge")
+		return
+	}
+	h.respondWithJob(w, r, "/users:purge", job)
+}
+
+// Helper function to get the job of the request, it responds with an error if it cannot
+func (h *JobHandler) getJob(w http.ResponseWriter, r *http.Request) (*domain.Job, bool) {
+	id, err := parseIDVar(r, "id")
+	if err != nil {
+		h.respondWithError(w, http.StatusBadRequest, "Invalid job ID")
+		return nil, false
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 1*time.Second)
+	defer cancel()
+
+	job, err := h.store.GetJob(ctx, id)
+	if errors.Is(err, storage.ErrJobNotFound) {
+		h.respondWithError(w, http.StatusNotFound, "Job not found")
+		return nil, false
+	}
+	if err != nil {
+		h.logger.Error("Failed to get job", zap.Error(err), zap.Int64("id", id))
+		h.respondWithError(w, storeErrorStatus(err), "Failed to get job")
+		return nil, false
+	}
+	return job, true
+}
+
+// Helper function to read the file a job produced from its result, it is empty if there is none
+func jobFile(job *domain.Job) domain.JobFile {
+	var file domain.JobFile
+	if job.Result != nil {
+		_ = json.Unmarshal(job.Result, &file)
+	}
+	return file
+}
+
+// Helper function to respond to a request that started a job
+// The job can be followed at the URL in Location, found by replacing the route of the request
+func (h responder) respondWithJob(w http.ResponseWriter, r *http.Request, route string, job *domain.Job) {
+	location := strings.TrimSuffix(r.URL.Path, route) + "/jobs/" + strconv.FormatInt(job.ID, 10)
+	w.Header().Set("Location", location)
+	h.respondWithData(w, http.StatusAccepted, job)
+}
@@ -0,0 +1,111 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/huberts90/restful-api/internal/domain"
+	"github.com/huberts90/restful-api/internal/jobs"
+	"github.com/huberts90/restful-api/internal/logger"
+	"github.com/huberts90/restful-api/internal/storage"
+	storagemocks "github.com/huberts90/restful-api/internal/storage/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func setupJobRouter(t *testing.T) (*mux.Router, *storagemocks.MockJobStorer, jobs.Config) {
+	t.Helper()
+	mockStore := storagemocks.NewMockJobStorer(t)
+	cfg := jobs.Config{Dir: t.TempDir()}
+	router := mux.NewRouter()
+	NewJobHandler(mockStore, cfg, logger.NewNoOpLogger()).RegisterRoutes(router.PathPrefix("/api").Subrouter())
+	return router, mockStore, cfg
+}
+
+func TestGetJob(t *testing.T) {
+	router, mockStore, cfg := setupJobRouter(t)
+
+	finishedAt := time.Date(2023, 1, 1, 12, 0, 0, 0, time.UTC)
+	mockStore.On("GetJob", mock.Anything, int64(7)).Return(&domain.Job{
+		ID:         7,
+		Type:       jobs.TypeExportUsers,
+		Status:     domain.JobSucceeded,
+		Progress:   domain.JobProgress{Done: 2},
+		Result:     json.RawMessage(`{"file_name":"users.csv","content_type":"text/csv","rows":2}`),
+		FinishedAt: &finishedAt,
+	}, nil)
+	mockStore.On("GetJob", mock.Anything, int64(8)).Return(nil, storage.ErrJobNotFound)
+	require.NoError(t, os.WriteFile(cfg.FilePath(7), []byte("id\n1\n2\n"), 0o600))
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/jobs/7", nil))
+	require.Equal(t, http.StatusOK, rr.Code)
+	var job domain.Job
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &job))
+	assert.Equal(t, "/api/jobs/7/download", job.DownloadURL)
+
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, job.DownloadURL, nil))
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "text/csv", rr.Header().Get("Content-Type"))
+	assert.Equal(t, `attachment; filename="users.csv"`, rr.Header().Get("Content-Disposition"))
+	assert.Equal(t, "id\n1\n2\n", rr.Body.String())
+
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/jobs/8", nil))
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+}
+
+func TestDownloadJobFile_NotReady(t *testing.T) {
+	router, mockStore, _ := setupJobRouter(t)
+
+	mockStore.On("GetJob", mock.Anything, int64(7)).Return(&domain.Job{ID: 7, Type: jobs.TypeExportUsers, Status: domain.JobRunning}, nil)
+	mockStore.On("GetJob", mock.Anything, int64(8)).Return(&domain.Job{
+		ID:     8,
+		Type:   jobs.TypePurgeUsers,
+		Status: domain.JobSucceeded,
+		Result: json.RawMessage(`{"purged":3}`),
+	}, nil)
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/jobs/7/download", nil))
+	assert.Equal(t, http.StatusConflict, rr.Code)
+
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/jobs/8/download", nil))
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+}
+
+func TestCancelJob(t *testing.T) {
+	router, mockStore, _ := setupJobRouter(t)
+
+	mockStore.On("CancelJob", mock.Anything, int64(7)).Return(&domain.Job{ID: 7, Status: domain.JobRunning, CancelRequested: true}, nil)
+	mockStore.On("CancelJob", mock.Anything, int64(8)).Return(nil, storage.ErrJobFinished)
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/api/jobs/7/cancel", nil))
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Contains(t, rr.Body.String(), `"cancel_requested":true`)
+
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/api/jobs/8/cancel", nil))
+	assert.Equal(t, http.StatusConflict, rr.Code)
+}
+
+func TestPurgeUsers(t *testing.T) {
+	router, mockStore, _ := setupJobRouter(t)
+
+	mockStore.On("EnqueueJob", mock.Anything, jobs.TypePurgeUsers, struct{}{}).
+		Return(&domain.Job{ID: 4, Type: jobs.TypePurgeUsers, Status: domain.JobQueued}, nil)
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/api/users:purge", nil))
+	assert.Equal(t, http.StatusAccepted, rr.Code)
+	assert.Equal(t, "/api/jobs/4", rr.Header().Get("Location"))
+}
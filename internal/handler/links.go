@@ -0,0 +1,57 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/huberts90/restful-api/internal/domain"
+)
+
+// Helper function to build the links of a page of a list to itself and to the other pages
+// The links keep the query parameters of the request other than the page
+func pageLinks(r *http.Request, page, totalPages int) domain.PageLinks {
+	link := func(page int) domain.Link {
+		query := r.URL.Query()
+		query.Set("page", strconv.Itoa(page))
+		return domain.Link{Href: (&url.URL{Path: r.URL.Path, RawQuery: query.Encode()}).String()}
+	}
+
+	links := domain.PageLinks{
+		Self:  link(page),
+		First: link(1),
+		Last:  link(totalPages),
+	}
+	if page > 1 {
+		// A page past the end comes after the last one
+		prev := link(min(page-1, totalPages))
+		links.Prev = &prev
+	}
+	if page < totalPages {
+		next := link(page + 1)
+		links.Next = &next
+	}
+	return links
+}
+
+// Helper function to set the RFC 8288 Link header of a page of a list
+func setLinkHeader(w http.ResponseWriter, links domain.PageLinks) {
+	values := []string{
+		formatLink(links.First, "first"),
+	}
+	if links.Prev != nil {
+		values = append(values, formatLink(*links.Prev, "prev"))
+	}
+	if links.Next != nil {
+		values = append(values, formatLink(*links.Next, "next"))
+	}
+	values = append(values, formatLink(links.Last, "last"))
+
+	w.Header().Set("Link", strings.Join(values, ", "))
+}
+
+func formatLink(link domain.Link, rel string) string {
+	return fmt.Sprintf(`<%s>; rel="%s"`, link.Href, rel)
+}
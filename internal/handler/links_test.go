@@ -0,0 +1,104 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/huberts90/restful-api/internal/domain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestListUsers_Links(t *testing.T) {
+	tests := []struct {
+		name           string
+		path           string
+		page           int
+		totalCount     int
+		wantTotalPages int
+		wantLink       string
+		wantUserSelf   string
+	}{
+		{
+			name:           "partial last page",
+			path:           "/api/users?page=2&page_size=10",
+			page:           2,
+			totalCount:     21,
+			wantTotalPages: 3,
+			wantLink: `</api/users?page=1&page_size=10>; rel="first", </api/users?page=1&page_size=10>; rel="prev", ` +
+				`</api/users?page=3&page_size=10>; rel="next", </api/users?page=3&page_size=10>; rel="last"`,
+			wantUserSelf: "/api/users/7",
+		},
+		{
+			name:           "first page",
+			path:           "/api/v1/users?fields=id",
+			page:           1,
+			totalCount:     11,
+			wantTotalPages: 2,
+			wantLink:       `</api/v1/users?fields=id&page=1>; rel="first", </api/v1/users?fields=id&page=2>; rel="next", </api/v1/users?fields=id&page=2>; rel="last"`,
+			wantUserSelf:   "/api/v1/users/7",
+		},
+		{
+			name:           "no users",
+			path:           "/api/users",
+			page:           1,
+			totalCount:     0,
+			wantTotalPages: 1,
+			wantLink:       `</api/users?page=1>; rel="first", </api/users?page=1>; rel="last"`,
+			wantUserSelf:   "/api/users/7",
+		},
+		{
+			name:           "past the end",
+			path:           "/api/users?page=5",
+			page:           5,
+			totalCount:     10,
+			wantTotalPages: 1,
+			wantLink:       `</api/users?page=1>; rel="first", </api/users?page=1>; rel="prev", </api/users?page=1>; rel="last"`,
+			wantUserSelf:   "/api/users/7",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockStore, router := setupVersionedRouter(t, VersionConfig{})
+			mockStore.On("ListUsers", mock.Anything, tt.page, 10, mock.Anything).Return([]domain.User{{ID: 7}}, tt.totalCount, nil).Maybe()
+			mockStore.On("ListUsers", mock.Anything, tt.page, 10).Return([]domain.User{{ID: 7}}, tt.totalCount, nil).Maybe()
+
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, tt.path, nil))
+
+			require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+			assert.Equal(t, tt.wantLink, rr.Header().Get("Link"))
+
+			var response domain.PaginatedUsersResponse
+			require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &response))
+			assert.Equal(t, tt.wantTotalPages, response.TotalPages)
+
+			// The links of the body are those of the header
+			require.NotNil(t, response.Links)
+			assert.Contains(t, tt.wantLink, "<"+response.Links.First.Href+`>; rel="first"`)
+			assert.Contains(t, tt.wantLink, "<"+response.Links.Last.Href+`>; rel="last"`)
+			assert.Equal(t, tt.page > 1, response.Links.Prev != nil)
+			assert.Equal(t, tt.page < tt.wantTotalPages, response.Links.Next != nil)
+
+			require.Len(t, response.Users, 1)
+			assert.Equal(t, &domain.UserLinks{Self: domain.Link{Href: tt.wantUserSelf}}, response.Users[0].Links)
+		})
+	}
+}
+
+func TestGetUser_Links(t *testing.T) {
+	mockStore, router := setupVersionedRouter(t, VersionConfig{})
+	mockStore.On("GetUserByID", mock.Anything, int64(1)).Return(&domain.User{ID: 1}, nil)
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/v2/users/1", nil))
+
+	require.Equal(t, http.StatusOK, rr.Code)
+	var response domain.UserResponseV2
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &response))
+	assert.Equal(t, &domain.UserLinks{Self: domain.Link{Href: "/api/v2/users/1"}}, response.Links)
+}
@@ -0,0 +1,125 @@
+package handler
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/huberts90/restful-api/internal/domain"
+	"github.com/huberts90/restful-api/internal/storage"
+	"go.uber.org/zap"
+)
+
+// responder writes the JSON responses of the handlers
+type responder struct {
+	logger *zap.Logger
+}
+
+func (h responder) respondWithJSON(w http.ResponseWriter, code int, payload interface{}) {
+	w.Header().Set("Content-Type", jsonCodec.mediaType)
+	h.respond(w, code, payload)
+}
+
+// Helper function to respond in the media type negotiated for the response
+// Routes that negotiate set the Content-Type before they are handled, JSON is written otherwise
+func (h responder) respond(w http.ResponseWriter, code int, payload interface{}) {
+	c := codecFor(w.Header().Get("Content-Type"))
+	if c == nil {
+		c = jsonCodec
+		w.Header().Set("Content-Type", c.mediaType)
+	}
+
+	var response bytes.Buffer
+	if err := c.encode(&response, payload); err != nil {
+		h.logger.Error("failed to encode response", zap.Error(err), zap.String("media_type", c.mediaType))
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(code)
+	if _, err := w.Write(response.Bytes()); err != nil {
+		h.logger.Error("failed to write response", zap.Error(err))
+	}
+}
+
+// Helper function to respond with an error
+// Standardizes error response format across the API
+func (h responder) respondWithError(w http.ResponseWriter, code int, message string) {
+	h.respond(w, code, domain.ErrorResponse{Error: message})
+}
+
+// Helper function to respond with data
+// Centralizes data response creation to avoid code duplication
+func (h responder) respondWithData(w http.ResponseWriter, code int, payload interface{}) {
+	h.respond(w, code, payload)
+}
+
+// Helper function to respond to a request body that cannot be decoded
+// Bodies in a media type the handler cannot read get a 415, bodies over the limit a 413, and the
+// others a 400 with the given message followed by the field or offset that failed
+func (h responder) respondWithDecodeError(w http.ResponseWriter, err error, message string) {
+	code, message := decodeErrorStatus(err, message)
+	h.respondWithError(w, code, message)
+}
+
+// negotiated picks the media type of the response from the Accept header before handling a request
+// Requests accepting none of the media types of the codecs get a 406
+func (h responder) negotiated(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Accept")
+
+		c, mediaType := negotiate(r.Header.Values("Accept"))
+		if c == nil {
+			h.respondWithError(w, http.StatusNotAcceptable, "Not acceptable, the supported media types are "+supportedMediaTypes())
+			return
+		}
+
+		w.Header().Set("Content-Type", mediaType)
+		next(w, r)
+	}
+}
+
+// Helper function to list the media types of the codecs
+func supportedMediaTypes() string {
+	mediaTypes := make([]string, len(codecs))
+	for i, c := range codecs {
+		mediaTypes[i] = c.mediaType
+	}
+	return strings.Join(mediaTypes, ", ")
+}
+
+// Helper function to pick the status code of an unexpected store error
+// An open circuit breaker means the database is unavailable, so clients should retry later
+func storeErrorStatus(err error) int {
+	if errors.Is(err, storage.ErrCircuitOpen) {
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusInternalServerError
+}
+
+// Helper function to parse the page and page_size query parameters
+// Missing or invalid values fall back to the first page of 10 items
+func parsePagination(r *http.Request) (int, int) {
+	page, err := strconv.Atoi(r.URL.Query().Get("page"))
+	if err != nil || page <= 0 {
+		page = 1
+	}
+
+	pageSize, err := strconv.Atoi(r.URL.Query().Get("page_size"))
+	if err != nil || pageSize <= 0 || pageSize > 100 {
+		pageSize = 10
+	}
+
+	return page, pageSize
+}
+
+// Helper function to parse an optional boolean query parameter, false if it is missing
+func parseBoolQuery(r *http.Request, name string) (bool, error) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return false, nil
+	}
+	return strconv.ParseBool(value)
+}
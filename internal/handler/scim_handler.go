@@ -0,0 +1,395 @@
+package handler
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/huberts90/restful-api/internal/domain"
+	"github.com/huberts90/restful-api/internal/scim"
+	"github.com/huberts90/restful-api/internal/storage"
+	"go.uber.org/zap"
+)
+
+// SCIMPathPrefix is the path the SCIM endpoint is served under
+const SCIMPathPrefix = "/scim/v2"
+
+// SCIMHandler handles the SCIM 2.0 requests identity providers provision users with
+// Deleted users are inactive users, so reads include them and deactivating a user deletes it
+type SCIMHandler struct {
+	store  storage.Storer
+	cfg    scim.Config
+	limits BodyLimits
+	logger *zap.Logger
+}
+
+// NewSCIMHandler creates a new SCIMHandler with the given dependencies
+func NewSCIMHandler(store storage.Storer, cfg scim.Config, limits BodyLimits, logger *zap.Logger) *SCIMHandler {
+	return &SCIMHandler{
+		store:  store,
+		cfg:    cfg,
+		limits: limits,
+		logger: logger,
+	}
+}
+
+// RegisterRoutes registers the SCIM routes with a router serving SCIMPathPrefix, and authenticates its requests
+func (h *SCIMHandler) RegisterRoutes(router *mux.Router) {
+	router.Use(h.authenticate)
+	router.HandleFunc("/Users", h.CreateUser).Methods(http.MethodPost)
+	router.HandleFunc("/Users", h.ListUsers).Methods(http.MethodGet)
+	router.HandleFunc("/Users/{id}", h.GetUser).Methods(http.MethodGet)
+	router.HandleFunc("/Users/{id}", h.ReplaceUser).Methods(http.MethodPut)
+	router.HandleFunc("/Users/{id}", h.PatchUser).Methods(http.MethodPatch)
+	router.HandleFunc("/Users/{id}", h.DeleteUser).Methods(http.MethodDelete)
+	router.HandleFunc("/ServiceProviderConfig", h.GetServiceProviderConfig).Methods(http.MethodGet)
+	router.HandleFunc("/ResourceTypes", h.ListResourceTypes).Methods(http.MethodGet)
+	router.HandleFunc("/ResourceTypes/{id}", h.GetResourceType).Methods(http.MethodGet)
+	router.HandleFunc("/Schemas", h.ListSchemas).Methods(http.MethodGet)
+	router.HandleFunc("/Schemas/{id}", h.GetSchema).Methods(http.MethodGet)
+}
+
+// authenticate checks the bearer token of the identity provider, unless no token is configured
+func (h *SCIMHandler) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if h.cfg.Token != "" {
+			want := []byte("Bearer " + h.cfg.Token)
+			if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), want) != 1 {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="scim"`)
+				h.respondWithSCIMError(w, scim.NewError(http.StatusUnauthorized, "", "Invalid bearer token"))
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// CreateUser handles the provisioning of a new user
+func (h *SCIMHandler) CreateUser(w http.ResponseWriter, r *http.Request) {
+	var user scim.User
+	if err := h.decodeBody(w, r, &user); err != nil {
+		h.respondWithSCIMError(w, err)
+		return
+	}
+
+	userCreate := user.Create()
+	if err := userCreate.Validate(); err != nil {
+		h.respondWithSCIMError(w, scim.NewError(http.StatusBadRequest, scim.ErrorInvalidValue, err.Error()))
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 500*time.Millisecond)
+	defer cancel()
+
+	id, err := h.store.CreateUser(ctx, userCreate)
+	if err == nil && !user.IsActive() {
+		err = h.store.DeleteUser(ctx, id)
+	}
+	if err != nil {
+		h.respondWithStoreError(w, err, "Failed to create user")
+		return
+	}
+
+	created, err := h.store.GetUserByID(ctx, id, storage.IncludeDeleted())
+	if err != nil {
+		h.respondWithStoreError(w, err, "Failed to get user")
+		return
+	}
+
+	location := h.userLocation(created.ID)
+	w.Header().Set("Location", location)
+	h.respondWithSCIM(w, http.StatusCreated, scim.NewUser(*created, location))
+}
+
+// GetUser handles retrieving a user by ID
+func (h *SCIMHandler) GetUser(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 300*time.Millisecond)
+	defer cancel()
+
+	user, ok := h.loadUser(ctx, w, r)
+	if !ok {
+		return
+	}
+
+	h.respondWithSCIM(w, http.StatusOK, scim.NewUser(*user, h.userLocation(user.ID)))
+}
+
+// ListUsers handles retrieving a page of the users matching a filter
+// Pages are given by a 1-based startIndex and a count, which is capped by the maximum number of results
+func (h *SCIMHandler) ListUsers(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	var filter storage.UserFilter
+	if f := query.Get("filter"); f != "" {
+		var err error
+		if filter, err = scim.ParseFilter(f); err != nil {
+			h.respondWithSCIMError(w, err)
+			return
+		}
+	}
+
+	startIndex, err := strconv.Atoi(query.Get("startIndex"))
+	if err != nil || startIndex < 1 {
+		startIndex = 1
+	}
+	count, err := strconv.Atoi(query.Get("count"))
+	if err != nil || count > h.cfg.MaxResults {
+		count = h.cfg.MaxResults
+	}
+	if count < 0 {
+		count = 0
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 1*time.Second)
+	defer cancel()
+
+	users, totalCount, err := h.store.SearchUsers(ctx, filter, startIndex-1, count, storage.IncludeDeleted())
+	if err != nil {
+		h.respondWithStoreError(w, err, "Failed to list users")
+		return
+	}
+
+	resources := make([]scim.User, len(users))
+	for i, user := range users {
+		resources[i] = scim.NewUser(user, h.userLocation(user.ID))
+	}
+
+	h.respondWithSCIM(w, http.StatusOK, scim.ListResponse{
+		Schemas:      []string{scim.SchemaListResponse},
+		TotalResults: totalCount,
+		StartIndex:   startIndex,
+		ItemsPerPage: len(resources),
+		Resources:    resources,
+	})
+}
+
+// ReplaceUser handles replacing the attributes of a user
+func (h *SCIMHandler) ReplaceUser(w http.ResponseWriter, r *http.Request) {
+	var desired scim.User
+	if err := h.decodeBody(w, r, &desired); err != nil {
+		h.respondWithSCIMError(w, err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 500*time.Millisecond)
+	defer cancel()
+
+	current, ok := h.loadUser(ctx, w, r)
+	if !ok {
+		return
+	}
+
+	h.saveUser(ctx, w, current, desired)
+}
+
+// PatchUser handles changing some attributes of a user
+func (h *SCIMHandler) PatchUser(w http.ResponseWriter, r *http.Request) {
+	var patch scim.PatchOp
+	if err := h.decodeBody(w, r, &patch); err != nil {
+		h.respondWithSCIMError(w, err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 500*time.Millisecond)
+	defer cancel()
+
+	current, ok := h.loadUser(ctx, w, r)
+	if !ok {
+		return
+	}
+
+	desired := scim.NewUser(*current, h.userLocation(current.ID))
+	if err := patch.Apply(&desired); err != nil {
+		h.respondWithSCIMError(w, err)
+		return
+	}
+
+	h.saveUser(ctx, w, current, desired)
+}
+
+// DeleteUser handles deprovisioning a user, which soft deletes it
+func (h *SCIMHandler) DeleteUser(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil || id <= 0 {
+		h.respondWithSCIMError(w, scim.NewError(http.StatusNotFound, "", "User not found"))
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 300*time.Millisecond)
+	defer cancel()
+
+	if err := h.store.DeleteUser(ctx, id); err != nil {
+		h.respondWithStoreError(w, err, "Failed to delete user")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetServiceProviderConfig handles describing the features of the endpoint
+func (h *SCIMHandler) GetServiceProviderConfig(w http.ResponseWriter, r *http.Request) {
+	h.respondWithSCIM(w, http.StatusOK, scim.NewServiceProviderConfig(h.cfg, SCIMPathPrefix))
+}
+
+// ListResourceTypes handles listing the types of resources the endpoint serves
+func (h *SCIMHandler) ListResourceTypes(w http.ResponseWriter, r *http.Request) {
+	resourceTypes := scim.NewResourceTypes(SCIMPathPrefix)
+	h.respondWithSCIM(w, http.StatusOK, scim.ListResponse{
+		Schemas:      []string{scim.SchemaListResponse},
+		TotalResults: len(resourceTypes),
+		StartIndex:   1,
+		ItemsPerPage: len(resourceTypes),
+		Resources:    resourceTypes,
+	})
+}
+
+// GetResourceType handles retrieving a type of resource by ID
+func (h *SCIMHandler) GetResourceType(w http.ResponseWriter, r *http.Request) {
+	for _, resourceType := range scim.NewResourceTypes(SCIMPathPrefix) {
+		if resourceType.ID == mux.Vars(r)["id"] {
+			h.respondWithSCIM(w, http.StatusOK, resourceType)
+			return
+		}
+	}
+	h.respondWithSCIMError(w, scim.NewError(http.StatusNotFound, "", "Resource type not found"))
+}
+
+// ListSchemas handles listing the schemas of the resources
+func (h *SCIMHandler) ListSchemas(w http.ResponseWriter, r *http.Request) {
+	schemas := scim.NewSchemas(SCIMPathPrefix)
+	h.respondWithSCIM(w, http.StatusOK, scim.ListResponse{
+		Schemas:      []string{scim.SchemaListResponse},
+		TotalResults: len(schemas),
+		StartIndex:   1,
+		ItemsPerPage: len(schemas),
+		Resources:    schemas,
+	})
+}
+
+// GetSchema handles retrieving a schema by its URN
+func (h *SCIMHandler) GetSchema(w http.ResponseWriter, r *http.Request) {
+	for _, schema := range scim.NewSchemas(SCIMPathPrefix) {
+		if schema.ID == mux.Vars(r)["id"] {
+			h.respondWithSCIM(w, http.StatusOK, schema)
+			return
+		}
+	}
+	h.respondWithSCIMError(w, scim.NewError(http.StatusNotFound, "", "Schema not found"))
+}
+
+// Helper function to load the user of the URL, deleted users included
+// Responds with an error and returns false if the user cannot be loaded
+func (h *SCIMHandler) loadUser(ctx context.Context, w http.ResponseWriter, r *http.Request) (*domain.User, bool) {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil || id <= 0 {
+		h.respondWithSCIMError(w, scim.NewError(http.StatusNotFound, "", "User not found"))
+		return nil, false
+	}
+
+	user, err := h.store.GetUserByID(ctx, id, storage.IncludeDeleted())
+	if err != nil {
+		h.respondWithStoreError(w, err, "Failed to get user")
+		return nil, false
+	}
+	return user, true
+}
+
+// Helper function to store the desired state of a user and respond with the result
+// Restoring, updating and deleting the user are applied together, so a failed request leaves the user as it was
+func (h *SCIMHandler) saveUser(ctx context.Context, w http.ResponseWriter, current *domain.User, desired scim.User) {
+	want := desired.Create()
+	if err := want.Validate(); err != nil {
+		h.respondWithSCIMError(w, scim.NewError(http.StatusBadRequest, scim.ErrorInvalidValue, err.Error()))
+		return
+	}
+
+	wasActive := current.DeletedAt == nil
+	active := desired.IsActive()
+	changed := want.Email != current.Email || want.FirstName != current.FirstName || want.LastName != current.LastName
+	if changed && !wasActive && !active {
+		h.respondWithSCIMError(w, scim.NewError(http.StatusBadRequest, scim.ErrorMutability, "Inactive users cannot be changed"))
+		return
+	}
+
+	var update domain.UserUpdate
+	if changed {
+		update = domain.UserUpdate{Email: want.Email, FirstName: want.FirstName, LastName: want.LastName}
+	}
+	if err := h.store.ReplaceUser(ctx, current.ID, update, active); err != nil {
+		h.respondWithStoreError(w, err, "Failed to update user")
+		return
+	}
+
+	updated, err := h.store.GetUserByID(ctx, current.ID, storage.IncludeDeleted())
+	if err != nil {
+		h.respondWithStoreError(w, err, "Failed to get user")
+		return
+	}
+
+	h.respondWithSCIM(w, http.StatusOK, scim.NewUser(*updated, h.userLocation(updated.ID)))
+}
+
+// Helper function to build the location of a user
+func (h *SCIMHandler) userLocation(id int64) string {
+	return SCIMPathPrefix + "/Users/" + strconv.FormatInt(id, 10)
+}
+
+// Helper function to decode the JSON body of a SCIM request
+// Identity providers send attributes the users do not have, such as displayName, so unknown fields are ignored
+func (h *SCIMHandler) decodeBody(w http.ResponseWriter, r *http.Request, v interface{}) error {
+	err := decodeJSONBody(w, r, v, h.limits.Default, true)
+	if err == nil {
+		return nil
+	}
+	code, message := decodeErrorStatus(err, "Invalid request body")
+	if code == http.StatusBadRequest {
+		return scim.NewError(code, scim.ErrorInvalidSyntax, message)
+	}
+	return scim.NewError(code, "", message)
+}
+
+// Helper function to respond with a SCIM resource or message
+func (h *SCIMHandler) respondWithSCIM(w http.ResponseWriter, code int, payload interface{}) {
+	response, err := json.Marshal(payload)
+	if err != nil {
+		h.logger.Error("failed to marshal SCIM response", zap.Error(err))
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", scim.MediaType)
+	w.WriteHeader(code)
+	if _, err = w.Write(response); err != nil {
+		h.logger.Error("failed to write response", zap.Error(err))
+	}
+}
+
+// Helper function to respond with a SCIM error, errors other than SCIM errors are internal errors
+func (h *SCIMHandler) respondWithSCIMError(w http.ResponseWriter, err error) {
+	var scimErr *scim.Error
+	if !errors.As(err, &scimErr) {
+		scimErr = scim.NewError(http.StatusInternalServerError, "", "Internal error")
+	}
+
+	code, _ := strconv.Atoi(scimErr.Status)
+	h.respondWithSCIM(w, code, scimErr)
+}
+
+// Helper function to respond with the SCIM error of a store error
+func (h *SCIMHandler) respondWithStoreError(w http.ResponseWriter, err error, message string) {
+	switch {
+	case errors.Is(err, storage.ErrUserNotFound):
+		h.respondWithSCIMError(w, scim.NewError(http.StatusNotFound, "", "User not found"))
+	case errors.Is(err, storage.ErrDuplicateEmail):
+		h.respondWithSCIMError(w, scim.NewError(http.StatusConflict, scim.ErrorUniqueness, "userName already exists"))
+	default:
+		h.logger.Error(message, zap.Error(err))
+		status := storeErrorStatus(err)
+		h.respondWithSCIMError(w, scim.NewError(status, "", message))
+	}
+}
@@ -0,0 +1,282 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/huberts90/restful-api/internal/domain"
+	"github.com/huberts90/restful-api/internal/logger"
+	"github.com/huberts90/restful-api/internal/scim"
+	"github.com/huberts90/restful-api/internal/storage"
+	storagemocks "github.com/huberts90/restful-api/internal/storage/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// scimStep is a request of the validator fixture, along with the status and the subset of the response expected
+// Values of the form ${name} are replaced by the ID of the user a previous step captured under that name
+type scimStep struct {
+	Name     string          `json:"name"`
+	Method   string          `json:"method"`
+	Path     string          `json:"path"`
+	Body     json.RawMessage `json:"body"`
+	Status   int             `json:"status"`
+	Response json.RawMessage `json:"response"`
+	Capture  string          `json:"capture"`
+}
+
+// TestSCIMValidator runs the provisioning flows of an identity provider against an in-memory store
+func TestSCIMValidator(t *testing.T) {
+	fixture, err := os.ReadFile("testdata/scim_validator.json")
+	require.NoError(t, err)
+	var steps []scimStep
+	require.NoError(t, json.Unmarshal(fixture, &steps))
+
+	router := setupSCIMRouter(newMemoryStore(), scim.Config{Token: "secret", MaxResults: 2})
+	ids := map[string]string{}
+	expand := func(s string) string {
+		for name, id := range ids {
+			s = strings.ReplaceAll(s, "${"+name+"}", id)
+		}
+		return s
+	}
+
+	for _, step := range steps {
+		ok := t.Run(step.Name, func(t *testing.T) {
+			req := httptest.NewRequest(step.Method, SCIMPathPrefix+expand(step.Path), strings.NewReader(expand(string(step.Body))))
+			req.Header.Set("Authorization", "Bearer secret")
+			req.Header.Set("Content-Type", scim.MediaType)
+			rr := httptest.NewRecorder()
+
+			router.ServeHTTP(rr, req)
+
+			require.Equal(t, step.Status, rr.Code, rr.Body.String())
+			if step.Response != nil {
+				assert.Equal(t, scim.MediaType, rr.Header().Get("Content-Type"))
+
+				var want, got interface{}
+				require.NoError(t, json.Unmarshal([]byte(expand(string(step.Response))), &want))
+				require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &got))
+				assertSubset(t, want, got, "response")
+			}
+			if step.Capture != "" {
+				var user scim.User
+				require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &user))
+				ids[step.Capture] = user.ID
+				assert.Equal(t, SCIMPathPrefix+"/Users/"+user.ID, rr.Header().Get("Location"))
+			}
+		})
+		if !ok {
+			// Later steps depend on the earlier ones
+			break
+		}
+	}
+}
+
+func TestSCIMHandler_Authentication(t *testing.T) {
+	router := setupSCIMRouter(storagemocks.NewMockStorer(t), scim.Config{Token: "secret", MaxResults: 10})
+
+	for _, header := range []string{"", "Bearer wrong", "secret"} {
+		req := httptest.NewRequest(http.MethodGet, SCIMPathPrefix+"/Users", nil)
+		req.Header.Set("Authorization", header)
+		rr := httptest.NewRecorder()
+
+		router.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusUnauthorized, rr.Code)
+		assert.NotEmpty(t, rr.Header().Get("WWW-Authenticate"))
+	}
+}
+
+func TestSCIMHandler_StoreUnavailable(t *testing.T) {
+	mockStore := storagemocks.NewMockStorer(t)
+	mockStore.On("SearchUsers", mock.Anything, nil, 0, 10, mock.Anything).Return(nil, 0, storage.ErrCircuitOpen)
+	router := setupSCIMRouter(mockStore, scim.Config{MaxResults: 10})
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, SCIMPathPrefix+"/Users", nil))
+
+	assert.Equal(t, http.StatusServiceUnavailable, rr.Code)
+	var scimErr scim.Error
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &scimErr))
+	assert.Equal(t, "503", scimErr.Status)
+}
+
+func setupSCIMRouter(store storage.Storer, cfg scim.Config) *mux.Router {
+	router := mux.NewRouter()
+	NewSCIMHandler(store, cfg, DefaultBodyLimits, logger.NewNoOpLogger()).RegisterRoutes(router.PathPrefix(SCIMPathPrefix).Subrouter())
+	return router
+}
+
+// assertSubset checks that every value of want is in got, lists must have the same length
+func assertSubset(t *testing.T, want, got interface{}, path string) {
+	t.Helper()
+
+	switch want := want.(type) {
+	case map[string]interface{}:
+		gotMap, ok := got.(map[string]interface{})
+		if !assert.True(t, ok, "%s: want an object, got %v", path, got) {
+			return
+		}
+		for key, value := range want {
+			assertSubset(t, value, gotMap[key], path+"."+key)
+		}
+	case []interface{}:
+		gotList, ok := got.([]interface{})
+		if !assert.True(t, ok, "%s: want a list, got %v", path, got) || !assert.Len(t, gotList, len(want), path) {
+			return
+		}
+		for i := range want {
+			assertSubset(t, want[i], gotList[i], path+"["+strconv.Itoa(i)+"]")
+		}
+	default:
+		assert.Equal(t, want, got, path)
+	}
+}
+
+// memoryStore is a store of the user methods the SCIM endpoint uses, which keeps users in memory
+type memoryStore struct {
+	storage.Storer
+	users  map[int64]*domain.User
+	nextID int64
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{users: map[int64]*domain.User{}, nextID: 1}
+}
+
+func (s *memoryStore) CreateUser(_ context.Context, user domain.UserCreate) (int64, error) {
+	if s.emailTaken(user.Email, 0) {
+		return 0, storage.ErrDuplicateEmail
+	}
+
+	now := time.Now().UTC()
+	id := s.nextID
+	s.nextID++
+	s.users[id] = &domain.User{ID: id, Email: user.Email, FirstName: user.FirstName, LastName: user.LastName, CreatedAt: now, UpdatedAt: now}
+	return id, nil
+}
+
+func (s *memoryStore) GetUserByID(_ context.Context, id int64, opts ...storage.ReadOption) (*domain.User, error) {
+	user, ok := s.users[id]
+	if !ok || (user.DeletedAt != nil && !storage.NewReadOptions(opts...).IncludeDeleted) {
+		return nil, storage.ErrUserNotFound
+	}
+	u := *user
+	return &u, nil
+}
+
+func (s *memoryStore) UpdateUser(_ context.Context, id int64, update domain.UserUpdate) error {
+	user, ok := s.users[id]
+	if !ok || user.DeletedAt != nil {
+		return storage.ErrUserNotFound
+	}
+	if update.Email != "" && s.emailTaken(update.Email, id) {
+		return storage.ErrDuplicateEmail
+	}
+
+	if update.Email != "" {
+		user.Email = update.Email
+	}
+	if update.FirstName != "" {
+		user.FirstName = update.FirstName
+	}
+	if update.LastName != "" {
+		user.LastName = update.LastName
+	}
+	user.UpdatedAt = time.Now().UTC()
+	return nil
+}
+
+func (s *memoryStore) DeleteUser(_ context.Context, id int64) error {
+	user, ok := s.users[id]
+	if !ok || user.DeletedAt != nil {
+		return storage.ErrUserNotFound
+	}
+	now := time.Now().UTC()
+	user.DeletedAt = &now
+	return nil
+}
+
+func (s *memoryStore) RestoreUser(_ context.Context, id int64) error {
+	user, ok := s.users[id]
+	if !ok || user.DeletedAt == nil {
+		return storage.ErrUserNotFound
+	}
+	if s.emailTaken(user.Email, id) {
+		return storage.ErrDuplicateEmail
+	}
+	user.DeletedAt = nil
+	return nil
+}
+
+func (s *memoryStore) ReplaceUser(ctx context.Context, id int64, update domain.UserUpdate, active bool) error {
+	user, ok := s.users[id]
+	if !ok {
+		return storage.ErrUserNotFound
+	}
+	// The steps are undone if one fails, like the transaction of the Postgres store
+	before := *user
+	err := func() error {
+		wasActive := user.DeletedAt == nil
+		if active && !wasActive {
+			if err := s.RestoreUser(ctx, id); err != nil {
+				return err
+			}
+		}
+		if update != (domain.UserUpdate{}) {
+			if err := s.UpdateUser(ctx, id, update); err != nil {
+				return err
+			}
+		}
+		if wasActive && !active {
+			return s.DeleteUser(ctx, id)
+		}
+		return nil
+	}()
+	if err != nil {
+		*user = before
+	}
+	return err
+}
+
+func (s *memoryStore) SearchUsers(_ context.Context, filter storage.UserFilter, offset, limit int, opts ...storage.ReadOption) ([]domain.User, int, error) {
+	includeDeleted := storage.NewReadOptions(opts...).IncludeDeleted
+
+	var matching []domain.User
+	for _, user := range s.users {
+		if (includeDeleted || user.DeletedAt == nil) && (filter == nil || filter.Matches(*user)) {
+			matching = append(matching, *user)
+		}
+	}
+	sort.Slice(matching, func(i, j int) bool { return matching[i].ID < matching[j].ID })
+
+	if offset > len(matching) {
+		offset = len(matching)
+	}
+	end := offset + limit
+	if end > len(matching) {
+		end = len(matching)
+	}
+	return matching[offset:end], len(matching), nil
+}
+
+// emailTaken reports whether a live user other than the given one has the email
+func (s *memoryStore) emailTaken(email string, id int64) bool {
+	for _, user := range s.users {
+		if user.ID != id && user.DeletedAt == nil && strings.EqualFold(user.Email, email) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,262 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/huberts90/restful-api/internal/domain"
+	"github.com/huberts90/restful-api/internal/storage"
+	"go.uber.org/zap"
+)
+
+// BatchConfig holds the limits of the batch endpoints
+type BatchConfig struct {
+	MaxItems int
+	Timeout  time.Duration
+}
+
+// DefaultBatchConfig is used unless the handler is given another one
+var DefaultBatchConfig = BatchConfig{
+	MaxItems: 1000,
+	Timeout:  10 * time.Second,
+}
+
+// UserHandlerOption configures a UserHandler
+type UserHandlerOption func(*UserHandler)
+
+// WithBatchConfig sets the limits of the batch endpoints
+func WithBatchConfig(cfg BatchConfig) UserHandlerOption {
+	return func(h *UserHandler) {
+		h.batch = cfg
+	}
+}
+
+// batchOp describes a batch endpoint to runBatch
+type batchOp struct {
+	name          string
+	mode          domain.BatchMode
+	items         int
+	successStatus int
+	// validate checks item i before anything is stored
+	validate func(i int) error
+	// apply stores the items at the given indexes, its results are in the same order
+	apply func(ctx context.Context, indexes []int, atomic bool) ([]storage.BatchResult, error)
+}
+
+// BatchCreateUsers handles POST /users:batchCreate
+func (h *UserHandler) BatchCreateUsers(w http.ResponseWriter, r *http.Request) {
+	mode, items, err := h.version(r).batchCreate(w, r, h.limits.Batch)
+	if err != nil {
+		h.respondWithDecodeError(w, err, "Invalid request body")
+		return
+	}
+
+	h.runBatch(w, r, batchOp{
+		name:          "create",
+		mode:          mode,
+		items:         len(items),
+		successStatus: http.StatusCreated,
+		validate: func(i int) error {
+			return items[i].Validate()
+		},
+		apply: func(ctx context.Context, indexes []int, atomic bool) ([]storage.BatchResult, error) {
+			users := make([]domain.UserCreate, len(indexes))
+			for j, i := range indexes {
+				users[j] = items[i].ToCreate()
+			}
+			return h.store.CreateUsers(ctx, users, atomic)
+		},
+	})
+}
+
+// BatchUpdateUsers handles POST /users:batchUpdate
+func (h *UserHandler) BatchUpdateUsers(w http.ResponseWriter, r *http.Request) {
+	mode, items, err := h.version(r).batchUpdate(w, r, h.limits.Batch)
+	if err != nil {
+		h.respondWithDecodeError(w, err, "Invalid request body")
+		return
+	}
+
+	seen := make(map[int64]bool, len(items))
+	h.runBatch(w, r, batchOp{
+		name:          "update",
+		mode:          mode,
+		items:         len(items),
+		successStatus: http.StatusOK,
+		validate: func(i int) error {
+			if err := items[i].Validate(); err != nil {
+				return err
+			}
+			return checkUniqueID(seen, items[i].ToBatchUpdate().ID)
+		},
+		apply: func(ctx context.Context, indexes []int, atomic bool) ([]storage.BatchResult, error) {
+			updates := make([]domain.UserBatchUpdate, len(indexes))
+			for j, i := range indexes {
+				updates[j] = items[i].ToBatchUpdate()
+			}
+			return h.store.UpdateUsers(ctx, updates, atomic)
+		},
+	})
+}
+
+// BatchDeleteUsers handles POST /users:batchDelete
+func (h *UserHandler) BatchDeleteUsers(w http.ResponseWriter, r *http.Request) {
+	var req domain.BatchDeleteRequest
+	if err := decodeBody(w, r, &req, h.limits.Batch); err != nil {
+		h.respondWithDecodeError(w, err, "Invalid request body")
+		return
+	}
+
+	seen := make(map[int64]bool, len(req.Items))
+	h.runBatch(w, r, batchOp{
+		name:          "delete",
+		mode:          req.Mode,
+		items:         len(req.Items),
+		successStatus: http.StatusOK,
+		validate: func(i int) error {
+			if req.Items[i].ID <= 0 {
+				return errors.New("invalid user ID")
+			}
+			return checkUniqueID(seen, req.Items[i].ID)
+		},
+		apply: func(ctx context.Context, indexes []int, atomic bool) ([]storage.BatchResult, error) {
+			ids := make([]int64, len(indexes))
+			for j, i := range indexes {
+				ids[j] = req.Items[i].ID
+			}
+			return h.store.DeleteUsers(ctx, ids, atomic)
+		},
+	})
+}
+
+// runBatch validates the items of a batch, stores the valid ones and responds with the result of every item
+// An atomic batch responds with the status of its first failed item, a best-effort one always with 200
+func (h *UserHandler) runBatch(w http.ResponseWriter, r *http.Request, op batchOp) {
+	mode := op.mode
+	if mode == "" {
+		mode = domain.BatchAtomic
+	}
+	if mode != domain.BatchAtomic && mode != domain.BatchBestEffort {
+		h.respondWithError(w, http.StatusBadRequest, "Invalid batch mode")
+		return
+	}
+	if op.items == 0 {
+		h.respondWithError(w, http.StatusBadRequest, "Batch has no items")
+		return
+	}
+	if op.items > h.batch.MaxItems {
+		h.respondWithError(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("Batch has more than %d items", h.batch.MaxItems))
+		return
+	}
+	atomic := mode == domain.BatchAtomic
+
+	results := make([]domain.BatchItemResult, op.items)
+	valid := make([]int, 0, op.items)
+	for i := range results {
+		results[i].Index = i
+		if err := op.validate(i); err != nil {
+			results[i].Status = http.StatusBadRequest
+			results[i].Problem = batchProblem(http.StatusBadRequest, err.Error())
+			continue
+		}
+		valid = append(valid, i)
+	}
+
+	// An atomic batch with an invalid item is rejected before anything is stored
+	if atomic && len(valid) < op.items {
+		for _, i := range valid {
+			results[i].Status = http.StatusFailedDependency
+			results[i].Problem = batchProblem(http.StatusFailedDependency, storage.ErrBatchAborted.Error())
+		}
+		h.respondWithBatch(w, mode, results)
+		return
+	}
+
+	if len(valid) > 0 {
+		ctx, cancel := context.WithTimeout(r.Context(), h.batch.Timeout)
+		defer cancel()
+
+		stored, err := op.apply(ctx, valid, atomic)
+		if err != nil {
+			h.logger.Error("Failed to "+op.name+" users", zap.Error(err), zap.Int("items", len(valid)))
+			h.respondWithError(w, storeErrorStatus(err), "Failed to "+op.name+" users")
+			return
+		}
+
+		for j, i := range valid {
+			results[i].ID = stored[j].ID
+			status, detail := batchErrorStatus(stored[j].Err)
+			if status == 0 {
+				results[i].Status = op.successStatus
+				continue
+			}
+			if status == http.StatusInternalServerError {
+				h.logger.Error("Failed to "+op.name+" user", zap.Error(stored[j].Err), zap.Int("index", i))
+			}
+			results[i].Status = status
+			results[i].Problem = batchProblem(status, detail)
+		}
+	}
+
+	h.respondWithBatch(w, mode, results)
+}
+
+// respondWithBatch writes the results of a batch
+func (h *UserHandler) respondWithBatch(w http.ResponseWriter, mode domain.BatchMode, results []domain.BatchItemResult) {
+	response := domain.BatchResponse{Mode: mode, Results: results}
+	status := http.StatusOK
+	for _, result := range results {
+		if result.Problem == nil {
+			response.Succeeded++
+			continue
+		}
+		response.Failed++
+		// Aborted items only fail because of another one
+		if mode == domain.BatchAtomic && status == http.StatusOK && result.Status != http.StatusFailedDependency {
+			status = result.Status
+		}
+	}
+
+	h.respondWithData(w, status, response)
+}
+
+// Helper function to map the error of a batch item to its status code and message
+// A zero status code means that the item succeeded
+func batchErrorStatus(err error) (int, string) {
+	switch {
+	case err == nil:
+		return 0, ""
+	case errors.Is(err, storage.ErrInvalidID):
+		return http.StatusBadRequest, "Invalid user ID"
+	case errors.Is(err, storage.ErrUserNotFound):
+		return http.StatusNotFound, "User not found"
+	case errors.Is(err, storage.ErrDuplicateEmail):
+		return http.StatusConflict, "Email already exists"
+	case errors.Is(err, storage.ErrBatchAborted):
+		return http.StatusFailedDependency, storage.ErrBatchAborted.Error()
+	default:
+		return http.StatusInternalServerError, "Internal error"
+	}
+}
+
+// Helper function to build the problem detail of a failed batch item
+func batchProblem(status int, detail string) *domain.Problem {
+	return &domain.Problem{
+		Type:   "about:blank",
+		Title:  http.StatusText(status),
+		Status: status,
+		Detail: detail,
+	}
+}
+
+// Helper function to reject the repeated IDs of a batch, as the same user cannot be changed twice in one statement
+func checkUniqueID(seen map[int64]bool, id int64) error {
+	if seen[id] {
+		return errors.New("duplicate user ID in batch")
+	}
+	seen[id] = true
+	return nil
+}
@@ -0,0 +1,137 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/huberts90/restful-api/internal/domain"
+	"github.com/huberts90/restful-api/internal/logger"
+	"github.com/huberts90/restful-api/internal/storage"
+	storagemocks "github.com/huberts90/restful-api/internal/storage/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBatchCreateUsers(t *testing.T) {
+	valid := domain.UserCreate{Email: "a@example.com", FirstName: "Ann", LastName: "Lee"}
+	taken := domain.UserCreate{Email: "b@example.com", FirstName: "Bob", LastName: "Ray"}
+	invalid := domain.UserCreate{Email: "not-an-email", FirstName: "Cid", LastName: "Ng"}
+
+	tests := []struct {
+		name          string
+		request       domain.BatchCreateRequest
+		setup         func(*storagemocks.MockStorer)
+		wantStatus    int
+		wantStatuses  []int
+		wantSucceeded int
+	}{
+		{
+			name:    "best effort stores valid items",
+			request: domain.BatchCreateRequest{Mode: domain.BatchBestEffort, Items: []domain.UserCreate{valid, invalid, taken}},
+			setup: func(m *storagemocks.MockStorer) {
+				m.On("CreateUsers", mock.Anything, []domain.UserCreate{valid, taken}, false).
+					Return([]storage.BatchResult{{ID: 7}, {Err: storage.ErrDuplicateEmail}}, nil)
+			},
+			wantStatus:    http.StatusOK,
+			wantStatuses:  []int{http.StatusCreated, http.StatusBadRequest, http.StatusConflict},
+			wantSucceeded: 1,
+		},
+		{
+			name:          "atomic rejects invalid items before storing",
+			request:       domain.BatchCreateRequest{Items: []domain.UserCreate{valid, invalid}},
+			setup:         func(*storagemocks.MockStorer) {},
+			wantStatus:    http.StatusBadRequest,
+			wantStatuses:  []int{http.StatusFailedDependency, http.StatusBadRequest},
+			wantSucceeded: 0,
+		},
+		{
+			name:    "atomic reports the failed item",
+			request: domain.BatchCreateRequest{Mode: domain.BatchAtomic, Items: []domain.UserCreate{valid, taken}},
+			setup: func(m *storagemocks.MockStorer) {
+				m.On("CreateUsers", mock.Anything, []domain.UserCreate{valid, taken}, true).
+					Return([]storage.BatchResult{{ID: 7, Err: storage.ErrBatchAborted}, {Err: storage.ErrDuplicateEmail}}, nil)
+			},
+			wantStatus:    http.StatusConflict,
+			wantStatuses:  []int{http.StatusFailedDependency, http.StatusConflict},
+			wantSucceeded: 0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockStore := storagemocks.NewMockStorer(t)
+			tt.setup(mockStore)
+			router := mux.NewRouter()
+			NewUserHandler(mockStore, logger.NewNoOpLogger()).RegisterRoutes(router)
+
+			body, _ := json.Marshal(tt.request)
+			req := httptest.NewRequest(http.MethodPost, "/users:batchCreate", bytes.NewReader(body))
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+
+			assert.Equal(t, tt.wantStatus, rr.Code)
+
+			var response domain.BatchResponse
+			require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &response))
+			require.Len(t, response.Results, len(tt.wantStatuses))
+			for i, result := range response.Results {
+				assert.Equal(t, i, result.Index)
+				assert.Equal(t, tt.wantStatuses[i], result.Status)
+			}
+			assert.Equal(t, tt.wantSucceeded, response.Succeeded)
+			assert.Equal(t, len(tt.wantStatuses)-tt.wantSucceeded, response.Failed)
+		})
+	}
+}
+
+func TestBatchUpdateUsers_DuplicateID(t *testing.T) {
+	mockStore := storagemocks.NewMockStorer(t)
+	handler := NewUserHandler(mockStore, logger.NewNoOpLogger())
+
+	update := domain.UserBatchUpdate{ID: 1, UserUpdate: domain.UserUpdate{FirstName: "Johnny"}}
+	mockStore.On("UpdateUsers", mock.Anything, []domain.UserBatchUpdate{update}, false).
+		Return([]storage.BatchResult{{ID: 1}}, nil)
+
+	body, _ := json.Marshal(domain.BatchUpdateRequest{Mode: domain.BatchBestEffort, Items: []domain.UserBatchUpdate{update, update}})
+	req := httptest.NewRequest(http.MethodPost, "/users:batchUpdate", bytes.NewReader(body))
+	rr := httptest.NewRecorder()
+	handler.BatchUpdateUsers(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+
+	var response domain.BatchResponse
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &response))
+	assert.Equal(t, int64(1), response.Results[0].ID)
+	assert.Equal(t, http.StatusBadRequest, response.Results[1].Status)
+	assert.Equal(t, "duplicate user ID in batch", response.Results[1].Problem.Detail)
+}
+
+func TestBatchDeleteUsers_Limits(t *testing.T) {
+	handler := NewUserHandler(storagemocks.NewMockStorer(t), logger.NewNoOpLogger(), WithBatchConfig(BatchConfig{MaxItems: 2}))
+
+	tests := []struct {
+		name       string
+		body       string
+		wantStatus int
+	}{
+		{name: "no items", body: `{"items": []}`, wantStatus: http.StatusBadRequest},
+		{name: "too many items", body: `{"items": [{"id": 1}, {"id": 2}, {"id": 3}]}`, wantStatus: http.StatusRequestEntityTooLarge},
+		{name: "unknown mode", body: `{"mode": "sometimes", "items": [{"id": 1}]}`, wantStatus: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/users:batchDelete", strings.NewReader(tt.body))
+			rr := httptest.NewRecorder()
+			handler.BatchDeleteUsers(rr, req)
+
+			assert.Equal(t, tt.wantStatus, rr.Code)
+		})
+	}
+}
@@ -2,7 +2,6 @@ package handler
 
 import (
 	"context"
-	"encoding/json"
 	"errors"
 	"fmt"
 	"net/http"
@@ -17,35 +16,70 @@ import (
 
 // UserHandler handles HTTP requests related to users
 type UserHandler struct {
-	store  storage.Storer
-	logger *zap.Logger
+	responder
+	store    storage.Storer
+	logger   *zap.Logger
+	batch    BatchConfig
+	cache    CacheConfig
+	limits   BodyLimits
+	versions []*apiVersion // oldest first
 }
 
 // NewUserHandler creates a new UserHandler with the given dependencies
-func NewUserHandler(store storage.Storer, logger *zap.Logger) *UserHandler {
-	return &UserHandler{
-		store:  store,
-		logger: logger,
+func NewUserHandler(store storage.Storer, logger *zap.Logger, opts ...UserHandlerOption) *UserHandler {
+	h := &UserHandler{
+		responder: responder{logger: logger},
+		store:     store,
+		logger:    logger,
+		batch:     DefaultBatchConfig,
+		cache:     DefaultCacheConfig,
+		limits:    DefaultBodyLimits,
+		versions:  newAPIVersions(VersionConfig{}),
 	}
+	for _, opt := range opts {
+		opt(h)
+	}
+	return h
 }
 
 // RegisterRoutes registers all the user-related routes with the router
 // This method centralizes route configuration, making it easier to understand the API
+// Every version is served under its own prefix, such as /v2/users, and the unversioned routes serve
+// the version the Accept header asks for, version 1 by default
 func (h *UserHandler) RegisterRoutes(router *mux.Router) {
-	router.HandleFunc("/users", h.CreateUser).Methods(http.MethodPost)
-	router.HandleFunc("/users/{id:[0-9]+}", h.GetUser).Methods(http.MethodGet)
-	router.HandleFunc("/users/{id:[0-9]+}", h.UpdateUser).Methods(http.MethodPut)
-	router.HandleFunc("/users/{id:[0-9]+}", h.DeleteUser).Methods(http.MethodDelete)
-	router.HandleFunc("/users", h.ListUsers).Methods(http.MethodGet)
+	h.registerRoutes(router, nil)
+	for _, v := range h.versions {
+		h.registerRoutes(router.PathPrefix("/"+v.name()).Subrouter(), v)
+	}
+}
+
+// registerRoutes registers the routes of a version, or the unversioned routes if it is nil
+// Every route negotiates the media type of its response with the Accept header
+func (h *UserHandler) registerRoutes(router *mux.Router, v *apiVersion) {
+	handle := func(next http.HandlerFunc) http.HandlerFunc {
+		return h.versioned(v, h.negotiated(next))
+	}
+
+	router.HandleFunc("/users", handle(h.CreateUser)).Methods(http.MethodPost)
+	router.HandleFunc("/users:batchCreate", handle(h.BatchCreateUsers)).Methods(http.MethodPost)
+	router.HandleFunc("/users:batchUpdate", handle(h.BatchUpdateUsers)).Methods(http.MethodPost)
+	router.HandleFunc("/users:batchDelete", handle(h.BatchDeleteUsers)).Methods(http.MethodPost)
+	router.HandleFunc("/users/{id:[0-9]+}", handle(h.conditional(h.cache.User, false, h.GetUser))).Methods(http.MethodGet)
+	router.HandleFunc("/users/{id:[0-9]+}", handle(h.UpdateUser)).Methods(http.MethodPut)
+	router.HandleFunc("/users/{id:[0-9]+}", handle(h.DeleteUser)).Methods(http.MethodDelete)
+	// TODO: restrict restoring and reading deleted users to admins once authentication lands
+	router.HandleFunc("/users/{id:[0-9]+}/restore", handle(h.RestoreUser)).Methods(http.MethodPost)
+	router.HandleFunc("/users/{id:[0-9]+}/history", handle(h.GetUserHistory)).Methods(http.MethodGet)
+	router.HandleFunc("/users", handle(h.conditional(h.cache.Users, true, h.ListUsers))).Methods(http.MethodGet)
 }
 
 // CreateUser handles the creation of a new user
 func (h *UserHandler) CreateUser(w http.ResponseWriter, r *http.Request) {
 	// Parse and validate the request body
-	var userCreate domain.UserCreate
+	userCreate := h.version(r).newCreate()
 	// TODO: log request details
-	if err := json.NewDecoder(r.Body).Decode(&userCreate); err != nil {
-		h.respondWithError(w, http.StatusBadRequest, "Invalid request")
+	if err := decodeBody(w, r, userCreate, h.limits.Default); err != nil {
+		h.respondWithDecodeError(w, err, "Invalid request")
 		return
 	}
 
@@ -61,14 +95,15 @@ func (h *UserHandler) CreateUser(w http.ResponseWriter, r *http.Request) {
 	defer cancel()
 
 	// Create the user
-	userID, err := h.store.CreateUser(ctx, userCreate)
+	user := userCreate.ToCreate()
+	userID, err := h.store.CreateUser(ctx, user)
 	if err != nil {
 		if errors.Is(err, storage.ErrDuplicateEmail) {
 			h.respondWithError(w, http.StatusConflict, "Email already exists")
 			return
 		}
-		h.logger.Error("Failed to create user", zap.Error(err), zap.String("email", userCreate.Email))
-		h.respondWithError(w, http.StatusInternalServerError, "Failed to create user")
+		h.logger.Error("Failed to create user", zap.Error(err), zap.String("email", user.Email))
+		h.respondWithError(w, storeErrorStatus(err), "Failed to create user")
 		return
 	}
 
@@ -84,23 +119,38 @@ func (h *UserHandler) GetUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	opts, err := parseReadOptions(r)
+	if err != nil {
+		h.respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	fields, err := h.version(r).parseFields(r)
+	if err != nil {
+		h.respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	opts = append(opts, fields.readOptions()...)
+
 	// Create a context with timeout for the database operation
 	ctx, cancel := context.WithTimeout(r.Context(), 300*time.Millisecond)
 	defer cancel()
 
 	// Get the user
-	user, err := h.store.GetUserByID(ctx, id)
+	user, err := h.store.GetUserByID(ctx, id, opts...)
 	if err != nil {
 		if errors.Is(err, storage.ErrUserNotFound) {
 			h.respondWithError(w, http.StatusNotFound, "User not found")
 			return
 		}
 		h.logger.Error("Failed to get user", zap.Error(err), zap.Int64("id", id))
-		h.respondWithError(w, http.StatusInternalServerError, "Failed to get user")
+		h.respondWithError(w, storeErrorStatus(err), "Failed to get user")
 		return
 	}
 
-	h.respondWithData(w, http.StatusOK, user.ToResponse())
+	if !user.UpdatedAt.IsZero() {
+		w.Header().Set("Last-Modified", user.UpdatedAt.UTC().Format(http.TimeFormat))
+	}
+	h.respondWithData(w, http.StatusOK, fields.user(h.version(r).user(user, r.URL.Path)))
 }
 
 // UpdateUser handles updating a user by ID
@@ -112,9 +162,9 @@ func (h *UserHandler) UpdateUser(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Parse and validate the request body
-	var userUpdate domain.UserUpdate
-	if err := json.NewDecoder(r.Body).Decode(&userUpdate); err != nil {
-		h.respondWithError(w, http.StatusBadRequest, "Invalid request body")
+	userUpdate := h.version(r).newUpdate()
+	if err := decodeBody(w, r, userUpdate, h.limits.Default); err != nil {
+		h.respondWithDecodeError(w, err, "Invalid request body")
 		return
 	}
 
@@ -128,7 +178,7 @@ func (h *UserHandler) UpdateUser(w http.ResponseWriter, r *http.Request) {
 	defer cancel()
 
 	// Update the user
-	err = h.store.UpdateUser(ctx, id, userUpdate)
+	err = h.store.UpdateUser(ctx, id, userUpdate.ToUpdate())
 	if err != nil {
 		if errors.Is(err, storage.ErrUserNotFound) {
 			h.respondWithError(w, http.StatusNotFound, "User not found")
@@ -140,7 +190,7 @@ func (h *UserHandler) UpdateUser(w http.ResponseWriter, r *http.Request) {
 		}
 
 		h.logger.Error("Failed to update user", zap.Error(err), zap.Int64("id", id))
-		h.respondWithError(w, http.StatusInternalServerError, "Failed to update user")
+		h.respondWithError(w, storeErrorStatus(err), "Failed to update user")
 		return
 	}
 
@@ -166,7 +216,37 @@ func (h *UserHandler) DeleteUser(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 		h.logger.Error("Failed to delete user", zap.Error(err), zap.Int64("id", id))
-		h.respondWithError(w, http.StatusInternalServerError, "Failed to delete user")
+		h.respondWithError(w, storeErrorStatus(err), "Failed to delete user")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// RestoreUser handles bringing back a soft deleted user by ID
+func (h *UserHandler) RestoreUser(w http.ResponseWriter, r *http.Request) {
+	id, err := h.parseIDFromURL(r)
+	if err != nil {
+		h.respondWithError(w, http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+
+	// Create a context with timeout for the database operation
+	ctx, cancel := context.WithTimeout(r.Context(), 300*time.Millisecond)
+	defer cancel()
+
+	// Restore the user
+	if err := h.store.RestoreUser(ctx, id); err != nil {
+		if errors.Is(err, storage.ErrUserNotFound) {
+			h.respondWithError(w, http.StatusNotFound, "Deleted user not found")
+			return
+		}
+		if errors.Is(err, storage.ErrDuplicateEmail) {
+			h.respondWithError(w, http.StatusConflict, "Email already exists")
+			return
+		}
+		h.logger.Error("Failed to restore user", zap.Error(err), zap.Int64("id", id))
+		h.respondWithError(w, storeErrorStatus(err), "Failed to restore user")
 		return
 	}
 
@@ -177,77 +257,107 @@ func (h *UserHandler) DeleteUser(w http.ResponseWriter, r *http.Request) {
 // Supports pagination via query parameters
 func (h *UserHandler) ListUsers(w http.ResponseWriter, r *http.Request) {
 	// Parse query parameters
-	page, err := strconv.Atoi(r.URL.Query().Get("page"))
-	if err != nil || page <= 0 {
-		page = 1
-	}
+	page, pageSize := parsePagination(r)
 
-	pageSize, err := strconv.Atoi(r.URL.Query().Get("page_size"))
-	if err != nil || pageSize <= 0 || pageSize > 100 {
-		pageSize = 10
+	opts, err := parseReadOptions(r)
+	if err != nil {
+		h.respondWithError(w, http.StatusBadRequest, err.Error())
+		return
 	}
+	fields, err := h.version(r).parseFields(r)
+	if err != nil {
+		h.respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	opts = append(opts, fields.readOptions()...)
 
 	// Create a context with timeout for the database operation
 	ctx, cancel := context.WithTimeout(r.Context(), 1*time.Second)
 	defer cancel()
 
 	// List users
-	users, totalCount, err := h.store.ListUsers(ctx, page, pageSize)
+	users, totalCount, err := h.store.ListUsers(ctx, page, pageSize, opts...)
 	if err != nil {
 		h.logger.Error("Failed to list users", zap.Error(err))
-		h.respondWithError(w, http.StatusInternalServerError, "Failed to list users")
+		h.respondWithError(w, storeErrorStatus(err), "Failed to list users")
 		return
 	}
 
-	// @MENTION_ME: direct access to the field in loop is more efficient
-	usersToResponse := make([]domain.UserResponse, len(users))
-	for i, usr := range users {
-		usersToResponse[i] = usr.ToResponse()
+	// Calculate total pages (with minimum of 1), the last page may be partial
+	totalPages := (totalCount + pageSize - 1) / pageSize
+	if totalPages < 1 {
+		totalPages = 1
+	}
+
+	links := pageLinks(r, page, totalPages)
+	setLinkHeader(w, links)
+
+	response := fields.list(h.version(r).users(userList{
+		users:      users,
+		path:       r.URL.Path,
+		totalCount: totalCount,
+		totalPages: totalPages,
+		page:       page,
+		pageSize:   pageSize,
+		links:      links,
+	}))
+
+	h.respondWithData(w, http.StatusOK, response)
+}
+
+// GetUserHistory handles GET /users/{id}/history requests
+// The audit records are returned newest first
+func (h *UserHandler) GetUserHistory(w http.ResponseWriter, r *http.Request) {
+	id, err := h.parseIDFromURL(r)
+	if err != nil {
+		h.respondWithError(w, http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+
+	page, pageSize := parsePagination(r)
+
+	ctx, cancel := context.WithTimeout(r.Context(), 1*time.Second)
+	defer cancel()
+
+	records, totalCount, err := h.store.ListUserHistory(ctx, id, page, pageSize)
+	if err != nil {
+		h.logger.Error("Failed to list user history", zap.Error(err), zap.Int64("id", id))
+		h.respondWithError(w, storeErrorStatus(err), "Failed to list user history")
+		return
 	}
 
 	// Calculate total pages (with minimum of 1)
-	totalPages := totalCount / pageSize
+	totalPages := (totalCount + pageSize - 1) / pageSize
 	if totalPages < 1 {
 		totalPages = 1
 	}
 
-	response := domain.PaginatedUsersResponse{
-		Users:      usersToResponse,
+	response := domain.PaginatedAuditResponse{
+		Records:    records,
 		TotalCount: totalCount,
 		TotalPages: totalPages,
 		Page:       page,
 		PageSize:   pageSize,
 	}
 
-	h.respondWithJSON(w, http.StatusOK, response)
+	h.respondWithData(w, http.StatusOK, response)
 }
 
-// Helper function to respond with JSON
-func (h *UserHandler) respondWithJSON(w http.ResponseWriter, code int, payload interface{}) {
-	response, err := json.Marshal(payload)
-	if err != nil {
-		h.logger.Error("failed to marshal JSON response", zap.Error(err))
-		w.WriteHeader(http.StatusInternalServerError)
-		return
-	}
+// Helper function to build the storage read options from the query parameters
+func parseReadOptions(r *http.Request) ([]storage.ReadOption, error) {
+	var opts []storage.ReadOption
 
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(code)
-	if _, err = w.Write(response); err != nil {
-		h.logger.Error("failed to write response", zap.Error(err))
+	if v := r.URL.Query().Get("include_deleted"); v != "" {
+		includeDeleted, err := strconv.ParseBool(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid include_deleted: %v", v)
+		}
+		if includeDeleted {
+			opts = append(opts, storage.IncludeDeleted())
+		}
 	}
-}
-
-// Helper function to respond with an error
-// Standardizes error response format across the API
-func (h *UserHandler) respondWithError(w http.ResponseWriter, code int, message string) {
-	h.respondWithJSON(w, code, map[string]string{"error": message})
-}
 
-// Helper function to respond with data
-// Centralizes data response creation to avoid code duplication
-func (h *UserHandler) respondWithData(w http.ResponseWriter, code int, payload interface{}) {
-	h.respondWithJSON(w, code, payload)
+	return opts, nil
 }
 
 // Helper function to extract and parse user ID from the URL
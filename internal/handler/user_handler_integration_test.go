@@ -2,6 +2,7 @@ package handler_test
 
 import (
 	"bytes"
+	"context"
 	"database/sql"
 	"encoding/json"
 	"fmt"
@@ -132,6 +133,46 @@ func (s *UserHandlerIntegrationSuite) TestCreateUser() {
 	assert.Equal(t, http.StatusConflict, recorder.Code)
 }
 
+// TestEventsAfterLateCommit tests that an event committing after a higher ID is still replayed
+func (s *UserHandlerIntegrationSuite) TestEventsAfterLateCommit() {
+	t := s.T()
+	ctx := context.Background()
+
+	var lastID int64
+	require.NoError(t, s.db.QueryRow("SELECT COALESCE(MAX(id), 0) FROM user_outbox").Scan(&lastID))
+
+	insert := func(tx *sql.Tx) int64 {
+		var id int64
+		err := tx.QueryRow(`INSERT INTO user_outbox (user_id, event_type, payload) VALUES (1, 'user.updated', '{}') RETURNING id`).Scan(&id)
+		require.NoError(t, err)
+		return id
+	}
+
+	// The lower ID is drawn first but committed last
+	late, err := s.db.Begin()
+	require.NoError(t, err)
+	defer late.Rollback()
+	lateID := insert(late)
+
+	early, err := s.db.Begin()
+	require.NoError(t, err)
+	earlyID := insert(early)
+	require.NoError(t, early.Commit())
+	require.Greater(t, earlyID, lateID)
+
+	events, err := s.store.EventsAfter(ctx, lastID, 100)
+	require.NoError(t, err)
+	assert.Empty(t, events, "the higher ID must wait for the lower one")
+
+	require.NoError(t, late.Commit())
+
+	events, err = s.store.EventsAfter(ctx, lastID, 100)
+	require.NoError(t, err)
+	require.Len(t, events, 2)
+	assert.Equal(t, lateID, events[0].ID)
+	assert.Equal(t, earlyID, events[1].ID)
+}
+
 // Run the test suite
 func TestUserHandlerIntegrationSuite(t *testing.T) {
 	// Skip if explicitly disabled
@@ -278,3 +278,110 @@ func TestListUser(t *testing.T) {
 	// Check first user if available
 	assert.Equal(t, int64(1), response.Users[0].ID)
 }
+
+func TestRestoreUser(t *testing.T) {
+	tests := []struct {
+		name     string
+		storeErr error
+		wantCode int
+	}{
+		{name: "success", storeErr: nil, wantCode: http.StatusNoContent},
+		{name: "not deleted", storeErr: storage.ErrUserNotFound, wantCode: http.StatusNotFound},
+		{name: "email taken", storeErr: storage.ErrDuplicateEmail, wantCode: http.StatusConflict},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Set up the mock store
+			mockStore := storagemocks.NewMockStorer(t)
+			handler := NewUserHandler(mockStore, logger.NewNoOpLogger())
+
+			// Mock the store's RestoreUser method
+			mockStore.On("RestoreUser", mock.Anything, int64(1)).Return(tt.storeErr)
+
+			// Create a test request
+			req, err := http.NewRequest("POST", "/users/1/restore", nil)
+			require.NoError(t, err)
+			req = mux.SetURLVars(req, map[string]string{"id": "1"})
+
+			rr := httptest.NewRecorder()
+			handler.RestoreUser(rr, req)
+
+			assert.Equal(t, tt.wantCode, rr.Code)
+			mockStore.AssertExpectations(t)
+		})
+	}
+}
+
+func TestGetUser_IncludeDeleted(t *testing.T) {
+	// Set up the mock store
+	mockStore := storagemocks.NewMockStorer(t)
+	handler := NewUserHandler(mockStore, logger.NewNoOpLogger())
+
+	deletedAt := time.Now()
+	user := &domain.User{ID: 1, Email: "test@example.com", DeletedAt: &deletedAt}
+
+	// The read option is passed on to the store
+	mockStore.On("GetUserByID", mock.Anything, int64(1), mock.AnythingOfType("storage.ReadOption")).Return(user, nil)
+
+	req, err := http.NewRequest("GET", "/users/1?include_deleted=true", nil)
+	require.NoError(t, err)
+	req = mux.SetURLVars(req, map[string]string{"id": "1"})
+
+	rr := httptest.NewRecorder()
+	handler.GetUser(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	var responseUser domain.UserResponse
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &responseUser))
+	assert.NotNil(t, responseUser.DeletedAt)
+
+	// Invalid values are rejected
+	req, err = http.NewRequest("GET", "/users/1?include_deleted=maybe", nil)
+	require.NoError(t, err)
+	req = mux.SetURLVars(req, map[string]string{"id": "1"})
+	rr = httptest.NewRecorder()
+	handler.GetUser(rr, req)
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+}
+
+func TestGetUserHistory(t *testing.T) {
+	// Set up the mock store
+	mockStore := storagemocks.NewMockStorer(t)
+	handler := NewUserHandler(mockStore, logger.NewNoOpLogger())
+
+	email := "john@example.com"
+	records := []domain.AuditRecord{
+		{
+			ID:        1,
+			UserID:    1,
+			Actor:     "system",
+			Operation: domain.AuditCreate,
+			Changes:   map[string]domain.FieldChange{"email": {New: &email}},
+			CreatedAt: time.Now(),
+		},
+	}
+
+	// Mock the store's ListUserHistory method
+	mockStore.On("ListUserHistory", mock.Anything, int64(1), 2, 5).Return(records, 6, nil)
+
+	// Create a test request
+	req, err := http.NewRequest("GET", "/users/1/history?page=2&page_size=5", nil)
+	require.NoError(t, err)
+	req = mux.SetURLVars(req, map[string]string{"id": "1"})
+
+	rr := httptest.NewRecorder()
+	handler.GetUserHistory(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+
+	var response domain.PaginatedAuditResponse
+	err = json.Unmarshal(rr.Body.Bytes(), &response)
+	require.NoError(t, err)
+	assert.Len(t, response.Records, 1)
+	assert.Equal(t, domain.AuditCreate, response.Records[0].Operation)
+	assert.Equal(t, 6, response.TotalCount)
+	assert.Equal(t, 2, response.TotalPages)
+
+	mockStore.AssertExpectations(t)
+}
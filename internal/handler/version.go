@@ -0,0 +1,271 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/huberts90/restful-api/internal/domain"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var apiVersionRequestsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "api_version_requests_total",
+	Help: "Requests to the user API by version, and by what selected it: the path, the Accept header or the default",
+}, []string{"version", "selected_by"})
+
+// VersionConfig holds the deprecation schedule of the versions of the user API
+type VersionConfig struct {
+	V1Deprecation time.Time // zero while version 1 is not deprecated
+	V1Sunset      time.Time // zero until version 1 has a removal date
+}
+
+// WithVersionConfig sets the deprecation schedule of the versions of the user API
+func WithVersionConfig(cfg VersionConfig) UserHandlerOption {
+	return func(h *UserHandler) {
+		h.versions = newAPIVersions(cfg)
+	}
+}
+
+// versionMediaType matches the vendor media types that select a version, such as application/vnd.users.v2+json
+var versionMediaType = regexp.MustCompile(`^application/vnd\.users\.v([0-9]+)\+json$`)
+
+// createRequest is the body of a create request in some version of the API
+type createRequest interface {
+	Validate() error
+	ToCreate() domain.UserCreate
+}
+
+// updateRequest is the body of an update request in some version of the API
+type updateRequest interface {
+	Validate() error
+	ToUpdate() domain.UserUpdate
+}
+
+// batchUpdateItem is an item of a batch update request in some version of the API
+type batchUpdateItem interface {
+	Validate() error
+	ToBatchUpdate() domain.UserBatchUpdate
+}
+
+// apiVersion maps users to and from their representation in a version of the API
+type apiVersion struct {
+	number      int
+	deprecation time.Time
+	sunset      time.Time
+
+	newCreate   func() createRequest
+	newUpdate   func() updateRequest
+	batchCreate func(w http.ResponseWriter, r *http.Request, limit int64) (domain.BatchMode, []createRequest, error)
+	batchUpdate func(w http.ResponseWriter, r *http.Request, limit int64) (domain.BatchMode, []batchUpdateItem, error)
+	columns     map[string]string // the columns of the store each field of the users comes from
+	user        func(u *domain.User, self string) interface{}
+	users       func(list userList) interface{}
+}
+
+// userList is a page of users, along with what the list responses of every version tell about it
+type userList struct {
+	users      []domain.User
+	path       string // the path of the list, the path of a user is below it
+	totalCount int
+	totalPages int
+	page       int
+	pageSize   int
+	links      domain.PageLinks
+}
+
+// Helper function to get the path of a user of the list
+func (l userList) userPath(u domain.User) string {
+	return l.path + "/" + strconv.FormatInt(u.ID, 10)
+}
+
+func (v *apiVersion) name() string {
+	return "v" + strconv.Itoa(v.number)
+}
+
+func (v *apiVersion) mediaType() string {
+	return fmt.Sprintf("application/vnd.users.v%d+json", v.number)
+}
+
+// newAPIVersions returns the versions of the API, oldest first
+func newAPIVersions(cfg VersionConfig) []*apiVersion {
+	v1 := &apiVersion{
+		number:      1,
+		deprecation: cfg.V1Deprecation,
+		sunset:      cfg.V1Sunset,
+		newCreate:   func() createRequest { return &domain.UserCreate{} },
+		newUpdate:   func() updateRequest { return &domain.UserUpdate{} },
+		batchCreate: func(w http.ResponseWriter, r *http.Request, limit int64) (domain.BatchMode, []createRequest, error) {
+			var req domain.BatchCreateRequest
+			err := decodeBody(w, r, &req, limit)
+			return req.Mode, toInterfaces[createRequest](req.Items), err
+		},
+		batchUpdate: func(w http.ResponseWriter, r *http.Request, limit int64) (domain.BatchMode, []batchUpdateItem, error) {
+			var req domain.BatchUpdateRequest
+			err := decodeBody(w, r, &req, limit)
+			return req.Mode, toInterfaces[batchUpdateItem](req.Items), err
+		},
+		columns: map[string]string{
+			"id":         "id",
+			"email":      "email",
+			"first_name": "first_name",
+			"last_name":  "last_name",
+			"created_at": "created_at",
+			"updated_at": "updated_at",
+			"deleted_at": "deleted_at",
+		},
+		user: func(u *domain.User, self string) interface{} {
+			response := u.ToResponse()
+			response.Links = &domain.UserLinks{Self: domain.Link{Href: self}}
+			return response
+		},
+		users: func(list userList) interface{} {
+			// @MENTION_ME: direct access to the field in loop is more efficient
+			usersToResponse := make([]domain.UserResponse, len(list.users))
+			for i, usr := range list.users {
+				usersToResponse[i] = usr.ToResponse()
+				usersToResponse[i].Links = &domain.UserLinks{Self: domain.Link{Href: list.userPath(usr)}}
+			}
+			return domain.PaginatedUsersResponse{
+				Users:      usersToResponse,
+				TotalCount: list.totalCount,
+				TotalPages: list.totalPages,
+				Page:       list.page,
+				PageSize:   list.pageSize,
+				Links:      &list.links,
+			}
+		},
+	}
+
+	v2 := &apiVersion{
+		number:    2,
+		newCreate: func() createRequest { return &domain.UserCreateV2{} },
+		newUpdate: func() updateRequest { return &domain.UserUpdateV2{} },
+		batchCreate: func(w http.ResponseWriter, r *http.Request, limit int64) (domain.BatchMode, []createRequest, error) {
+			var req domain.BatchCreateRequestV2
+			err := decodeBody(w, r, &req, limit)
+			return req.Mode, toInterfaces[createRequest](req.Items), err
+		},
+		batchUpdate: func(w http.ResponseWriter, r *http.Request, limit int64) (domain.BatchMode, []batchUpdateItem, error) {
+			var req domain.BatchUpdateRequestV2
+			err := decodeBody(w, r, &req, limit)
+			return req.Mode, toInterfaces[batchUpdateItem](req.Items), err
+		},
+		columns: map[string]string{
+			"id":          "id",
+			"email":       "email",
+			"given_name":  "first_name",
+			"family_name": "last_name",
+			"status":      "deleted_at",
+			"created_at":  "created_at",
+			"updated_at":  "updated_at",
+			"deleted_at":  "deleted_at",
+		},
+		user: func(u *domain.User, self string) interface{} {
+			response := u.ToResponseV2()
+			response.Links = &domain.UserLinks{Self: domain.Link{Href: self}}
+			return response
+		},
+		users: func(list userList) interface{} {
+			usersToResponse := make([]domain.UserResponseV2, len(list.users))
+			for i, usr := range list.users {
+				usersToResponse[i] = usr.ToResponseV2()
+				usersToResponse[i].Links = &domain.UserLinks{Self: domain.Link{Href: list.userPath(usr)}}
+			}
+			return domain.PaginatedUsersResponseV2{
+				Users:      usersToResponse,
+				TotalCount: list.totalCount,
+				TotalPages: list.totalPages,
+				Page:       list.page,
+				PageSize:   list.pageSize,
+				Links:      &list.links,
+			}
+		},
+	}
+
+	return []*apiVersion{v1, v2}
+}
+
+// Helper function to convert the items of a request to the interface the handlers work with
+func toInterfaces[I any, T any](items []T) []I {
+	converted := make([]I, len(items))
+	for i, item := range items {
+		converted[i] = any(item).(I)
+	}
+	return converted
+}
+
+type versionKey struct{}
+
+// Helper function to get the API version of a request, version 1 unless a route selected another one
+func (h *UserHandler) version(r *http.Request) *apiVersion {
+	if v, ok := r.Context().Value(versionKey{}).(*apiVersion); ok {
+		return v
+	}
+	return h.versions[0]
+}
+
+// versioned resolves the API version of a request before handling it, fixed is the version of the path if any
+// The response of a deprecated version tells so with the Deprecation and Sunset headers
+func (h *UserHandler) versioned(fixed *apiVersion, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		requested, err := h.acceptedVersion(r)
+		if err != nil {
+			h.respondWithError(w, http.StatusNotAcceptable, err.Error())
+			return
+		}
+
+		v, selectedBy := fixed, "path"
+		switch {
+		case fixed != nil && requested != nil && requested != fixed:
+			h.respondWithError(w, http.StatusNotAcceptable, fmt.Sprintf("Accept asks for %s on a %s route", requested.name(), fixed.name()))
+			return
+		case fixed == nil && requested != nil:
+			v, selectedBy = requested, "accept"
+		case fixed == nil:
+			v, selectedBy = h.versions[0], "default"
+		}
+		apiVersionRequestsTotal.WithLabelValues(v.name(), selectedBy).Inc()
+
+		if !v.deprecation.IsZero() {
+			// RFC 9745 dates are Unix timestamps
+			w.Header().Set("Deprecation", "@"+strconv.FormatInt(v.deprecation.Unix(), 10))
+		}
+		if !v.sunset.IsZero() {
+			w.Header().Set("Sunset", v.sunset.UTC().Format(http.TimeFormat))
+		}
+
+		next(w, r.WithContext(context.WithValue(r.Context(), versionKey{}, v)))
+	}
+}
+
+// Helper function to find the version a vendor media type of the Accept header asks for
+// Returns nil if the header has none, and an error if it only asks for unknown versions
+func (h *UserHandler) acceptedVersion(r *http.Request) (*apiVersion, error) {
+	var unknown string
+	for _, accept := range r.Header.Values("Accept") {
+		for _, mediaRange := range strings.Split(accept, ",") {
+			mediaType, _, _ := strings.Cut(mediaRange, ";")
+			match := versionMediaType.FindStringSubmatch(strings.ToLower(strings.TrimSpace(mediaType)))
+			if match == nil {
+				continue
+			}
+			for _, v := range h.versions {
+				if strconv.Itoa(v.number) == match[1] {
+					return v, nil
+				}
+			}
+			unknown = match[0]
+		}
+	}
+
+	if unknown != "" {
+		return nil, fmt.Errorf("unsupported API version: %s", unknown)
+	}
+	return nil, nil
+}
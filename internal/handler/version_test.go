@@ -0,0 +1,152 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/huberts90/restful-api/internal/domain"
+	"github.com/huberts90/restful-api/internal/logger"
+	"github.com/huberts90/restful-api/internal/storage"
+	storagemocks "github.com/huberts90/restful-api/internal/storage/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func setupVersionedRouter(t *testing.T, cfg VersionConfig) (*storagemocks.MockStorer, *mux.Router) {
+	t.Helper()
+
+	mockStore := storagemocks.NewMockStorer(t)
+	router := mux.NewRouter()
+	NewUserHandler(mockStore, logger.NewNoOpLogger(), WithVersionConfig(cfg)).RegisterRoutes(router.PathPrefix("/api").Subrouter())
+	return mockStore, router
+}
+
+func TestVersionedGetUser(t *testing.T) {
+	user := &domain.User{ID: 1, Email: "test@example.com", FirstName: "John", LastName: "Doe"}
+
+	tests := []struct {
+		name            string
+		path            string
+		accept          string
+		wantCode        int
+		wantFirstName   string
+		wantGivenName   string
+		wantContentType string
+	}{
+		{name: "unversioned", path: "/api/users/1", wantCode: http.StatusOK, wantFirstName: "John", wantContentType: "application/json"},
+		{name: "v1 path", path: "/api/v1/users/1", wantCode: http.StatusOK, wantFirstName: "John", wantContentType: "application/json"},
+		{name: "v2 path", path: "/api/v2/users/1", wantCode: http.StatusOK, wantGivenName: "John", wantContentType: "application/json"},
+		{
+			name:            "v2 media type",
+			path:            "/api/users/1",
+			accept:          "application/json;q=0.5, application/vnd.users.v2+json",
+			wantCode:        http.StatusOK,
+			wantGivenName:   "John",
+			wantContentType: "application/vnd.users.v2+json",
+		},
+		{
+			name:            "matching media type and path",
+			path:            "/api/v2/users/1",
+			accept:          "application/vnd.users.v2+json",
+			wantCode:        http.StatusOK,
+			wantGivenName:   "John",
+			wantContentType: "application/vnd.users.v2+json",
+		},
+		{name: "unknown version", path: "/api/users/1", accept: "application/vnd.users.v9+json", wantCode: http.StatusNotAcceptable},
+		{name: "media type of another version", path: "/api/v1/users/1", accept: "application/vnd.users.v2+json", wantCode: http.StatusNotAcceptable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockStore, router := setupVersionedRouter(t, VersionConfig{})
+			if tt.wantCode == http.StatusOK {
+				mockStore.On("GetUserByID", mock.Anything, int64(1)).Return(user, nil)
+			}
+
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			if tt.accept != "" {
+				req.Header.Set("Accept", tt.accept)
+			}
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+
+			require.Equal(t, tt.wantCode, rr.Code, rr.Body.String())
+			if tt.wantCode != http.StatusOK {
+				return
+			}
+			assert.Equal(t, tt.wantContentType, rr.Header().Get("Content-Type"))
+
+			var body map[string]interface{}
+			require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &body))
+			if tt.wantGivenName != "" {
+				assert.Equal(t, tt.wantGivenName, body["given_name"])
+				assert.Equal(t, string(domain.UserStatusActive), body["status"])
+				assert.NotContains(t, body, "first_name")
+			} else {
+				assert.Equal(t, tt.wantFirstName, body["first_name"])
+				assert.NotContains(t, body, "given_name")
+			}
+		})
+	}
+}
+
+func TestVersionedCreateUser(t *testing.T) {
+	mockStore, router := setupVersionedRouter(t, VersionConfig{})
+	mockStore.On("CreateUser", mock.Anything, domain.UserCreate{
+		Email:     "test@example.com",
+		FirstName: "John",
+		LastName:  "Doe",
+	}).Return(int64(1), nil)
+
+	body := `{"email":"test@example.com","given_name":"John","family_name":"Doe"}`
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/api/v2/users", bytes.NewBufferString(body)))
+	assert.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+
+	// The names of version 1 are not those of version 2
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/api/v1/users", bytes.NewBufferString(body)))
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+}
+
+func TestVersionedBatchUpdateUsers(t *testing.T) {
+	mockStore, router := setupVersionedRouter(t, VersionConfig{})
+	mockStore.On("UpdateUsers", mock.Anything, []domain.UserBatchUpdate{
+		{ID: 1, UserUpdate: domain.UserUpdate{FirstName: "Johnny"}},
+	}, true).Return([]storage.BatchResult{{ID: 1}}, nil)
+
+	body := `{"items":[{"id":1,"given_name":"Johnny"}]}`
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/api/v2/users:batchUpdate", bytes.NewBufferString(body)))
+
+	assert.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+}
+
+func TestVersionDeprecation(t *testing.T) {
+	deprecation := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	sunset := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	mockStore, router := setupVersionedRouter(t, VersionConfig{V1Deprecation: deprecation, V1Sunset: sunset})
+	mockStore.On("ListUsers", mock.Anything, 1, 10).Return([]domain.User{}, 0, nil)
+
+	for _, path := range []string{"/api/users", "/api/v1/users"} {
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, path, nil))
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, "@1735689600", rr.Header().Get("Deprecation"))
+		assert.Equal(t, "Thu, 01 Jan 2026 00:00:00 GMT", rr.Header().Get("Sunset"))
+	}
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/v2/users", nil))
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Empty(t, rr.Header().Get("Deprecation"))
+	assert.Empty(t, rr.Header().Get("Sunset"))
+}
@@ -0,0 +1,219 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/huberts90/restful-api/internal/domain"
+	"github.com/huberts90/restful-api/internal/storage"
+	"github.com/huberts90/restful-api/internal/webhook"
+	"go.uber.org/zap"
+)
+
+// WebhookHandler handles HTTP requests related to webhook subscriptions
+type WebhookHandler struct {
+	responder
+	store  storage.WebhookStorer
+	cfg    webhook.Config
+	limits BodyLimits
+	logger *zap.Logger
+}
+
+// NewWebhookHandler creates a new WebhookHandler with the given dependencies
+func NewWebhookHandler(store storage.WebhookStorer, cfg webhook.Config, limits BodyLimits, logger *zap.Logger) *WebhookHandler {
+	return &WebhookHandler{
+		responder: responder{logger: logger},
+		store:     store,
+		cfg:       cfg,
+		limits:    limits,
+		logger:    logger,
+	}
+}
+
+// RegisterRoutes registers all the webhook-related routes with the router
+// TODO: restrict managing webhooks to admins once authentication lands
+func (h *WebhookHandler) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc("/webhooks", h.CreateWebhook).Methods(http.MethodPost)
+	router.HandleFunc("/webhooks", h.ListWebhooks).Methods(http.MethodGet)
+	router.HandleFunc("/webhooks/{id:[0-9]+}", h.GetWebhook).Methods(http.MethodGet)
+	router.HandleFunc("/webhooks/{id:[0-9]+}", h.DeleteWebhook).Methods(http.MethodDelete)
+	router.HandleFunc("/webhooks/{id:[0-9]+}/deliveries", h.ListDeliveries).Methods(http.MethodGet)
+	router.HandleFunc("/webhooks/{id:[0-9]+}/deliveries/{deliveryID:[0-9]+}/redeliver", h.Redeliver).Methods(http.MethodPost)
+}
+
+// CreateWebhook handles the registration of a new webhook
+func (h *WebhookHandler) CreateWebhook(w http.ResponseWriter, r *http.Request) {
+	var webhookCreate domain.WebhookCreate
+	if err := decodeJSONBody(w, r, &webhookCreate, h.limits.Default, false); err != nil {
+		h.respondWithDecodeError(w, err, "Invalid request")
+		return
+	}
+
+	if err := webhookCreate.Validate(); err != nil {
+		h.respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if !h.cfg.AllowPrivateNetworks {
+		if err := webhook.CheckURL(webhookCreate.URL); err != nil {
+			h.respondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 1*time.Second)
+	defer cancel()
+
+	created, err := h.store.CreateWebhook(ctx, webhookCreate)
+	if err != nil {
+		h.logger.Error("Failed to create webhook", zap.Error(err))
+		h.respondWithError(w, storeErrorStatus(err), "Failed to create webhook")
+		return
+	}
+
+	h.respondWithData(w, http.StatusCreated, created)
+}
+
+// ListWebhooks handles GET /webhooks requests
+func (h *WebhookHandler) ListWebhooks(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 1*time.Second)
+	defer cancel()
+
+	webhooks, err := h.store.ListWebhooks(ctx)
+	if err != nil {
+		h.logger.Error("Failed to list webhooks", zap.Error(err))
+		h.respondWithError(w, storeErrorStatus(err), "Failed to list webhooks")
+		return
+	}
+
+	h.respondWithData(w, http.StatusOK, webhooks)
+}
+
+// GetWebhook handles GET /webhooks/{id} requests
+func (h *WebhookHandler) GetWebhook(w http.ResponseWriter, r *http.Request) {
+	id, err := parseIDVar(r, "id")
+	if err != nil {
+		h.respondWithError(w, http.StatusBadRequest, "Invalid webhook ID")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 1*time.Second)
+	defer cancel()
+
+	webhook, err := h.store.GetWebhook(ctx, id)
+	if err != nil {
+		if errors.Is(err, storage.ErrWebhookNotFound) {
+			h.respondWithError(w, http.StatusNotFound, "Webhook not found")
+			return
+		}
+		h.logger.Error("Failed to get webhook", zap.Error(err), zap.Int64("id", id))
+		h.respondWithError(w, storeErrorStatus(err), "Failed to get webhook")
+		return
+	}
+
+	h.respondWithData(w, http.StatusOK, webhook)
+}
+
+// DeleteWebhook handles DELETE /webhooks/{id} requests
+func (h *WebhookHandler) DeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	id, err := parseIDVar(r, "id")
+	if err != nil {
+		h.respondWithError(w, http.StatusBadRequest, "Invalid webhook ID")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 1*time.Second)
+	defer cancel()
+
+	if err := h.store.DeleteWebhook(ctx, id); err != nil {
+		if errors.Is(err, storage.ErrWebhookNotFound) {
+			h.respondWithError(w, http.StatusNotFound, "Webhook not found")
+			return
+		}
+		h.logger.Error("Failed to delete webhook", zap.Error(err), zap.Int64("id", id))
+		h.respondWithError(w, storeErrorStatus(err), "Failed to delete webhook")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ListDeliveries handles GET /webhooks/{id}/deliveries requests
+// The deliveries are returned newest first
+func (h *WebhookHandler) ListDeliveries(w http.ResponseWriter, r *http.Request) {
+	id, err := parseIDVar(r, "id")
+	if err != nil {
+		h.respondWithError(w, http.StatusBadRequest, "Invalid webhook ID")
+		return
+	}
+
+	page, pageSize := parsePagination(r)
+
+	ctx, cancel := context.WithTimeout(r.Context(), 1*time.Second)
+	defer cancel()
+
+	deliveries, totalCount, err := h.store.ListDeliveries(ctx, id, page, pageSize)
+	if err != nil {
+		h.logger.Error("Failed to list webhook deliveries", zap.Error(err), zap.Int64("id", id))
+		h.respondWithError(w, storeErrorStatus(err), "Failed to list webhook deliveries")
+		return
+	}
+
+	// Calculate total pages (with minimum of 1)
+	totalPages := (totalCount + pageSize - 1) / pageSize
+	if totalPages < 1 {
+		totalPages = 1
+	}
+
+	h.respondWithJSON(w, http.StatusOK, domain.PaginatedDeliveriesResponse{
+		Deliveries: deliveries,
+		TotalCount: totalCount,
+		TotalPages: totalPages,
+		Page:       page,
+		PageSize:   pageSize,
+	})
+}
+
+// Redeliver handles POST /webhooks/{id}/deliveries/{deliveryID}/redeliver requests
+// The delivery is sent again by the dispatcher, with a fresh set of retries
+func (h *WebhookHandler) Redeliver(w http.ResponseWriter, r *http.Request) {
+	id, err := parseIDVar(r, "id")
+	if err != nil {
+		h.respondWithError(w, http.StatusBadRequest, "Invalid webhook ID")
+		return
+	}
+	deliveryID, err := parseIDVar(r, "deliveryID")
+	if err != nil {
+		h.respondWithError(w, http.StatusBadRequest, "Invalid delivery ID")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 1*time.Second)
+	defer cancel()
+
+	if err := h.store.Redeliver(ctx, id, deliveryID); err != nil {
+		if errors.Is(err, storage.ErrDeliveryNotFound) {
+			h.respondWithError(w, http.StatusNotFound, "Delivery not found")
+			return
+		}
+		h.logger.Error("Failed to redeliver", zap.Error(err), zap.Int64("id", deliveryID))
+		h.respondWithError(w, storeErrorStatus(err), "Failed to redeliver")
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// Helper function to extract and parse a positive ID from the URL variables
+func parseIDVar(r *http.Request, name string) (int64, error) {
+	value := mux.Vars(r)[name]
+	id, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || id <= 0 {
+		return 0, fmt.Errorf("invalid %s: %v", name, value)
+	}
+	return id, nil
+}
@@ -0,0 +1,171 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/huberts90/restful-api/internal/domain"
+	"github.com/huberts90/restful-api/internal/logger"
+	"github.com/huberts90/restful-api/internal/storage"
+	storagemocks "github.com/huberts90/restful-api/internal/storage/mocks"
+	"github.com/huberts90/restful-api/internal/webhook"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCreateWebhook(t *testing.T) {
+	tests := []struct {
+		name     string
+		body     string
+		setup    func(*storagemocks.MockWebhookStorer)
+		wantCode int
+	}{
+		{
+			name: "success",
+			body: `{"url":"https://partner.example.com/hooks","events":["user.created"],"secret":"0123456789abcdef"}`,
+			setup: func(store *storagemocks.MockWebhookStorer) {
+				store.On("CreateWebhook", mock.Anything, domain.WebhookCreate{
+					URL:    "https://partner.example.com/hooks",
+					Events: []domain.EventType{domain.EventUserCreated},
+					Secret: "0123456789abcdef",
+				}).Return(&domain.Webhook{
+					ID:        1,
+					URL:       "https://partner.example.com/hooks",
+					Events:    []domain.EventType{domain.EventUserCreated},
+					Secret:    "0123456789abcdef",
+					CreatedAt: time.Now(),
+				}, nil)
+			},
+			wantCode: http.StatusCreated,
+		},
+		{
+			name:     "unknown event",
+			body:     `{"url":"https://partner.example.com/hooks","events":["user.renamed"],"secret":"0123456789abcdef"}`,
+			setup:    func(*storagemocks.MockWebhookStorer) {},
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			name:     "loopback destination",
+			body:     `{"url":"http://127.0.0.1:8080/api/users","secret":"0123456789abcdef"}`,
+			setup:    func(*storagemocks.MockWebhookStorer) {},
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			name:     "metadata service destination",
+			body:     `{"url":"http://169.254.169.254/latest/meta-data","secret":"0123456789abcdef"}`,
+			setup:    func(*storagemocks.MockWebhookStorer) {},
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			name:     "short secret",
+			body:     `{"url":"https://partner.example.com/hooks","secret":"short"}`,
+			setup:    func(*storagemocks.MockWebhookStorer) {},
+			wantCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Set up the mock store
+			mockStore := storagemocks.NewMockWebhookStorer(t)
+			handler := NewWebhookHandler(mockStore, webhook.Config{}, DefaultBodyLimits, logger.NewNoOpLogger())
+			tt.setup(mockStore)
+
+			req, err := http.NewRequest("POST", "/webhooks", bytes.NewBufferString(tt.body))
+			require.NoError(t, err)
+
+			rr := httptest.NewRecorder()
+			handler.CreateWebhook(rr, req)
+
+			assert.Equal(t, tt.wantCode, rr.Code)
+			// The secret is never sent back
+			assert.NotContains(t, rr.Body.String(), "0123456789abcdef")
+			mockStore.AssertExpectations(t)
+		})
+	}
+}
+
+func TestGetWebhook_NotFound(t *testing.T) {
+	// Set up the mock store
+	mockStore := storagemocks.NewMockWebhookStorer(t)
+	handler := NewWebhookHandler(mockStore, webhook.Config{}, DefaultBodyLimits, logger.NewNoOpLogger())
+
+	mockStore.On("GetWebhook", mock.Anything, int64(9)).Return(nil, storage.ErrWebhookNotFound)
+
+	req, err := http.NewRequest("GET", "/webhooks/9", nil)
+	require.NoError(t, err)
+	req = mux.SetURLVars(req, map[string]string{"id": "9"})
+
+	rr := httptest.NewRecorder()
+	handler.GetWebhook(rr, req)
+
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+	mockStore.AssertExpectations(t)
+}
+
+func TestListDeliveries(t *testing.T) {
+	// Set up the mock store
+	mockStore := storagemocks.NewMockWebhookStorer(t)
+	handler := NewWebhookHandler(mockStore, webhook.Config{}, DefaultBodyLimits, logger.NewNoOpLogger())
+
+	lastError := "receiver returned 500 Internal Server Error"
+	deliveries := []domain.WebhookDelivery{
+		{ID: 2, WebhookID: 1, EventID: 8, EventType: domain.EventUserDeleted, Status: domain.DeliveryDead, Attempts: 8, LastError: &lastError},
+		{ID: 1, WebhookID: 1, EventID: 7, EventType: domain.EventUserCreated, Status: domain.DeliveryDelivered, Attempts: 1},
+	}
+	mockStore.On("ListDeliveries", mock.Anything, int64(1), 1, 10).Return(deliveries, 2, nil)
+
+	req, err := http.NewRequest("GET", "/webhooks/1/deliveries", nil)
+	require.NoError(t, err)
+	req = mux.SetURLVars(req, map[string]string{"id": "1"})
+
+	rr := httptest.NewRecorder()
+	handler.ListDeliveries(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+
+	var response domain.PaginatedDeliveriesResponse
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &response))
+	require.Len(t, response.Deliveries, 2)
+	assert.Equal(t, domain.DeliveryDead, response.Deliveries[0].Status)
+	assert.Equal(t, 1, response.TotalPages)
+
+	mockStore.AssertExpectations(t)
+}
+
+func TestRedeliver(t *testing.T) {
+	tests := []struct {
+		name     string
+		storeErr error
+		wantCode int
+	}{
+		{name: "success", storeErr: nil, wantCode: http.StatusAccepted},
+		{name: "unknown delivery", storeErr: storage.ErrDeliveryNotFound, wantCode: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Set up the mock store
+			mockStore := storagemocks.NewMockWebhookStorer(t)
+			handler := NewWebhookHandler(mockStore, webhook.Config{}, DefaultBodyLimits, logger.NewNoOpLogger())
+
+			mockStore.On("Redeliver", mock.Anything, int64(1), int64(5)).Return(tt.storeErr)
+
+			req, err := http.NewRequest("POST", "/webhooks/1/deliveries/5/redeliver", nil)
+			require.NoError(t, err)
+			req = mux.SetURLVars(req, map[string]string{"id": "1", "deliveryID": "5"})
+
+			rr := httptest.NewRecorder()
+			handler.Redeliver(rr, req)
+
+			assert.Equal(t, tt.wantCode, rr.Code)
+			mockStore.AssertExpectations(t)
+		})
+	}
+}
@@ -0,0 +1,212 @@
+package importer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/huberts90/restful-api/internal/domain"
+	"github.com/huberts90/restful-api/internal/storage"
+	"go.uber.org/zap"
+)
+
+// Config holds the configuration of imports
+type Config struct {
+	BatchSize int           // rows stored at once
+	MaxErrors int           // errors kept in a report
+	Timeout   time.Duration // time to read and store a whole file
+	// MaxUploadSize is the largest file, in bytes, uploaded to be imported by a background job
+	MaxUploadSize int64
+}
+
+// Store defines the storage an import writes to
+type Store interface {
+	ImportUsers(ctx context.Context, users []domain.UserCreate, policy domain.DuplicatePolicy, dryRun bool) ([]storage.ImportResult, error)
+}
+
+// Options tune a single import
+type Options struct {
+	Policy domain.DuplicatePolicy
+	DryRun bool
+}
+
+// Importer streams the rows of files into the store
+// Only one batch of rows is held in memory at a time
+type Importer struct {
+	store  Store
+	cfg    Config
+	logger *zap.Logger
+}
+
+// New creates an importer
+func New(store Store, cfg Config, logger *zap.Logger) *Importer {
+	return &Importer{
+		store:  store,
+		cfg:    cfg,
+		logger: logger,
+	}
+}
+
+// Import validates every row read from r and stores the valid ones in batches
+// Batches are stored as they fill up, so an import that stops midway keeps the rows stored so far
+// The report is returned along with the error, if any, and is then marked incomplete
+func (i *Importer) Import(ctx context.Context, r Reader, opts Options) (*domain.ImportReport, error) {
+	report := &domain.ImportReport{DryRun: opts.DryRun, Errors: []domain.ImportError{}}
+	batch := &rowBatch{rows: make([]Row, 0, i.cfg.BatchSize), emails: make(map[string]struct{}, i.cfg.BatchSize)}
+
+	for {
+		row, err := r.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		var rowErr *RowError
+		if errors.As(err, &rowErr) {
+			report.Rows++
+			i.fail(report, rowErr.ImportError)
+			continue
+		}
+		if err != nil {
+			// The rows read so far are stored all the same
+			if storeErr := i.flush(ctx, report, batch, opts); storeErr != nil {
+				err = storeErr
+			}
+			report.Incomplete = true
+			return report, err
+		}
+
+		report.Rows++
+		if err := row.User.Validate(); err != nil {
+			i.fail(report, validationErrors(row.Line, err)...)
+			continue
+		}
+
+		// Rows are applied in order, a repeated email waits for the row before it to be stored
+		// Emails are unique regardless of case, so they are compared in lower case
+		_, repeated := batch.emails[strings.ToLower(row.User.Email)]
+		if repeated || len(batch.rows) == i.cfg.BatchSize {
+			if err := i.flush(ctx, report, batch, opts); err != nil {
+				report.Incomplete = true
+				return report, err
+			}
+		}
+		batch.add(row)
+	}
+
+	if err := i.flush(ctx, report, batch, opts); err != nil {
+		report.Incomplete = true
+		return report, err
+	}
+	return report, nil
+}
+
+// flush writes a batch of rows, adds their outcome to the report and empties the batch
+func (i *Importer) flush(ctx context.Context, report *domain.ImportReport, batch *rowBatch, opts Options) error {
+	if len(batch.rows) == 0 {
+		return nil
+	}
+	defer batch.reset()
+
+	users := make([]domain.UserCreate, len(batch.rows))
+	for j, row := range batch.rows {
+		users[j] = row.User
+	}
+
+	results, err := i.store.ImportUsers(ctx, users, opts.Policy, opts.DryRun)
+	if err != nil {
+		return fmt.Errorf("failed to store rows: %w", err)
+	}
+
+	for j, result := range results {
+		line := batch.rows[j].Line
+		switch {
+		case result.Err == nil && result.Outcome == storage.ImportCreated:
+			report.Created++
+		case result.Err == nil && result.Outcome == storage.ImportUpdated:
+			report.Updated++
+		case result.Err == nil:
+			report.Skipped++
+		case errors.Is(result.Err, storage.ErrDuplicateEmail):
+			i.fail(report, domain.ImportError{Line: line, Column: "email", Reason: "email already exists"})
+		default:
+			i.logger.Error("Failed to import row", zap.Error(result.Err), zap.Int("line", line))
+			i.fail(report, domain.ImportError{Line: line, Reason: "failed to store row"})
+		}
+	}
+
+	return nil
+}
+
+// fail counts a failed row and keeps its errors up to MaxErrors
+func (i *Importer) fail(report *domain.ImportReport, errs ...domain.ImportError) {
+	report.Failed++
+	for _, err := range errs {
+		if len(report.Errors) >= i.cfg.MaxErrors {
+			report.Truncated = true
+			return
+		}
+		report.Errors = append(report.Errors, err)
+	}
+}
+
+// rowBatch holds the rows waiting to be stored
+type rowBatch struct {
+	rows   []Row
+	emails map[string]struct{}
+}
+
+func (b *rowBatch) add(row Row) {
+	b.rows = append(b.rows, row)
+	b.emails[strings.ToLower(row.User.Email)] = struct{}{}
+}
+
+func (b *rowBatch) reset() {
+	b.rows = b.rows[:0]
+	clear(b.emails)
+}
+
+// validationErrors describes the failed validation of a row, one error per invalid column
+func validationErrors(line int, err error) []domain.ImportError {
+	var fieldErrs validator.ValidationErrors
+	if !errors.As(err, &fieldErrs) {
+		return []domain.ImportError{{Line: line, Reason: err.Error()}}
+	}
+
+	errs := make([]domain.ImportError, 0, len(fieldErrs))
+	for _, fieldErr := range fieldErrs {
+		errs = append(errs, domain.ImportError{
+			Line:   line,
+			Column: columnName(fieldErr.StructField()),
+			Reason: validationReason(fieldErr),
+		})
+	}
+	return errs
+}
+
+// columnName returns the column of a field of domain.UserCreate, which is its JSON name
+func columnName(field string) string {
+	f, ok := reflect.TypeOf(domain.UserCreate{}).FieldByName(field)
+	if !ok {
+		return field
+	}
+	name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+	return name
+}
+
+// validationReason explains a failed validation rule
+func validationReason(fieldErr validator.FieldError) string {
+	switch fieldErr.Tag() {
+	case "required":
+		return "is required"
+	case "email":
+		return "is not a valid email address"
+	case "alpha":
+		return "must only contain letters"
+	default:
+		return fmt.Sprintf("failed the %s rule", fieldErr.Tag())
+	}
+}
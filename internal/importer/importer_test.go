@@ -0,0 +1,141 @@
+package importer
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/huberts90/restful-api/internal/domain"
+	"github.com/huberts90/restful-api/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// fakeStore records the batches it is given, emails it has seen in any case are duplicates
+type fakeStore struct {
+	batches [][]domain.UserCreate
+	emails  map[string]bool
+}
+
+func (f *fakeStore) ImportUsers(_ context.Context, users []domain.UserCreate, policy domain.DuplicatePolicy, _ bool) ([]storage.ImportResult, error) {
+	f.batches = append(f.batches, users)
+	results := make([]storage.ImportResult, len(users))
+	for i, user := range users {
+		switch {
+		case !f.emails[strings.ToLower(user.Email)]:
+			f.emails[strings.ToLower(user.Email)] = true
+			results[i].Outcome = storage.ImportCreated
+		case policy == domain.DuplicateSkip:
+			results[i].Outcome = storage.ImportSkipped
+		case policy == domain.DuplicateUpsert:
+			results[i].Outcome = storage.ImportUpdated
+		default:
+			results[i].Err = storage.ErrDuplicateEmail
+		}
+	}
+	return results, nil
+}
+
+func TestImport_CSV(t *testing.T) {
+	file := "\ufeffEmail,First_Name,Last_Name,Department\n" +
+		"ann@example.com,Ann,Lee,HR\n" +
+		"bob@example.com,Bob,Ray,IT\n" +
+		"not-an-email,Cid,\n" +
+		"ANN@example.com,Anna,Lee,HR\n" +
+		"dan@example.com,Dan\n"
+
+	tests := []struct {
+		name       string
+		policy     domain.DuplicatePolicy
+		want       domain.ImportReport
+		wantErrors []domain.ImportError
+	}{
+		{
+			name:   "fail duplicates",
+			policy: domain.DuplicateFail,
+			want:   domain.ImportReport{Rows: 5, Created: 2, Failed: 3},
+			wantErrors: []domain.ImportError{
+				{Line: 4, Column: "email", Reason: "is not a valid email address"},
+				{Line: 4, Column: "last_name", Reason: "is required"},
+				{Line: 6, Column: "last_name", Reason: "missing value"},
+				{Line: 5, Column: "email", Reason: "email already exists"},
+			},
+		},
+		{
+			name:   "upsert duplicates",
+			policy: domain.DuplicateUpsert,
+			want:   domain.ImportReport{Rows: 5, Created: 2, Updated: 1, Failed: 2},
+			wantErrors: []domain.ImportError{
+				{Line: 4, Column: "email", Reason: "is not a valid email address"},
+				{Line: 4, Column: "last_name", Reason: "is required"},
+				{Line: 6, Column: "last_name", Reason: "missing value"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := &fakeStore{emails: map[string]bool{}}
+			imp := New(store, Config{BatchSize: 10, MaxErrors: 10}, zap.NewNop())
+
+			reader, err := NewCSVReader(strings.NewReader(file))
+			require.NoError(t, err)
+
+			report, err := imp.Import(context.Background(), reader, Options{Policy: tt.policy})
+			require.NoError(t, err)
+
+			assert.Equal(t, tt.want.Rows, report.Rows)
+			assert.Equal(t, tt.want.Created, report.Created)
+			assert.Equal(t, tt.want.Updated, report.Updated)
+			assert.Equal(t, tt.want.Failed, report.Failed)
+			assert.Equal(t, tt.wantErrors, report.Errors)
+
+			// The repeated email is stored after the row before it
+			require.Len(t, store.batches, 2)
+			assert.Len(t, store.batches[0], 2)
+			assert.Equal(t, "Anna", store.batches[1][0].FirstName)
+		})
+	}
+}
+
+func TestImport_NDJSON(t *testing.T) {
+	file := `{"email": "ann@example.com", "first_name": "Ann", "last_name": "Lee"}
+
+{"email": "bob@example.com", "first_name": 7, "last_name": "Ray"}
+{"email": "cid@example.com",
+{"email": "dan@example.com", "first_name": "Dan", "last_name": "Kim"}
+{"email": "eve@example.com", "first_name": "Eve", "last_name": "Fox"}
+`
+	store := &fakeStore{emails: map[string]bool{}}
+	imp := New(store, Config{BatchSize: 2, MaxErrors: 1}, zap.NewNop())
+
+	report, err := imp.Import(context.Background(), NewNDJSONReader(strings.NewReader(file)), Options{Policy: domain.DuplicateFail})
+	require.NoError(t, err)
+
+	assert.Equal(t, 5, report.Rows)
+	assert.Equal(t, 3, report.Created)
+	assert.Equal(t, 2, report.Failed)
+	assert.Equal(t, []domain.ImportError{{Line: 3, Column: "first_name", Reason: "must be a string"}}, report.Errors)
+	assert.True(t, report.Truncated)
+	assert.Len(t, store.batches, 2)
+}
+
+func TestImport_LineTooLong(t *testing.T) {
+	file := `{"email": "ann@example.com", "first_name": "Ann", "last_name": "Lee"}` + "\n" +
+		`{"email": "` + strings.Repeat("a", maxLineSize) + `"}` + "\n"
+	store := &fakeStore{emails: map[string]bool{}}
+	imp := New(store, Config{BatchSize: 10, MaxErrors: 10}, zap.NewNop())
+
+	report, err := imp.Import(context.Background(), NewNDJSONReader(strings.NewReader(file)), Options{Policy: domain.DuplicateFail})
+
+	assert.ErrorIs(t, err, ErrInvalidFile)
+	assert.True(t, report.Incomplete)
+	assert.Equal(t, 1, report.Created, "rows read before the error are stored")
+}
+
+func TestNewCSVReader_MissingColumn(t *testing.T) {
+	_, err := NewCSVReader(strings.NewReader("email,first_name\nann@example.com,Ann\n"))
+	assert.ErrorIs(t, err, ErrInvalidFile)
+	assert.ErrorContains(t, err, `missing column "last_name"`)
+}
@@ -0,0 +1,183 @@
+package importer
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/huberts90/restful-api/internal/domain"
+)
+
+var (
+	// ErrInvalidFile is returned when a file cannot be read any further
+	ErrInvalidFile = errors.New("invalid file")
+	// ErrUnsupportedMediaType is returned for files that are neither CSV nor NDJSON
+	ErrUnsupportedMediaType = errors.New("media type must be text/csv or application/x-ndjson")
+)
+
+// maxLineSize bounds the memory taken by a single NDJSON line
+const maxLineSize = 1 << 20
+
+// columns are the names of the fields of domain.UserCreate in a file, in the order they are checked
+var columns = []string{"email", "first_name", "last_name"}
+
+// Row is a user read from a file, along with the line it starts on
+type Row struct {
+	Line int
+	User domain.UserCreate
+}
+
+// RowError describes a row that cannot be read, the following rows can still be
+type RowError struct {
+	domain.ImportError
+}
+
+func (e *RowError) Error() string {
+	return fmt.Sprintf("line %d: %s", e.Line, e.Reason)
+}
+
+// Reader reads the rows of a file one at a time
+// Next returns a *RowError for a row that cannot be read, io.EOF once the file is over,
+// and an error wrapping ErrInvalidFile if the rest of the file cannot be read
+type Reader interface {
+	Next() (Row, error)
+}
+
+// SupportsMediaType reports whether files of the given media type can be read
+func SupportsMediaType(mediaType string) bool {
+	switch mediaType {
+	case "text/csv", "application/x-ndjson", "application/ndjson":
+		return true
+	default:
+		return false
+	}
+}
+
+// NewReader returns a reader of the rows of a file of the given media type
+func NewReader(mediaType string, r io.Reader) (Reader, error) {
+	switch {
+	case !SupportsMediaType(mediaType):
+		return nil, ErrUnsupportedMediaType
+	case mediaType == "text/csv":
+		return NewCSVReader(r)
+	default:
+		return NewNDJSONReader(r), nil
+	}
+}
+
+// csvReader reads a CSV file with a header naming the columns
+type csvReader struct {
+	r       *csv.Reader
+	indexes []int // position of each of columns in a record
+}
+
+// NewCSVReader reads the header of a CSV file and returns a reader of its rows
+// The header must name every column, other columns are ignored
+func NewCSVReader(r io.Reader) (Reader, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.ReuseRecord = true
+
+	header, err := cr.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("%w: missing header", ErrInvalidFile)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidFile, err)
+	}
+
+	positions := make(map[string]int, len(header))
+	for i, name := range header {
+		// Spreadsheets tend to start their exports with a byte order mark
+		name = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+		positions[name] = i
+	}
+
+	indexes := make([]int, len(columns))
+	for i, name := range columns {
+		pos, ok := positions[name]
+		if !ok {
+			return nil, fmt.Errorf("%w: missing column %q", ErrInvalidFile, name)
+		}
+		indexes[i] = pos
+	}
+
+	return &csvReader{r: cr, indexes: indexes}, nil
+}
+
+func (c *csvReader) Next() (Row, error) {
+	record, err := c.r.Read()
+	if err != nil {
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			return Row{}, &RowError{domain.ImportError{Line: parseErr.StartLine, Reason: parseErr.Err.Error()}}
+		}
+		if errors.Is(err, io.EOF) {
+			return Row{}, io.EOF
+		}
+		return Row{}, fmt.Errorf("%w: %w", ErrInvalidFile, err)
+	}
+
+	line, _ := c.r.FieldPos(0)
+	values := make([]string, len(columns))
+	for i, pos := range c.indexes {
+		if pos >= len(record) {
+			return Row{}, &RowError{domain.ImportError{Line: line, Column: columns[i], Reason: "missing value"}}
+		}
+		values[i] = strings.TrimSpace(record[pos])
+	}
+
+	return Row{
+		Line: line,
+		User: domain.UserCreate{Email: values[0], FirstName: values[1], LastName: values[2]},
+	}, nil
+}
+
+// ndjsonReader reads a file of JSON objects, one per line
+type ndjsonReader struct {
+	s    *bufio.Scanner
+	line int
+}
+
+// NewNDJSONReader returns a reader of the rows of a newline-delimited JSON file
+// Blank lines are skipped
+func NewNDJSONReader(r io.Reader) Reader {
+	s := bufio.NewScanner(r)
+	s.Buffer(make([]byte, 0, 64*1024), maxLineSize)
+	return &ndjsonReader{s: s}
+}
+
+func (n *ndjsonReader) Next() (Row, error) {
+	for n.s.Scan() {
+		n.line++
+		data := bytes.TrimSpace(n.s.Bytes())
+		if len(data) == 0 {
+			continue
+		}
+
+		var user domain.UserCreate
+		if err := json.Unmarshal(data, &user); err != nil {
+			rowErr := &RowError{domain.ImportError{Line: n.line, Reason: "invalid JSON"}}
+			var typeErr *json.UnmarshalTypeError
+			if errors.As(err, &typeErr) {
+				rowErr.Column = typeErr.Field
+				rowErr.Reason = "must be a " + typeErr.Type.String()
+			}
+			return Row{}, rowErr
+		}
+		return Row{Line: n.line, User: user}, nil
+	}
+
+	if err := n.s.Err(); err != nil {
+		if errors.Is(err, bufio.ErrTooLong) {
+			return Row{}, fmt.Errorf("%w: line %d is longer than %d bytes", ErrInvalidFile, n.line+1, maxLineSize)
+		}
+		return Row{}, fmt.Errorf("%w: %w", ErrInvalidFile, err)
+	}
+	return Row{}, io.EOF
+}
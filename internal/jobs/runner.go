@@ -0,0 +1,343 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/huberts90/restful-api/internal/domain"
+	"github.com/huberts90/restful-api/internal/storage"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"go.uber.org/zap"
+)
+
+// finishTimeout bounds recording the outcome of a job, which is done even if the worker is stopping
+const finishTimeout = 5 * time.Second
+
+var jobsFinishedTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "jobs_finished_total",
+	Help: "Number of finished background jobs by type and status",
+}, []string{"type", "status"})
+
+// Config holds the configuration of background jobs
+type Config struct {
+	Workers      int           // workers run in the process, the API leaves the jobs to cmd/worker with 0
+	PollInterval time.Duration // wait between claims while the queue is empty
+	Lease        time.Duration // time a claimed job is held for without a heartbeat
+	MaxAttempts  int           // claims of a job abandoned by dying workers before it fails
+	Retention    time.Duration // time finished jobs and their files are kept for
+	Dir          string        // directory of the files of jobs, shared by the API and the workers
+	// DownloadTimeout is the time the API may take to send the file of a job, which outlasts regular requests
+	DownloadTimeout time.Duration
+}
+
+// UploadPattern is the pattern of the names of files uploaded before their job is queued
+// A job moves its upload to its own path once it runs, uploads of jobs that never run are pruned
+const UploadPattern = "upload-*"
+
+// FilePath returns the path of the file of a job
+func (c Config) FilePath(id int64) string {
+	return filepath.Join(c.Dir, strconv.FormatInt(id, 10))
+}
+
+// Error is a job failure whose message can be shown to clients, the messages of other errors are only logged
+type Error struct {
+	Message string
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// Errorf returns an Error with a formatted message
+func Errorf(format string, args ...interface{}) error {
+	return &Error{Message: fmt.Sprintf(format, args...)}
+}
+
+// Progress is the progress of a running job, it is saved at every heartbeat
+type Progress struct {
+	mu       sync.Mutex
+	progress domain.JobProgress
+}
+
+// Set sets how much of the total is done
+func (p *Progress) Set(done, total int64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.progress = domain.JobProgress{Done: done, Total: total}
+}
+
+// Add adds to what is done
+func (p *Progress) Add(done int64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.progress.Done += done
+}
+
+func (p *Progress) get() domain.JobProgress {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.progress
+}
+
+// Handler runs a job and returns its result, which is saved as JSON even if the job fails
+// ctx is canceled when the job is canceled or the worker stops
+type Handler func(ctx context.Context, job *domain.Job, progress *Progress) (interface{}, error)
+
+// Runner claims queued jobs and runs them with the handler registered for their type
+// A job is run at least once: jobs of a worker that dies are claimed again once their lease expires
+type Runner struct {
+	store    storage.JobStorer
+	cfg      Config
+	logger   *zap.Logger
+	handlers map[string]Handler
+	types    []string
+}
+
+// NewRunner creates a new Runner
+func NewRunner(store storage.JobStorer, cfg Config, logger *zap.Logger) *Runner {
+	return &Runner{
+		store:    store,
+		cfg:      cfg,
+		logger:   logger,
+		handlers: make(map[string]Handler),
+	}
+}
+
+// Register sets the handler of a job type, it must be called before Run
+func (r *Runner) Register(jobType string, handler Handler) {
+	if _, ok := r.handlers[jobType]; !ok {
+		r.types = append(r.types, jobType)
+	}
+	r.handlers[jobType] = handler
+}
+
+// Run runs the workers and prunes finished jobs every hour until ctx is done
+// Jobs still running then are put back in the queue for another worker
+func (r *Runner) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	for i := 0; i < r.cfg.Workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			r.work(ctx)
+		}()
+	}
+
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			wg.Wait()
+			return
+		case <-ticker.C:
+			r.PruneOnce(ctx)
+		}
+	}
+}
+
+// work runs jobs one after the other, waiting for new ones while the queue is empty
+func (r *Runner) work(ctx context.Context) {
+	for ctx.Err() == nil {
+		if r.RunOnce(ctx) {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+		case <-time.After(r.cfg.PollInterval):
+		}
+	}
+}
+
+// RunOnce claims a job and runs it, it reports whether there was one
+func (r *Runner) RunOnce(ctx context.Context) bool {
+	job, err := r.store.ClaimJob(ctx, r.types, r.cfg.Lease)
+	if err != nil {
+		if ctx.Err() == nil {
+			r.logger.Error("failed to claim job", zap.Error(err))
+		}
+		return false
+	}
+	if job == nil {
+		return false
+	}
+
+	r.run(ctx, job)
+	return true
+}
+
+// run runs a claimed job, sending heartbeats meanwhile, and records how it ended
+func (r *Runner) run(ctx context.Context, job *domain.Job) {
+	logger := r.logger.With(zap.Int64("job_id", job.ID), zap.String("type", job.Type), zap.Int("attempt", job.Attempts))
+
+	// Cancellations of jobs abandoned by their worker are only seen when they are claimed again
+	switch {
+	case job.CancelRequested:
+		r.finish(ctx, job, storage.JobOutcome{Status: domain.JobCanceled, Progress: job.Progress}, logger)
+		return
+	case job.Attempts > r.cfg.MaxAttempts:
+		logger.Error("job abandoned too many times")
+		r.finish(ctx, job, storage.JobOutcome{Status: domain.JobFailed, Progress: job.Progress, Error: "Job abandoned by its workers"}, logger)
+		return
+	}
+
+	jobCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	progress := &Progress{progress: job.Progress}
+	var canceled, lost atomic.Bool
+	heartbeats := make(chan struct{})
+	go func() {
+		defer close(heartbeats)
+		r.heartbeat(jobCtx, job, progress, func(isLost bool) {
+			if isLost {
+				lost.Store(true)
+			} else {
+				canceled.Store(true)
+			}
+			cancel()
+		}, logger)
+	}()
+
+	result, err := r.handle(jobCtx, job, progress)
+	cancel()
+	<-heartbeats
+
+	outcome := storage.JobOutcome{Status: domain.JobSucceeded, Progress: progress.get(), Result: result}
+	switch {
+	case lost.Load():
+		logger.Warn("job claimed again by another worker, its outcome is dropped")
+		return
+	case canceled.Load():
+		outcome.Status = domain.JobCanceled
+	case err == nil:
+	case ctx.Err() != nil:
+		// The worker is stopping, another one starts the job over
+		releaseCtx, cancelRelease := context.WithTimeout(context.WithoutCancel(ctx), finishTimeout)
+		defer cancelRelease()
+		if err := r.store.ReleaseJob(releaseCtx, job.ID, job.Attempts); err != nil {
+			logger.Error("failed to release job", zap.Error(err))
+		}
+		return
+	default:
+		outcome.Status = domain.JobFailed
+		outcome.Error = failureMessage(err)
+		logger.Error("job failed", zap.Error(err))
+	}
+
+	r.finish(ctx, job, outcome, logger)
+}
+
+// handle runs the handler of a job, turning panics into errors so that the worker survives them
+func (r *Runner) handle(ctx context.Context, job *domain.Job, progress *Progress) (result interface{}, err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("job panicked: %v", p)
+		}
+	}()
+	return r.handlers[job.Type](ctx, job, progress)
+}
+
+// heartbeat saves the progress of a job and extends its lease until ctx is done
+// stop is called when the job is canceled, or with true when it was claimed by another worker
+func (r *Runner) heartbeat(ctx context.Context, job *domain.Job, progress *Progress, stop func(lost bool), logger *zap.Logger) {
+	ticker := time.NewTicker(r.cfg.Lease / 3)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		cancelRequested, err := r.store.HeartbeatJob(ctx, job.ID, job.Attempts, progress.get(), r.cfg.Lease)
+		switch {
+		case errors.Is(err, storage.ErrJobLeaseLost):
+			stop(true)
+			return
+		case err != nil:
+			// The lease lasts a few heartbeats, so a failed one is not fatal
+			if ctx.Err() == nil {
+				logger.Warn("failed to record job heartbeat", zap.Error(err))
+			}
+		case cancelRequested:
+			logger.Info("job canceled")
+			stop(false)
+			return
+		}
+	}
+}
+
+// finish records the outcome of a job, even if the worker is stopping
+func (r *Runner) finish(ctx context.Context, job *domain.Job, outcome storage.JobOutcome, logger *zap.Logger) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), finishTimeout)
+	defer cancel()
+
+	if err := r.store.FinishJob(ctx, job.ID, job.Attempts, outcome); err != nil {
+		logger.Error("failed to finish job", zap.Error(err))
+		return
+	}
+	jobsFinishedTotal.WithLabelValues(job.Type, string(outcome.Status)).Inc()
+}
+
+// PruneOnce deletes the jobs finished longer than the retention ago along with their files
+// It returns the number of pruned jobs
+func (r *Runner) PruneOnce(ctx context.Context) int {
+	before := time.Now().Add(-r.cfg.Retention)
+	r.pruneUploads(before)
+
+	ids, err := r.store.PruneJobs(ctx, before)
+	if err != nil {
+		r.logger.Error("failed to prune jobs", zap.Error(err))
+		return 0
+	}
+
+	for _, id := range ids {
+		if err := os.Remove(r.cfg.FilePath(id)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			r.logger.Warn("failed to remove job file", zap.Int64("job_id", id), zap.Error(err))
+		}
+	}
+	return len(ids)
+}
+
+// pruneUploads deletes the uploads last written before the given time
+// They belong to jobs canceled while queued, or that failed to be queued, as no job waits in the queue that long
+func (r *Runner) pruneUploads(before time.Time) {
+	paths, err := filepath.Glob(filepath.Join(r.cfg.Dir, UploadPattern))
+	if err != nil {
+		r.logger.Error("failed to list uploads", zap.Error(err))
+		return
+	}
+
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil || !info.ModTime().Before(before) {
+			continue
+		}
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			r.logger.Warn("failed to remove upload", zap.String("path", path), zap.Error(err))
+		}
+	}
+}
+
+// failureMessage returns the message of a job error that can be shown to clients
+func failureMessage(err error) string {
+	var jobErr *Error
+	switch {
+	case errors.As(err, &jobErr):
+		return jobErr.Message
+	case errors.Is(err, context.DeadlineExceeded):
+		return "Job timed out"
+	default:
+		return "Job failed"
+	}
+}
@@ -0,0 +1,176 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/huberts90/restful-api/internal/domain"
+	"github.com/huberts90/restful-api/internal/storage"
+	storagemocks "github.com/huberts90/restful-api/internal/storage/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func newTestRunner(t *testing.T, lease time.Duration) (*Runner, *storagemocks.MockJobStorer) {
+	t.Helper()
+	store := storagemocks.NewMockJobStorer(t)
+	cfg := Config{Workers: 1, PollInterval: time.Millisecond, Lease: lease, MaxAttempts: 3, Retention: time.Hour, Dir: t.TempDir()}
+	return NewRunner(store, cfg, zap.NewNop()), store
+}
+
+func TestRunOnce(t *testing.T) {
+	tests := []struct {
+		name    string
+		handler Handler
+		want    storage.JobOutcome
+	}{
+		{
+			name: "success",
+			handler: func(_ context.Context, _ *domain.Job, progress *Progress) (interface{}, error) {
+				progress.Set(2, 2)
+				return PurgeResult{Purged: 2}, nil
+			},
+			want: storage.JobOutcome{Status: domain.JobSucceeded, Progress: domain.JobProgress{Done: 2, Total: 2}, Result: PurgeResult{Purged: 2}},
+		},
+		{
+			name: "failure shown to clients",
+			handler: func(context.Context, *domain.Job, *Progress) (interface{}, error) {
+				return nil, Errorf("Invalid payload")
+			},
+			want: storage.JobOutcome{Status: domain.JobFailed, Error: "Invalid payload"},
+		},
+		{
+			name: "internal failure",
+			handler: func(context.Context, *domain.Job, *Progress) (interface{}, error) {
+				return nil, errors.New("connection reset")
+			},
+			want: storage.JobOutcome{Status: domain.JobFailed, Error: "Job failed"},
+		},
+		{
+			name: "panic",
+			handler: func(context.Context, *domain.Job, *Progress) (interface{}, error) {
+				panic("boom")
+			},
+			want: storage.JobOutcome{Status: domain.JobFailed, Error: "Job failed"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			runner, store := newTestRunner(t, time.Minute)
+			runner.Register(TypePurgeUsers, tt.handler)
+
+			store.On("ClaimJob", mock.Anything, []string{TypePurgeUsers}, time.Minute).
+				Return(&domain.Job{ID: 3, Type: TypePurgeUsers, Status: domain.JobRunning, Attempts: 1}, nil)
+			store.On("FinishJob", mock.Anything, int64(3), 1, tt.want).Return(nil)
+
+			assert.True(t, runner.RunOnce(context.Background()))
+		})
+	}
+}
+
+func TestRunOnce_EmptyQueue(t *testing.T) {
+	runner, store := newTestRunner(t, time.Minute)
+	runner.Register(TypePurgeUsers, nil)
+	store.On("ClaimJob", mock.Anything, []string{TypePurgeUsers}, time.Minute).Return(nil, nil)
+
+	assert.False(t, runner.RunOnce(context.Background()))
+}
+
+func TestRunOnce_Canceled(t *testing.T) {
+	runner, store := newTestRunner(t, 30*time.Millisecond)
+	runner.Register(TypeExportUsers, func(ctx context.Context, _ *domain.Job, progress *Progress) (interface{}, error) {
+		progress.Add(5)
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+
+	store.On("ClaimJob", mock.Anything, mock.Anything, mock.Anything).
+		Return(&domain.Job{ID: 3, Type: TypeExportUsers, Status: domain.JobRunning, Attempts: 1}, nil)
+	store.On("HeartbeatJob", mock.Anything, int64(3), 1, domain.JobProgress{Done: 5}, 30*time.Millisecond).Return(true, nil)
+	store.On("FinishJob", mock.Anything, int64(3), 1, storage.JobOutcome{Status: domain.JobCanceled, Progress: domain.JobProgress{Done: 5}}).Return(nil)
+
+	assert.True(t, runner.RunOnce(context.Background()))
+}
+
+func TestRunOnce_LeaseLost(t *testing.T) {
+	runner, store := newTestRunner(t, 30*time.Millisecond)
+	runner.Register(TypeExportUsers, func(ctx context.Context, _ *domain.Job, _ *Progress) (interface{}, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+
+	// The outcome belongs to the worker that claimed the job again
+	store.On("ClaimJob", mock.Anything, mock.Anything, mock.Anything).
+		Return(&domain.Job{ID: 3, Type: TypeExportUsers, Status: domain.JobRunning, Attempts: 1}, nil)
+	store.On("HeartbeatJob", mock.Anything, int64(3), 1, mock.Anything, mock.Anything).Return(false, storage.ErrJobLeaseLost)
+
+	assert.True(t, runner.RunOnce(context.Background()))
+}
+
+func TestRunOnce_Shutdown(t *testing.T) {
+	runner, store := newTestRunner(t, time.Minute)
+	ctx, cancel := context.WithCancel(context.Background())
+	runner.Register(TypeExportUsers, func(ctx context.Context, _ *domain.Job, _ *Progress) (interface{}, error) {
+		cancel()
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+
+	store.On("ClaimJob", mock.Anything, mock.Anything, mock.Anything).
+		Return(&domain.Job{ID: 3, Type: TypeExportUsers, Status: domain.JobRunning, Attempts: 2}, nil)
+	store.On("ReleaseJob", mock.Anything, int64(3), 2).Return(nil)
+
+	assert.True(t, runner.RunOnce(ctx))
+}
+
+func TestRunOnce_Abandoned(t *testing.T) {
+	runner, store := newTestRunner(t, time.Minute)
+	runner.Register(TypeExportUsers, func(context.Context, *domain.Job, *Progress) (interface{}, error) {
+		t.Fatal("a job abandoned too many times must not run")
+		return nil, nil
+	})
+
+	store.On("ClaimJob", mock.Anything, mock.Anything, mock.Anything).
+		Return(&domain.Job{ID: 3, Type: TypeExportUsers, Status: domain.JobRunning, Attempts: 4}, nil)
+	store.On("FinishJob", mock.Anything, int64(3), 4, storage.JobOutcome{Status: domain.JobFailed, Error: "Job abandoned by its workers"}).Return(nil)
+
+	assert.True(t, runner.RunOnce(context.Background()))
+}
+
+func TestPruneOnce(t *testing.T) {
+	runner, store := newTestRunner(t, time.Minute)
+	require.NoError(t, os.WriteFile(runner.cfg.FilePath(1), []byte("id\n"), 0o600))
+
+	store.On("PruneJobs", mock.Anything, mock.Anything).Return([]int64{1, 2}, nil)
+
+	assert.Equal(t, 2, runner.PruneOnce(context.Background()))
+	_, err := os.Stat(runner.cfg.FilePath(1))
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestPruneOnce_Uploads(t *testing.T) {
+	runner, store := newTestRunner(t, time.Minute)
+	stale := filepath.Join(runner.cfg.Dir, "upload-1")
+	fresh := filepath.Join(runner.cfg.Dir, "upload-2")
+	for _, path := range []string{stale, fresh} {
+		require.NoError(t, os.WriteFile(path, []byte("email\n"), 0o600))
+	}
+	// The upload of a job canceled while queued is older than the retention
+	old := time.Now().Add(-2 * runner.cfg.Retention)
+	require.NoError(t, os.Chtimes(stale, old, old))
+
+	store.On("PruneJobs", mock.Anything, mock.Anything).Return(nil, nil)
+
+	runner.PruneOnce(context.Background())
+	_, err := os.Stat(stale)
+	assert.ErrorIs(t, err, os.ErrNotExist)
+	_, err = os.Stat(fresh)
+	assert.NoError(t, err)
+}
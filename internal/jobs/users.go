@@ -0,0 +1,194 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/huberts90/restful-api/internal/domain"
+	"github.com/huberts90/restful-api/internal/export"
+	"github.com/huberts90/restful-api/internal/importer"
+	"github.com/huberts90/restful-api/internal/storage"
+	"go.uber.org/zap"
+)
+
+// Types of the jobs operating on users
+const (
+	TypeExportUsers = "users.export"
+	TypeImportUsers = "users.import"
+	TypePurgeUsers  = "users.purge"
+)
+
+// ExportPayload is the input of an export job
+type ExportPayload struct {
+	Format         export.Format `json:"format"`
+	IncludeDeleted bool          `json:"include_deleted"`
+}
+
+// ExportResult is the result of an export job, the file can be downloaded from the job
+type ExportResult struct {
+	domain.JobFile
+	Rows int `json:"rows"`
+}
+
+// ImportPayload is the input of an import job
+// The uploaded file is moved to the file of the job once it starts, so that it is pruned along with it
+type ImportPayload struct {
+	File      string                 `json:"file"`
+	MediaType string                 `json:"media_type"`
+	Policy    domain.DuplicatePolicy `json:"on_duplicate"`
+	DryRun    bool                   `json:"dry_run"`
+}
+
+// PurgeResult is the result of a purge job
+type PurgeResult struct {
+	Purged int64 `json:"purged"`
+}
+
+// UserConfig holds the configuration of the jobs operating on users
+type UserConfig struct {
+	Import         importer.Config
+	Export         export.Config
+	PurgeRetention time.Duration // time users stay soft deleted before being purged
+}
+
+// RegisterUserJobs registers the handlers of the jobs operating on users
+func RegisterUserJobs(r *Runner, store storage.Storer, cfg UserConfig, logger *zap.Logger) {
+	users := &userJobs{store: store, files: r.cfg, cfg: cfg, logger: logger}
+	r.Register(TypeExportUsers, users.export)
+	r.Register(TypeImportUsers, users.importFile)
+	r.Register(TypePurgeUsers, users.purge)
+}
+
+// userJobs runs the jobs operating on users
+type userJobs struct {
+	store  storage.Storer
+	files  Config
+	cfg    UserConfig
+	logger *zap.Logger
+}
+
+// export writes the users to the file of the job, the number of users written is its progress
+func (u *userJobs) export(ctx context.Context, job *domain.Job, progress *Progress) (interface{}, error) {
+	var payload ExportPayload
+	if err := decodePayload(job, &payload); err != nil {
+		return nil, err
+	}
+	var opts []storage.ReadOption
+	if payload.IncludeDeleted {
+		opts = append(opts, storage.IncludeDeleted())
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, u.cfg.Export.Timeout)
+	defer cancel()
+
+	// The file is written under a temporary name, so that it is never downloaded halfway
+	path := u.files.FilePath(job.ID)
+	file, err := os.Create(path + ".tmp")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create export file: %w", err)
+	}
+	defer os.Remove(file.Name())
+
+	progress.Set(0, 0)
+	rows, err := export.Write(ctx, &progressExporter{store: u.store, progress: progress}, payload.Format, file, opts...)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Rename(file.Name(), path); err != nil {
+		return nil, fmt.Errorf("failed to save export file: %w", err)
+	}
+
+	return ExportResult{
+		JobFile: domain.JobFile{FileName: payload.Format.Filename(), ContentType: payload.Format.ContentType()},
+		Rows:    rows,
+	}, nil
+}
+
+// importFile imports the uploaded file, the bytes read are its progress
+// The report is the result, also when the import fails midway
+func (u *userJobs) importFile(ctx context.Context, job *domain.Job, progress *Progress) (interface{}, error) {
+	var payload ImportPayload
+	if err := decodePayload(job, &payload); err != nil {
+		return nil, err
+	}
+
+	path := u.files.FilePath(job.ID)
+	if err := os.Rename(payload.File, path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("failed to move uploaded file: %w", err)
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open uploaded file: %w", err)
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat uploaded file: %w", err)
+	}
+	progress.Set(0, info.Size())
+
+	reader, err := importer.NewReader(payload.MediaType, &progressReader{r: file, progress: progress})
+	if err != nil {
+		return nil, &Error{Message: err.Error()}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, u.cfg.Import.Timeout)
+	defer cancel()
+
+	report, err := importer.New(u.store, u.cfg.Import, u.logger).Import(ctx, reader, importer.Options{Policy: payload.Policy, DryRun: payload.DryRun})
+	if errors.Is(err, importer.ErrInvalidFile) {
+		err = &Error{Message: err.Error()}
+	}
+	return report, err
+}
+
+// purge hard deletes the users soft deleted for longer than the retention
+func (u *userJobs) purge(ctx context.Context, _ *domain.Job, _ *Progress) (interface{}, error) {
+	purged, err := u.store.PurgeDeletedUsers(ctx, time.Now().Add(-u.cfg.PurgeRetention))
+	if err != nil {
+		return nil, err
+	}
+	return PurgeResult{Purged: purged}, nil
+}
+
+// decodePayload decodes the payload of a job into v
+func decodePayload(job *domain.Job, v interface{}) error {
+	if err := json.Unmarshal(job.Payload, v); err != nil {
+		return Errorf("Invalid payload: %v", err)
+	}
+	return nil
+}
+
+// progressExporter counts the users exported from a store
+type progressExporter struct {
+	store    export.Exporter
+	progress *Progress
+}
+
+func (e *progressExporter) ExportUsers(ctx context.Context, fn func(domain.User) error, opts ...storage.ReadOption) error {
+	return e.store.ExportUsers(ctx, func(user domain.User) error {
+		e.progress.Add(1)
+		return fn(user)
+	}, opts...)
+}
+
+// progressReader counts the bytes read from a file
+type progressReader struct {
+	r        io.Reader
+	progress *Progress
+}
+
+func (r *progressReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.progress.Add(int64(n))
+	return n, err
+}
@@ -0,0 +1,105 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/huberts90/restful-api/internal/domain"
+	"github.com/huberts90/restful-api/internal/export"
+	"github.com/huberts90/restful-api/internal/importer"
+	"github.com/huberts90/restful-api/internal/storage"
+	storagemocks "github.com/huberts90/restful-api/internal/storage/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func newUserJobs(t *testing.T) (*userJobs, *storagemocks.MockStorer) {
+	t.Helper()
+	store := storagemocks.NewMockStorer(t)
+	return &userJobs{
+		store: store,
+		files: Config{Dir: t.TempDir()},
+		cfg: UserConfig{
+			Import: importer.Config{BatchSize: 10, MaxErrors: 10, Timeout: time.Minute},
+			Export: export.Config{Timeout: time.Minute},
+		},
+		logger: zap.NewNop(),
+	}, store
+}
+
+func newJob(t *testing.T, id int64, jobType string, payload interface{}) *domain.Job {
+	t.Helper()
+	data, err := json.Marshal(payload)
+	require.NoError(t, err)
+	return &domain.Job{ID: id, Type: jobType, Status: domain.JobRunning, Attempts: 1, Payload: data}
+}
+
+func TestExportJob(t *testing.T) {
+	users, store := newUserJobs(t)
+	store.On("ExportUsers", mock.Anything, mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		fn := args.Get(1).(func(domain.User) error)
+		_ = fn(domain.User{ID: 1, Email: "ann@example.com", FirstName: "Ann", LastName: "Lee"})
+	}).Return(nil)
+
+	progress := &Progress{}
+	job := newJob(t, 7, TypeExportUsers, ExportPayload{Format: export.NDJSON, IncludeDeleted: true})
+	result, err := users.export(context.Background(), job, progress)
+
+	require.NoError(t, err)
+	assert.Equal(t, ExportResult{JobFile: domain.JobFile{FileName: "users.ndjson", ContentType: "application/x-ndjson"}, Rows: 1}, result)
+	assert.Equal(t, domain.JobProgress{Done: 1}, progress.get())
+
+	content, err := os.ReadFile(users.files.FilePath(7))
+	require.NoError(t, err)
+	assert.Contains(t, string(content), `"email":"ann@example.com"`)
+	_, err = os.Stat(users.files.FilePath(7) + ".tmp")
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestImportJob(t *testing.T) {
+	users, store := newUserJobs(t)
+	upload := filepath.Join(users.files.Dir, "upload-1")
+	file := "email,first_name,last_name\nann@example.com,Ann,Lee\n"
+	require.NoError(t, os.WriteFile(upload, []byte(file), 0o600))
+
+	store.On("ImportUsers", mock.Anything, []domain.UserCreate{{Email: "ann@example.com", FirstName: "Ann", LastName: "Lee"}}, domain.DuplicateSkip, false).
+		Return([]storage.ImportResult{{ID: 1, Outcome: storage.ImportCreated}}, nil)
+
+	progress := &Progress{}
+	job := newJob(t, 8, TypeImportUsers, ImportPayload{File: upload, MediaType: "text/csv", Policy: domain.DuplicateSkip})
+	result, err := users.importFile(context.Background(), job, progress)
+
+	require.NoError(t, err)
+	report := result.(*domain.ImportReport)
+	assert.Equal(t, 1, report.Created)
+	assert.Equal(t, domain.JobProgress{Done: int64(len(file)), Total: int64(len(file))}, progress.get())
+
+	// The upload is now the file of the job, which is pruned along with it
+	_, err = os.Stat(upload)
+	assert.ErrorIs(t, err, os.ErrNotExist)
+	_, err = os.Stat(users.files.FilePath(8))
+	assert.NoError(t, err)
+
+	// A job claimed again finds the file where the previous attempt moved it
+	_, err = users.importFile(context.Background(), job, &Progress{})
+	assert.NoError(t, err)
+}
+
+func TestImportJob_InvalidFile(t *testing.T) {
+	users, _ := newUserJobs(t)
+	upload := filepath.Join(users.files.Dir, "upload-1")
+	require.NoError(t, os.WriteFile(upload, []byte("email\nann@example.com\n"), 0o600))
+
+	job := newJob(t, 8, TypeImportUsers, ImportPayload{File: upload, MediaType: "text/csv", Policy: domain.DuplicateFail})
+	_, err := users.importFile(context.Background(), job, &Progress{})
+
+	var jobErr *Error
+	require.ErrorAs(t, err, &jobErr)
+	assert.Contains(t, jobErr.Message, "invalid file")
+}
@@ -0,0 +1,22 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/huberts90/restful-api/internal/storage"
+)
+
+// AuditMiddleware creates a middleware that tells the storage layer who is behind a request
+// Mutations record the client and the request ID in the audit trail
+func AuditMiddleware() mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := storage.WithAuditInfo(r.Context(), storage.AuditInfo{
+				Actor:     clientKey(r),
+				RequestID: RequestIDFromContext(r.Context()),
+			})
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
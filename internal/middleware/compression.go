@@ -0,0 +1,431 @@
+package middleware
+
+import (
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/andybalholm/brotli"
+	"github.com/gorilla/mux"
+	"github.com/klauspost/compress/zstd"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"go.uber.org/zap"
+)
+
+var compressedResponsesTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "http_compressed_responses_total",
+	Help: "Number of responses by the content coding they were sent with, identity if they were not compressed",
+}, []string{"encoding"})
+
+// CompressionConfig holds the compression of responses and the decompression of requests
+type CompressionConfig struct {
+	Enabled             bool
+	MinSize             int      // responses smaller than this are sent as is, unless they are streamed
+	ContentTypes        []string // media types to compress, patterns such as application/*+json are allowed
+	MaxDecompressedSize int64    // requests decompressing to more than this are rejected
+}
+
+// DefaultCompressionContentTypes are the media types compressed unless configured otherwise
+var DefaultCompressionContentTypes = []string{
+	"application/json",
+	"application/*+json",
+	"application/xml",
+	"text/xml",
+	"text/csv",
+	"application/x-ndjson",
+	"text/plain",
+}
+
+// encoding is a content coding the middleware speaks
+type encoding struct {
+	name      string
+	newWriter func(w io.Writer) (compressor, error)
+	newReader func(r io.Reader, maxSize int64) (io.ReadCloser, error)
+	writers   sync.Pool // compressors of ended responses, they hold large buffers that are costly to allocate
+}
+
+// compressor is the writer of a content coding
+type compressor interface {
+	io.WriteCloser
+	Flush() error
+	Reset(w io.Writer)
+}
+
+// Helper function to get a compressor writing to w, reusing one from the pool if there is one
+func (e *encoding) getWriter(w io.Writer) (compressor, error) {
+	if c, ok := e.writers.Get().(compressor); ok {
+		c.Reset(w)
+		return c, nil
+	}
+	return e.newWriter(w)
+}
+
+// Helper function to give back a compressor whose stream is ended
+func (e *encoding) putWriter(c compressor) {
+	// The pool must not keep the response it wrote to alive
+	c.Reset(nil)
+	e.writers.Put(c)
+}
+
+// encodings are the content codings of responses, in order of preference when a client accepts several equally
+var encodings = []*encoding{
+	{
+		name:      "zstd",
+		newWriter: func(w io.Writer) (compressor, error) { return zstd.NewWriter(w, zstd.WithEncoderConcurrency(1)) },
+		newReader: func(r io.Reader, maxSize int64) (io.ReadCloser, error) {
+			// A frame header alone can ask for a window of hundreds of MiB, allocated before a byte is decoded
+			d, err := zstd.NewReader(r,
+				zstd.WithDecoderConcurrency(1),
+				zstd.WithDecoderLowmem(true),
+				zstd.WithDecoderMaxWindow(zstdMaxWindow),
+				zstd.WithDecoderMaxMemory(uint64(maxSize)),
+			)
+			if err != nil {
+				return nil, zstdError(err, maxSize)
+			}
+			return &zstdReader{ReadCloser: d.IOReadCloser(), maxSize: maxSize}, nil
+		},
+	},
+	{
+		name:      "br",
+		newWriter: func(w io.Writer) (compressor, error) { return brotli.NewWriterLevel(w, 4), nil },
+		newReader: func(r io.Reader, _ int64) (io.ReadCloser, error) { return io.NopCloser(brotli.NewReader(r)), nil },
+	},
+	{
+		name:      "gzip",
+		newWriter: func(w io.Writer) (compressor, error) { return gzip.NewWriter(w), nil },
+		newReader: func(r io.Reader, _ int64) (io.ReadCloser, error) { return gzip.NewReader(r) },
+	},
+}
+
+// zstdMaxWindow is the largest zstd window a request may use, RFC 8878 asks decoders of HTTP
+// content to support 8 MiB
+const zstdMaxWindow = 8 << 20
+
+// zstdReader reports frames needing more memory than allowed the way other oversized bodies are
+type zstdReader struct {
+	io.ReadCloser
+	maxSize int64
+}
+
+func (r *zstdReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	return n, zstdError(err, r.maxSize)
+}
+
+// Helper function to turn the errors of a zstd frame too large to decode into an *http.MaxBytesError
+func zstdError(err error, maxSize int64) error {
+	if errors.Is(err, zstd.ErrWindowSizeExceeded) || errors.Is(err, zstd.ErrDecoderSizeExceeded) {
+		return &http.MaxBytesError{Limit: maxSize}
+	}
+	return err
+}
+
+// CompressionMiddleware creates a middleware that compresses responses in the content coding
+// negotiated with Accept-Encoding, and decompresses request bodies sent with a Content-Encoding.
+// Only responses of the allowed media types and at least MinSize bytes are compressed, streamed
+// responses are compressed as soon as they are flushed
+func CompressionMiddleware(cfg CompressionConfig, logger *zap.Logger) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if contentEncoding := r.Header.Get("Content-Encoding"); contentEncoding != "" && !strings.EqualFold(contentEncoding, "identity") {
+				body, err := decompress(r.Body, contentEncoding, cfg.MaxDecompressedSize)
+				var (
+					unsupported *unsupportedEncodingError
+					maxBytesErr *http.MaxBytesError
+				)
+				if errors.As(err, &maxBytesErr) {
+					writeProblem(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("Request body too large, the limit is %d bytes", maxBytesErr.Limit), RequestIDFromContext(r.Context()))
+					return
+				}
+				if errors.As(err, &unsupported) {
+					// RFC 9110 tells the client which codings it may send instead
+					w.Header().Set("Accept-Encoding", "zstd, br, gzip")
+					writeProblem(w, http.StatusUnsupportedMediaType, err.Error(), RequestIDFromContext(r.Context()))
+					return
+				}
+				if err != nil {
+					writeProblem(w, http.StatusBadRequest, "Invalid "+contentEncoding+" body", RequestIDFromContext(r.Context()))
+					return
+				}
+				defer body.Close()
+				r.Body = body
+				r.Header.Del("Content-Encoding")
+				r.Header.Del("Content-Length")
+				r.ContentLength = -1
+			}
+
+			enc := negotiateEncoding(r.Header.Values("Accept-Encoding"))
+			if enc == nil || r.Method == http.MethodHead {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			cw := &compressWriter{ResponseWriter: w, cfg: cfg, enc: enc, statusCode: http.StatusOK, logger: logger}
+			next.ServeHTTP(cw, r)
+			// A panic aborting the response skips this, the client only sees the connection cut
+			cw.Close()
+		})
+	}
+}
+
+// Helper function to pick the content coding of a response from the Accept-Encoding header
+// The coding of the highest quality wins, nil means the response is sent as is
+func negotiateEncoding(accept []string) *encoding {
+	qualities := make(map[string]float64)
+	for _, header := range accept {
+		for _, coding := range strings.Split(header, ",") {
+			name, params, _ := strings.Cut(strings.TrimSpace(coding), ";")
+			quality := 1.0
+			if q, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+				var err error
+				if quality, err = strconv.ParseFloat(q, 64); err != nil {
+					continue
+				}
+			}
+			qualities[strings.ToLower(strings.TrimSpace(name))] = quality
+		}
+	}
+
+	var best *encoding
+	bestQuality := 0.0
+	for _, enc := range encodings {
+		quality, ok := qualities[enc.name]
+		if !ok {
+			quality = qualities["*"]
+		}
+		if quality > bestQuality {
+			best, bestQuality = enc, quality
+		}
+	}
+	return best
+}
+
+// Helper function to decompress a request body, reads past maxSize fail with an *http.MaxBytesError
+func decompress(body io.ReadCloser, contentEncoding string, maxSize int64) (io.ReadCloser, error) {
+	for _, enc := range encodings {
+		if strings.EqualFold(strings.TrimSpace(contentEncoding), enc.name) {
+			reader, err := enc.newReader(body, maxSize)
+			if err != nil {
+				return nil, err
+			}
+			return &limitedReader{r: reader, remaining: maxSize, limit: maxSize, closers: []io.Closer{reader, body}}, nil
+		}
+	}
+	return nil, &unsupportedEncodingError{contentEncoding}
+}
+
+type unsupportedEncodingError struct {
+	encoding string
+}
+
+func (e *unsupportedEncodingError) Error() string {
+	return "unsupported Content-Encoding " + e.encoding + ", supported are zstd, br and gzip"
+}
+
+// limitedReader fails once more than its limit is read, so that a small compressed body cannot
+// decompress into an unbounded one
+type limitedReader struct {
+	r         io.Reader
+	remaining int64
+	limit     int64
+	closers   []io.Closer
+}
+
+func (l *limitedReader) Read(p []byte) (int, error) {
+	if l.remaining < 0 {
+		return 0, &http.MaxBytesError{Limit: l.limit}
+	}
+	// Read one byte more than allowed to tell a body of exactly the limit from a longer one
+	if int64(len(p)) > l.remaining+1 {
+		p = p[:l.remaining+1]
+	}
+	n, err := l.r.Read(p)
+	l.remaining -= int64(n)
+	if l.remaining < 0 {
+		return n + int(l.remaining), &http.MaxBytesError{Limit: l.limit}
+	}
+	return n, err
+}
+
+func (l *limitedReader) Close() error {
+	var err error
+	for _, c := range l.closers {
+		if cerr := c.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
+// compressWriter holds back the start of a response until it knows whether to compress it
+type compressWriter struct {
+	http.ResponseWriter
+	cfg    CompressionConfig
+	enc    *encoding
+	logger *zap.Logger
+
+	statusCode int
+	buf        []byte
+	decided    bool
+	writer     compressor // nil if the response is sent as is
+}
+
+// WriteHeader holds the status code back along with the start of the body
+func (w *compressWriter) WriteHeader(statusCode int) {
+	if w.decided {
+		return
+	}
+	if statusCode < http.StatusOK {
+		w.ResponseWriter.WriteHeader(statusCode)
+		return
+	}
+	w.statusCode = statusCode
+	// Responses without a body, and those sent as is whatever their size, are sent right away
+	if statusCode == http.StatusNoContent || statusCode == http.StatusNotModified || w.asIs() {
+		_ = w.start(false)
+	}
+}
+
+// asIs tells whether the response must be sent as the handler wrote it
+// The offsets of a partial response, or of one the client may ask ranges of, are those of the uncompressed
+// content, and a response that is already encoded must not be encoded twice
+func (w *compressWriter) asIs() bool {
+	header := w.Header()
+	return w.statusCode == http.StatusPartialContent ||
+		header.Get("Content-Range") != "" ||
+		header.Get("Content-Encoding") != "" ||
+		strings.EqualFold(header.Get("Accept-Ranges"), "bytes")
+}
+
+func (w *compressWriter) Write(p []byte) (int, error) {
+	if !w.decided {
+		w.buf = append(w.buf, p...)
+		if len(w.buf) < w.cfg.MinSize {
+			return len(p), nil
+		}
+		if err := w.start(true); err != nil {
+			return 0, err
+		}
+		return len(p), nil
+	}
+
+	if w.writer != nil {
+		return w.writer.Write(p)
+	}
+	return w.ResponseWriter.Write(p)
+}
+
+// Flush compresses what is written so far and sends it, a streamed response is compressed whatever its size
+func (w *compressWriter) Flush() {
+	if !w.decided {
+		if err := w.start(true); err != nil {
+			return
+		}
+	}
+	if w.writer != nil {
+		if err := w.writer.Flush(); err != nil {
+			return
+		}
+	}
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap returns the wrapped ResponseWriter, so that http.ResponseController can reach it
+func (w *compressWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// Close sends what is held back and ends the compressed stream
+func (w *compressWriter) Close() {
+	if !w.decided {
+		if err := w.start(len(w.buf) >= w.cfg.MinSize); err != nil {
+			return
+		}
+	}
+	if w.writer != nil {
+		if err := w.writer.Close(); err != nil {
+			w.logger.Warn("failed to end compressed response", zap.Error(err), zap.String("encoding", w.enc.name))
+			return
+		}
+		w.enc.putWriter(w.writer)
+		w.writer = nil
+	}
+}
+
+// start sends the status code and what is held back of the body, compressed if it may be
+func (w *compressWriter) start(large bool) error {
+	w.decided = true
+
+	// The bytes sent depend on the coding, so the ETag only tells the content apart. Whether it is weak goes
+	// by the coding the request negotiated, not the response, so that a 304 carries the ETag of its 200.
+	// A response sent as is keeps its strong ETag, which If-Range needs
+	header := w.Header()
+	asIs := w.asIs()
+	if !asIs {
+		header.Add("Vary", "Accept-Encoding")
+		if etag := header.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+			header.Set("ETag", "W/"+etag)
+		}
+	}
+
+	if large && !asIs && w.compressible() {
+		writer, err := w.enc.getWriter(w.ResponseWriter)
+		if err != nil {
+			w.logger.Error("failed to start compressed response", zap.Error(err), zap.String("encoding", w.enc.name))
+		} else {
+			w.writer = writer
+			header.Set("Content-Encoding", w.enc.name)
+			header.Del("Content-Length")
+		}
+	}
+	if w.writer != nil {
+		compressedResponsesTotal.WithLabelValues(w.enc.name).Inc()
+	} else {
+		compressedResponsesTotal.WithLabelValues("identity").Inc()
+	}
+
+	w.ResponseWriter.WriteHeader(w.statusCode)
+	if len(w.buf) == 0 {
+		return nil
+	}
+	buf := w.buf
+	w.buf = nil
+	if w.writer != nil {
+		_, err := w.writer.Write(buf)
+		return err
+	}
+	_, err := w.ResponseWriter.Write(buf)
+	return err
+}
+
+// Helper function to tell whether the media type of the response is one to compress
+func (w *compressWriter) compressible() bool {
+	contentType := w.Header().Get("Content-Type")
+	if contentType == "" {
+		if len(w.buf) == 0 {
+			return false
+		}
+		contentType = http.DetectContentType(w.buf)
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	for _, pattern := range w.cfg.ContentTypes {
+		if ok, _ := path.Match(pattern, mediaType); ok {
+			return true
+		}
+	}
+	return false
+}
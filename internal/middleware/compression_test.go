@@ -0,0 +1,395 @@
+package middleware
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/andybalholm/brotli"
+	"github.com/gorilla/mux"
+	"github.com/klauspost/compress/zstd"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+var testCompressionConfig = CompressionConfig{
+	Enabled:             true,
+	MinSize:             64,
+	ContentTypes:        DefaultCompressionContentTypes,
+	MaxDecompressedSize: 1024,
+}
+
+func TestNegotiateEncoding(t *testing.T) {
+	tests := []struct {
+		accept string
+		want   string
+	}{
+		{accept: "", want: ""},
+		{accept: "gzip", want: "gzip"},
+		{accept: "gzip, deflate, br", want: "br"},
+		{accept: "gzip, br, zstd", want: "zstd"},
+		{accept: "br;q=0.5, gzip", want: "gzip"},
+		{accept: "*", want: "zstd"},
+		{accept: "*;q=0.5, zstd;q=0", want: "br"},
+		{accept: "identity", want: ""},
+		{accept: "gzip;q=0", want: ""},
+		{accept: "GZIP", want: "gzip"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.accept, func(t *testing.T) {
+			var accept []string
+			if tt.accept != "" {
+				accept = []string{tt.accept}
+			}
+
+			got := ""
+			if enc := negotiateEncoding(accept); enc != nil {
+				got = enc.name
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+// Helper function to decode a response body in a content coding
+func decodeResponse(t *testing.T, encoding string, body []byte) string {
+	t.Helper()
+	var r io.Reader
+	switch encoding {
+	case "gzip":
+		gr, err := gzip.NewReader(bytes.NewReader(body))
+		require.NoError(t, err)
+		r = gr
+	case "br":
+		r = brotli.NewReader(bytes.NewReader(body))
+	case "zstd":
+		zr, err := zstd.NewReader(bytes.NewReader(body))
+		require.NoError(t, err)
+		defer zr.Close()
+		r = zr
+	default:
+		r = bytes.NewReader(body)
+	}
+	data, err := io.ReadAll(r)
+	require.NoError(t, err)
+	return string(data)
+}
+
+func TestCompressionMiddleware_Responses(t *testing.T) {
+	large := `{"users":[` + strings.Repeat(`{"email":"test@example.com"},`, 20) + `{}]}`
+
+	tests := []struct {
+		name         string
+		accept       string
+		contentType  string
+		body         string
+		wantEncoding string
+	}{
+		{name: "gzip", accept: "gzip", contentType: "application/json", body: large, wantEncoding: "gzip"},
+		{name: "br", accept: "br", contentType: "application/json", body: large, wantEncoding: "br"},
+		{name: "zstd", accept: "zstd", contentType: "application/json", body: large, wantEncoding: "zstd"},
+		{name: "vendor type", accept: "gzip", contentType: "application/vnd.users.v2+json", body: large, wantEncoding: "gzip"},
+		{name: "below min size", accept: "gzip", contentType: "application/json", body: `{"id":1}`},
+		{name: "type not allowed", accept: "gzip", contentType: "image/png", body: large},
+		{name: "not accepted", contentType: "application/json", body: large},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := mux.NewRouter()
+			router.Use(CompressionMiddleware(testCompressionConfig, zap.NewNop()))
+			router.HandleFunc("/users", func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", tt.contentType)
+				w.Header().Set("ETag", `"abc"`)
+				w.WriteHeader(http.StatusOK)
+				_, _ = io.WriteString(w, tt.body)
+			})
+
+			req := httptest.NewRequest(http.MethodGet, "/users", nil)
+			if tt.accept != "" {
+				req.Header.Set("Accept-Encoding", tt.accept)
+			}
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+
+			require.Equal(t, http.StatusOK, rr.Code)
+			assert.Equal(t, tt.wantEncoding, rr.Header().Get("Content-Encoding"))
+			assert.Equal(t, tt.body, decodeResponse(t, tt.wantEncoding, rr.Body.Bytes()))
+			// The ETag is weak whenever a coding was negotiated, whether or not this response is compressed
+			if tt.accept != "" {
+				assert.Contains(t, rr.Header().Values("Vary"), "Accept-Encoding")
+				assert.Equal(t, `W/"abc"`, rr.Header().Get("ETag"))
+			} else {
+				assert.Equal(t, `"abc"`, rr.Header().Get("ETag"))
+			}
+			if tt.wantEncoding != "" {
+				assert.Less(t, rr.Body.Len(), len(tt.body))
+			}
+		})
+	}
+}
+
+func TestCompressionMiddleware_ReusedEncoders(t *testing.T) {
+	router := mux.NewRouter()
+	router.Use(CompressionMiddleware(testCompressionConfig, zap.NewNop()))
+	router.HandleFunc("/users/{id}", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		_, _ = io.WriteString(w, strings.Repeat(mux.Vars(r)["id"], 256))
+	})
+
+	// The encoders of earlier responses are reused, every response must still decode to its own body
+	for _, enc := range encodings {
+		for _, id := range []string{"1", "2", "3"} {
+			req := httptest.NewRequest(http.MethodGet, "/users/"+id, nil)
+			req.Header.Set("Accept-Encoding", enc.name)
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+
+			require.Equal(t, enc.name, rr.Header().Get("Content-Encoding"))
+			assert.Equal(t, strings.Repeat(id, 256), decodeResponse(t, enc.name, rr.Body.Bytes()))
+		}
+	}
+}
+
+func TestCompressionMiddleware_NotModified(t *testing.T) {
+	router := mux.NewRouter()
+	router.Use(CompressionMiddleware(testCompressionConfig, zap.NewNop()))
+	router.HandleFunc("/users/1", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", `"abc"`)
+		w.WriteHeader(http.StatusNotModified)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/users/1", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusNotModified, rr.Code)
+	assert.Empty(t, rr.Header().Get("Content-Encoding"))
+	assert.Zero(t, rr.Body.Len())
+	// The 304 carries the same ETag as the compressed 200 it stands for
+	assert.Equal(t, `W/"abc"`, rr.Header().Get("ETag"))
+	assert.Contains(t, rr.Header().Values("Vary"), "Accept-Encoding")
+}
+
+func TestCompressionMiddleware_Ranges(t *testing.T) {
+	content := strings.Repeat("id,email,name\n", 1000)
+	router := mux.NewRouter()
+	router.Use(CompressionMiddleware(testCompressionConfig, zap.NewNop()))
+	router.HandleFunc("/jobs/1/download", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", `"abc"`)
+		http.ServeContent(w, r, "users.csv", time.Time{}, strings.NewReader(content))
+	})
+
+	tests := []struct {
+		name          string
+		rangeHeader   string
+		expectedCode  int
+		expectedRange string
+		expectedBody  string
+	}{
+		{"partial", "bytes=0-4999", http.StatusPartialContent, fmt.Sprintf("bytes 0-4999/%d", len(content)), content[:5000]},
+		{"full with accept ranges", "", http.StatusOK, "", content},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/jobs/1/download", nil)
+			req.Header.Set("Accept-Encoding", "gzip")
+			if tt.rangeHeader != "" {
+				req.Header.Set("Range", tt.rangeHeader)
+			}
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+
+			assert.Equal(t, tt.expectedCode, rr.Code)
+			assert.Empty(t, rr.Header().Get("Content-Encoding"))
+			// The strong ETag is kept so that If-Range still matches
+			assert.Equal(t, `"abc"`, rr.Header().Get("ETag"))
+			assert.Equal(t, tt.expectedRange, rr.Header().Get("Content-Range"))
+			assert.Equal(t, tt.expectedBody, rr.Body.String())
+		})
+	}
+}
+
+func TestCompressionMiddleware_Streaming(t *testing.T) {
+	flushed := make(chan string, 1)
+	router := mux.NewRouter()
+	router.Use(CompressionMiddleware(testCompressionConfig, zap.NewNop()))
+	router.HandleFunc("/export", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		_, _ = io.WriteString(w, "{\"id\":1}\n")
+		w.(http.Flusher).Flush()
+		// What is flushed so far decodes on its own, before the stream ends
+		flushed <- decodeFlushed(t, w.(*compressWriter).ResponseWriter.(*httptest.ResponseRecorder).Body.Bytes())
+		_, _ = io.WriteString(w, "{\"id\":2}\n")
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/export", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	assert.Equal(t, "gzip", rr.Header().Get("Content-Encoding"))
+	assert.True(t, rr.Flushed)
+	assert.Equal(t, "{\"id\":1}\n", <-flushed)
+	assert.Equal(t, "{\"id\":1}\n{\"id\":2}\n", decodeResponse(t, "gzip", rr.Body.Bytes()))
+}
+
+// Helper function to decode a gzip stream that has been flushed but not ended
+func decodeFlushed(t *testing.T, body []byte) string {
+	t.Helper()
+	gr, err := gzip.NewReader(bytes.NewReader(body))
+	require.NoError(t, err)
+	data, err := io.ReadAll(gr)
+	require.ErrorIs(t, err, io.ErrUnexpectedEOF)
+	return string(data)
+}
+
+func TestCompressionMiddleware_Requests(t *testing.T) {
+	var gzipped bytes.Buffer
+	gw := gzip.NewWriter(&gzipped)
+	_, _ = io.WriteString(gw, `{"email":"test@example.com"}`)
+	require.NoError(t, gw.Close())
+
+	var bomb bytes.Buffer
+	gw = gzip.NewWriter(&bomb)
+	_, _ = gw.Write(make([]byte, 1<<20))
+	require.NoError(t, gw.Close())
+
+	tests := []struct {
+		name            string
+		contentEncoding string
+		body            []byte
+		wantCode        int
+		wantBody        string
+	}{
+		{name: "gzip", contentEncoding: "gzip", body: gzipped.Bytes(), wantCode: http.StatusOK, wantBody: `{"email":"test@example.com"}`},
+		{name: "identity", contentEncoding: "identity", body: []byte(`{}`), wantCode: http.StatusOK, wantBody: `{}`},
+		{name: "over the limit", contentEncoding: "gzip", body: bomb.Bytes(), wantCode: http.StatusRequestEntityTooLarge},
+		{name: "corrupt", contentEncoding: "gzip", body: []byte("not gzip"), wantCode: http.StatusBadRequest},
+		{name: "unsupported", contentEncoding: "compress", body: []byte("x"), wantCode: http.StatusUnsupportedMediaType},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := mux.NewRouter()
+			router.Use(CompressionMiddleware(testCompressionConfig, zap.NewNop()))
+			router.HandleFunc("/users", func(w http.ResponseWriter, r *http.Request) {
+				if tt.contentEncoding != "identity" {
+					assert.Empty(t, r.Header.Get("Content-Encoding"))
+				}
+				data, err := io.ReadAll(r.Body)
+				var maxBytesErr *http.MaxBytesError
+				if errors.As(err, &maxBytesErr) {
+					w.WriteHeader(http.StatusRequestEntityTooLarge)
+					return
+				}
+				require.NoError(t, err)
+				_, _ = w.Write(data)
+			})
+
+			req := httptest.NewRequest(http.MethodPost, "/users", bytes.NewReader(tt.body))
+			req.Header.Set("Content-Encoding", tt.contentEncoding)
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+
+			assert.Equal(t, tt.wantCode, rr.Code, rr.Body.String())
+			if tt.wantCode == http.StatusOK {
+				assert.Equal(t, tt.wantBody, rr.Body.String())
+			}
+			if tt.wantCode == http.StatusUnsupportedMediaType {
+				assert.Equal(t, "zstd, br, gzip", rr.Header().Get("Accept-Encoding"))
+			}
+		})
+	}
+}
+
+func TestCompressionMiddleware_ZstdWindowLimit(t *testing.T) {
+	cfg := testCompressionConfig
+	cfg.MaxDecompressedSize = 32 << 20
+
+	// A frame of a single raw byte whose header declares a 512 MiB window
+	frame := []byte{
+		0x28, 0xb5, 0x2f, 0xfd, // magic number
+		0x00,             // frame header descriptor, no content size or checksum
+		19 << 3,          // window descriptor, 1 << (10+19) bytes
+		0x09, 0x00, 0x00, // last raw block of one byte
+		'x',
+	}
+
+	router := mux.NewRouter()
+	router.Use(CompressionMiddleware(cfg, zap.NewNop()))
+	router.HandleFunc("/users", func(w http.ResponseWriter, r *http.Request) {
+		_, err := io.ReadAll(r.Body)
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			w.WriteHeader(http.StatusRequestEntityTooLarge)
+			return
+		}
+		require.NoError(t, err)
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "/users", bytes.NewReader(frame))
+	req.Header.Set("Content-Encoding", "zstd")
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusRequestEntityTooLarge, rr.Code)
+
+	// The same frame declaring a window within the limit decodes
+	frame[5] = 3 << 3
+	req = httptest.NewRequest(http.MethodPost, "/users", bytes.NewReader(frame))
+	req.Header.Set("Content-Encoding", "zstd")
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+}
+
+func TestLimitedReader_ExactLimit(t *testing.T) {
+	r := &limitedReader{r: strings.NewReader("abcd"), remaining: 4, limit: 4}
+	data, err := io.ReadAll(r)
+	require.NoError(t, err)
+	assert.Equal(t, "abcd", string(data))
+
+	r = &limitedReader{r: strings.NewReader("abcde"), remaining: 4, limit: 4}
+	data, err = io.ReadAll(r)
+	var maxBytesErr *http.MaxBytesError
+	require.ErrorAs(t, err, &maxBytesErr)
+	assert.Equal(t, int64(4), maxBytesErr.Limit)
+	assert.Equal(t, "abcd", string(data))
+}
+
+func TestLoggingMiddleware_CountsCompressedBytes(t *testing.T) {
+	core, logs := observer.New(zap.InfoLevel)
+	body := strings.Repeat("a", 4096)
+
+	router := mux.NewRouter()
+	router.Use(LoggingMiddleware(zap.New(core)))
+	router.Use(CompressionMiddleware(testCompressionConfig, zap.NewNop()))
+	router.HandleFunc("/users", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		_, _ = io.WriteString(w, body)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/users", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	require.Equal(t, 1, logs.Len())
+	bytesSent := logs.All()[0].ContextMap()["bytes"]
+	assert.Equal(t, int64(rr.Body.Len()), bytesSent)
+	assert.Less(t, rr.Body.Len(), len(body))
+}
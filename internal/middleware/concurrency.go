@@ -0,0 +1,196 @@
+package middleware
+
+import (
+	"net/http"
+	"path"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"go.uber.org/zap"
+)
+
+var (
+	concurrencyLimit = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "http_concurrency_limit",
+		Help: "Current adaptive limit of concurrent HTTP requests",
+	})
+	concurrencyInflight = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "http_concurrency_inflight",
+		Help: "Number of HTTP requests currently admitted by the concurrency limiter",
+	})
+	concurrencyShedTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "http_concurrency_shed_total",
+		Help: "Number of HTTP requests rejected by the concurrency limiter",
+	})
+)
+
+// ConcurrencyConfig holds the configuration of the adaptive concurrency limiter
+type ConcurrencyConfig struct {
+	Enabled          bool
+	InitialLimit     int
+	MinLimit         int
+	MaxLimit         int
+	LatencyThreshold time.Duration // requests slower than this are treated as congestion
+	BackoffRatio     float64       // multiplier applied to the limit on congestion
+	// LongRunningRoutes are route templates, patterns such as /api/users:batch* are allowed, of requests
+	// that take long by design. They hold a slot but their latency does not move the limit.
+	// Templates are matched without their version segment, /api/users:batch* covers /api/v2/users:batch* too
+	LongRunningRoutes []string
+	// ExemptRoutes are route templates of requests that are not limited at all, such as event streams
+	// which stay open for long and are capped on their own
+	ExemptRoutes []string
+}
+
+// DefaultLongRunningRoutes are the routes of exports, imports, batches and downloads of job files
+var DefaultLongRunningRoutes = []string{
+	"/api/users/export",
+	"/api/users/import",
+	"/api/users:batch*",
+	"/api/jobs/*/download",
+}
+
+// DefaultExemptRoutes is the event stream, whose connections are capped by the broadcaster
+var DefaultExemptRoutes = []string{
+	"/api/users/events",
+}
+
+// AdaptiveLimiter limits the number of concurrent requests using AIMD
+// The limit grows by one per window of successful requests and is cut multiplicatively
+// as soon as latency exceeds the threshold or requests fail, so that excess work is rejected
+// early instead of piling up on a slow database. The limit is cut once per round trip: requests
+// admitted before the last cut saw the old limit, so their outcome does not cut it again
+type AdaptiveLimiter struct {
+	cfg      ConcurrencyConfig
+	now      func() time.Time
+	mu       sync.Mutex
+	limit    float64
+	inflight int
+	lastCut  time.Time
+}
+
+// NewAdaptiveLimiter creates a new AdaptiveLimiter
+func NewAdaptiveLimiter(cfg ConcurrencyConfig) *AdaptiveLimiter {
+	l := &AdaptiveLimiter{
+		cfg:   cfg,
+		now:   time.Now,
+		limit: float64(cfg.InitialLimit),
+	}
+	concurrencyLimit.Set(l.limit)
+	return l
+}
+
+// Acquire admits a request if the current limit allows it
+// Every successful Acquire must be followed by a Release
+func (l *AdaptiveLimiter) Acquire() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.inflight >= int(l.limit) {
+		return false
+	}
+	l.inflight++
+	concurrencyInflight.Set(float64(l.inflight))
+	return true
+}
+
+// Release returns a slot to the limiter and adjusts the limit based on the outcome
+func (l *AdaptiveLimiter) Release(latency time.Duration, failed bool) {
+	l.release(latency, failed, true)
+}
+
+// ReleaseLongRunning returns the slot of a request that takes long by design
+// Only its failure adjusts the limit, its latency says nothing about congestion
+func (l *AdaptiveLimiter) ReleaseLongRunning(latency time.Duration, failed bool) {
+	l.release(latency, failed, false)
+}
+
+func (l *AdaptiveLimiter) release(latency time.Duration, failed, timed bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	// Only grow while the limit is actually being used, otherwise it would drift to the maximum
+	saturated := l.inflight*2 >= int(l.limit)
+	l.inflight--
+	concurrencyInflight.Set(float64(l.inflight))
+
+	now := l.now()
+	switch {
+	case failed || (timed && latency > l.cfg.LatencyThreshold):
+		if now.Add(-latency).After(l.lastCut) {
+			l.limit = max(float64(l.cfg.MinLimit), l.limit*l.cfg.BackoffRatio)
+			l.lastCut = now
+		}
+	case timed && saturated:
+		l.limit = min(float64(l.cfg.MaxLimit), l.limit+1/l.limit)
+	}
+	concurrencyLimit.Set(l.limit)
+}
+
+// Helper function to tell whether a route template is one of the long running routes
+func (l *AdaptiveLimiter) longRunning(route string) bool {
+	return matchRoute(l.cfg.LongRunningRoutes, unversionedRoute(route))
+}
+
+// Helper function to tell whether a route template is exempt from the limit
+func (l *AdaptiveLimiter) exempt(route string) bool {
+	return matchRoute(l.cfg.ExemptRoutes, unversionedRoute(route))
+}
+
+// Helper function to tell whether a route template matches one of the patterns
+func matchRoute(patterns []string, route string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, route); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Limit returns the current limit
+func (l *AdaptiveLimiter) Limit() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return int(l.limit)
+}
+
+// ConcurrencyLimitMiddleware creates a middleware that sheds requests above the adaptive limit with 503
+// It should only wrap the API routes so that health and metrics endpoints are always served
+func ConcurrencyLimitMiddleware(limiter *AdaptiveLimiter, logger *zap.Logger) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// Exemptions go by the matched route, a header would let any client skip the limit
+			route := routeTemplate(r)
+			if limiter.exempt(route) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if !limiter.Acquire() {
+				concurrencyShedTotal.Inc()
+				logger.Debug("request shed by concurrency limiter", zap.String("route", route))
+				w.Header().Set("Retry-After", "1")
+				writeProblem(w, http.StatusServiceUnavailable, "Server is overloaded", RequestIDFromContext(r.Context()))
+				return
+			}
+
+			start := time.Now()
+			release := limiter.Release
+			if limiter.longRunning(route) {
+				release = limiter.ReleaseLongRunning
+			}
+			ww := &responseWriterWrapper{
+				ResponseWriter: w,
+				statusCode:     http.StatusOK,
+			}
+			// @MENTION_ME: release in defer so that a panic does not leak a slot
+			defer func() {
+				release(time.Since(start), ww.statusCode >= http.StatusInternalServerError)
+			}()
+
+			next.ServeHTTP(ww, r)
+		})
+	}
+}
@@ -0,0 +1,128 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func testConcurrencyConfig() ConcurrencyConfig {
+	return ConcurrencyConfig{
+		InitialLimit:     2,
+		MinLimit:         1,
+		MaxLimit:         4,
+		LatencyThreshold: 100 * time.Millisecond,
+		BackoffRatio:     0.5,
+	}
+}
+
+func TestAdaptiveLimiter(t *testing.T) {
+	limiter := NewAdaptiveLimiter(testConcurrencyConfig())
+	now := time.Date(2023, 1, 1, 12, 0, 0, 0, time.UTC)
+	limiter.now = func() time.Time { return now }
+
+	assert.True(t, limiter.Acquire())
+	assert.True(t, limiter.Acquire())
+	assert.False(t, limiter.Acquire(), "limit should be reached")
+
+	// Fast successful requests grow the limit additively
+	limiter.Release(time.Millisecond, false)
+	limiter.Release(time.Millisecond, false)
+	assert.Equal(t, 2, limiter.Limit())
+	for i := 0; i < 10; i++ {
+		limiter.Acquire()
+		limiter.Acquire()
+		limiter.Release(time.Millisecond, false)
+		limiter.Release(time.Millisecond, false)
+	}
+	assert.Equal(t, 4, limiter.Limit(), "limit should be capped at the maximum")
+
+	// Slow or failed requests cut it multiplicatively, once per round trip
+	limiter.Acquire()
+	limiter.Acquire()
+	now = now.Add(time.Second)
+	limiter.Release(time.Second, false)
+	assert.Equal(t, 2, limiter.Limit())
+	limiter.Release(time.Second, false)
+	assert.Equal(t, 2, limiter.Limit(), "requests admitted before the cut should not cut again")
+	limiter.Acquire()
+	now = now.Add(10 * time.Millisecond)
+	limiter.Release(time.Millisecond, true)
+	assert.Equal(t, 1, limiter.Limit())
+	limiter.Acquire()
+	now = now.Add(10 * time.Millisecond)
+	limiter.Release(time.Millisecond, true)
+	assert.Equal(t, 1, limiter.Limit(), "limit should not drop below the minimum")
+}
+
+func TestAdaptiveLimiter_LongRunning(t *testing.T) {
+	cfg := testConcurrencyConfig()
+	cfg.LongRunningRoutes = DefaultLongRunningRoutes
+	limiter := NewAdaptiveLimiter(cfg)
+
+	assert.True(t, limiter.longRunning("/api/users/export"))
+	assert.True(t, limiter.longRunning("/api/v2/users:batchCreate"))
+	assert.True(t, limiter.longRunning("/api/jobs/{id:[0-9]+}/download"))
+	assert.False(t, limiter.longRunning("/api/users/{id:[0-9]+}"))
+
+	// Slow long running requests do not cut the limit
+	limiter.Acquire()
+	limiter.ReleaseLongRunning(time.Minute, false)
+	assert.Equal(t, 2, limiter.Limit())
+}
+
+func TestConcurrencyLimitMiddleware(t *testing.T) {
+	cfg := testConcurrencyConfig()
+	cfg.InitialLimit = 1
+	limiter := NewAdaptiveLimiter(cfg)
+
+	// Occupy the only slot
+	assert.True(t, limiter.Acquire())
+
+	handler := ConcurrencyLimitMiddleware(limiter, zap.NewNop())(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/users", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, rr.Code)
+	assert.Equal(t, "1", rr.Header().Get("Retry-After"))
+
+	limiter.Release(time.Millisecond, false)
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/users", nil))
+	assert.Equal(t, http.StatusOK, rr.Code)
+}
+
+func TestConcurrencyLimitMiddleware_ExemptRoutes(t *testing.T) {
+	cfg := testConcurrencyConfig()
+	cfg.InitialLimit = 1
+	cfg.ExemptRoutes = DefaultExemptRoutes
+	limiter := NewAdaptiveLimiter(cfg)
+
+	// Occupy the only slot
+	assert.True(t, limiter.Acquire())
+
+	router := mux.NewRouter()
+	apiRouter := router.PathPrefix("/api").Subrouter()
+	apiRouter.Use(ConcurrencyLimitMiddleware(limiter, zap.NewNop()))
+	ok := func(w http.ResponseWriter, _ *http.Request) { w.WriteHeader(http.StatusOK) }
+	apiRouter.HandleFunc("/users/events", ok)
+	apiRouter.HandleFunc("/users", ok)
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/users/events", nil))
+	assert.Equal(t, http.StatusOK, rr.Code)
+
+	// Asking for an event stream on another route does not skip the limit
+	req := httptest.NewRequest(http.MethodGet, "/api/users", nil)
+	req.Header.Set("Accept", "text/event-stream")
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusServiceUnavailable, rr.Code)
+}
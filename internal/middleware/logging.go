@@ -9,7 +9,7 @@ import (
 )
 
 // LoggingMiddleware creates a middleware that logs each HTTP request
-// It captures the method, path, status code, and response time
+// It captures the method, path, status code, bytes sent, and response time
 func LoggingMiddleware(logger *zap.Logger) mux.MiddlewareFunc {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -27,10 +27,12 @@ func LoggingMiddleware(logger *zap.Logger) mux.MiddlewareFunc {
 			// Log the request
 			duration := time.Since(start)
 			logger.Info("HTTP request",
+				zap.String("request_id", RequestIDFromContext(r.Context())),
 				zap.String("method", r.Method),
 				zap.String("path", r.URL.Path),
 				zap.String("remote_addr", r.RemoteAddr),
 				zap.Int("status", ww.statusCode),
+				zap.Int64("bytes", ww.bytes),
 				zap.Duration("duration", duration),
 				zap.String("user_agent", r.UserAgent()),
 			)
@@ -38,10 +40,12 @@ func LoggingMiddleware(logger *zap.Logger) mux.MiddlewareFunc {
 	}
 }
 
-// responseWriterWrapper is a wrapper around http.ResponseWriter that captures the status code
+// responseWriterWrapper is a wrapper around http.ResponseWriter that captures the status code and
+// counts the bytes of the body as sent, after any compression
 type responseWriterWrapper struct {
 	http.ResponseWriter
 	statusCode int
+	bytes      int64
 }
 
 // WriteHeader captures the status code before passing it to the wrapped ResponseWriter
@@ -49,3 +53,22 @@ func (w *responseWriterWrapper) WriteHeader(statusCode int) {
 	w.statusCode = statusCode
 	w.ResponseWriter.WriteHeader(statusCode)
 }
+
+// Write counts the bytes written before passing them to the wrapped ResponseWriter
+func (w *responseWriterWrapper) Write(p []byte) (int, error) {
+	n, err := w.ResponseWriter.Write(p)
+	w.bytes += int64(n)
+	return n, err
+}
+
+// Flush sends buffered data to the client, streaming responses such as SSE rely on it
+func (w *responseWriterWrapper) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap returns the wrapped ResponseWriter, so that http.ResponseController can reach it
+func (w *responseWriterWrapper) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
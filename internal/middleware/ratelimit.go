@@ -0,0 +1,149 @@
+package middleware
+
+import (
+	"context"
+	"math"
+	"net"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/huberts90/restful-api/internal/ratelimit"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"go.uber.org/zap"
+)
+
+var rateLimitedTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "http_rate_limited_total",
+	Help: "Number of HTTP requests rejected by the rate limiter",
+}, []string{"route"})
+
+// RateLimitMiddleware creates a middleware enforcing per-client token bucket limits
+// Clients are identified by their verified identity if authentication set one, or else by their IP address
+// as resolved by ClientIPMiddleware. Every version of
+// a route shares its rule and bucket, so that switching versions does not multiply the quota
+func RateLimitMiddleware(store ratelimit.Store, cfg ratelimit.Config, logger *zap.Logger) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			route := unversionedRoute(routeTemplate(r))
+			name, limit := cfg.LimitFor(r.Method, route)
+
+			res, err := store.Take(r.Context(), name+"|"+clientKey(r), limit, time.Now())
+			if err != nil {
+				// @MENTION_ME: fail open - an unavailable bucket store must not take the API down
+				logger.Warn("rate limiter unavailable", zap.Error(err), zap.String("route", route))
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			w.Header().Set("RateLimit-Limit", strconv.Itoa(limit.Burst))
+			w.Header().Set("RateLimit-Remaining", strconv.Itoa(res.Remaining))
+			w.Header().Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(res.Reset)))
+
+			if !res.Allowed {
+				rateLimitedTotal.WithLabelValues(route).Inc()
+				w.Header().Set("Retry-After", strconv.Itoa(ceilSeconds(res.RetryAfter)))
+				writeProblem(w, http.StatusTooManyRequests, "Rate limit exceeded", RequestIDFromContext(r.Context()))
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+type clientIPKey struct{}
+
+// ClientIPMiddleware creates a middleware that resolves the IP address of the client behind a request
+// Requests from one of the trusted proxies are attributed to the address the proxies forwarded them for,
+// the rightmost untrusted address of X-Forwarded-For. Other requests are attributed to their peer, since
+// anyone could send the header
+func ClientIPMiddleware(trustedProxies []netip.Prefix) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ip := clientIP(r, trustedProxies)
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), clientIPKey{}, ip)))
+		})
+	}
+}
+
+// Helper function to resolve the IP address of the client behind a request
+func clientIP(r *http.Request, trustedProxies []netip.Prefix) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	if !trusted(host, trustedProxies) {
+		return host
+	}
+
+	// Each proxy appends the address it got the request from, so the addresses are read from the right
+	// until one that is not a proxy of ours, anything left of it may have been made up by the client
+	forwarded := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+	for i := len(forwarded) - 1; i >= 0; i-- {
+		addr := strings.TrimSpace(forwarded[i])
+		if _, err := netip.ParseAddr(addr); err != nil {
+			break
+		}
+		host = addr
+		if !trusted(addr, trustedProxies) {
+			break
+		}
+	}
+	return host
+}
+
+// Helper function to tell whether an address belongs to one of the trusted proxies
+func trusted(host string, trustedProxies []netip.Prefix) bool {
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+	for _, prefix := range trustedProxies {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+type clientIDKey struct{}
+
+// WithClientID returns a copy of the context carrying the verified identity of the client
+// Only an authentication layer that has checked the credentials should set it
+func WithClientID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, clientIDKey{}, id)
+}
+
+// ClientIDFromContext returns the verified identity of the client stored in the context, if any
+func ClientIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(clientIDKey{}).(string)
+	return id
+}
+
+// clientKey identifies the client a request belongs to, by its verified identity or else its IP address
+// Credentials such as an API key header or a JWT subject are not read here, since a client could change
+// them on every request to get a fresh bucket
+func clientKey(r *http.Request) string {
+	if id := ClientIDFromContext(r.Context()); id != "" {
+		return "client:" + id
+	}
+	if ip, ok := r.Context().Value(clientIPKey{}).(string); ok {
+		return "ip:" + ip
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return "ip:" + host
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
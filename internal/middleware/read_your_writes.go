@@ -0,0 +1,18 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/huberts90/restful-api/internal/storage"
+)
+
+// ReadYourWritesMiddleware creates a middleware that identifies the client to the storage layer
+// The store uses it to serve reads from the primary shortly after the same client wrote
+func ReadYourWritesMiddleware() mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(w, r.WithContext(storage.WithClient(r.Context(), clientKey(r))))
+		})
+	}
+}
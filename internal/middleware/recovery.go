@@ -0,0 +1,233 @@
+package middleware
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/huberts90/restful-api/internal/domain"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"go.uber.org/zap"
+)
+
+var panicsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "http_panics_total",
+	Help: "Number of panics recovered from HTTP handlers",
+}, []string{"route"})
+
+// RecoveryMiddleware creates a middleware that turns handler panics into 500 responses
+// The panic is logged with its stack trace and, if a reporter is given, written to a crash report.
+// A response the handler had already started cannot become a 500, so it is aborted instead
+func RecoveryMiddleware(logger *zap.Logger, reporter *CrashReporter) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			sw := &startedWriter{ResponseWriter: w}
+			defer func() {
+				p := recover()
+				if p == nil {
+					return
+				}
+				// @MENTION_ME: http.ErrAbortHandler is the documented way to abort a response silently
+				if err, ok := p.(error); ok && errors.Is(err, http.ErrAbortHandler) {
+					panic(p)
+				}
+
+				stack := debug.Stack()
+				route := routeTemplate(r)
+				requestID := RequestIDFromContext(r.Context())
+				panicsTotal.WithLabelValues(route).Inc()
+
+				fields := []zap.Field{
+					zap.Any("panic", p),
+					zap.String("request_id", requestID),
+					zap.String("method", r.Method),
+					zap.String("route", route),
+					zap.ByteString("stack", stack),
+				}
+				if reporter != nil {
+					path, err := reporter.Report(r, p, stack)
+					if err != nil {
+						logger.Warn("failed to write crash report", zap.Error(err))
+					} else if path != "" {
+						fields = append(fields, zap.String("crash_report", path))
+					}
+				}
+				logger.Error("Recovered from panic", fields...)
+
+				if sw.started {
+					panic(http.ErrAbortHandler)
+				}
+				writeProblem(w, http.StatusInternalServerError, "Internal server error", requestID)
+			}()
+
+			next.ServeHTTP(sw, r)
+		})
+	}
+}
+
+// startedWriter tells whether the status code or part of the body of a response has been written
+type startedWriter struct {
+	http.ResponseWriter
+	started bool
+}
+
+// WriteHeader marks the response as started, informational responses do not start it
+func (w *startedWriter) WriteHeader(statusCode int) {
+	if statusCode >= http.StatusOK {
+		w.started = true
+	}
+	w.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (w *startedWriter) Write(p []byte) (int, error) {
+	w.started = true
+	return w.ResponseWriter.Write(p)
+}
+
+// Flush sends buffered data to the client, which starts the response
+func (w *startedWriter) Flush() {
+	w.started = true
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap returns the wrapped ResponseWriter, so that http.ResponseController can reach it
+func (w *startedWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// CrashReporter writes panic details to files for post-mortems
+// The number of reports is capped per hour so that a panic loop cannot fill the disk
+type CrashReporter struct {
+	dir         string
+	maxPerHour  int
+	now         func() time.Time
+	mu          sync.Mutex
+	windowStart time.Time
+	written     int
+}
+
+// crashReport is the on-disk format of a crash report
+type crashReport struct {
+	Time      time.Time   `json:"time"`
+	RequestID string      `json:"request_id"`
+	Method    string      `json:"method"`
+	Path      string      `json:"path"`
+	Route     string      `json:"route"`
+	Panic     string      `json:"panic"`
+	Stack     string      `json:"stack"`
+	Headers   http.Header `json:"headers"`
+}
+
+// NewCrashReporter creates a new CrashReporter writing at most maxPerHour reports into dir
+func NewCrashReporter(dir string, maxPerHour int) (*CrashReporter, error) {
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, fmt.Errorf("failed to create crash report directory: %w", err)
+	}
+
+	return &CrashReporter{
+		dir:        dir,
+		maxPerHour: maxPerHour,
+		now:        time.Now,
+	}, nil
+}
+
+// Report writes a crash report and returns its path
+// An empty path means the hourly cap has been reached and nothing was written
+func (c *CrashReporter) Report(r *http.Request, p interface{}, stack []byte) (string, error) {
+	now := c.now()
+	if !c.allow(now) {
+		return "", nil
+	}
+
+	// Do not leak credentials into files that may be shared around
+	headers := r.Header.Clone()
+	headers.Del("Authorization")
+	headers.Del("Cookie")
+
+	requestID := RequestIDFromContext(r.Context())
+	report := crashReport{
+		Time:      now.UTC(),
+		RequestID: requestID,
+		Method:    r.Method,
+		Path:      r.URL.Path,
+		Route:     routeTemplate(r),
+		Panic:     fmt.Sprint(p),
+		Stack:     string(stack),
+		Headers:   headers,
+	}
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal crash report: %w", err)
+	}
+
+	name := fmt.Sprintf("crash-%s.json", now.UTC().Format("20060102T150405.000000000"))
+	path := filepath.Join(c.dir, name)
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		return "", fmt.Errorf("failed to write crash report: %w", err)
+	}
+
+	return path, nil
+}
+
+// allow reports whether another crash report fits into the current hourly window
+func (c *CrashReporter) allow(now time.Time) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if now.Sub(c.windowStart) >= time.Hour {
+		c.windowStart = now
+		c.written = 0
+	}
+	if c.written >= c.maxPerHour {
+		return false
+	}
+	c.written++
+	return true
+}
+
+// routeTemplate returns the matched mux route template
+// Using the template instead of the raw path keeps the cardinality of metric labels bounded
+func routeTemplate(r *http.Request) string {
+	if route := mux.CurrentRoute(r); route != nil {
+		if tpl, err := route.GetPathTemplate(); err == nil {
+			return tpl
+		}
+	}
+	return "unmatched"
+}
+
+// unversionedRoute returns a route template without its API version segment, such as /api/v2/users for
+// /api/users, so that every version of a route shares its limits and buckets
+func unversionedRoute(route string) string {
+	segments := strings.Split(route, "/")
+	for i, segment := range segments {
+		if len(segment) > 1 && segment[0] == 'v' && strings.Trim(segment[1:], "0123456789") == "" {
+			return strings.Join(append(segments[:i:i], segments[i+1:]...), "/")
+		}
+	}
+	return route
+}
+
+// writeProblem writes an RFC 7807 problem response
+func writeProblem(w http.ResponseWriter, status int, detail, instance string) {
+	w.Header().Set("Content-Type", domain.ProblemContentType)
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(domain.Problem{
+		Type:     "about:blank",
+		Title:    http.StatusText(status),
+		Status:   status,
+		Detail:   detail,
+		Instance: instance,
+	})
+}
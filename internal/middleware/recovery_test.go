@@ -0,0 +1,87 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/huberts90/restful-api/internal/domain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestRecoveryMiddleware(t *testing.T) {
+	reporter, err := NewCrashReporter(t.TempDir(), 1)
+	require.NoError(t, err)
+
+	router := mux.NewRouter()
+	router.Use(RequestIDMiddleware())
+	router.Use(RecoveryMiddleware(zap.NewNop(), reporter))
+	router.HandleFunc("/boom", func(http.ResponseWriter, *http.Request) {
+		panic("boom")
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/boom", nil)
+	req.Header.Set(RequestIDHeader, "req-1")
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusInternalServerError, rr.Code)
+	assert.Equal(t, domain.ProblemContentType, rr.Header().Get("Content-Type"))
+
+	var problem domain.Problem
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &problem))
+	assert.Equal(t, http.StatusInternalServerError, problem.Status)
+	assert.Equal(t, "req-1", problem.Instance)
+
+	entries, err := os.ReadDir(reporter.dir)
+	require.NoError(t, err)
+	assert.Len(t, entries, 1)
+}
+
+func TestRecoveryMiddleware_StartedResponse(t *testing.T) {
+	router := mux.NewRouter()
+	router.Use(RecoveryMiddleware(zap.NewNop(), nil))
+	router.HandleFunc("/boom", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte(`{"id":`))
+		panic("boom")
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/boom", nil)
+	rr := httptest.NewRecorder()
+	assert.PanicsWithValue(t, http.ErrAbortHandler, func() { router.ServeHTTP(rr, req) })
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, `{"id":`, rr.Body.String())
+}
+
+func TestCrashReporter_HourlyCap(t *testing.T) {
+	reporter, err := NewCrashReporter(t.TempDir(), 2)
+	require.NoError(t, err)
+
+	now := time.Date(2023, 1, 1, 12, 0, 0, 0, time.UTC)
+	reporter.now = func() time.Time { return now }
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+
+	for i := 0; i < 3; i++ {
+		now = now.Add(time.Second)
+		path, err := reporter.Report(req, "boom", nil)
+		require.NoError(t, err)
+		if i < 2 {
+			assert.NotEmpty(t, path)
+		} else {
+			assert.Empty(t, path, "cap should have been reached")
+		}
+	}
+
+	// A new hour opens a new window
+	now = now.Add(time.Hour)
+	path, err := reporter.Report(req, "boom", nil)
+	require.NoError(t, err)
+	assert.NotEmpty(t, path)
+}
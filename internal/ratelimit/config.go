@@ -0,0 +1,78 @@
+package ratelimit
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Rule applies a limit to requests matching a method and a route template
+// "*" matches any method or any route
+type Rule struct {
+	Method string
+	Route  string
+	Limit  Limit
+}
+
+// Matches reports whether the rule applies to the given method and route template
+func (r Rule) Matches(method, route string) bool {
+	return (r.Method == "*" || r.Method == method) && (r.Route == "*" || r.Route == route)
+}
+
+// Config holds the rate limiting configuration
+type Config struct {
+	Enabled bool
+	Backend string // "memory" or "postgres"
+	Default Limit
+	Rules   []Rule
+}
+
+// RefillTime returns the longest time a bucket of any of the limits takes to refill from empty
+func (c Config) RefillTime() time.Duration {
+	longest := c.Default.refillTime()
+	for _, rule := range c.Rules {
+		if d := rule.Limit.refillTime(); d > longest {
+			longest = d
+		}
+	}
+	return longest
+}
+
+// ParseRules parses rules in the "METHOD ROUTE=rate:burst" form separated by commas
+// e.g. "GET /api/users=2:10,POST /api/users=1:5"
+func ParseRules(s string) ([]Rule, error) {
+	var rules []Rule
+	for _, item := range strings.Split(s, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+
+		target, limitStr, ok := strings.Cut(item, "=")
+		if !ok {
+			return nil, fmt.Errorf("%w: rule %q", ErrInvalidLimit, item)
+		}
+		fields := strings.Fields(target)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("%w: rule %q", ErrInvalidLimit, item)
+		}
+		limit, err := ParseLimit(limitStr)
+		if err != nil {
+			return nil, err
+		}
+
+		rules = append(rules, Rule{Method: strings.ToUpper(fields[0]), Route: fields[1], Limit: limit})
+	}
+	return rules, nil
+}
+
+// LimitFor returns the limit applying to the given method and route template along with a name for it
+// Rules are checked in order and the first match wins, the default limit applies otherwise
+func (c Config) LimitFor(method, route string) (string, Limit) {
+	for _, rule := range c.Rules {
+		if rule.Matches(method, route) {
+			return rule.Method + " " + rule.Route, rule.Limit
+		}
+	}
+	return "default", c.Default
+}
@@ -0,0 +1,143 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseRules(t *testing.T) {
+	rules, err := ParseRules("GET /api/users=2:10, post /api/users=0.5:5")
+	require.NoError(t, err)
+	assert.Equal(t, []Rule{
+		{Method: "GET", Route: "/api/users", Limit: Limit{Rate: 2, Burst: 10}},
+		{Method: "POST", Route: "/api/users", Limit: Limit{Rate: 0.5, Burst: 5}},
+	}, rules)
+
+	_, err = ParseRules("GET /api/users=2")
+	assert.ErrorIs(t, err, ErrInvalidLimit)
+	_, err = ParseRules("/api/users=2:10")
+	assert.ErrorIs(t, err, ErrInvalidLimit)
+}
+
+func TestConfig_LimitFor(t *testing.T) {
+	cfg := Config{
+		Default: Limit{Rate: 10, Burst: 20},
+		Rules: []Rule{
+			{Method: "GET", Route: "/api/users", Limit: Limit{Rate: 1, Burst: 5}},
+			{Method: "*", Route: "/api/users", Limit: Limit{Rate: 2, Burst: 2}},
+		},
+	}
+
+	name, limit := cfg.LimitFor("GET", "/api/users")
+	assert.Equal(t, "GET /api/users", name)
+	assert.Equal(t, Limit{Rate: 1, Burst: 5}, limit)
+
+	name, limit = cfg.LimitFor("POST", "/api/users")
+	assert.Equal(t, "* /api/users", name)
+	assert.Equal(t, Limit{Rate: 2, Burst: 2}, limit)
+
+	name, limit = cfg.LimitFor("GET", "/api/users/{id:[0-9]+}")
+	assert.Equal(t, "default", name)
+	assert.Equal(t, cfg.Default, limit)
+}
+
+func TestMemoryStore_Take(t *testing.T) {
+	store := NewMemoryStore()
+	limit := Limit{Rate: 1, Burst: 2}
+	now := time.Date(2023, 1, 1, 12, 0, 0, 0, time.UTC)
+	ctx := context.Background()
+
+	res, err := store.Take(ctx, "client", limit, now)
+	require.NoError(t, err)
+	assert.True(t, res.Allowed)
+	assert.Equal(t, 1, res.Remaining)
+
+	res, _ = store.Take(ctx, "client", limit, now)
+	assert.True(t, res.Allowed)
+	assert.Equal(t, 0, res.Remaining)
+
+	res, _ = store.Take(ctx, "client", limit, now)
+	assert.False(t, res.Allowed)
+	assert.Equal(t, time.Second, res.RetryAfter)
+	assert.Equal(t, 2*time.Second, res.Reset)
+
+	// Other clients have their own bucket
+	res, _ = store.Take(ctx, "other", limit, now)
+	assert.True(t, res.Allowed)
+
+	// Tokens are refilled over time
+	res, _ = store.Take(ctx, "client", limit, now.Add(time.Second))
+	assert.True(t, res.Allowed)
+
+	// Full buckets are swept
+	_, _ = store.Take(ctx, "client", limit, now.Add(time.Hour))
+	assert.Len(t, store.buckets, 1)
+}
+
+func TestPostgresStore_Take(t *testing.T) {
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	require.NoError(t, err)
+	defer db.Close()
+
+	store := NewPostgresStore(db)
+	limit := Limit{Rate: 1, Burst: 5}
+	now := time.Date(2023, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	t.Run("new bucket", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectExec(sqlInsertBucket).WithArgs("client", float64(5), now).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectQuery(sqlSelectBucket).WithArgs("client").
+			WillReturnRows(sqlmock.NewRows([]string{"tokens", "updated_at"}).AddRow(float64(5), now))
+		mock.ExpectExec(sqlUpdateBucket).WithArgs("client", float64(4), now).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		res, err := store.Take(context.Background(), "client", limit, now)
+		require.NoError(t, err)
+		assert.True(t, res.Allowed)
+		assert.Equal(t, 4, res.Remaining)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("empty bucket", func(t *testing.T) {
+		mock.ExpectBegin()
+		// The bucket exists, so nothing is inserted
+		mock.ExpectExec(sqlInsertBucket).WithArgs("client", float64(5), now).WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectQuery(sqlSelectBucket).WithArgs("client").
+			WillReturnRows(sqlmock.NewRows([]string{"tokens", "updated_at"}).AddRow(0.5, now))
+		mock.ExpectExec(sqlUpdateBucket).WithArgs("client", 0.5, now).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		res, err := store.Take(context.Background(), "client", limit, now)
+		require.NoError(t, err)
+		assert.False(t, res.Allowed)
+		assert.Equal(t, 500*time.Millisecond, res.RetryAfter)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestConfig_RefillTime(t *testing.T) {
+	cfg := Config{
+		Default: Limit{Rate: 10, Burst: 20},
+		Rules:   []Rule{{Method: "*", Route: "/users", Limit: Limit{Rate: 0.5, Burst: 5}}},
+	}
+	assert.Equal(t, 10*time.Second, cfg.RefillTime())
+}
+
+func TestPostgresStore_Prune(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	before := time.Date(2023, 1, 1, 12, 0, 0, 0, time.UTC)
+	mock.ExpectExec("DELETE FROM rate_limit_buckets").WithArgs(before).WillReturnResult(sqlmock.NewResult(0, 3))
+
+	pruned, err := NewPostgresStore(db).Prune(context.Background(), before)
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), pruned)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,90 @@
+package ratelimit
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// A missing bucket is created full first, so that concurrent first requests lock the same row
+	sqlInsertBucket = `INSERT INTO rate_limit_buckets (key, tokens, updated_at) VALUES ($1, $2, $3) ON CONFLICT (key) DO NOTHING`
+	sqlSelectBucket = `SELECT tokens, updated_at FROM rate_limit_buckets WHERE key = $1 FOR UPDATE`
+	sqlUpdateBucket = `UPDATE rate_limit_buckets SET tokens = $2, updated_at = $3 WHERE key = $1`
+	sqlPruneBuckets = `DELETE FROM rate_limit_buckets WHERE updated_at < $1`
+)
+
+// PostgresStore keeps token buckets in PostgreSQL so that limits are shared across replicas
+// Each Take costs one short transaction, the bucket row is locked for its duration
+type PostgresStore struct {
+	db *sql.DB
+}
+
+// NewPostgresStore creates a new PostgreSQL bucket store
+func NewPostgresStore(db *sql.DB) *PostgresStore {
+	return &PostgresStore{db: db}
+}
+
+// Take implements the Store interface
+func (s *PostgresStore) Take(ctx context.Context, key string, limit Limit, now time.Time) (Result, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return Result{}, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback() // nolint: errcheck
+
+	if _, err := tx.ExecContext(ctx, sqlInsertBucket, key, float64(limit.Burst), now); err != nil {
+		return Result{}, fmt.Errorf("failed to insert bucket: %w", err)
+	}
+
+	var tokens float64
+	var updatedAt time.Time
+	if err := tx.QueryRowContext(ctx, sqlSelectBucket, key).Scan(&tokens, &updatedAt); err != nil {
+		return Result{}, fmt.Errorf("failed to select bucket: %w", err)
+	}
+
+	var res Result
+	tokens, res = take(refill(tokens, now.Sub(updatedAt), limit), limit)
+
+	if _, err := tx.ExecContext(ctx, sqlUpdateBucket, key, tokens, now); err != nil {
+		return Result{}, fmt.Errorf("failed to update bucket: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return Result{}, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return res, nil
+}
+
+// Prune deletes the buckets last taken from before the given time
+func (s *PostgresStore) Prune(ctx context.Context, before time.Time) (int64, error) {
+	res, err := s.db.ExecContext(ctx, sqlPruneBuckets, before)
+	if err != nil {
+		return 0, fmt.Errorf("failed to prune buckets: %w", err)
+	}
+	return res.RowsAffected()
+}
+
+// RunPruner deletes idle buckets every sweepInterval until ctx is done
+// A bucket idle for longer than idle has refilled completely, so it is equivalent to a new one
+func (s *PostgresStore) RunPruner(ctx context.Context, idle time.Duration, logger *zap.Logger) {
+	ticker := time.NewTicker(sweepInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			pruned, err := s.Prune(ctx, time.Now().Add(-idle))
+			if err != nil {
+				logger.Warn("failed to prune rate limit buckets", zap.Error(err))
+			} else if pruned > 0 {
+				logger.Debug("pruned rate limit buckets", zap.Int64("count", pruned))
+			}
+		}
+	}
+}
@@ -0,0 +1,285 @@
+package scim
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/huberts90/restful-api/internal/storage"
+)
+
+// userAttributePrefix may be prepended to the attributes of users in filters and paths
+const userAttributePrefix = "urn:ietf:params:scim:schemas:core:2.0:user:"
+
+// attributeKind tells how the values of an attribute are compared
+type attributeKind int
+
+const (
+	kindText attributeKind = iota
+	kindID
+	kindTime
+	kindActive
+)
+
+type attribute struct {
+	field storage.UserField
+	kind  attributeKind
+}
+
+// filterAttributes are the attributes filters can test, by their lower case name
+var filterAttributes = map[string]attribute{
+	"id":                {field: storage.UserFieldID, kind: kindID},
+	"username":          {field: storage.UserFieldEmail, kind: kindText},
+	"emails":            {field: storage.UserFieldEmail, kind: kindText},
+	"emails.value":      {field: storage.UserFieldEmail, kind: kindText},
+	"name.givenname":    {field: storage.UserFieldFirstName, kind: kindText},
+	"name.familyname":   {field: storage.UserFieldLastName, kind: kindText},
+	"meta.created":      {field: storage.UserFieldCreatedAt, kind: kindTime},
+	"meta.lastmodified": {field: storage.UserFieldUpdatedAt, kind: kindTime},
+	"active":            {kind: kindActive},
+}
+
+// ParseFilter parses a filter expression of a list request into a filter of the store
+// Attribute expressions can be combined with and, or, not and parentheses, value paths are not supported
+func ParseFilter(s string) (storage.UserFilter, error) {
+	tokens, err := lexFilter(s)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &filterParser{tokens: tokens}
+	filter, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.tokens) {
+		return nil, invalidFilter("unexpected %q", p.tokens[p.pos].text)
+	}
+	return filter, nil
+}
+
+func invalidFilter(format string, args ...interface{}) *Error {
+	return NewError(http.StatusBadRequest, ErrorInvalidFilter, "Invalid filter: "+fmt.Sprintf(format, args...))
+}
+
+type tokenKind int
+
+const (
+	tokenWord tokenKind = iota
+	tokenString
+	tokenOpen
+	tokenClose
+)
+
+type token struct {
+	kind tokenKind
+	text string
+}
+
+// lexFilter splits a filter into words, strings and parentheses
+func lexFilter(s string) ([]token, error) {
+	var tokens []token
+	for i := 0; i < len(s); {
+		switch c := s[i]; {
+		case c == ' ' || c == '\t':
+			i++
+		case c == '(':
+			tokens = append(tokens, token{kind: tokenOpen, text: "("})
+			i++
+		case c == ')':
+			tokens = append(tokens, token{kind: tokenClose, text: ")"})
+			i++
+		case c == '"':
+			// Strings are JSON strings, find the closing quote and let the JSON decoder unescape them
+			end := i + 1
+			for end < len(s) && s[end] != '"' {
+				if s[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			if end >= len(s) {
+				return nil, invalidFilter("unterminated string")
+			}
+			var value string
+			if err := json.Unmarshal([]byte(s[i:end+1]), &value); err != nil {
+				return nil, invalidFilter("invalid string %s", s[i:end+1])
+			}
+			tokens = append(tokens, token{kind: tokenString, text: value})
+			i = end + 1
+		case c == '[' || c == ']':
+			return nil, invalidFilter("value paths are not supported")
+		default:
+			end := i
+			for end < len(s) && !strings.ContainsRune(" \t()[]\"", rune(s[end])) {
+				end++
+			}
+			tokens = append(tokens, token{kind: tokenWord, text: s[i:end]})
+			i = end
+		}
+	}
+
+	if len(tokens) == 0 {
+		return nil, invalidFilter("empty filter")
+	}
+	return tokens, nil
+}
+
+// filterParser parses tokens by precedence, not binds tighter than and, which binds tighter than or
+type filterParser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *filterParser) next() (token, bool) {
+	if p.pos >= len(p.tokens) {
+		return token{}, false
+	}
+	t := p.tokens[p.pos]
+	p.pos++
+	return t, true
+}
+
+// keyword reports whether the next token is the keyword, and consumes it if so
+func (p *filterParser) keyword(word string) bool {
+	if p.pos < len(p.tokens) && p.tokens[p.pos].kind == tokenWord && strings.EqualFold(p.tokens[p.pos].text, word) {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *filterParser) parseOr() (storage.UserFilter, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.keyword("or") {
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = storage.FilterOr(left, right)
+	}
+	return left, nil
+}
+
+func (p *filterParser) parseAnd() (storage.UserFilter, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for p.keyword("and") {
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		left = storage.FilterAnd(left, right)
+	}
+	return left, nil
+}
+
+func (p *filterParser) parseNot() (storage.UserFilter, error) {
+	negate := p.keyword("not")
+
+	t, ok := p.next()
+	if !ok {
+		return nil, invalidFilter("unexpected end")
+	}
+
+	var filter storage.UserFilter
+	var err error
+	switch {
+	case t.kind == tokenOpen:
+		if filter, err = p.parseOr(); err != nil {
+			return nil, err
+		}
+		if closing, ok := p.next(); !ok || closing.kind != tokenClose {
+			return nil, invalidFilter("missing )")
+		}
+	case negate:
+		return nil, invalidFilter("not must be followed by (")
+	case t.kind == tokenWord:
+		if filter, err = p.parseComparison(t.text); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, invalidFilter("unexpected %q", t.text)
+	}
+
+	if negate {
+		return storage.FilterNot(filter), nil
+	}
+	return filter, nil
+}
+
+// parseComparison parses an attribute expression, such as userName eq "ann@example.com" or name.givenName pr
+func (p *filterParser) parseComparison(name string) (storage.UserFilter, error) {
+	attr, ok := filterAttributes[strings.TrimPrefix(strings.ToLower(name), userAttributePrefix)]
+	if !ok {
+		return nil, invalidFilter("unsupported attribute %v", name)
+	}
+
+	opToken, ok := p.next()
+	if !ok || opToken.kind != tokenWord {
+		return nil, invalidFilter("missing operator after %v", name)
+	}
+	op := storage.FilterOp(strings.ToLower(opToken.text))
+
+	if op == "pr" {
+		if attr.kind == kindText {
+			return storage.FilterCompare(attr.field, storage.FilterNe, ""), nil
+		}
+		// The other attributes are always present
+		return storage.FilterOr(storage.FilterActive(true), storage.FilterActive(false)), nil
+	}
+
+	value, ok := p.next()
+	if !ok || value.kind == tokenOpen || value.kind == tokenClose {
+		return nil, invalidFilter("missing value after %v %v", name, opToken.text)
+	}
+
+	switch attr.kind {
+	case kindActive:
+		active, err := strconv.ParseBool(value.text)
+		if err != nil || value.kind != tokenWord || (op != storage.FilterEq && op != storage.FilterNe) {
+			return nil, invalidFilter("active can only be compared to true or false with eq or ne")
+		}
+		return storage.FilterActive(active == (op == storage.FilterEq)), nil
+	case kindText:
+		if value.kind != tokenString {
+			return nil, invalidFilter("%v must be compared to a string", name)
+		}
+		if !validOp(op, true) {
+			return nil, invalidFilter("unsupported operator %v", opToken.text)
+		}
+		return storage.FilterCompare(attr.field, op, value.text), nil
+	case kindID:
+		id, err := strconv.ParseInt(value.text, 10, 64)
+		if err != nil || !validOp(op, false) {
+			return nil, invalidFilter("id must be compared to an ID with eq, ne, gt, ge, lt or le")
+		}
+		return storage.FilterCompare(attr.field, op, id), nil
+	default:
+		t, err := time.Parse(time.RFC3339Nano, value.text)
+		if err != nil || value.kind != tokenString || !validOp(op, false) {
+			return nil, invalidFilter("%v must be compared to a date time with eq, ne, gt, ge, lt or le", name)
+		}
+		return storage.FilterCompare(attr.field, op, t), nil
+	}
+}
+
+// validOp reports whether an operator applies to an attribute, text attributes also support co, sw and ew
+func validOp(op storage.FilterOp, text bool) bool {
+	switch op {
+	case storage.FilterEq, storage.FilterNe, storage.FilterGt, storage.FilterGe, storage.FilterLt, storage.FilterLe:
+		return true
+	case storage.FilterContains, storage.FilterStartsWith, storage.FilterEndsWith:
+		return text
+	default:
+		return false
+	}
+}
@@ -0,0 +1,174 @@
+package scim
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+// PatchOp is a PATCH request, a list of operations applied in order
+type PatchOp struct {
+	Schemas    []string         `json:"schemas"`
+	Operations []PatchOperation `json:"Operations"`
+}
+
+// PatchOperation adds, replaces or removes the attribute at a path
+// Without a path the value is an object of the attributes to set
+type PatchOperation struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path,omitempty"`
+	Value json.RawMessage `json:"value,omitempty"`
+}
+
+// Apply applies the operations to a user, stopping at the first one that fails
+func (p PatchOp) Apply(u *User) error {
+	if len(p.Operations) == 0 {
+		return NewError(http.StatusBadRequest, ErrorInvalidSyntax, "No operations")
+	}
+
+	for _, op := range p.Operations {
+		if err := op.apply(u); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (op PatchOperation) apply(u *User) error {
+	path := strings.TrimPrefix(strings.ToLower(op.Path), userAttributePrefix)
+
+	switch strings.ToLower(op.Op) {
+	case "add", "replace":
+		if path == "" {
+			return setAttributes(u, op.Value)
+		}
+		return setAttribute(u, path, op.Value)
+	case "remove":
+		return removeAttribute(u, path)
+	default:
+		return NewError(http.StatusBadRequest, ErrorInvalidSyntax, "Unsupported operation "+op.Op)
+	}
+}
+
+// setAttributes sets the attributes of an object, the names may be paths such as name.givenName
+func setAttributes(u *User, value json.RawMessage) error {
+	var attrs map[string]json.RawMessage
+	if err := json.Unmarshal(value, &attrs); err != nil {
+		return NewError(http.StatusBadRequest, ErrorInvalidValue, "Value must be an object")
+	}
+
+	for name, v := range attrs {
+		if err := setAttribute(u, strings.TrimPrefix(strings.ToLower(name), userAttributePrefix), v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func setAttribute(u *User, path string, value json.RawMessage) error {
+	switch {
+	case path == "username" || path == "emails.value" || isEmailValuePath(path):
+		return setString(value, path, u.setEmail)
+	case path == "emails":
+		var emails []Email
+		if err := json.Unmarshal(value, &emails); err != nil || len(emails) == 0 {
+			return NewError(http.StatusBadRequest, ErrorInvalidValue, "emails must be a list of emails")
+		}
+		// Users have a single email, the primary one is kept
+		email := emails[0]
+		for _, e := range emails {
+			if e.Primary {
+				email = e
+			}
+		}
+		u.setEmail(email.Value)
+		return nil
+	case path == "name":
+		return setAttributes(u, prefixNames(value))
+	case path == "name.givenname":
+		return setString(value, path, func(s string) { u.Name.GivenName = s })
+	case path == "name.familyname":
+		return setString(value, path, func(s string) { u.Name.FamilyName = s })
+	case path == "active":
+		active, err := parseActive(value)
+		if err != nil {
+			return err
+		}
+		u.Active = &active
+		return nil
+	case path == "schemas" || path == "id" || path == "name.formatted" || strings.HasPrefix(path, "meta"):
+		// Identity providers send the read only and derived attributes back, they are ignored
+		return nil
+	default:
+		return NewError(http.StatusBadRequest, ErrorInvalidPath, "Unsupported path "+path)
+	}
+}
+
+func removeAttribute(u *User, path string) error {
+	switch {
+	case path == "":
+		return NewError(http.StatusBadRequest, ErrorNoTarget, "Remove needs a path")
+	case path == "active":
+		u.Active = nil
+		return nil
+	case path == "username" || path == "emails" || path == "emails.value" || isEmailValuePath(path) ||
+		path == "name" || path == "name.givenname" || path == "name.familyname":
+		return NewError(http.StatusBadRequest, ErrorInvalidValue, path+" is required")
+	default:
+		return NewError(http.StatusBadRequest, ErrorInvalidPath, "Unsupported path "+path)
+	}
+}
+
+// isEmailValuePath reports whether a path selects the value of an email, such as emails[type eq "work"].value
+// Users have a single email, so any filter selects it
+func isEmailValuePath(path string) bool {
+	return strings.HasPrefix(path, "emails[") && strings.HasSuffix(path, "].value")
+}
+
+func (u *User) setEmail(email string) {
+	u.UserName = email
+	u.Emails = []Email{{Value: email, Type: "work", Primary: true}}
+}
+
+func setString(value json.RawMessage, path string, set func(string)) error {
+	var s string
+	if err := json.Unmarshal(value, &s); err != nil {
+		return NewError(http.StatusBadRequest, ErrorInvalidValue, path+" must be a string")
+	}
+	set(s)
+	return nil
+}
+
+// parseActive reads the active attribute, some identity providers send it as the string "True" or "False"
+func parseActive(value json.RawMessage) (bool, error) {
+	var active bool
+	if err := json.Unmarshal(value, &active); err == nil {
+		return active, nil
+	}
+
+	var s string
+	if err := json.Unmarshal(value, &s); err == nil {
+		switch strings.ToLower(s) {
+		case "true":
+			return true, nil
+		case "false":
+			return false, nil
+		}
+	}
+	return false, NewError(http.StatusBadRequest, ErrorInvalidValue, "active must be a boolean")
+}
+
+// prefixNames turns the attributes of a name object into paths, so they can be set by setAttributes
+func prefixNames(value json.RawMessage) json.RawMessage {
+	var attrs map[string]json.RawMessage
+	if err := json.Unmarshal(value, &attrs); err != nil {
+		return value
+	}
+
+	prefixed := make(map[string]json.RawMessage, len(attrs))
+	for name, v := range attrs {
+		prefixed["name."+name] = v
+	}
+	b, _ := json.Marshal(prefixed)
+	return b
+}
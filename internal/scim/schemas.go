@@ -0,0 +1,142 @@
+package scim
+
+// ServiceProviderConfig describes the features of the endpoint
+type ServiceProviderConfig struct {
+	Schemas               []string               `json:"schemas"`
+	DocumentationURI      string                 `json:"documentationUri,omitempty"`
+	Patch                 Supported              `json:"patch"`
+	Bulk                  BulkSupport            `json:"bulk"`
+	Filter                FilterSupport          `json:"filter"`
+	ChangePassword        Supported              `json:"changePassword"`
+	Sort                  Supported              `json:"sort"`
+	ETag                  Supported              `json:"etag"`
+	AuthenticationSchemes []AuthenticationScheme `json:"authenticationSchemes"`
+	Meta                  ResourceMeta           `json:"meta"`
+}
+
+// Supported tells whether a feature is supported
+type Supported struct {
+	Supported bool `json:"supported"`
+}
+
+// BulkSupport tells whether bulk requests are supported, and their limits
+type BulkSupport struct {
+	Supported      bool `json:"supported"`
+	MaxOperations  int  `json:"maxOperations"`
+	MaxPayloadSize int  `json:"maxPayloadSize"`
+}
+
+// FilterSupport tells whether filters are supported, and how many results a list returns at most
+type FilterSupport struct {
+	Supported  bool `json:"supported"`
+	MaxResults int  `json:"maxResults"`
+}
+
+// AuthenticationScheme is a way to authenticate with the endpoint
+type AuthenticationScheme struct {
+	Type        string `json:"type"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Primary     bool   `json:"primary,omitempty"`
+}
+
+// ResourceMeta is the metadata of the resources describing the endpoint
+type ResourceMeta struct {
+	ResourceType string `json:"resourceType"`
+	Location     string `json:"location"`
+}
+
+// ResourceType describes a type of resource the endpoint serves
+type ResourceType struct {
+	Schemas  []string     `json:"schemas"`
+	ID       string       `json:"id"`
+	Name     string       `json:"name"`
+	Endpoint string       `json:"endpoint"`
+	Schema   string       `json:"schema"`
+	Meta     ResourceMeta `json:"meta"`
+}
+
+// Schema describes the attributes of a resource
+type Schema struct {
+	Schemas     []string          `json:"schemas"`
+	ID          string            `json:"id"`
+	Name        string            `json:"name"`
+	Description string            `json:"description"`
+	Attributes  []SchemaAttribute `json:"attributes"`
+	Meta        ResourceMeta      `json:"meta"`
+}
+
+// SchemaAttribute describes an attribute of a schema
+type SchemaAttribute struct {
+	Name          string            `json:"name"`
+	Type          string            `json:"type"`
+	MultiValued   bool              `json:"multiValued"`
+	Required      bool              `json:"required"`
+	CaseExact     bool              `json:"caseExact"`
+	Mutability    string            `json:"mutability"`
+	Returned      string            `json:"returned"`
+	Uniqueness    string            `json:"uniqueness"`
+	SubAttributes []SchemaAttribute `json:"subAttributes,omitempty"`
+}
+
+// NewServiceProviderConfig returns the features of the endpoint, baseURL is the URL the endpoint is served at
+func NewServiceProviderConfig(cfg Config, baseURL string) ServiceProviderConfig {
+	return ServiceProviderConfig{
+		Schemas: []string{SchemaServiceProviderConfig},
+		Patch:   Supported{Supported: true},
+		Filter:  FilterSupport{Supported: true, MaxResults: cfg.MaxResults},
+		AuthenticationSchemes: []AuthenticationScheme{{
+			Type:        "oauthbearertoken",
+			Name:        "OAuth Bearer Token",
+			Description: "Authentication with the bearer token shared with the identity provider",
+			Primary:     true,
+		}},
+		Meta: ResourceMeta{ResourceType: "ServiceProviderConfig", Location: baseURL + "/ServiceProviderConfig"},
+	}
+}
+
+// NewResourceTypes returns the types of resources the endpoint serves
+func NewResourceTypes(baseURL string) []ResourceType {
+	return []ResourceType{{
+		Schemas:  []string{SchemaResourceType},
+		ID:       "User",
+		Name:     "User",
+		Endpoint: "/Users",
+		Schema:   SchemaUser,
+		Meta:     ResourceMeta{ResourceType: "ResourceType", Location: baseURL + "/ResourceTypes/User"},
+	}}
+}
+
+// NewSchemas returns the schemas of the resources the endpoint serves
+// Only the attributes mapped onto users are described
+func NewSchemas(baseURL string) []Schema {
+	text := func(name string, required bool) SchemaAttribute {
+		return SchemaAttribute{Name: name, Type: "string", Required: required, Mutability: "readWrite", Returned: "default", Uniqueness: "none"}
+	}
+
+	userName := text("userName", true)
+	userName.Uniqueness = "server"
+	emailValue := text("value", true)
+	emailValue.Uniqueness = "server"
+	primary := SchemaAttribute{Name: "primary", Type: "boolean", Mutability: "readWrite", Returned: "default", Uniqueness: "none"}
+
+	return []Schema{{
+		Schemas:     []string{SchemaSchema},
+		ID:          SchemaUser,
+		Name:        "User",
+		Description: "User Account",
+		Attributes: []SchemaAttribute{
+			userName,
+			{
+				Name: "name", Type: "complex", Required: true, Mutability: "readWrite", Returned: "default", Uniqueness: "none",
+				SubAttributes: []SchemaAttribute{text("givenName", true), text("familyName", true)},
+			},
+			{
+				Name: "emails", Type: "complex", MultiValued: true, Mutability: "readWrite", Returned: "default", Uniqueness: "none",
+				SubAttributes: []SchemaAttribute{emailValue, text("type", false), primary},
+			},
+			{Name: "active", Type: "boolean", Mutability: "readWrite", Returned: "default", Uniqueness: "none"},
+		},
+		Meta: ResourceMeta{ResourceType: "Schema", Location: baseURL + "/Schemas/" + SchemaUser},
+	}}
+}
@@ -0,0 +1,150 @@
+package scim
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/huberts90/restful-api/internal/domain"
+)
+
+// MediaType is the media type of SCIM requests and responses
+const MediaType = "application/scim+json"
+
+// Schema URNs used by the resources and messages
+const (
+	SchemaUser                  = "urn:ietf:params:scim:schemas:core:2.0:User"
+	SchemaListResponse          = "urn:ietf:params:scim:api:messages:2.0:ListResponse"
+	SchemaPatchOp               = "urn:ietf:params:scim:api:messages:2.0:PatchOp"
+	SchemaError                 = "urn:ietf:params:scim:api:messages:2.0:Error"
+	SchemaServiceProviderConfig = "urn:ietf:params:scim:schemas:core:2.0:ServiceProviderConfig"
+	SchemaResourceType          = "urn:ietf:params:scim:schemas:core:2.0:ResourceType"
+	SchemaSchema                = "urn:ietf:params:scim:schemas:core:2.0:Schema"
+)
+
+// Config holds the configuration of the SCIM endpoint
+type Config struct {
+	Token      string // bearer token identity providers authenticate with, empty disables authentication
+	MaxResults int    // users returned by a list at most
+}
+
+// User is the SCIM representation of a user
+// The user name is the email, and users are active until they are soft deleted
+type User struct {
+	Schemas  []string `json:"schemas"`
+	ID       string   `json:"id,omitempty"`
+	UserName string   `json:"userName"`
+	Name     Name     `json:"name"`
+	Emails   []Email  `json:"emails,omitempty"`
+	Active   *bool    `json:"active,omitempty"` // nil in requests that leave it out, which means active
+	Meta     *Meta    `json:"meta,omitempty"`
+}
+
+// Name is the name of a user
+type Name struct {
+	GivenName  string `json:"givenName"`
+	FamilyName string `json:"familyName"`
+}
+
+// Email is an email address of a user, users have a single primary one
+type Email struct {
+	Value   string `json:"value"`
+	Type    string `json:"type,omitempty"`
+	Primary bool   `json:"primary,omitempty"`
+}
+
+// Meta holds the metadata of a resource
+type Meta struct {
+	ResourceType string    `json:"resourceType"`
+	Created      time.Time `json:"created"`
+	LastModified time.Time `json:"lastModified"`
+	Location     string    `json:"location"`
+}
+
+// ListResponse is a page of resources
+type ListResponse struct {
+	Schemas      []string    `json:"schemas"`
+	TotalResults int         `json:"totalResults"`
+	StartIndex   int         `json:"startIndex"`
+	ItemsPerPage int         `json:"itemsPerPage"`
+	Resources    interface{} `json:"Resources"`
+}
+
+// NewUser converts a user to its SCIM representation, location is the URL of the resource
+func NewUser(u domain.User, location string) User {
+	active := u.DeletedAt == nil
+	return User{
+		Schemas:  []string{SchemaUser},
+		ID:       strconv.FormatInt(u.ID, 10),
+		UserName: u.Email,
+		Name:     Name{GivenName: u.FirstName, FamilyName: u.LastName},
+		Emails:   []Email{{Value: u.Email, Type: "work", Primary: true}},
+		Active:   &active,
+		Meta: &Meta{
+			ResourceType: "User",
+			Created:      u.CreatedAt,
+			LastModified: u.UpdatedAt,
+			Location:     location,
+		},
+	}
+}
+
+// Email returns the email of a user in a request, the user name unless only a primary email is given
+func (u User) Email() string {
+	if u.UserName != "" {
+		return u.UserName
+	}
+	for _, email := range u.Emails {
+		if email.Primary {
+			return email.Value
+		}
+	}
+	return ""
+}
+
+// IsActive reports whether a user in a request is active, users are active unless told otherwise
+func (u User) IsActive() bool {
+	return u.Active == nil || *u.Active
+}
+
+// Create returns the domain user to create from a user in a request
+func (u User) Create() domain.UserCreate {
+	return domain.UserCreate{
+		Email:     u.Email(),
+		FirstName: u.Name.GivenName,
+		LastName:  u.Name.FamilyName,
+	}
+}
+
+// Error types, the scimType of an error response
+const (
+	ErrorInvalidFilter = "invalidFilter"
+	ErrorInvalidSyntax = "invalidSyntax"
+	ErrorInvalidPath   = "invalidPath"
+	ErrorInvalidValue  = "invalidValue"
+	ErrorNoTarget      = "noTarget"
+	ErrorMutability    = "mutability"
+	ErrorUniqueness    = "uniqueness"
+	ErrorTooMany       = "tooMany"
+)
+
+// Error is a SCIM error response, the error type is only set for some 400 and 409 errors
+type Error struct {
+	Schemas []string `json:"schemas"`
+	Status  string   `json:"status"`
+	Type    string   `json:"scimType,omitempty"`
+	Detail  string   `json:"detail"`
+}
+
+func (e *Error) Error() string {
+	return e.Detail
+}
+
+// NewError creates an error response
+func NewError(status int, errorType, detail string) *Error {
+	return &Error{
+		Schemas: []string{SchemaError},
+		Status:  strconv.Itoa(status),
+		Type:    errorType,
+		Detail:  detail,
+	}
+}
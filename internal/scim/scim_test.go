@@ -0,0 +1,182 @@
+package scim
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/huberts90/restful-api/internal/domain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseFilter(t *testing.T) {
+	created := time.Date(2023, 1, 1, 12, 0, 0, 0, time.UTC)
+	deleted := created.Add(time.Hour)
+	ann := domain.User{ID: 7, Email: "ann.lee@example.com", FirstName: "Ann", LastName: "Lee", CreatedAt: created, UpdatedAt: created}
+	bob := domain.User{ID: 8, Email: "bob@example.org", FirstName: "Bob", LastName: "Stone", CreatedAt: deleted, UpdatedAt: deleted, DeletedAt: &deleted}
+
+	tests := []struct {
+		filter string
+		want   []domain.User
+	}{
+		{filter: `userName eq "ANN.LEE@example.com"`, want: []domain.User{ann}},
+		{filter: `urn:ietf:params:scim:schemas:core:2.0:User:userName eq "bob@example.org"`, want: []domain.User{bob}},
+		{filter: `emails.value ew "example.org"`, want: []domain.User{bob}},
+		{filter: `name.givenName sw "a" or name.familyName co "ton"`, want: []domain.User{ann, bob}},
+		{filter: `name.givenName pr and not (name.familyName eq "Lee")`, want: []domain.User{bob}},
+		{filter: `id eq "7"`, want: []domain.User{ann}},
+		{filter: `id gt 7`, want: []domain.User{bob}},
+		{filter: `active eq false`, want: []domain.User{bob}},
+		{filter: `active ne false and userName pr`, want: []domain.User{ann}},
+		{filter: `meta.created lt "2023-01-01T12:30:00Z"`, want: []domain.User{ann}},
+		{filter: `(userName eq "nobody@example.com")`, want: nil},
+		{filter: `meta.lastModified pr`, want: []domain.User{ann, bob}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.filter, func(t *testing.T) {
+			filter, err := ParseFilter(tt.filter)
+			require.NoError(t, err)
+
+			var got []domain.User
+			for _, user := range []domain.User{ann, bob} {
+				if filter.Matches(user) {
+					got = append(got, user)
+				}
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestParseFilter_Invalid(t *testing.T) {
+	for _, filter := range []string{
+		``,
+		`userName`,
+		`userName eq`,
+		`userName eq "unterminated`,
+		`userName xx "ann@example.com"`,
+		`userName eq 42`,
+		`password eq "secret"`,
+		`emails[type eq "work"]`,
+		`active eq "yes"`,
+		`active gt true`,
+		`id co "7"`,
+		`meta.created gt "yesterday"`,
+		`not userName pr`,
+		`(userName pr`,
+		`userName pr)`,
+	} {
+		t.Run(filter, func(t *testing.T) {
+			_, err := ParseFilter(filter)
+
+			var scimErr *Error
+			require.True(t, errors.As(err, &scimErr), "got %v", err)
+			assert.Equal(t, "400", scimErr.Status)
+			assert.Equal(t, ErrorInvalidFilter, scimErr.Type)
+		})
+	}
+}
+
+func TestPatchOp_Apply(t *testing.T) {
+	base := domain.User{ID: 7, Email: "ann@example.com", FirstName: "Ann", LastName: "Lee"}
+
+	tests := []struct {
+		name       string
+		operations string
+		check      func(*testing.T, User)
+		wantType   string
+	}{
+		{
+			name:       "replace paths",
+			operations: `[{"op":"Replace","path":"name.givenName","value":"Anna"},{"op":"replace","path":"emails[type eq \"work\"].value","value":"anna@example.com"}]`,
+			check: func(t *testing.T, u User) {
+				assert.Equal(t, "Anna", u.Name.GivenName)
+				assert.Equal(t, "anna@example.com", u.Email())
+				assert.Equal(t, "anna@example.com", u.Emails[0].Value)
+			},
+		},
+		{
+			name:       "replace without path",
+			operations: `[{"op":"replace","value":{"userName":"anna@example.com","name.familyName":"Li","active":"False"}}]`,
+			check: func(t *testing.T, u User) {
+				assert.Equal(t, "anna@example.com", u.Email())
+				assert.Equal(t, "Li", u.Name.FamilyName)
+				assert.False(t, u.IsActive())
+			},
+		},
+		{
+			name:       "add name object",
+			operations: `[{"op":"add","path":"name","value":{"givenName":"Anna","formatted":"Anna Lee"}}]`,
+			check: func(t *testing.T, u User) {
+				assert.Equal(t, "Anna", u.Name.GivenName)
+				assert.Equal(t, "Lee", u.Name.FamilyName)
+			},
+		},
+		{
+			name:       "replace emails",
+			operations: `[{"op":"replace","path":"emails","value":[{"value":"home@example.com","type":"home"},{"value":"work@example.com","primary":true}]}]`,
+			check: func(t *testing.T, u User) {
+				assert.Equal(t, "work@example.com", u.Email())
+			},
+		},
+		{
+			name:       "deactivate",
+			operations: `[{"op":"replace","path":"active","value":false}]`,
+			check: func(t *testing.T, u User) {
+				assert.False(t, u.IsActive())
+			},
+		},
+		{
+			name:       "remove required attribute",
+			operations: `[{"op":"remove","path":"userName"}]`,
+			wantType:   ErrorInvalidValue,
+		},
+		{
+			name:       "remove without path",
+			operations: `[{"op":"remove"}]`,
+			wantType:   ErrorNoTarget,
+		},
+		{
+			name:       "unknown path",
+			operations: `[{"op":"replace","path":"nickName","value":"annie"}]`,
+			wantType:   ErrorInvalidPath,
+		},
+		{
+			name:       "invalid value",
+			operations: `[{"op":"replace","path":"active","value":"maybe"}]`,
+			wantType:   ErrorInvalidValue,
+		},
+		{
+			name:       "unknown operation",
+			operations: `[{"op":"move","path":"userName","value":"anna@example.com"}]`,
+			wantType:   ErrorInvalidSyntax,
+		},
+		{
+			name:       "no operations",
+			operations: `[]`,
+			wantType:   ErrorInvalidSyntax,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var patch PatchOp
+			require.NoError(t, json.Unmarshal([]byte(`{"schemas":["`+SchemaPatchOp+`"],"Operations":`+tt.operations+`}`), &patch))
+
+			user := NewUser(base, "/scim/v2/Users/7")
+			err := patch.Apply(&user)
+
+			if tt.wantType != "" {
+				var scimErr *Error
+				require.True(t, errors.As(err, &scimErr), "got %v", err)
+				assert.Equal(t, tt.wantType, scimErr.Type)
+				return
+			}
+			require.NoError(t, err)
+			tt.check(t, user)
+		})
+	}
+}
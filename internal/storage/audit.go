@@ -0,0 +1,170 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/huberts90/restful-api/internal/domain"
+	"github.com/lib/pq"
+	"go.uber.org/zap"
+)
+
+const (
+	sqlInsertAudit = `INSERT INTO user_audit_log (user_id, actor, request_id, operation, changes, created_at) VALUES ($1, $2, $3, $4, $5, NOW())`
+	// A batch writes all of its records at once, the changes are passed in their text form
+	sqlInsertAudits = `INSERT INTO user_audit_log (user_id, actor, request_id, operation, changes, created_at) ` +
+		`SELECT user_id, $1::varchar, $2::varchar, $3::varchar, changes::jsonb, NOW() FROM unnest($4::bigint[], $5::text[]) AS a(user_id, changes)`
+	sqlCountAudit = `SELECT COUNT(*) FROM user_audit_log WHERE user_id = $1`
+	sqlListAudit  = `SELECT id, user_id, actor, request_id, operation, changes, created_at FROM user_audit_log WHERE user_id = $1 ORDER BY id DESC LIMIT $2 OFFSET $3`
+)
+
+// AuditInfo describes who is behind a mutation
+type AuditInfo struct {
+	Actor     string
+	RequestID string
+}
+
+type auditInfoKey struct{}
+
+// WithAuditInfo stores the audit information in the context
+// Mutations read it to fill in their audit records
+func WithAuditInfo(ctx context.Context, info AuditInfo) context.Context {
+	return context.WithValue(ctx, auditInfoKey{}, info)
+}
+
+func auditInfoFromContext(ctx context.Context) AuditInfo {
+	info, _ := ctx.Value(auditInfoKey{}).(AuditInfo)
+	if info.Actor == "" {
+		info.Actor = "system"
+	}
+	return info
+}
+
+// writeAudit appends an audit record within the transaction of the mutation
+func (s *PostgresStore) writeAudit(ctx context.Context, tx *sql.Tx, userID int64, op domain.AuditOperation, changes map[string]domain.FieldChange) error {
+	data, err := json.Marshal(changes)
+	if err != nil {
+		return s.handleError(err, "failed to marshal audit changes", zap.Int64("id", userID))
+	}
+
+	// lib/pq sends []byte as bytea, JSONB columns take the text form
+	info := auditInfoFromContext(ctx)
+	if _, err := tx.ExecContext(ctx, sqlInsertAudit, userID, info.Actor, info.RequestID, string(op), string(data)); err != nil {
+		return s.handleError(err, "failed to write audit record", zap.Int64("id", userID))
+	}
+	return nil
+}
+
+// writeAudits appends the audit records of a batch within its transaction, changes[i] are the changes of userIDs[i]
+func (s *PostgresStore) writeAudits(ctx context.Context, tx *sql.Tx, op domain.AuditOperation, userIDs []int64, changes []map[string]domain.FieldChange) error {
+	data := make([]string, len(changes))
+	for i, change := range changes {
+		b, err := json.Marshal(change)
+		if err != nil {
+			return s.handleError(err, "failed to marshal audit changes", zap.Int64("id", userIDs[i]))
+		}
+		data[i] = string(b)
+	}
+
+	info := auditInfoFromContext(ctx)
+	if _, err := tx.ExecContext(ctx, sqlInsertAudits, info.Actor, info.RequestID, string(op), pq.Array(userIDs), pq.Array(data)); err != nil {
+		return s.handleError(err, "failed to write audit records", zap.Int("count", len(userIDs)))
+	}
+	return nil
+}
+
+// ListUserHistory retrieves a paginated list of the audit records of a user, newest first
+func (s *PostgresStore) ListUserHistory(ctx context.Context, userID int64, page, pageSize int) ([]domain.AuditRecord, int, error) {
+	if userID <= 0 {
+		return nil, 0, ErrInvalidID
+	}
+	if page < 1 {
+		return nil, 0, ErrInvalidPage
+	}
+	if pageSize < 1 || pageSize > 100 {
+		return nil, 0, ErrInvalidPageSize
+	}
+
+	var records []domain.AuditRecord
+	var totalCount int
+
+	err := s.withTx(ctx, true, func(tx *sql.Tx) error {
+		if err := tx.QueryRowContext(ctx, sqlCountAudit, userID).Scan(&totalCount); err != nil {
+			return s.handleError(err, "failed to count audit records")
+		}
+
+		rows, err := tx.QueryContext(ctx, sqlListAudit, userID, pageSize, (page-1)*pageSize)
+		if err != nil {
+			return s.handleError(err, "failed to query audit records")
+		}
+		defer rows.Close()
+
+		records = make([]domain.AuditRecord, 0, pageSize)
+		for rows.Next() {
+			var record domain.AuditRecord
+			var operation string
+			var changes []byte
+			err := rows.Scan(&record.ID, &record.UserID, &record.Actor, &record.RequestID, &operation, &changes, &record.CreatedAt)
+			if err != nil {
+				return s.handleError(err, "failed to scan audit record row")
+			}
+			if err := json.Unmarshal(changes, &record.Changes); err != nil {
+				return s.handleError(err, "failed to unmarshal audit changes")
+			}
+			record.Operation = domain.AuditOperation(operation)
+			records = append(records, record)
+		}
+
+		if err = rows.Err(); err != nil {
+			return s.handleError(err, "error iterating audit record rows")
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return records, totalCount, nil
+}
+
+// createChanges describes the fields set on a new user
+func createChanges(user domain.UserCreate) map[string]domain.FieldChange {
+	return map[string]domain.FieldChange{
+		"email":      {New: &user.Email},
+		"first_name": {New: &user.FirstName},
+		"last_name":  {New: &user.LastName},
+	}
+}
+
+// updateChanges describes the fields an update actually changes
+func updateChanges(before *domain.User, update domain.UserUpdate) map[string]domain.FieldChange {
+	changes := make(map[string]domain.FieldChange)
+	addChange := func(field, oldVal, newVal string) {
+		if newVal != "" && newVal != oldVal {
+			changes[field] = domain.FieldChange{Old: &oldVal, New: &newVal}
+		}
+	}
+
+	addChange("email", before.Email, update.Email)
+	addChange("first_name", before.FirstName, update.FirstName)
+	addChange("last_name", before.LastName, update.LastName)
+	return changes
+}
+
+// deletedAtChange describes a change of the soft delete marker
+func deletedAtChange(oldVal, newVal *time.Time) map[string]domain.FieldChange {
+	format := func(t *time.Time) *string {
+		if t == nil {
+			return nil
+		}
+		s := t.UTC().Format(time.RFC3339Nano)
+		return &s
+	}
+	return map[string]domain.FieldChange{
+		"deleted_at": {Old: format(oldVal), New: format(newVal)},
+	}
+}
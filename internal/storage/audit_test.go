@@ -0,0 +1,83 @@
+package storage
+
+import (
+	"context"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/huberts90/restful-api/internal/domain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestListUserHistory(t *testing.T) {
+	f := setupTest(t)
+	defer f.cleanup()
+
+	tests := []struct {
+		name      string
+		userID    int64
+		page      int
+		pageSize  int
+		setup     func(sqlmock.Sqlmock)
+		wantCount int
+		wantErr   error
+	}{
+		{
+			name:     "success",
+			userID:   1,
+			page:     1,
+			pageSize: 10,
+			setup: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(sqlCountAudit).
+					WithArgs(int64(1)).
+					WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(2))
+				mock.ExpectQuery(sqlListAudit).
+					WithArgs(int64(1), 10, 0).
+					WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "actor", "request_id", "operation", "changes", "created_at"}).
+						AddRow(2, 1, "ip:192.0.2.1", "req-2", "update", []byte(`{"email":{"old":"a@example.com","new":"b@example.com"}}`), f.now).
+						AddRow(1, 1, "system", "", "create", []byte(`{"email":{"old":null,"new":"a@example.com"}}`), f.now))
+				mock.ExpectCommit()
+			},
+			wantCount: 2,
+		},
+		{
+			name:     "invalid id",
+			userID:   0,
+			page:     1,
+			pageSize: 10,
+			setup:    func(mock sqlmock.Sqlmock) {},
+			wantErr:  ErrInvalidID,
+		},
+		{
+			name:     "invalid page size",
+			userID:   1,
+			page:     1,
+			pageSize: 101,
+			setup:    func(mock sqlmock.Sqlmock) {},
+			wantErr:  ErrInvalidPageSize,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.setup(f.mock)
+
+			records, totalCount, err := f.store.ListUserHistory(context.Background(), tt.userID, tt.page, tt.pageSize)
+
+			if tt.wantErr != nil {
+				assert.Equal(t, tt.wantErr, err)
+			} else {
+				require.NoError(t, err)
+				assert.Equal(t, tt.wantCount, totalCount)
+				require.Len(t, records, 2)
+				assert.Equal(t, domain.AuditUpdate, records[0].Operation)
+				assert.Equal(t, "b@example.com", *records[0].Changes["email"].New)
+				assert.Nil(t, records[1].Changes["email"].Old)
+			}
+
+			assert.NoError(t, f.mock.ExpectationsWereMet(), "SQL expectations not met")
+		})
+	}
+}
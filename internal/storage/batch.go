@@ -0,0 +1,357 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/huberts90/restful-api/internal/domain"
+	"github.com/lib/pq"
+)
+
+// ErrBatchAborted is reported for the items of an atomic batch rolled back because another item failed
+var ErrBatchAborted = errors.New("batch aborted")
+
+// errRollback rolls back the transaction of a batch without it being reported as a failure
+var errRollback = errors.New("rollback")
+
+// Batch statements take arrays so that every batch is a single round trip whatever its size
+const (
+	// Conflicting rows are skipped rather than failing the statement, the missing ones are the duplicates
+	sqlBatchCreateUsers = `INSERT INTO users (email, first_name, last_name, created_at, updated_at) ` +
+		`SELECT email, first_name, last_name, NOW(), NOW() FROM unnest($1::text[], $2::text[], $3::text[]) WITH ORDINALITY AS u(email, first_name, last_name, ord) ` +
+		`ORDER BY ord ON CONFLICT DO NOTHING RETURNING id, email`
+	sqlBatchLockUsers = `SELECT id, email, first_name, last_name, created_at, updated_at, deleted_at FROM users WHERE id = ANY($1) AND deleted_at IS NULL ORDER BY id FOR UPDATE`
+	// Empty values keep the current ones, like in a single update
+	sqlBatchUpdateUsers = `UPDATE users AS u SET email = COALESCE(NULLIF(v.email, ''), u.email), ` +
+		`first_name = COALESCE(NULLIF(v.first_name, ''), u.first_name), last_name = COALESCE(NULLIF(v.last_name, ''), u.last_name), updated_at = NOW() ` +
+		`FROM unnest($1::bigint[], $2::text[], $3::text[], $4::text[]) AS v(id, email, first_name, last_name) WHERE u.id = v.id AND u.deleted_at IS NULL`
+	sqlBatchDeleteUsers  = `UPDATE users SET deleted_at = NOW(), updated_at = NOW() WHERE id = ANY($1) AND deleted_at IS NULL RETURNING id, deleted_at`
+	sqlSavepoint         = `SAVEPOINT batch`
+	sqlRollbackSavepoint = `ROLLBACK TO SAVEPOINT batch`
+)
+
+// BatchResult is the outcome of one item of a batch, in the order of the input
+// Err is nil if the item was applied
+type BatchResult struct {
+	ID  int64
+	Err error
+}
+
+// CreateUsers inserts many users with a single statement
+// Items whose email is taken, by a user or an earlier item, fail with ErrDuplicateEmail
+// If atomic, nothing is inserted unless every item succeeds, and the other items fail with ErrBatchAborted
+// The returned error is only set if the batch could not be processed at all
+func (s *PostgresStore) CreateUsers(ctx context.Context, users []domain.UserCreate, atomic bool) ([]BatchResult, error) {
+	results := make([]BatchResult, len(users))
+	if len(users) == 0 {
+		return results, nil
+	}
+
+	err := s.withTx(ctx, false, func(tx *sql.Tx) error {
+		inserted, err := s.insertUsers(ctx, tx, users)
+		if err != nil {
+			return err
+		}
+
+		// The first item with an email got it, the later ones are duplicates
+		ids := make([]int64, 0, len(inserted))
+		changes := make([]map[string]domain.FieldChange, 0, len(inserted))
+		for i, user := range users {
+			id, ok := inserted[user.Email]
+			if !ok {
+				results[i].Err = ErrDuplicateEmail
+				continue
+			}
+			delete(inserted, user.Email)
+			results[i].ID = id
+			ids = append(ids, id)
+			changes = append(changes, createChanges(user))
+		}
+
+		if atomic && len(ids) < len(users) {
+			abortBatch(results)
+			return errRollback
+		}
+		return s.recordChanges(ctx, tx, domain.AuditCreate, ids, changes)
+	})
+
+	return s.batchResults(ctx, results, err)
+}
+
+// UpdateUsers updates many users with a single statement, the IDs must be unique within the batch
+// Items fail with ErrInvalidID, ErrUserNotFound or ErrDuplicateEmail
+// If atomic, nothing is updated unless every item succeeds, and the other items fail with ErrBatchAborted
+// The returned error is only set if the batch could not be processed at all
+func (s *PostgresStore) UpdateUsers(ctx context.Context, updates []domain.UserBatchUpdate, atomic bool) ([]BatchResult, error) {
+	results := make([]BatchResult, len(updates))
+	if len(updates) == 0 {
+		return results, nil
+	}
+
+	ids := make([]int64, len(updates))
+	for i, update := range updates {
+		ids[i] = update.ID
+		results[i].ID = update.ID
+		if update.ID <= 0 {
+			results[i].Err = ErrInvalidID
+		}
+	}
+
+	err := s.withTx(ctx, false, func(tx *sql.Tx) error {
+		before, err := s.lockUsers(ctx, tx, ids)
+		if err != nil {
+			return err
+		}
+
+		pending := make([]int, 0, len(updates))
+		for i, update := range updates {
+			if results[i].Err != nil {
+				continue
+			}
+			if _, ok := before[update.ID]; !ok {
+				results[i].Err = ErrUserNotFound
+				continue
+			}
+			pending = append(pending, i)
+		}
+
+		// A taken email fails the whole statement, drop the items setting it and try again with the rest
+		for len(pending) > 0 && (!atomic || len(pending) == len(updates)) {
+			if !atomic {
+				if _, err := tx.ExecContext(ctx, sqlSavepoint); err != nil {
+					return s.handleError(err, "failed to create savepoint")
+				}
+			}
+
+			err := s.execBatchUpdate(ctx, tx, updates, pending)
+			if err == nil {
+				break
+			}
+			email, ok := duplicateEmail(err)
+			if !ok {
+				return s.handleError(err, "failed to update users")
+			}
+			if !atomic {
+				if _, err := tx.ExecContext(ctx, sqlRollbackSavepoint); err != nil {
+					return s.handleError(err, "failed to roll back to savepoint")
+				}
+			}
+
+			remaining := pending[:0]
+			for _, i := range pending {
+				if strings.EqualFold(updates[i].Email, email) {
+					results[i].Err = ErrDuplicateEmail
+					continue
+				}
+				remaining = append(remaining, i)
+			}
+			if len(remaining) == len(pending) {
+				// The conflict is not caused by any email of the batch
+				return s.handleError(err, "failed to update users")
+			}
+			pending = remaining
+		}
+
+		if atomic && len(pending) < len(updates) {
+			abortBatch(results)
+			return errRollback
+		}
+
+		updatedIDs := make([]int64, len(pending))
+		changes := make([]map[string]domain.FieldChange, len(pending))
+		for j, i := range pending {
+			updatedIDs[j] = updates[i].ID
+			changes[j] = updateChanges(before[updates[i].ID], updates[i].UserUpdate)
+		}
+		return s.recordChanges(ctx, tx, domain.AuditUpdate, updatedIDs, changes)
+	})
+
+	return s.batchResults(ctx, results, err)
+}
+
+// DeleteUsers soft deletes many users with a single statement
+// Items fail with ErrInvalidID or ErrUserNotFound
+// If atomic, nothing is deleted unless every item succeeds, and the other items fail with ErrBatchAborted
+// The returned error is only set if the batch could not be processed at all
+func (s *PostgresStore) DeleteUsers(ctx context.Context, ids []int64, atomic bool) ([]BatchResult, error) {
+	results := make([]BatchResult, len(ids))
+	if len(ids) == 0 {
+		return results, nil
+	}
+
+	for i, id := range ids {
+		results[i].ID = id
+		if id <= 0 {
+			results[i].Err = ErrInvalidID
+		}
+	}
+
+	err := s.withTx(ctx, false, func(tx *sql.Tx) error {
+		rows, err := tx.QueryContext(ctx, sqlBatchDeleteUsers, pq.Array(ids))
+		if err != nil {
+			return s.handleError(err, "failed to delete users")
+		}
+		defer rows.Close()
+
+		deleted := make(map[int64]time.Time, len(ids))
+		for rows.Next() {
+			var id int64
+			var deletedAt time.Time
+			if err := rows.Scan(&id, &deletedAt); err != nil {
+				return s.handleError(err, "failed to scan deleted user row")
+			}
+			deleted[id] = deletedAt
+		}
+		if err := rows.Err(); err != nil {
+			return s.handleError(err, "error iterating deleted user rows")
+		}
+
+		deletedIDs := make([]int64, 0, len(deleted))
+		changes := make([]map[string]domain.FieldChange, 0, len(deleted))
+		for i, id := range ids {
+			if results[i].Err != nil {
+				continue
+			}
+			deletedAt, ok := deleted[id]
+			if !ok {
+				results[i].Err = ErrUserNotFound
+				continue
+			}
+			delete(deleted, id)
+			deletedIDs = append(deletedIDs, id)
+			changes = append(changes, deletedAtChange(nil, &deletedAt))
+		}
+
+		if atomic && len(deletedIDs) < len(ids) {
+			abortBatch(results)
+			return errRollback
+		}
+		return s.recordChanges(ctx, tx, domain.AuditDelete, deletedIDs, changes)
+	})
+
+	return s.batchResults(ctx, results, err)
+}
+
+// insertUsers inserts the users whose email is free and returns their IDs by email
+func (s *PostgresStore) insertUsers(ctx context.Context, tx *sql.Tx, users []domain.UserCreate) (map[string]int64, error) {
+	emails := make([]string, len(users))
+	firstNames := make([]string, len(users))
+	lastNames := make([]string, len(users))
+	for i, user := range users {
+		emails[i], firstNames[i], lastNames[i] = user.Email, user.FirstName, user.LastName
+	}
+
+	rows, err := tx.QueryContext(ctx, sqlBatchCreateUsers, pq.Array(emails), pq.Array(firstNames), pq.Array(lastNames))
+	if err != nil {
+		return nil, s.handleError(err, "failed to create users")
+	}
+	defer rows.Close()
+
+	inserted := make(map[string]int64, len(users))
+	for rows.Next() {
+		var id int64
+		var email string
+		if err := rows.Scan(&id, &email); err != nil {
+			return nil, s.handleError(err, "failed to scan created user row")
+		}
+		inserted[email] = id
+	}
+	if err := rows.Err(); err != nil {
+		return nil, s.handleError(err, "error iterating created user rows")
+	}
+
+	return inserted, nil
+}
+
+// lockUsers locks the live users among ids and returns them by ID
+func (s *PostgresStore) lockUsers(ctx context.Context, tx *sql.Tx, ids []int64) (map[int64]*domain.User, error) {
+	locked, err := s.queryLockedUsers(ctx, tx, sqlBatchLockUsers, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+
+	users := make(map[int64]*domain.User, len(locked))
+	for _, user := range locked {
+		users[user.ID] = user
+	}
+	return users, nil
+}
+
+// queryLockedUsers runs a query locking users and scans them
+func (s *PostgresStore) queryLockedUsers(ctx context.Context, tx *sql.Tx, query string, args ...interface{}) ([]*domain.User, error) {
+	rows, err := tx.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, s.handleError(err, "failed to lock users")
+	}
+	defer rows.Close()
+
+	var users []*domain.User
+	for rows.Next() {
+		user, err := s.scanUser(rows)
+		if err != nil {
+			return nil, s.handleError(err, "failed to scan user row")
+		}
+		users = append(users, user)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, s.handleError(err, "error iterating user rows")
+	}
+
+	return users, nil
+}
+
+// execBatchUpdate applies the updates at the given indexes
+func (s *PostgresStore) execBatchUpdate(ctx context.Context, tx *sql.Tx, updates []domain.UserBatchUpdate, indexes []int) error {
+	ids := make([]int64, len(indexes))
+	emails := make([]string, len(indexes))
+	firstNames := make([]string, len(indexes))
+	lastNames := make([]string, len(indexes))
+	for j, i := range indexes {
+		ids[j] = updates[i].ID
+		emails[j], firstNames[j], lastNames[j] = updates[i].Email, updates[i].FirstName, updates[i].LastName
+	}
+
+	_, err := tx.ExecContext(ctx, sqlBatchUpdateUsers, pq.Array(ids), pq.Array(emails), pq.Array(firstNames), pq.Array(lastNames))
+	return err
+}
+
+// batchResults completes a batch once its transaction is over
+func (s *PostgresStore) batchResults(ctx context.Context, results []BatchResult, err error) ([]BatchResult, error) {
+	if err != nil && !errors.Is(err, errRollback) {
+		return nil, err
+	}
+
+	if slices.ContainsFunc(results, func(r BatchResult) bool { return r.Err == nil }) {
+		s.wrote(ctx)
+	}
+	return results, nil
+}
+
+// abortBatch fails the items of a rolled back batch that did not fail on their own
+func abortBatch(results []BatchResult) {
+	for i := range results {
+		if results[i].Err == nil {
+			results[i].Err = ErrBatchAborted
+		}
+	}
+}
+
+// duplicateEmail extracts the email of a unique violation of users_email_key, in lower case
+// Postgres reports it in the detail as: Key (lower(email::text))=(john@example.com) already exists.
+func duplicateEmail(err error) (string, bool) {
+	const pgDuplicateCode = "23505"
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) || pqErr.Code != pgDuplicateCode || pqErr.Constraint != "users_email_key" {
+		return "", false
+	}
+
+	_, email, ok := strings.Cut(pqErr.Detail, ")=(")
+	if !ok {
+		return "", false
+	}
+	return strings.CutSuffix(email, ") already exists.")
+}
@@ -0,0 +1,172 @@
+package storage
+
+import (
+	"context"
+	"database/sql/driver"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/huberts90/restful-api/internal/domain"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// expectBatchChanges expects the audit records and the outbox events of a batch made without audit information in the context
+func expectBatchChanges(mock sqlmock.Sqlmock, ids []int64, op domain.AuditOperation) {
+	mock.ExpectExec(sqlInsertAudits).
+		WithArgs("system", "", string(op), pq.Array(ids), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, int64(len(ids))))
+	mock.ExpectExec(sqlInsertEvents).
+		WithArgs(pq.Array(ids), string(auditEvents[op])).
+		WillReturnResult(sqlmock.NewResult(0, int64(len(ids))))
+}
+
+func TestCreateUsers(t *testing.T) {
+	f := setupTest(t)
+	defer f.cleanup()
+
+	users := []domain.UserCreate{
+		{Email: "a@example.com", FirstName: "Ann", LastName: "Lee"},
+		{Email: "b@example.com", FirstName: "Bob", LastName: "Ray"},
+		{Email: "a@example.com", FirstName: "Amy", LastName: "Lee"},
+	}
+	args := []driver.Value{
+		pq.Array([]string{"a@example.com", "b@example.com", "a@example.com"}),
+		pq.Array([]string{"Ann", "Bob", "Amy"}),
+		pq.Array([]string{"Lee", "Ray", "Lee"}),
+	}
+
+	tests := []struct {
+		name   string
+		atomic bool
+		setup  func(sqlmock.Sqlmock)
+		want   []BatchResult
+	}{
+		{
+			name:   "best effort skips duplicates",
+			atomic: false,
+			setup: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(sqlBatchCreateUsers).WithArgs(args...).
+					WillReturnRows(sqlmock.NewRows([]string{"id", "email"}).AddRow(7, "a@example.com").AddRow(8, "b@example.com"))
+				expectBatchChanges(mock, []int64{7, 8}, domain.AuditCreate)
+				mock.ExpectCommit()
+			},
+			want: []BatchResult{{ID: 7}, {ID: 8}, {Err: ErrDuplicateEmail}},
+		},
+		{
+			name:   "atomic rolls back on duplicates",
+			atomic: true,
+			setup: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(sqlBatchCreateUsers).WithArgs(args...).
+					WillReturnRows(sqlmock.NewRows([]string{"id", "email"}).AddRow(7, "a@example.com").AddRow(8, "b@example.com"))
+				mock.ExpectRollback()
+			},
+			want: []BatchResult{{ID: 7, Err: ErrBatchAborted}, {ID: 8, Err: ErrBatchAborted}, {Err: ErrDuplicateEmail}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.setup(f.mock)
+
+			got, err := f.store.CreateUsers(context.Background(), users, tt.atomic)
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+			assert.NoError(t, f.mock.ExpectationsWereMet(), "SQL expectations not met")
+		})
+	}
+}
+
+func TestUpdateUsers(t *testing.T) {
+	f := setupTest(t)
+	defer f.cleanup()
+
+	updates := []domain.UserBatchUpdate{
+		{ID: 1, UserUpdate: domain.UserUpdate{Email: "User2@example.com"}},
+		{ID: 2, UserUpdate: domain.UserUpdate{FirstName: "Janet"}},
+		{ID: 9, UserUpdate: domain.UserUpdate{LastName: "Gone"}},
+	}
+	// Emails are unique regardless of case, the conflict reports the email in lower case
+	conflict := &pq.Error{Code: "23505", Constraint: "users_email_key", Detail: "Key (lower(email::text))=(user2@example.com) already exists."}
+
+	tests := []struct {
+		name   string
+		atomic bool
+		setup  func(sqlmock.Sqlmock)
+		want   []BatchResult
+	}{
+		{
+			name:   "best effort retries without conflicting items",
+			atomic: false,
+			setup: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(sqlBatchLockUsers).WithArgs(pq.Array([]int64{1, 2, 9})).WillReturnRows(f.userRows)
+				mock.ExpectExec(sqlSavepoint).WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectExec(sqlBatchUpdateUsers).
+					WithArgs(pq.Array([]int64{1, 2}), pq.Array([]string{"User2@example.com", ""}), pq.Array([]string{"", "Janet"}), pq.Array([]string{"", ""})).
+					WillReturnError(conflict)
+				mock.ExpectExec(sqlRollbackSavepoint).WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectExec(sqlSavepoint).WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectExec(sqlBatchUpdateUsers).
+					WithArgs(pq.Array([]int64{2}), pq.Array([]string{""}), pq.Array([]string{"Janet"}), pq.Array([]string{""})).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				expectBatchChanges(mock, []int64{2}, domain.AuditUpdate)
+				mock.ExpectCommit()
+			},
+			want: []BatchResult{{ID: 1, Err: ErrDuplicateEmail}, {ID: 2}, {ID: 9, Err: ErrUserNotFound}},
+		},
+		{
+			name:   "atomic rolls back on missing users",
+			atomic: true,
+			setup: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(sqlBatchLockUsers).WithArgs(pq.Array([]int64{1, 2, 9})).
+					WillReturnRows(userRow(f.users[0], nil).AddRow(f.users[1].ID, f.users[1].Email, f.users[1].FirstName, f.users[1].LastName, f.now, f.now, nil))
+				mock.ExpectRollback()
+			},
+			want: []BatchResult{{ID: 1, Err: ErrBatchAborted}, {ID: 2, Err: ErrBatchAborted}, {ID: 9, Err: ErrUserNotFound}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.setup(f.mock)
+
+			got, err := f.store.UpdateUsers(context.Background(), updates, tt.atomic)
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+			assert.NoError(t, f.mock.ExpectationsWereMet(), "SQL expectations not met")
+		})
+	}
+}
+
+func TestDeleteUsers(t *testing.T) {
+	f := setupTest(t)
+	defer f.cleanup()
+
+	f.mock.ExpectBegin()
+	f.mock.ExpectQuery(sqlBatchDeleteUsers).WithArgs(pq.Array([]int64{1, 0, 9})).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "deleted_at"}).AddRow(1, f.now))
+	expectBatchChanges(f.mock, []int64{1}, domain.AuditDelete)
+	f.mock.ExpectCommit()
+
+	got, err := f.store.DeleteUsers(context.Background(), []int64{1, 0, 9}, false)
+
+	require.NoError(t, err)
+	assert.Equal(t, []BatchResult{{ID: 1}, {ID: 0, Err: ErrInvalidID}, {ID: 9, Err: ErrUserNotFound}}, got)
+	assert.NoError(t, f.mock.ExpectationsWereMet(), "SQL expectations not met")
+}
+
+func TestDuplicateEmail(t *testing.T) {
+	email, ok := duplicateEmail(&pq.Error{Code: "23505", Constraint: "users_email_key", Detail: "Key (lower(email::text))=(john@example.com) already exists."})
+	assert.True(t, ok)
+	assert.Equal(t, "john@example.com", email)
+
+	_, ok = duplicateEmail(&pq.Error{Code: "23505", Constraint: "other_key", Detail: "Key (email)=(john@example.com) already exists."})
+	assert.False(t, ok)
+}
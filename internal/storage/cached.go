@@ -0,0 +1,214 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/huberts90/restful-api/internal/cache"
+	"github.com/huberts90/restful-api/internal/domain"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"go.uber.org/zap"
+	"golang.org/x/sync/singleflight"
+)
+
+const (
+	// invalidateTimeout bounds the time spent dropping a cache entry after a write
+	invalidateTimeout = 200 * time.Millisecond
+	// loadTimeout bounds a read shared by concurrent misses, which outlives the callers that give up on it
+	loadTimeout = 1 * time.Second
+	// tombstoneTTL keeps the mark of a write until every fill that may have read before it has checked for it
+	tombstoneTTL = 2 * loadTimeout
+)
+
+var cacheRequestsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "storage_cache_requests_total",
+	Help: "Number of user cache lookups by result: hit, miss or error",
+}, []string{"result"})
+
+// CachedStore decorates a Storer with a read-through cache of users by ID
+// Concurrent misses for the same ID are collapsed into a single query, and
+// writes invalidate the entry once the underlying store is done. Writes also leave a tombstone in the cache,
+// so that a fill on any instance sharing it drops a user it may have read before the write
+type CachedStore struct {
+	Storer
+	cache  cache.Cache
+	ttl    time.Duration
+	group  singleflight.Group
+	logger *zap.Logger
+}
+
+// NewCachedStore creates a new CachedStore wrapping the given store
+func NewCachedStore(store Storer, c cache.Cache, ttl time.Duration, logger *zap.Logger) *CachedStore {
+	return &CachedStore{
+		Storer: store,
+		cache:  c,
+		ttl:    ttl,
+		logger: logger,
+	}
+}
+
+// GetUserByID implements the Storer interface
+// Only live users are cached, reads including deleted ones go straight to the store
+// Whole users are cached whatever the columns selected, so that a cached user serves every projection
+func (s *CachedStore) GetUserByID(ctx context.Context, id int64, opts ...ReadOption) (*domain.User, error) {
+	if NewReadOptions(opts...).IncludeDeleted {
+		return s.Storer.GetUserByID(ctx, id, opts...)
+	}
+
+	key := userCacheKey(id)
+
+	data, err := s.cache.Get(ctx, key)
+	switch {
+	case err == nil:
+		var user domain.User
+		if err := json.Unmarshal(data, &user); err == nil {
+			cacheRequestsTotal.WithLabelValues("hit").Inc()
+			return &user, nil
+		}
+		s.logger.Warn("failed to decode cached user", zap.Int64("id", id))
+	case errors.Is(err, cache.ErrMiss):
+		cacheRequestsTotal.WithLabelValues("miss").Inc()
+	default:
+		// @MENTION_ME: the cache is an optimisation, fall back to the store when it is down
+		cacheRequestsTotal.WithLabelValues("error").Inc()
+		s.logger.Warn("failed to read user from cache", zap.Error(err), zap.Int64("id", id))
+	}
+
+	// Each caller waits for the shared read as long as its own ctx allows, the read itself is not canceled with it
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case res := <-s.group.DoChan(key, func() (interface{}, error) { return s.fill(ctx, key, id) }):
+		if res.Err != nil {
+			return nil, res.Err
+		}
+		// Callers sharing the result must not be able to modify each other's copy
+		user := *res.Val.(*domain.User)
+		return &user, nil
+	}
+}
+
+// fill reads a user from the store and caches it
+// The user is read from the primary, a replica lagging behind a write would have its stale copy served
+// to every client for the whole TTL
+func (s *CachedStore) fill(ctx context.Context, key string, id int64) (*domain.User, error) {
+	ctx, cancel := context.WithTimeout(WithPrimaryReads(context.WithoutCancel(ctx)), loadTimeout)
+	defer cancel()
+
+	user, err := s.Storer.GetUserByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := json.Marshal(user)
+	if err == nil {
+		err = s.cache.Set(ctx, key, data, s.ttl)
+	}
+	if err != nil {
+		s.logger.Warn("failed to cache user", zap.Error(err), zap.Int64("id", id))
+	}
+
+	// A write invalidated the user while it was read, or the tombstone cannot be told, drop what may be stale
+	if _, err := s.cache.Get(ctx, tombstoneKey(id)); !errors.Is(err, cache.ErrMiss) {
+		if err := s.cache.Delete(ctx, key); err != nil {
+			s.logger.Warn("failed to drop cached user", zap.Error(err), zap.Int64("id", id))
+		}
+	}
+	return user, nil
+}
+
+// UpdateUser implements the Storer interface
+func (s *CachedStore) UpdateUser(ctx context.Context, id int64, user domain.UserUpdate) error {
+	err := s.Storer.UpdateUser(ctx, id, user)
+	s.invalidate(ctx, id)
+	return err
+}
+
+// DeleteUser implements the Storer interface
+func (s *CachedStore) DeleteUser(ctx context.Context, id int64) error {
+	err := s.Storer.DeleteUser(ctx, id)
+	s.invalidate(ctx, id)
+	return err
+}
+
+// RestoreUser implements the Storer interface
+func (s *CachedStore) RestoreUser(ctx context.Context, id int64) error {
+	err := s.Storer.RestoreUser(ctx, id)
+	s.invalidate(ctx, id)
+	return err
+}
+
+// ReplaceUser implements the Storer interface
+func (s *CachedStore) ReplaceUser(ctx context.Context, id int64, user domain.UserUpdate, active bool) error {
+	err := s.Storer.ReplaceUser(ctx, id, user, active)
+	s.invalidate(ctx, id)
+	return err
+}
+
+// UpdateUsers implements the Storer interface
+func (s *CachedStore) UpdateUsers(ctx context.Context, updates []domain.UserBatchUpdate, atomic bool) ([]BatchResult, error) {
+	results, err := s.Storer.UpdateUsers(ctx, updates, atomic)
+	for _, update := range updates {
+		s.invalidate(ctx, update.ID)
+	}
+	return results, err
+}
+
+// DeleteUsers implements the Storer interface
+func (s *CachedStore) DeleteUsers(ctx context.Context, ids []int64, atomic bool) ([]BatchResult, error) {
+	results, err := s.Storer.DeleteUsers(ctx, ids, atomic)
+	for _, id := range ids {
+		s.invalidate(ctx, id)
+	}
+	return results, err
+}
+
+// ImportUsers implements the Storer interface
+func (s *CachedStore) ImportUsers(ctx context.Context, users []domain.UserCreate, policy domain.DuplicatePolicy, dryRun bool) ([]ImportResult, error) {
+	results, err := s.Storer.ImportUsers(ctx, users, policy, dryRun)
+	for _, result := range results {
+		if result.Outcome == ImportUpdated {
+			s.invalidate(ctx, result.ID)
+		}
+	}
+	return results, err
+}
+
+// Close closes the cache and the underlying store
+func (s *CachedStore) Close() error {
+	if err := s.cache.Close(); err != nil {
+		s.logger.Warn("failed to close cache", zap.Error(err))
+	}
+	return s.Storer.Close()
+}
+
+// invalidate drops a cached user
+// It runs even when the write failed, as a failed commit may still have been applied
+func (s *CachedStore) invalidate(ctx context.Context, id int64) {
+	// The write may have used up the deadline of ctx, the entry must be dropped regardless
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), invalidateTimeout)
+	defer cancel()
+
+	// The tombstone is left before the entry is dropped: a fill caching the user before the drop is undone by
+	// it, and one caching it after finds the tombstone. Later reads must not join the fills in flight
+	key := userCacheKey(id)
+	s.group.Forget(key)
+	if err := s.cache.Set(ctx, tombstoneKey(id), []byte{1}, tombstoneTTL); err != nil {
+		s.logger.Error("failed to mark cached user as invalidated", zap.Error(err), zap.Int64("id", id))
+	}
+	if err := s.cache.Delete(ctx, key); err != nil {
+		s.logger.Error("failed to invalidate cached user", zap.Error(err), zap.Int64("id", id))
+	}
+}
+
+func userCacheKey(id int64) string {
+	return "user:" + strconv.FormatInt(id, 10)
+}
+
+func tombstoneKey(id int64) string {
+	return "user-invalidated:" + strconv.FormatInt(id, 10)
+}
@@ -0,0 +1,164 @@
+package storage_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/huberts90/restful-api/internal/cache"
+	"github.com/huberts90/restful-api/internal/domain"
+	"github.com/huberts90/restful-api/internal/storage"
+	storagemocks "github.com/huberts90/restful-api/internal/storage/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestCachedStore(t *testing.T) {
+	mockStore := storagemocks.NewMockStorer(t)
+	store := storage.NewCachedStore(mockStore, cache.NewLRU(10), time.Minute, zap.NewNop())
+	ctx := context.Background()
+
+	user := &domain.User{ID: 1, Email: "test@example.com", FirstName: "John", LastName: "Doe"}
+	mockStore.On("GetUserByID", mock.Anything, int64(1)).Return(user, nil).Once()
+
+	// The first read fills the cache, the second one is served from it
+	for i := 0; i < 2; i++ {
+		got, err := store.GetUserByID(ctx, 1)
+		require.NoError(t, err)
+		assert.Equal(t, user, got)
+	}
+
+	// Writes invalidate the entry
+	mockStore.On("UpdateUser", mock.Anything, int64(1), mock.Anything).Return(nil).Once()
+	require.NoError(t, store.UpdateUser(ctx, 1, domain.UserUpdate{FirstName: "Johnny"}))
+
+	mockStore.On("GetUserByID", mock.Anything, int64(1)).Return(user, nil).Once()
+	_, err := store.GetUserByID(ctx, 1)
+	require.NoError(t, err)
+
+	// Errors are not cached
+	mockStore.On("GetUserByID", mock.Anything, int64(2)).Return(nil, storage.ErrUserNotFound).Twice()
+	for i := 0; i < 2; i++ {
+		_, err := store.GetUserByID(ctx, 2)
+		assert.ErrorIs(t, err, storage.ErrUserNotFound)
+	}
+}
+
+func TestCachedStore_CollapsesConcurrentMisses(t *testing.T) {
+	mockStore := storagemocks.NewMockStorer(t)
+	store := storage.NewCachedStore(mockStore, cache.NewLRU(10), time.Minute, zap.NewNop())
+
+	user := &domain.User{ID: 1}
+	mockStore.On("GetUserByID", mock.Anything, int64(1)).
+		WaitUntil(time.After(30*time.Millisecond)).
+		Return(user, nil).
+		Once()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			got, err := store.GetUserByID(context.Background(), 1)
+			assert.NoError(t, err)
+			assert.Equal(t, user.ID, got.ID)
+		}()
+	}
+
+	wg.Wait()
+}
+
+func TestCachedStore_CanceledCallerDoesNotFailOthers(t *testing.T) {
+	mockStore := storagemocks.NewMockStorer(t)
+	store := storage.NewCachedStore(mockStore, cache.NewLRU(10), time.Minute, zap.NewNop())
+
+	user := &domain.User{ID: 1}
+	mockStore.On("GetUserByID", mock.MatchedBy(func(ctx context.Context) bool { return ctx.Err() == nil }), int64(1)).
+		WaitUntil(time.After(30*time.Millisecond)).
+		Return(user, nil).
+		Once()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Millisecond)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		_, err := store.GetUserByID(ctx, 1)
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+	}()
+	time.Sleep(time.Millisecond)
+
+	got, err := store.GetUserByID(context.Background(), 1)
+	require.NoError(t, err)
+	assert.Equal(t, user.ID, got.ID)
+	wg.Wait()
+}
+
+func TestCachedStore_FillRacingInvalidation(t *testing.T) {
+	mockStore := storagemocks.NewMockStorer(t)
+	store := storage.NewCachedStore(mockStore, cache.NewLRU(10), time.Minute, zap.NewNop())
+	ctx := context.Background()
+
+	// The read starts before the update and ends after it, so it returns the old user
+	updated := make(chan time.Time)
+	stale := &domain.User{ID: 1, FirstName: "John"}
+	fresh := &domain.User{ID: 1, FirstName: "Johnny"}
+	mockStore.On("GetUserByID", mock.Anything, int64(1)).WaitUntil(updated).Return(stale, nil).Once()
+	mockStore.On("UpdateUser", mock.Anything, int64(1), mock.Anything).Return(nil).Once()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_, err := store.GetUserByID(ctx, 1)
+		assert.NoError(t, err)
+	}()
+	time.Sleep(10 * time.Millisecond)
+	require.NoError(t, store.UpdateUser(ctx, 1, domain.UserUpdate{FirstName: "Johnny"}))
+	close(updated)
+	<-done
+
+	// The stale user is not served from the cache
+	mockStore.On("GetUserByID", mock.Anything, int64(1)).Return(fresh, nil).Once()
+	got, err := store.GetUserByID(ctx, 1)
+	require.NoError(t, err)
+	assert.Equal(t, "Johnny", got.FirstName)
+}
+
+func TestCachedStore_FillRacingInvalidationOnAnotherInstance(t *testing.T) {
+	mockStore := storagemocks.NewMockStorer(t)
+	shared := cache.NewLRU(10)
+	reader := storage.NewCachedStore(mockStore, shared, time.Minute, zap.NewNop())
+	writer := storage.NewCachedStore(mockStore, shared, time.Minute, zap.NewNop())
+	ctx := context.Background()
+
+	// The read starts on one instance before the update on another, and ends after it
+	updated := make(chan time.Time)
+	stale := &domain.User{ID: 1, FirstName: "John"}
+	fresh := &domain.User{ID: 1, FirstName: "Johnny"}
+	mockStore.On("GetUserByID", mock.Anything, int64(1)).WaitUntil(updated).Return(stale, nil).Once()
+	mockStore.On("UpdateUser", mock.Anything, int64(1), mock.Anything).Return(nil).Once()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_, err := reader.GetUserByID(ctx, 1)
+		assert.NoError(t, err)
+	}()
+	time.Sleep(10 * time.Millisecond)
+	require.NoError(t, writer.UpdateUser(ctx, 1, domain.UserUpdate{FirstName: "Johnny"}))
+	close(updated)
+	<-done
+
+	// Neither instance serves the stale user from the shared cache
+	mockStore.On("GetUserByID", mock.Anything, int64(1)).Return(fresh, nil).Twice()
+	for _, store := range []*storage.CachedStore{reader, writer} {
+		got, err := store.GetUserByID(ctx, 1)
+		require.NoError(t, err)
+		assert.Equal(t, "Johnny", got.FirstName)
+	}
+}
@@ -16,4 +16,23 @@ type PostgresConfig struct {
 	MaxIdleConns    int
 	ConnMaxLifetime time.Duration
 	ConnMaxIdleTime time.Duration
+
+	// Read replicas, reads are served by the primary when the list is empty
+	ReplicaDSNs          []string
+	ReplicaCheckInterval time.Duration
+	MaxReplicaLag        time.Duration // replicas lagging further behind stop receiving reads
+	ReadYourWritesWindow time.Duration // time a client reads from the primary after a write
+
+	// Soft deleted users are purged once they are older than the retention period
+	SoftDeleteRetention time.Duration
+	PurgeInterval       time.Duration // zero disables the purge job
+}
+
+// ResilienceConfig holds the configuration of the retries and the circuit breaker around the store
+type ResilienceConfig struct {
+	RetryAttempts    int
+	RetryBaseDelay   time.Duration
+	RetryMaxDelay    time.Duration
+	FailureThreshold int           // consecutive failures that open the circuit
+	OpenTimeout      time.Duration // time the circuit stays open before a probe is let through
 }
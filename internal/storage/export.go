@@ -0,0 +1,72 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/huberts90/restful-api/internal/domain"
+	"go.uber.org/zap"
+)
+
+// exportBatchSize is the number of users read at once by an export
+const exportBatchSize = 1000
+
+const (
+	sqlExportUsers            = `SELECT id, email, first_name, last_name, created_at, updated_at, deleted_at FROM users WHERE id > $1 AND deleted_at IS NULL ORDER BY id LIMIT $2`
+	sqlExportUsersWithDeleted = `SELECT id, email, first_name, last_name, created_at, updated_at, deleted_at FROM users WHERE id > $1 ORDER BY id LIMIT $2`
+)
+
+// ExportUsers calls fn with every user in ID order, as of when the export started
+// Users are read in batches after the last ID seen, within a repeatable-read transaction,
+// so that memory does not grow with the table and concurrent writes do not show up halfway
+// An error returned by fn stops the export and is returned as is
+func (s *PostgresStore) ExportUsers(ctx context.Context, fn func(domain.User) error, opts ...ReadOption) error {
+	query := sqlExportUsers
+	if NewReadOptions(opts...).IncludeDeleted {
+		query = sqlExportUsersWithDeleted
+	}
+
+	return s.withTxOptions(ctx, &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true}, func(tx *sql.Tx) error {
+		users := make([]domain.User, 0, exportBatchSize)
+		var lastID int64
+		for {
+			users = users[:0]
+			if err := s.exportBatch(ctx, tx, query, lastID, &users); err != nil {
+				return err
+			}
+
+			for _, user := range users {
+				if err := fn(user); err != nil {
+					return err
+				}
+			}
+
+			if len(users) < exportBatchSize {
+				return nil
+			}
+			lastID = users[len(users)-1].ID
+		}
+	})
+}
+
+// exportBatch appends the next batch of users after lastID to users
+func (s *PostgresStore) exportBatch(ctx context.Context, tx *sql.Tx, query string, lastID int64, users *[]domain.User) error {
+	rows, err := tx.QueryContext(ctx, query, lastID, exportBatchSize)
+	if err != nil {
+		return s.handleError(err, "failed to query users to export", zap.Int64("after_id", lastID))
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		user, err := s.scanUser(rows)
+		if err != nil {
+			return s.handleError(err, "failed to scan user row")
+		}
+		*users = append(*users, *user)
+	}
+
+	if err := rows.Err(); err != nil {
+		return s.handleError(err, "error iterating user rows")
+	}
+	return nil
+}
@@ -0,0 +1,58 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/huberts90/restful-api/internal/domain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExportUsers(t *testing.T) {
+	f := setupTest(t)
+	defer f.cleanup()
+
+	// A full batch is followed by a query for the users after its last ID
+	full := sqlmock.NewRows([]string{"id", "email", "first_name", "last_name", "created_at", "updated_at", "deleted_at"})
+	for i := 1; i <= exportBatchSize; i++ {
+		u := f.users[0]
+		full.AddRow(int64(i), u.Email, u.FirstName, u.LastName, u.CreatedAt, u.UpdatedAt, nil)
+	}
+
+	f.mock.ExpectBegin()
+	f.mock.ExpectQuery(sqlExportUsers).WithArgs(int64(0), exportBatchSize).WillReturnRows(full)
+	f.mock.ExpectQuery(sqlExportUsers).WithArgs(int64(exportBatchSize), exportBatchSize).WillReturnRows(userRow(f.users[1], nil))
+	f.mock.ExpectCommit()
+
+	var ids []int64
+	err := f.store.ExportUsers(context.Background(), func(user domain.User) error {
+		ids = append(ids, user.ID)
+		return nil
+	})
+
+	require.NoError(t, err)
+	assert.Len(t, ids, exportBatchSize+1)
+	assert.Equal(t, f.users[1].ID, ids[exportBatchSize])
+	assert.NoError(t, f.mock.ExpectationsWereMet(), "SQL expectations not met")
+}
+
+func TestExportUsers_CallbackError(t *testing.T) {
+	f := setupTest(t)
+	defer f.cleanup()
+
+	f.mock.ExpectBegin()
+	f.mock.ExpectQuery(sqlExportUsersWithDeleted).WithArgs(int64(0), exportBatchSize).
+		WillReturnRows(userRow(f.users[0], nil))
+	f.mock.ExpectRollback()
+
+	errStop := errors.New("client went away")
+	err := f.store.ExportUsers(context.Background(), func(domain.User) error {
+		return errStop
+	}, IncludeDeleted())
+
+	assert.ErrorIs(t, err, errStop)
+	assert.NoError(t, f.mock.ExpectationsWereMet(), "SQL expectations not met")
+}
@@ -0,0 +1,155 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+
+	"github.com/huberts90/restful-api/internal/domain"
+	"github.com/lib/pq"
+)
+
+const sqlLockUsersByEmail = `SELECT id, email, first_name, last_name, created_at, updated_at, deleted_at FROM users WHERE lower(email) = ANY($1) AND deleted_at IS NULL ORDER BY id FOR UPDATE`
+
+// ImportOutcome tells what an import did with a row
+type ImportOutcome string
+
+const (
+	ImportCreated ImportOutcome = "created"
+	ImportUpdated ImportOutcome = "updated"
+	ImportSkipped ImportOutcome = "skipped"
+)
+
+// ImportResult is the outcome of one row of an import, in the order of the input
+// Err is set instead of Outcome if the row was not imported
+type ImportResult struct {
+	ID      int64 // unknown for skipped rows
+	Outcome ImportOutcome
+	Err     error
+}
+
+// ImportUsers creates many users at once, the emails must be unique within users
+// Rows whose email belongs to a live user are skipped, fail with ErrDuplicateEmail or update the names of the user, as set by policy
+// A dry run reports what would happen and rolls everything back
+// The returned error is only set if the rows could not be processed at all
+func (s *PostgresStore) ImportUsers(ctx context.Context, users []domain.UserCreate, policy domain.DuplicatePolicy, dryRun bool) ([]ImportResult, error) {
+	results := make([]ImportResult, len(users))
+	if len(users) == 0 {
+		return results, nil
+	}
+
+	err := s.withTx(ctx, false, func(tx *sql.Tx) error {
+		toInsert := users
+		var updates []domain.UserBatchUpdate
+		var updated []int
+		var before map[string]*domain.User
+
+		if policy == domain.DuplicateUpsert {
+			var err error
+			if before, err = s.lockUsersByEmail(ctx, tx, users); err != nil {
+				return err
+			}
+
+			toInsert = make([]domain.UserCreate, 0, len(users))
+			for i, user := range users {
+				existing, ok := before[strings.ToLower(user.Email)]
+				if !ok {
+					toInsert = append(toInsert, user)
+					continue
+				}
+				updates = append(updates, domain.UserBatchUpdate{
+					ID:         existing.ID,
+					UserUpdate: domain.UserUpdate{FirstName: user.FirstName, LastName: user.LastName},
+				})
+				updated = append(updated, i)
+			}
+		}
+
+		if len(updates) > 0 {
+			indexes := make([]int, len(updates))
+			for j := range indexes {
+				indexes[j] = j
+			}
+			if err := s.execBatchUpdate(ctx, tx, updates, indexes); err != nil {
+				return s.handleError(err, "failed to update imported users")
+			}
+		}
+
+		inserted := map[string]int64{}
+		if len(toInsert) > 0 {
+			var err error
+			if inserted, err = s.insertUsers(ctx, tx, toInsert); err != nil {
+				return err
+			}
+		}
+
+		createdIDs := make([]int64, 0, len(inserted))
+		createChangeSet := make([]map[string]domain.FieldChange, 0, len(inserted))
+		for i, user := range users {
+			if id, ok := inserted[user.Email]; ok {
+				results[i] = ImportResult{ID: id, Outcome: ImportCreated}
+				createdIDs = append(createdIDs, id)
+				createChangeSet = append(createChangeSet, createChanges(user))
+				continue
+			}
+			if _, ok := before[strings.ToLower(user.Email)]; ok {
+				continue
+			}
+			// The email was taken by a user created concurrently if the policy is upsert
+			if policy == domain.DuplicateSkip {
+				results[i].Outcome = ImportSkipped
+			} else {
+				results[i].Err = ErrDuplicateEmail
+			}
+		}
+
+		updatedIDs := make([]int64, len(updated))
+		updateChangeSet := make([]map[string]domain.FieldChange, len(updated))
+		for j, i := range updated {
+			results[i] = ImportResult{ID: updates[j].ID, Outcome: ImportUpdated}
+			updatedIDs[j] = updates[j].ID
+			updateChangeSet[j] = updateChanges(before[strings.ToLower(users[i].Email)], updates[j].UserUpdate)
+		}
+
+		if err := s.recordChanges(ctx, tx, domain.AuditCreate, createdIDs, createChangeSet); err != nil {
+			return err
+		}
+		if err := s.recordChanges(ctx, tx, domain.AuditUpdate, updatedIDs, updateChangeSet); err != nil {
+			return err
+		}
+
+		if dryRun {
+			return errRollback
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, errRollback) {
+		return nil, err
+	}
+	if !dryRun {
+		s.wrote(ctx)
+	}
+
+	return results, nil
+}
+
+// lockUsersByEmail locks the live users with the emails of users and returns them by email in lower case
+// Emails are matched regardless of case, as their uniqueness is
+func (s *PostgresStore) lockUsersByEmail(ctx context.Context, tx *sql.Tx, users []domain.UserCreate) (map[string]*domain.User, error) {
+	emails := make([]string, len(users))
+	for i, user := range users {
+		emails[i] = strings.ToLower(user.Email)
+	}
+
+	locked, err := s.queryLockedUsers(ctx, tx, sqlLockUsersByEmail, pq.Array(emails))
+	if err != nil {
+		return nil, err
+	}
+
+	existing := make(map[string]*domain.User, len(locked))
+	for _, user := range locked {
+		existing[strings.ToLower(user.Email)] = user
+	}
+	return existing, nil
+}
@@ -0,0 +1,77 @@
+package storage
+
+import (
+	"context"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/huberts90/restful-api/internal/domain"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestImportUsers(t *testing.T) {
+	f := setupTest(t)
+	defer f.cleanup()
+
+	// The first row is the existing user1@example.com, emails match regardless of case
+	users := []domain.UserCreate{
+		{Email: "User1@example.com", FirstName: "Johnny", LastName: "Doe"},
+		{Email: "new@example.com", FirstName: "New", LastName: "User"},
+	}
+
+	tests := []struct {
+		name   string
+		policy domain.DuplicatePolicy
+		dryRun bool
+		setup  func(sqlmock.Sqlmock)
+		want   []ImportResult
+	}{
+		{
+			name:   "upsert updates existing users",
+			policy: domain.DuplicateUpsert,
+			setup: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(sqlLockUsersByEmail).WithArgs(pq.Array([]string{"user1@example.com", "new@example.com"})).
+					WillReturnRows(userRow(f.users[0], nil))
+				mock.ExpectExec(sqlBatchUpdateUsers).
+					WithArgs(pq.Array([]int64{1}), pq.Array([]string{""}), pq.Array([]string{"Johnny"}), pq.Array([]string{"Doe"})).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectQuery(sqlBatchCreateUsers).
+					WithArgs(pq.Array([]string{"new@example.com"}), pq.Array([]string{"New"}), pq.Array([]string{"User"})).
+					WillReturnRows(sqlmock.NewRows([]string{"id", "email"}).AddRow(3, "new@example.com"))
+				expectBatchChanges(mock, []int64{3}, domain.AuditCreate)
+				expectBatchChanges(mock, []int64{1}, domain.AuditUpdate)
+				mock.ExpectCommit()
+			},
+			want: []ImportResult{{ID: 1, Outcome: ImportUpdated}, {ID: 3, Outcome: ImportCreated}},
+		},
+		{
+			name:   "dry run skipping existing users rolls back",
+			policy: domain.DuplicateSkip,
+			dryRun: true,
+			setup: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(sqlBatchCreateUsers).
+					WithArgs(pq.Array([]string{"User1@example.com", "new@example.com"}), pq.Array([]string{"Johnny", "New"}), pq.Array([]string{"Doe", "User"})).
+					WillReturnRows(sqlmock.NewRows([]string{"id", "email"}).AddRow(3, "new@example.com"))
+				expectBatchChanges(mock, []int64{3}, domain.AuditCreate)
+				mock.ExpectRollback()
+			},
+			want: []ImportResult{{Outcome: ImportSkipped}, {ID: 3, Outcome: ImportCreated}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.setup(f.mock)
+
+			got, err := f.store.ImportUsers(context.Background(), users, tt.policy, tt.dryRun)
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+			assert.NoError(t, f.mock.ExpectationsWereMet(), "SQL expectations not met")
+		})
+	}
+}
@@ -0,0 +1,200 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/huberts90/restful-api/internal/domain"
+	"github.com/lib/pq"
+	"go.uber.org/zap"
+)
+
+var (
+	ErrJobNotFound  = errors.New("job not found")
+	ErrJobFinished  = errors.New("job already finished")
+	ErrJobLeaseLost = errors.New("job lease lost")
+)
+
+const (
+	jobColumns    = `id, type, payload, status, progress_done, progress_total, result, error, attempts, cancel_requested, created_at, started_at, finished_at`
+	sqlEnqueueJob = `INSERT INTO jobs (type, payload, created_at) VALUES ($1, $2, NOW()) RETURNING ` + jobColumns
+	sqlGetJob     = `SELECT ` + jobColumns + ` FROM jobs WHERE id = $1`
+	// Queued jobs are canceled right away, running ones are stopped by their worker at its next heartbeat
+	sqlCancelJob = `UPDATE jobs SET cancel_requested = TRUE, ` +
+		`status = CASE WHEN status = 'queued' THEN 'canceled' ELSE status END, ` +
+		`finished_at = CASE WHEN status = 'queued' THEN NOW() END ` +
+		`WHERE id = $1 AND status IN ('queued', 'running') RETURNING ` + jobColumns
+	// Running jobs whose lease expired were abandoned by a worker that died, they are claimed again
+	sqlClaimJob = `UPDATE jobs SET status = 'running', attempts = attempts + 1, locked_until = NOW() + make_interval(secs => $2), ` +
+		`started_at = COALESCE(started_at, NOW()) ` +
+		`WHERE id = (SELECT id FROM jobs WHERE type = ANY($1) AND (status = 'queued' OR (status = 'running' AND locked_until < NOW())) ` +
+		`ORDER BY id LIMIT 1 FOR UPDATE SKIP LOCKED) RETURNING ` + jobColumns
+	sqlHeartbeatJob = `UPDATE jobs SET progress_done = $3, progress_total = $4, locked_until = NOW() + make_interval(secs => $5) ` +
+		`WHERE id = $1 AND attempts = $2 AND status = 'running' RETURNING cancel_requested`
+	sqlFinishJob = `UPDATE jobs SET status = $3, progress_done = $4, progress_total = $5, result = $6, error = $7, finished_at = NOW(), locked_until = NULL ` +
+		`WHERE id = $1 AND attempts = $2 AND status = 'running'`
+	// A released job did not really make an attempt
+	sqlReleaseJob = `UPDATE jobs SET status = 'queued', attempts = attempts - 1, locked_until = NULL WHERE id = $1 AND attempts = $2 AND status = 'running'`
+	sqlPruneJobs  = `DELETE FROM jobs WHERE finished_at < $1 RETURNING id`
+)
+
+// EnqueueJob queues a job of the given type, the payload is marshalled to JSON
+func (s *PostgresStore) EnqueueJob(ctx context.Context, jobType string, payload interface{}) (*domain.Job, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, s.handleError(err, "failed to marshal job payload", zap.String("type", jobType))
+	}
+
+	job, err := scanJob(s.db.QueryRowContext(ctx, sqlEnqueueJob, jobType, string(data)))
+	if err != nil {
+		return nil, s.handleError(err, "failed to enqueue job", zap.String("type", jobType))
+	}
+	return job, nil
+}
+
+// GetJob retrieves a job by its ID
+func (s *PostgresStore) GetJob(ctx context.Context, id int64) (*domain.Job, error) {
+	if id <= 0 {
+		return nil, ErrInvalidID
+	}
+
+	job, err := scanJob(s.db.QueryRowContext(ctx, sqlGetJob, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrJobNotFound
+	}
+	if err != nil {
+		return nil, s.handleError(err, "failed to get job", zap.Int64("id", id))
+	}
+	return job, nil
+}
+
+// CancelJob requests a job to be canceled and returns it
+// It returns ErrJobFinished if the job already finished
+func (s *PostgresStore) CancelJob(ctx context.Context, id int64) (*domain.Job, error) {
+	if id <= 0 {
+		return nil, ErrInvalidID
+	}
+
+	job, err := scanJob(s.db.QueryRowContext(ctx, sqlCancelJob, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		if _, err := s.GetJob(ctx, id); err != nil {
+			return nil, err
+		}
+		return nil, ErrJobFinished
+	}
+	if err != nil {
+		return nil, s.handleError(err, "failed to cancel job", zap.Int64("id", id))
+	}
+	return job, nil
+}
+
+// ClaimJob claims the oldest job of one of the given types for the given lease, nil if there is none
+// The lease must be extended with HeartbeatJob, otherwise the job is claimed again once it expires
+func (s *PostgresStore) ClaimJob(ctx context.Context, types []string, lease time.Duration) (*domain.Job, error) {
+	job, err := scanJob(s.db.QueryRowContext(ctx, sqlClaimJob, pq.Array(types), lease.Seconds()))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, s.handleError(err, "failed to claim job")
+	}
+	return job, nil
+}
+
+// HeartbeatJob records the progress of a claimed job and extends its lease
+// It reports whether the job was asked to be canceled, and returns ErrJobLeaseLost if it was claimed again
+func (s *PostgresStore) HeartbeatJob(ctx context.Context, id int64, attempt int, progress domain.JobProgress, lease time.Duration) (bool, error) {
+	var cancelRequested bool
+	err := s.db.QueryRowContext(ctx, sqlHeartbeatJob, id, attempt, progress.Done, progress.Total, lease.Seconds()).Scan(&cancelRequested)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, ErrJobLeaseLost
+	}
+	if err != nil {
+		return false, s.handleError(err, "failed to record job heartbeat", zap.Int64("id", id))
+	}
+	return cancelRequested, nil
+}
+
+// FinishJob records how a claimed job ended, it returns ErrJobLeaseLost if it was claimed again
+func (s *PostgresStore) FinishJob(ctx context.Context, id int64, attempt int, outcome JobOutcome) error {
+	var result sql.NullString
+	if outcome.Result != nil {
+		data, err := json.Marshal(outcome.Result)
+		if err != nil {
+			return s.handleError(err, "failed to marshal job result", zap.Int64("id", id))
+		}
+		result = sql.NullString{String: string(data), Valid: true}
+	}
+	var jobError sql.NullString
+	if outcome.Error != "" {
+		jobError = sql.NullString{String: outcome.Error, Valid: true}
+	}
+
+	res, err := s.db.ExecContext(ctx, sqlFinishJob, id, attempt, string(outcome.Status), outcome.Progress.Done, outcome.Progress.Total, result, jobError)
+	if err != nil {
+		return s.handleError(err, "failed to finish job", zap.Int64("id", id))
+	}
+	return s.expectAffected(res, ErrJobLeaseLost)
+}
+
+// ReleaseJob puts a claimed job back in the queue, for a worker that stops before finishing it
+func (s *PostgresStore) ReleaseJob(ctx context.Context, id int64, attempt int) error {
+	res, err := s.db.ExecContext(ctx, sqlReleaseJob, id, attempt)
+	if err != nil {
+		return s.handleError(err, "failed to release job", zap.Int64("id", id))
+	}
+	return s.expectAffected(res, ErrJobLeaseLost)
+}
+
+// PruneJobs deletes the jobs finished before the given time and returns their IDs
+func (s *PostgresStore) PruneJobs(ctx context.Context, before time.Time) ([]int64, error) {
+	rows, err := s.db.QueryContext(ctx, sqlPruneJobs, before)
+	if err != nil {
+		return nil, s.handleError(err, "failed to prune jobs")
+	}
+	defer rows.Close()
+
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, s.handleError(err, "failed to scan pruned job row")
+		}
+		ids = append(ids, id)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, s.handleError(err, "error iterating pruned job rows")
+	}
+	return ids, nil
+}
+
+func scanJob(row interface{ Scan(...interface{}) error }) (*domain.Job, error) {
+	var job domain.Job
+	var status string
+	var payload, result []byte
+	var jobError sql.NullString
+	var startedAt, finishedAt sql.NullTime
+	err := row.Scan(&job.ID, &job.Type, &payload, &status, &job.Progress.Done, &job.Progress.Total, &result, &jobError,
+		&job.Attempts, &job.CancelRequested, &job.CreatedAt, &startedAt, &finishedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	job.Status = domain.JobStatus(status)
+	job.Payload = payload
+	if result != nil {
+		job.Result = result
+	}
+	job.Error = jobError.String
+	if startedAt.Valid {
+		job.StartedAt = &startedAt.Time
+	}
+	if finishedAt.Valid {
+		job.FinishedAt = &finishedAt.Time
+	}
+	return &job, nil
+}
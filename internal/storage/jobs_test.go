@@ -0,0 +1,155 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/huberts90/restful-api/internal/domain"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// jobRow returns the row of a job as selected with jobColumns
+func jobRow(id int64, status domain.JobStatus, attempts int, now time.Time) *sqlmock.Rows {
+	return sqlmock.NewRows([]string{"id", "type", "payload", "status", "progress_done", "progress_total", "result", "error",
+		"attempts", "cancel_requested", "created_at", "started_at", "finished_at"}).
+		AddRow(id, "users.export", []byte(`{"format":"csv"}`), string(status), 0, 0, nil, nil, attempts, false, now, nil, nil)
+}
+
+func TestEnqueueJob(t *testing.T) {
+	f := setupTest(t)
+	defer f.cleanup()
+
+	f.mock.ExpectQuery(sqlEnqueueJob).
+		WithArgs("users.export", `{"format":"csv"}`).
+		WillReturnRows(jobRow(3, domain.JobQueued, 0, f.now))
+
+	job, err := f.store.EnqueueJob(context.Background(), "users.export", map[string]string{"format": "csv"})
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), job.ID)
+	assert.Equal(t, domain.JobQueued, job.Status)
+	assert.JSONEq(t, `{"format":"csv"}`, string(job.Payload))
+	assert.Nil(t, job.Result)
+	assert.NoError(t, f.mock.ExpectationsWereMet())
+}
+
+func TestClaimJob(t *testing.T) {
+	f := setupTest(t)
+	defer f.cleanup()
+
+	t.Run("claims the oldest job", func(t *testing.T) {
+		f.mock.ExpectQuery(sqlClaimJob).
+			WithArgs(pq.Array([]string{"users.export"}), float64(30)).
+			WillReturnRows(jobRow(3, domain.JobRunning, 1, f.now))
+
+		job, err := f.store.ClaimJob(context.Background(), []string{"users.export"}, 30*time.Second)
+		require.NoError(t, err)
+		assert.Equal(t, int64(3), job.ID)
+		assert.Equal(t, 1, job.Attempts)
+		assert.NoError(t, f.mock.ExpectationsWereMet())
+	})
+
+	t.Run("empty queue", func(t *testing.T) {
+		f.mock.ExpectQuery(sqlClaimJob).
+			WithArgs(pq.Array([]string{"users.export"}), float64(30)).
+			WillReturnError(sql.ErrNoRows)
+
+		job, err := f.store.ClaimJob(context.Background(), []string{"users.export"}, 30*time.Second)
+		assert.NoError(t, err)
+		assert.Nil(t, job)
+		assert.NoError(t, f.mock.ExpectationsWereMet())
+	})
+}
+
+func TestHeartbeatJob(t *testing.T) {
+	f := setupTest(t)
+	defer f.cleanup()
+
+	progress := domain.JobProgress{Done: 10, Total: 100}
+
+	t.Run("cancel requested", func(t *testing.T) {
+		f.mock.ExpectQuery(sqlHeartbeatJob).
+			WithArgs(int64(3), 2, int64(10), int64(100), float64(30)).
+			WillReturnRows(sqlmock.NewRows([]string{"cancel_requested"}).AddRow(true))
+
+		cancelRequested, err := f.store.HeartbeatJob(context.Background(), 3, 2, progress, 30*time.Second)
+		assert.NoError(t, err)
+		assert.True(t, cancelRequested)
+		assert.NoError(t, f.mock.ExpectationsWereMet())
+	})
+
+	t.Run("claimed by another worker", func(t *testing.T) {
+		f.mock.ExpectQuery(sqlHeartbeatJob).
+			WithArgs(int64(3), 2, int64(10), int64(100), float64(30)).
+			WillReturnError(sql.ErrNoRows)
+
+		_, err := f.store.HeartbeatJob(context.Background(), 3, 2, progress, 30*time.Second)
+		assert.ErrorIs(t, err, ErrJobLeaseLost)
+		assert.NoError(t, f.mock.ExpectationsWereMet())
+	})
+}
+
+func TestFinishJob(t *testing.T) {
+	f := setupTest(t)
+	defer f.cleanup()
+
+	f.mock.ExpectExec(sqlFinishJob).
+		WithArgs(int64(3), 1, "succeeded", int64(2), int64(0), sql.NullString{String: `{"rows":2}`, Valid: true}, sql.NullString{}).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	err := f.store.FinishJob(context.Background(), 3, 1, JobOutcome{
+		Status:   domain.JobSucceeded,
+		Progress: domain.JobProgress{Done: 2},
+		Result:   map[string]int{"rows": 2},
+	})
+	assert.NoError(t, err)
+	assert.NoError(t, f.mock.ExpectationsWereMet())
+}
+
+func TestCancelJob(t *testing.T) {
+	f := setupTest(t)
+	defer f.cleanup()
+
+	t.Run("queued job", func(t *testing.T) {
+		f.mock.ExpectQuery(sqlCancelJob).WithArgs(int64(3)).WillReturnRows(jobRow(3, domain.JobCanceled, 0, f.now))
+
+		job, err := f.store.CancelJob(context.Background(), 3)
+		require.NoError(t, err)
+		assert.Equal(t, domain.JobCanceled, job.Status)
+		assert.NoError(t, f.mock.ExpectationsWereMet())
+	})
+
+	t.Run("finished job", func(t *testing.T) {
+		f.mock.ExpectQuery(sqlCancelJob).WithArgs(int64(3)).WillReturnError(sql.ErrNoRows)
+		f.mock.ExpectQuery(sqlGetJob).WithArgs(int64(3)).WillReturnRows(jobRow(3, domain.JobSucceeded, 1, f.now))
+
+		_, err := f.store.CancelJob(context.Background(), 3)
+		assert.ErrorIs(t, err, ErrJobFinished)
+		assert.NoError(t, f.mock.ExpectationsWereMet())
+	})
+
+	t.Run("unknown job", func(t *testing.T) {
+		f.mock.ExpectQuery(sqlCancelJob).WithArgs(int64(4)).WillReturnError(sql.ErrNoRows)
+		f.mock.ExpectQuery(sqlGetJob).WithArgs(int64(4)).WillReturnError(sql.ErrNoRows)
+
+		_, err := f.store.CancelJob(context.Background(), 4)
+		assert.ErrorIs(t, err, ErrJobNotFound)
+		assert.NoError(t, f.mock.ExpectationsWereMet())
+	})
+}
+
+func TestPruneJobs(t *testing.T) {
+	f := setupTest(t)
+	defer f.cleanup()
+
+	f.mock.ExpectQuery(sqlPruneJobs).WithArgs(f.now).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1).AddRow(2))
+
+	ids, err := f.store.PruneJobs(context.Background(), f.now)
+	assert.NoError(t, err)
+	assert.Equal(t, []int64{1, 2}, ids)
+	assert.NoError(t, f.mock.ExpectationsWereMet())
+}
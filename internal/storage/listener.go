@@ -0,0 +1,81 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/huberts90/restful-api/internal/domain"
+	"github.com/lib/pq"
+	"go.uber.org/zap"
+)
+
+// eventsChannel is the channel the outbox trigger notifies
+const eventsChannel = "user_events"
+
+// EventListener receives the events committed to the outbox through Postgres LISTEN/NOTIFY
+// Unlike the relay, every instance receives every event
+type EventListener struct {
+	listener *pq.Listener
+	logger   *zap.Logger
+}
+
+// NewEventListener creates a new EventListener with its own connection to the primary
+func NewEventListener(cfg PostgresConfig, logger *zap.Logger) (*EventListener, error) {
+	connStr := fmt.Sprintf(
+		"host=%s port=%d user=%s password=%s dbname=%s sslmode=%s",
+		cfg.Host, cfg.Port, cfg.User, cfg.Password, cfg.DBName, cfg.SSLMode,
+	)
+
+	listener := pq.NewListener(connStr, time.Second, time.Minute, func(ev pq.ListenerEventType, err error) {
+		if err != nil {
+			logger.Warn("event listener connection problem", zap.Int("event", int(ev)), zap.Error(err))
+		}
+	})
+	if err := listener.Listen(eventsChannel); err != nil {
+		_ = listener.Close()
+		return nil, fmt.Errorf("failed to listen for events: %w", err)
+	}
+
+	return &EventListener{listener: listener, logger: logger}, nil
+}
+
+// Run passes the received events to handle until ctx is done
+// Events notified while the connection was lost are missed, clients resume from the outbox
+func (l *EventListener) Run(ctx context.Context, handle func(context.Context, domain.Event) error) {
+	// Pinging regularly detects a dead connection that would otherwise go unnoticed
+	ticker := time.NewTicker(90 * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := l.listener.Ping(); err != nil {
+				l.logger.Warn("event listener ping failed", zap.Error(err))
+			}
+		case n := <-l.listener.Notify:
+			if n == nil {
+				// The connection was re-established
+				l.logger.Warn("event listener reconnected, events may have been missed")
+				continue
+			}
+
+			var event domain.Event
+			if err := json.Unmarshal([]byte(n.Extra), &event); err != nil {
+				l.logger.Error("failed to decode event notification", zap.Error(err))
+				continue
+			}
+			if err := handle(ctx, event); err != nil {
+				l.logger.Error("failed to handle event notification", zap.Error(err), zap.Int64("id", event.ID))
+			}
+		}
+	}
+}
+
+// Close closes the connection of the listener
+func (l *EventListener) Close() error {
+	return l.listener.Close()
+}
@@ -0,0 +1,115 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/huberts90/restful-api/internal/domain"
+	"github.com/lib/pq"
+	"go.uber.org/zap"
+)
+
+const (
+	sqlGetUsersByIDs             = `SELECT id, email, first_name, last_name, created_at, updated_at, deleted_at FROM users WHERE id = ANY($1) AND deleted_at IS NULL ORDER BY id`
+	sqlGetUsersByIDsWithDeleted  = `SELECT id, email, first_name, last_name, created_at, updated_at, deleted_at FROM users WHERE id = ANY($1) ORDER BY id`
+	sqlListUsersAfter            = `SELECT id, email, first_name, last_name, created_at, updated_at, deleted_at FROM users WHERE id > $1 AND deleted_at IS NULL ORDER BY id LIMIT $2`
+	sqlListUsersAfterWithDeleted = `SELECT id, email, first_name, last_name, created_at, updated_at, deleted_at FROM users WHERE id > $1 ORDER BY id LIMIT $2`
+)
+
+// UserPage is a page of users read after a cursor
+type UserPage struct {
+	Users      []domain.User
+	TotalCount int
+	HasMore    bool // whether more users follow the page
+}
+
+// GetUsersByIDs retrieves the users with the given IDs in a single query, in ID order
+// Users that do not exist are left out rather than reported as an error
+func (s *PostgresStore) GetUsersByIDs(ctx context.Context, ids []int64, opts ...ReadOption) ([]domain.User, error) {
+	for _, id := range ids {
+		if id <= 0 {
+			return nil, ErrInvalidID
+		}
+	}
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	query := sqlGetUsersByIDs
+	if NewReadOptions(opts...).IncludeDeleted {
+		query = sqlGetUsersByIDsWithDeleted
+	}
+
+	rows, err := s.reader(ctx).QueryContext(ctx, query, pq.Array(ids))
+	if err != nil {
+		return nil, s.handleError(err, "failed to get users by IDs", zap.Int("count", len(ids)))
+	}
+	defer rows.Close()
+
+	users := make([]domain.User, 0, len(ids))
+	for rows.Next() {
+		user, err := s.scanUser(rows)
+		if err != nil {
+			return nil, s.handleError(err, "failed to scan user row")
+		}
+		users = append(users, *user)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, s.handleError(err, "error iterating user rows")
+	}
+	return users, nil
+}
+
+// ListUsersAfter retrieves up to limit users with an ID greater than afterID, in ID order
+// Unlike pages by number, pages after an ID do not shift when users are created or deleted meanwhile
+func (s *PostgresStore) ListUsersAfter(ctx context.Context, afterID int64, limit int, opts ...ReadOption) (*UserPage, error) {
+	if afterID < 0 {
+		return nil, ErrInvalidID
+	}
+	if limit < 1 || limit > 100 {
+		return nil, ErrInvalidPageSize
+	}
+
+	countQuery, listQuery := sqlCountUsers, sqlListUsersAfter
+	if NewReadOptions(opts...).IncludeDeleted {
+		countQuery, listQuery = sqlCountUsersWithDeleted, sqlListUsersAfterWithDeleted
+	}
+
+	page := &UserPage{}
+	err := s.withTx(ctx, true, func(tx *sql.Tx) error {
+		if err := tx.QueryRowContext(ctx, countQuery).Scan(&page.TotalCount); err != nil {
+			return s.handleError(err, "failed to count users")
+		}
+
+		// One more user than asked for tells whether the page is the last one
+		rows, err := tx.QueryContext(ctx, listQuery, afterID, limit+1)
+		if err != nil {
+			return s.handleError(err, "failed to query users", zap.Int64("after_id", afterID))
+		}
+		defer rows.Close()
+
+		page.Users = make([]domain.User, 0, limit+1)
+		for rows.Next() {
+			user, err := s.scanUser(rows)
+			if err != nil {
+				return s.handleError(err, "failed to scan user row")
+			}
+			page.Users = append(page.Users, *user)
+		}
+
+		if err := rows.Err(); err != nil {
+			return s.handleError(err, "error iterating user rows")
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(page.Users) > limit {
+		page.Users = page.Users[:limit]
+		page.HasMore = true
+	}
+	return page, nil
+}
@@ -0,0 +1,73 @@
+package storage
+
+import (
+	"context"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetUsersByIDs(t *testing.T) {
+	f := setupTest(t)
+	defer f.cleanup()
+
+	// A user that does not exist is left out
+	rows := userRow(f.users[0], nil)
+	f.mock.ExpectQuery(sqlGetUsersByIDs).
+		WithArgs(pq.Array([]int64{f.users[0].ID, 404})).
+		WillReturnRows(rows)
+
+	users, err := f.store.GetUsersByIDs(context.Background(), []int64{f.users[0].ID, 404})
+
+	require.NoError(t, err)
+	require.Len(t, users, 1)
+	assert.Equal(t, f.users[0].Email, users[0].Email)
+	assert.NoError(t, f.mock.ExpectationsWereMet(), "SQL expectations not met")
+}
+
+func TestGetUsersByIDs_InvalidID(t *testing.T) {
+	f := setupTest(t)
+	defer f.cleanup()
+
+	_, err := f.store.GetUsersByIDs(context.Background(), []int64{1, 0})
+
+	assert.ErrorIs(t, err, ErrInvalidID)
+}
+
+func TestListUsersAfter(t *testing.T) {
+	f := setupTest(t)
+	defer f.cleanup()
+
+	rows := sqlmock.NewRows([]string{"id", "email", "first_name", "last_name", "created_at", "updated_at", "deleted_at"})
+	for _, u := range f.users {
+		rows.AddRow(u.ID, u.Email, u.FirstName, u.LastName, u.CreatedAt, u.UpdatedAt, f.now)
+	}
+
+	f.mock.ExpectBegin()
+	f.mock.ExpectQuery(sqlCountUsersWithDeleted).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(5))
+	f.mock.ExpectQuery(sqlListUsersAfterWithDeleted).WithArgs(int64(0), len(f.users)).WillReturnRows(rows)
+	f.mock.ExpectCommit()
+
+	// The extra user read tells that the page is not the last one
+	page, err := f.store.ListUsersAfter(context.Background(), 0, len(f.users)-1, IncludeDeleted())
+
+	require.NoError(t, err)
+	assert.Len(t, page.Users, len(f.users)-1)
+	assert.Equal(t, 5, page.TotalCount)
+	assert.True(t, page.HasMore)
+	assert.NoError(t, f.mock.ExpectationsWereMet(), "SQL expectations not met")
+}
+
+func TestListUsersAfter_InvalidArguments(t *testing.T) {
+	f := setupTest(t)
+	defer f.cleanup()
+
+	_, err := f.store.ListUsersAfter(context.Background(), -1, 10)
+	assert.ErrorIs(t, err, ErrInvalidID)
+
+	_, err = f.store.ListUsersAfter(context.Background(), 0, 101)
+	assert.ErrorIs(t, err, ErrInvalidPageSize)
+}
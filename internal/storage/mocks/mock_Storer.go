@@ -4,8 +4,10 @@ package storagemocks
 
 import (
 	context "context"
+	time "time"
 
 	domain "github.com/huberts90/restful-api/internal/domain"
+	storage "github.com/huberts90/restful-api/internal/storage"
 	mock "github.com/stretchr/testify/mock"
 )
 
@@ -124,6 +126,66 @@ func (_c *MockStorer_CreateUser_Call) RunAndReturn(run func(context.Context, dom
 	return _c
 }
 
+// CreateUsers provides a mock function with given fields: ctx, users, atomic
+func (_m *MockStorer) CreateUsers(ctx context.Context, users []domain.UserCreate, atomic bool) ([]storage.BatchResult, error) {
+	ret := _m.Called(ctx, users, atomic)
+
+	if len(ret) == 0 {
+		panic("no return value specified for CreateUsers")
+	}
+
+	var r0 []storage.BatchResult
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, []domain.UserCreate, bool) ([]storage.BatchResult, error)); ok {
+		return rf(ctx, users, atomic)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, []domain.UserCreate, bool) []storage.BatchResult); ok {
+		r0 = rf(ctx, users, atomic)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]storage.BatchResult)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, []domain.UserCreate, bool) error); ok {
+		r1 = rf(ctx, users, atomic)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// MockStorer_CreateUsers_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'CreateUsers'
+type MockStorer_CreateUsers_Call struct {
+	*mock.Call
+}
+
+// CreateUsers is a helper method to define mock.On call
+//   - ctx context.Context
+//   - users []domain.UserCreate
+//   - atomic bool
+func (_e *MockStorer_Expecter) CreateUsers(ctx interface{}, users interface{}, atomic interface{}) *MockStorer_CreateUsers_Call {
+	return &MockStorer_CreateUsers_Call{Call: _e.mock.On("CreateUsers", ctx, users, atomic)}
+}
+
+func (_c *MockStorer_CreateUsers_Call) Run(run func(ctx context.Context, users []domain.UserCreate, atomic bool)) *MockStorer_CreateUsers_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].([]domain.UserCreate), args[2].(bool))
+	})
+	return _c
+}
+
+func (_c *MockStorer_CreateUsers_Call) Return(_a0 []storage.BatchResult, _a1 error) *MockStorer_CreateUsers_Call {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
+func (_c *MockStorer_CreateUsers_Call) RunAndReturn(run func(context.Context, []domain.UserCreate, bool) ([]storage.BatchResult, error)) *MockStorer_CreateUsers_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
 // DeleteUser provides a mock function with given fields: ctx, id
 func (_m *MockStorer) DeleteUser(ctx context.Context, id int64) error {
 	ret := _m.Called(ctx, id)
@@ -171,9 +233,138 @@ func (_c *MockStorer_DeleteUser_Call) RunAndReturn(run func(context.Context, int
 	return _c
 }
 
-// GetUserByID provides a mock function with given fields: ctx, id
-func (_m *MockStorer) GetUserByID(ctx context.Context, id int64) (*domain.User, error) {
-	ret := _m.Called(ctx, id)
+// DeleteUsers provides a mock function with given fields: ctx, ids, atomic
+func (_m *MockStorer) DeleteUsers(ctx context.Context, ids []int64, atomic bool) ([]storage.BatchResult, error) {
+	ret := _m.Called(ctx, ids, atomic)
+
+	if len(ret) == 0 {
+		panic("no return value specified for DeleteUsers")
+	}
+
+	var r0 []storage.BatchResult
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, []int64, bool) ([]storage.BatchResult, error)); ok {
+		return rf(ctx, ids, atomic)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, []int64, bool) []storage.BatchResult); ok {
+		r0 = rf(ctx, ids, atomic)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]storage.BatchResult)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, []int64, bool) error); ok {
+		r1 = rf(ctx, ids, atomic)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// MockStorer_DeleteUsers_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'DeleteUsers'
+type MockStorer_DeleteUsers_Call struct {
+	*mock.Call
+}
+
+// DeleteUsers is a helper method to define mock.On call
+//   - ctx context.Context
+//   - ids []int64
+//   - atomic bool
+func (_e *MockStorer_Expecter) DeleteUsers(ctx interface{}, ids interface{}, atomic interface{}) *MockStorer_DeleteUsers_Call {
+	return &MockStorer_DeleteUsers_Call{Call: _e.mock.On("DeleteUsers", ctx, ids, atomic)}
+}
+
+func (_c *MockStorer_DeleteUsers_Call) Run(run func(ctx context.Context, ids []int64, atomic bool)) *MockStorer_DeleteUsers_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].([]int64), args[2].(bool))
+	})
+	return _c
+}
+
+func (_c *MockStorer_DeleteUsers_Call) Return(_a0 []storage.BatchResult, _a1 error) *MockStorer_DeleteUsers_Call {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
+func (_c *MockStorer_DeleteUsers_Call) RunAndReturn(run func(context.Context, []int64, bool) ([]storage.BatchResult, error)) *MockStorer_DeleteUsers_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// ExportUsers provides a mock function with given fields: ctx, fn, opts
+func (_m *MockStorer) ExportUsers(ctx context.Context, fn func(domain.User) error, opts ...storage.ReadOption) error {
+	_va := make([]interface{}, len(opts))
+	for _i := range opts {
+		_va[_i] = opts[_i]
+	}
+	var _ca []interface{}
+	_ca = append(_ca, ctx, fn)
+	_ca = append(_ca, _va...)
+	ret := _m.Called(_ca...)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ExportUsers")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, func(domain.User) error, ...storage.ReadOption) error); ok {
+		r0 = rf(ctx, fn, opts...)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// MockStorer_ExportUsers_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'ExportUsers'
+type MockStorer_ExportUsers_Call struct {
+	*mock.Call
+}
+
+// ExportUsers is a helper method to define mock.On call
+//   - ctx context.Context
+//   - fn func(domain.User) error
+//   - opts ...storage.ReadOption
+func (_e *MockStorer_Expecter) ExportUsers(ctx interface{}, fn interface{}, opts ...interface{}) *MockStorer_ExportUsers_Call {
+	return &MockStorer_ExportUsers_Call{Call: _e.mock.On("ExportUsers",
+		append([]interface{}{ctx, fn}, opts...)...)}
+}
+
+func (_c *MockStorer_ExportUsers_Call) Run(run func(ctx context.Context, fn func(domain.User) error, opts ...storage.ReadOption)) *MockStorer_ExportUsers_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		variadicArgs := make([]storage.ReadOption, len(args)-2)
+		for i, a := range args[2:] {
+			if a != nil {
+				variadicArgs[i] = a.(storage.ReadOption)
+			}
+		}
+		run(args[0].(context.Context), args[1].(func(domain.User) error), variadicArgs...)
+	})
+	return _c
+}
+
+func (_c *MockStorer_ExportUsers_Call) Return(_a0 error) *MockStorer_ExportUsers_Call {
+	_c.Call.Return(_a0)
+	return _c
+}
+
+func (_c *MockStorer_ExportUsers_Call) RunAndReturn(run func(context.Context, func(domain.User) error, ...storage.ReadOption) error) *MockStorer_ExportUsers_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// GetUserByID provides a mock function with given fields: ctx, id, opts
+func (_m *MockStorer) GetUserByID(ctx context.Context, id int64, opts ...storage.ReadOption) (*domain.User, error) {
+	_va := make([]interface{}, len(opts))
+	for _i := range opts {
+		_va[_i] = opts[_i]
+	}
+	var _ca []interface{}
+	_ca = append(_ca, ctx, id)
+	_ca = append(_ca, _va...)
+	ret := _m.Called(_ca...)
 
 	if len(ret) == 0 {
 		panic("no return value specified for GetUserByID")
@@ -181,19 +372,19 @@ func (_m *MockStorer) GetUserByID(ctx context.Context, id int64) (*domain.User,
 
 	var r0 *domain.User
 	var r1 error
-	if rf, ok := ret.Get(0).(func(context.Context, int64) (*domain.User, error)); ok {
-		return rf(ctx, id)
+	if rf, ok := ret.Get(0).(func(context.Context, int64, ...storage.ReadOption) (*domain.User, error)); ok {
+		return rf(ctx, id, opts...)
 	}
-	if rf, ok := ret.Get(0).(func(context.Context, int64) *domain.User); ok {
-		r0 = rf(ctx, id)
+	if rf, ok := ret.Get(0).(func(context.Context, int64, ...storage.ReadOption) *domain.User); ok {
+		r0 = rf(ctx, id, opts...)
 	} else {
 		if ret.Get(0) != nil {
 			r0 = ret.Get(0).(*domain.User)
 		}
 	}
 
-	if rf, ok := ret.Get(1).(func(context.Context, int64) error); ok {
-		r1 = rf(ctx, id)
+	if rf, ok := ret.Get(1).(func(context.Context, int64, ...storage.ReadOption) error); ok {
+		r1 = rf(ctx, id, opts...)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -209,13 +400,21 @@ type MockStorer_GetUserByID_Call struct {
 // GetUserByID is a helper method to define mock.On call
 //   - ctx context.Context
 //   - id int64
-func (_e *MockStorer_Expecter) GetUserByID(ctx interface{}, id interface{}) *MockStorer_GetUserByID_Call {
-	return &MockStorer_GetUserByID_Call{Call: _e.mock.On("GetUserByID", ctx, id)}
+//   - opts ...storage.ReadOption
+func (_e *MockStorer_Expecter) GetUserByID(ctx interface{}, id interface{}, opts ...interface{}) *MockStorer_GetUserByID_Call {
+	return &MockStorer_GetUserByID_Call{Call: _e.mock.On("GetUserByID",
+		append([]interface{}{ctx, id}, opts...)...)}
 }
 
-func (_c *MockStorer_GetUserByID_Call) Run(run func(ctx context.Context, id int64)) *MockStorer_GetUserByID_Call {
+func (_c *MockStorer_GetUserByID_Call) Run(run func(ctx context.Context, id int64, opts ...storage.ReadOption)) *MockStorer_GetUserByID_Call {
 	_c.Call.Run(func(args mock.Arguments) {
-		run(args[0].(context.Context), args[1].(int64))
+		variadicArgs := make([]storage.ReadOption, len(args)-2)
+		for i, a := range args[2:] {
+			if a != nil {
+				variadicArgs[i] = a.(storage.ReadOption)
+			}
+		}
+		run(args[0].(context.Context), args[1].(int64), variadicArgs...)
 	})
 	return _c
 }
@@ -225,14 +424,224 @@ func (_c *MockStorer_GetUserByID_Call) Return(_a0 *domain.User, _a1 error) *Mock
 	return _c
 }
 
-func (_c *MockStorer_GetUserByID_Call) RunAndReturn(run func(context.Context, int64) (*domain.User, error)) *MockStorer_GetUserByID_Call {
+func (_c *MockStorer_GetUserByID_Call) RunAndReturn(run func(context.Context, int64, ...storage.ReadOption) (*domain.User, error)) *MockStorer_GetUserByID_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// GetUsersByIDs provides a mock function with given fields: ctx, ids, opts
+func (_m *MockStorer) GetUsersByIDs(ctx context.Context, ids []int64, opts ...storage.ReadOption) ([]domain.User, error) {
+	_va := make([]interface{}, len(opts))
+	for _i := range opts {
+		_va[_i] = opts[_i]
+	}
+	var _ca []interface{}
+	_ca = append(_ca, ctx, ids)
+	_ca = append(_ca, _va...)
+	ret := _m.Called(_ca...)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetUsersByIDs")
+	}
+
+	var r0 []domain.User
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, []int64, ...storage.ReadOption) ([]domain.User, error)); ok {
+		return rf(ctx, ids, opts...)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, []int64, ...storage.ReadOption) []domain.User); ok {
+		r0 = rf(ctx, ids, opts...)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]domain.User)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, []int64, ...storage.ReadOption) error); ok {
+		r1 = rf(ctx, ids, opts...)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// MockStorer_GetUsersByIDs_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'GetUsersByIDs'
+type MockStorer_GetUsersByIDs_Call struct {
+	*mock.Call
+}
+
+// GetUsersByIDs is a helper method to define mock.On call
+//   - ctx context.Context
+//   - ids []int64
+//   - opts ...storage.ReadOption
+func (_e *MockStorer_Expecter) GetUsersByIDs(ctx interface{}, ids interface{}, opts ...interface{}) *MockStorer_GetUsersByIDs_Call {
+	return &MockStorer_GetUsersByIDs_Call{Call: _e.mock.On("GetUsersByIDs",
+		append([]interface{}{ctx, ids}, opts...)...)}
+}
+
+func (_c *MockStorer_GetUsersByIDs_Call) Run(run func(ctx context.Context, ids []int64, opts ...storage.ReadOption)) *MockStorer_GetUsersByIDs_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		variadicArgs := make([]storage.ReadOption, len(args)-2)
+		for i, a := range args[2:] {
+			if a != nil {
+				variadicArgs[i] = a.(storage.ReadOption)
+			}
+		}
+		run(args[0].(context.Context), args[1].([]int64), variadicArgs...)
+	})
+	return _c
+}
+
+func (_c *MockStorer_GetUsersByIDs_Call) Return(_a0 []domain.User, _a1 error) *MockStorer_GetUsersByIDs_Call {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
+func (_c *MockStorer_GetUsersByIDs_Call) RunAndReturn(run func(context.Context, []int64, ...storage.ReadOption) ([]domain.User, error)) *MockStorer_GetUsersByIDs_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// ImportUsers provides a mock function with given fields: ctx, users, policy, dryRun
+func (_m *MockStorer) ImportUsers(ctx context.Context, users []domain.UserCreate, policy domain.DuplicatePolicy, dryRun bool) ([]storage.ImportResult, error) {
+	ret := _m.Called(ctx, users, policy, dryRun)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ImportUsers")
+	}
+
+	var r0 []storage.ImportResult
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, []domain.UserCreate, domain.DuplicatePolicy, bool) ([]storage.ImportResult, error)); ok {
+		return rf(ctx, users, policy, dryRun)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, []domain.UserCreate, domain.DuplicatePolicy, bool) []storage.ImportResult); ok {
+		r0 = rf(ctx, users, policy, dryRun)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]storage.ImportResult)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, []domain.UserCreate, domain.DuplicatePolicy, bool) error); ok {
+		r1 = rf(ctx, users, policy, dryRun)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// MockStorer_ImportUsers_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'ImportUsers'
+type MockStorer_ImportUsers_Call struct {
+	*mock.Call
+}
+
+// ImportUsers is a helper method to define mock.On call
+//   - ctx context.Context
+//   - users []domain.UserCreate
+//   - policy domain.DuplicatePolicy
+//   - dryRun bool
+func (_e *MockStorer_Expecter) ImportUsers(ctx interface{}, users interface{}, policy interface{}, dryRun interface{}) *MockStorer_ImportUsers_Call {
+	return &MockStorer_ImportUsers_Call{Call: _e.mock.On("ImportUsers", ctx, users, policy, dryRun)}
+}
+
+func (_c *MockStorer_ImportUsers_Call) Run(run func(ctx context.Context, users []domain.UserCreate, policy domain.DuplicatePolicy, dryRun bool)) *MockStorer_ImportUsers_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].([]domain.UserCreate), args[2].(domain.DuplicatePolicy), args[3].(bool))
+	})
+	return _c
+}
+
+func (_c *MockStorer_ImportUsers_Call) Return(_a0 []storage.ImportResult, _a1 error) *MockStorer_ImportUsers_Call {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
+func (_c *MockStorer_ImportUsers_Call) RunAndReturn(run func(context.Context, []domain.UserCreate, domain.DuplicatePolicy, bool) ([]storage.ImportResult, error)) *MockStorer_ImportUsers_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// ListUserHistory provides a mock function with given fields: ctx, userID, page, pageSize
+func (_m *MockStorer) ListUserHistory(ctx context.Context, userID int64, page int, pageSize int) ([]domain.AuditRecord, int, error) {
+	ret := _m.Called(ctx, userID, page, pageSize)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ListUserHistory")
+	}
+
+	var r0 []domain.AuditRecord
+	var r1 int
+	var r2 error
+	if rf, ok := ret.Get(0).(func(context.Context, int64, int, int) ([]domain.AuditRecord, int, error)); ok {
+		return rf(ctx, userID, page, pageSize)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, int64, int, int) []domain.AuditRecord); ok {
+		r0 = rf(ctx, userID, page, pageSize)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]domain.AuditRecord)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, int64, int, int) int); ok {
+		r1 = rf(ctx, userID, page, pageSize)
+	} else {
+		r1 = ret.Get(1).(int)
+	}
+
+	if rf, ok := ret.Get(2).(func(context.Context, int64, int, int) error); ok {
+		r2 = rf(ctx, userID, page, pageSize)
+	} else {
+		r2 = ret.Error(2)
+	}
+
+	return r0, r1, r2
+}
+
+// MockStorer_ListUserHistory_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'ListUserHistory'
+type MockStorer_ListUserHistory_Call struct {
+	*mock.Call
+}
+
+// ListUserHistory is a helper method to define mock.On call
+//   - ctx context.Context
+//   - userID int64
+//   - page int
+//   - pageSize int
+func (_e *MockStorer_Expecter) ListUserHistory(ctx interface{}, userID interface{}, page interface{}, pageSize interface{}) *MockStorer_ListUserHistory_Call {
+	return &MockStorer_ListUserHistory_Call{Call: _e.mock.On("ListUserHistory", ctx, userID, page, pageSize)}
+}
+
+func (_c *MockStorer_ListUserHistory_Call) Run(run func(ctx context.Context, userID int64, page int, pageSize int)) *MockStorer_ListUserHistory_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(int64), args[2].(int), args[3].(int))
+	})
+	return _c
+}
+
+func (_c *MockStorer_ListUserHistory_Call) Return(_a0 []domain.AuditRecord, _a1 int, _a2 error) *MockStorer_ListUserHistory_Call {
+	_c.Call.Return(_a0, _a1, _a2)
+	return _c
+}
+
+func (_c *MockStorer_ListUserHistory_Call) RunAndReturn(run func(context.Context, int64, int, int) ([]domain.AuditRecord, int, error)) *MockStorer_ListUserHistory_Call {
 	_c.Call.Return(run)
 	return _c
 }
 
-// ListUsers provides a mock function with given fields: ctx, page, pageSize
-func (_m *MockStorer) ListUsers(ctx context.Context, page int, pageSize int) ([]domain.User, int, error) {
-	ret := _m.Called(ctx, page, pageSize)
+// ListUsers provides a mock function with given fields: ctx, page, pageSize, opts
+func (_m *MockStorer) ListUsers(ctx context.Context, page int, pageSize int, opts ...storage.ReadOption) ([]domain.User, int, error) {
+	_va := make([]interface{}, len(opts))
+	for _i := range opts {
+		_va[_i] = opts[_i]
+	}
+	var _ca []interface{}
+	_ca = append(_ca, ctx, page, pageSize)
+	_ca = append(_ca, _va...)
+	ret := _m.Called(_ca...)
 
 	if len(ret) == 0 {
 		panic("no return value specified for ListUsers")
@@ -241,25 +650,25 @@ func (_m *MockStorer) ListUsers(ctx context.Context, page int, pageSize int) ([]
 	var r0 []domain.User
 	var r1 int
 	var r2 error
-	if rf, ok := ret.Get(0).(func(context.Context, int, int) ([]domain.User, int, error)); ok {
-		return rf(ctx, page, pageSize)
+	if rf, ok := ret.Get(0).(func(context.Context, int, int, ...storage.ReadOption) ([]domain.User, int, error)); ok {
+		return rf(ctx, page, pageSize, opts...)
 	}
-	if rf, ok := ret.Get(0).(func(context.Context, int, int) []domain.User); ok {
-		r0 = rf(ctx, page, pageSize)
+	if rf, ok := ret.Get(0).(func(context.Context, int, int, ...storage.ReadOption) []domain.User); ok {
+		r0 = rf(ctx, page, pageSize, opts...)
 	} else {
 		if ret.Get(0) != nil {
 			r0 = ret.Get(0).([]domain.User)
 		}
 	}
 
-	if rf, ok := ret.Get(1).(func(context.Context, int, int) int); ok {
-		r1 = rf(ctx, page, pageSize)
+	if rf, ok := ret.Get(1).(func(context.Context, int, int, ...storage.ReadOption) int); ok {
+		r1 = rf(ctx, page, pageSize, opts...)
 	} else {
 		r1 = ret.Get(1).(int)
 	}
 
-	if rf, ok := ret.Get(2).(func(context.Context, int, int) error); ok {
-		r2 = rf(ctx, page, pageSize)
+	if rf, ok := ret.Get(2).(func(context.Context, int, int, ...storage.ReadOption) error); ok {
+		r2 = rf(ctx, page, pageSize, opts...)
 	} else {
 		r2 = ret.Error(2)
 	}
@@ -276,13 +685,21 @@ type MockStorer_ListUsers_Call struct {
 //   - ctx context.Context
 //   - page int
 //   - pageSize int
-func (_e *MockStorer_Expecter) ListUsers(ctx interface{}, page interface{}, pageSize interface{}) *MockStorer_ListUsers_Call {
-	return &MockStorer_ListUsers_Call{Call: _e.mock.On("ListUsers", ctx, page, pageSize)}
+//   - opts ...storage.ReadOption
+func (_e *MockStorer_Expecter) ListUsers(ctx interface{}, page interface{}, pageSize interface{}, opts ...interface{}) *MockStorer_ListUsers_Call {
+	return &MockStorer_ListUsers_Call{Call: _e.mock.On("ListUsers",
+		append([]interface{}{ctx, page, pageSize}, opts...)...)}
 }
 
-func (_c *MockStorer_ListUsers_Call) Run(run func(ctx context.Context, page int, pageSize int)) *MockStorer_ListUsers_Call {
+func (_c *MockStorer_ListUsers_Call) Run(run func(ctx context.Context, page int, pageSize int, opts ...storage.ReadOption)) *MockStorer_ListUsers_Call {
 	_c.Call.Run(func(args mock.Arguments) {
-		run(args[0].(context.Context), args[1].(int), args[2].(int))
+		variadicArgs := make([]storage.ReadOption, len(args)-3)
+		for i, a := range args[3:] {
+			if a != nil {
+				variadicArgs[i] = a.(storage.ReadOption)
+			}
+		}
+		run(args[0].(context.Context), args[1].(int), args[2].(int), variadicArgs...)
 	})
 	return _c
 }
@@ -292,7 +709,318 @@ func (_c *MockStorer_ListUsers_Call) Return(_a0 []domain.User, _a1 int, _a2 erro
 	return _c
 }
 
-func (_c *MockStorer_ListUsers_Call) RunAndReturn(run func(context.Context, int, int) ([]domain.User, int, error)) *MockStorer_ListUsers_Call {
+func (_c *MockStorer_ListUsers_Call) RunAndReturn(run func(context.Context, int, int, ...storage.ReadOption) ([]domain.User, int, error)) *MockStorer_ListUsers_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// ListUsersAfter provides a mock function with given fields: ctx, afterID, limit, opts
+func (_m *MockStorer) ListUsersAfter(ctx context.Context, afterID int64, limit int, opts ...storage.ReadOption) (*storage.UserPage, error) {
+	_va := make([]interface{}, len(opts))
+	for _i := range opts {
+		_va[_i] = opts[_i]
+	}
+	var _ca []interface{}
+	_ca = append(_ca, ctx, afterID, limit)
+	_ca = append(_ca, _va...)
+	ret := _m.Called(_ca...)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ListUsersAfter")
+	}
+
+	var r0 *storage.UserPage
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, int64, int, ...storage.ReadOption) (*storage.UserPage, error)); ok {
+		return rf(ctx, afterID, limit, opts...)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, int64, int, ...storage.ReadOption) *storage.UserPage); ok {
+		r0 = rf(ctx, afterID, limit, opts...)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*storage.UserPage)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, int64, int, ...storage.ReadOption) error); ok {
+		r1 = rf(ctx, afterID, limit, opts...)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// MockStorer_ListUsersAfter_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'ListUsersAfter'
+type MockStorer_ListUsersAfter_Call struct {
+	*mock.Call
+}
+
+// ListUsersAfter is a helper method to define mock.On call
+//   - ctx context.Context
+//   - afterID int64
+//   - limit int
+//   - opts ...storage.ReadOption
+func (_e *MockStorer_Expecter) ListUsersAfter(ctx interface{}, afterID interface{}, limit interface{}, opts ...interface{}) *MockStorer_ListUsersAfter_Call {
+	return &MockStorer_ListUsersAfter_Call{Call: _e.mock.On("ListUsersAfter",
+		append([]interface{}{ctx, afterID, limit}, opts...)...)}
+}
+
+func (_c *MockStorer_ListUsersAfter_Call) Run(run func(ctx context.Context, afterID int64, limit int, opts ...storage.ReadOption)) *MockStorer_ListUsersAfter_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		variadicArgs := make([]storage.ReadOption, len(args)-3)
+		for i, a := range args[3:] {
+			if a != nil {
+				variadicArgs[i] = a.(storage.ReadOption)
+			}
+		}
+		run(args[0].(context.Context), args[1].(int64), args[2].(int), variadicArgs...)
+	})
+	return _c
+}
+
+func (_c *MockStorer_ListUsersAfter_Call) Return(_a0 *storage.UserPage, _a1 error) *MockStorer_ListUsersAfter_Call {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
+func (_c *MockStorer_ListUsersAfter_Call) RunAndReturn(run func(context.Context, int64, int, ...storage.ReadOption) (*storage.UserPage, error)) *MockStorer_ListUsersAfter_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// PurgeDeletedUsers provides a mock function with given fields: ctx, deletedBefore
+func (_m *MockStorer) PurgeDeletedUsers(ctx context.Context, deletedBefore time.Time) (int64, error) {
+	ret := _m.Called(ctx, deletedBefore)
+
+	if len(ret) == 0 {
+		panic("no return value specified for PurgeDeletedUsers")
+	}
+
+	var r0 int64
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, time.Time) (int64, error)); ok {
+		return rf(ctx, deletedBefore)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, time.Time) int64); ok {
+		r0 = rf(ctx, deletedBefore)
+	} else {
+		r0 = ret.Get(0).(int64)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, time.Time) error); ok {
+		r1 = rf(ctx, deletedBefore)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// MockStorer_PurgeDeletedUsers_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'PurgeDeletedUsers'
+type MockStorer_PurgeDeletedUsers_Call struct {
+	*mock.Call
+}
+
+// PurgeDeletedUsers is a helper method to define mock.On call
+//   - ctx context.Context
+//   - deletedBefore time.Time
+func (_e *MockStorer_Expecter) PurgeDeletedUsers(ctx interface{}, deletedBefore interface{}) *MockStorer_PurgeDeletedUsers_Call {
+	return &MockStorer_PurgeDeletedUsers_Call{Call: _e.mock.On("PurgeDeletedUsers", ctx, deletedBefore)}
+}
+
+func (_c *MockStorer_PurgeDeletedUsers_Call) Run(run func(ctx context.Context, deletedBefore time.Time)) *MockStorer_PurgeDeletedUsers_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(time.Time))
+	})
+	return _c
+}
+
+func (_c *MockStorer_PurgeDeletedUsers_Call) Return(_a0 int64, _a1 error) *MockStorer_PurgeDeletedUsers_Call {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
+func (_c *MockStorer_PurgeDeletedUsers_Call) RunAndReturn(run func(context.Context, time.Time) (int64, error)) *MockStorer_PurgeDeletedUsers_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// ReplaceUser provides a mock function with given fields: ctx, id, user, active
+func (_m *MockStorer) ReplaceUser(ctx context.Context, id int64, user domain.UserUpdate, active bool) error {
+	ret := _m.Called(ctx, id, user, active)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ReplaceUser")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, int64, domain.UserUpdate, bool) error); ok {
+		r0 = rf(ctx, id, user, active)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// MockStorer_ReplaceUser_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'ReplaceUser'
+type MockStorer_ReplaceUser_Call struct {
+	*mock.Call
+}
+
+// ReplaceUser is a helper method to define mock.On call
+//   - ctx context.Context
+//   - id int64
+//   - user domain.UserUpdate
+//   - active bool
+func (_e *MockStorer_Expecter) ReplaceUser(ctx interface{}, id interface{}, user interface{}, active interface{}) *MockStorer_ReplaceUser_Call {
+	return &MockStorer_ReplaceUser_Call{Call: _e.mock.On("ReplaceUser", ctx, id, user, active)}
+}
+
+func (_c *MockStorer_ReplaceUser_Call) Run(run func(ctx context.Context, id int64, user domain.UserUpdate, active bool)) *MockStorer_ReplaceUser_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(int64), args[2].(domain.UserUpdate), args[3].(bool))
+	})
+	return _c
+}
+
+func (_c *MockStorer_ReplaceUser_Call) Return(_a0 error) *MockStorer_ReplaceUser_Call {
+	_c.Call.Return(_a0)
+	return _c
+}
+
+func (_c *MockStorer_ReplaceUser_Call) RunAndReturn(run func(context.Context, int64, domain.UserUpdate, bool) error) *MockStorer_ReplaceUser_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// RestoreUser provides a mock function with given fields: ctx, id
+func (_m *MockStorer) RestoreUser(ctx context.Context, id int64) error {
+	ret := _m.Called(ctx, id)
+
+	if len(ret) == 0 {
+		panic("no return value specified for RestoreUser")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, int64) error); ok {
+		r0 = rf(ctx, id)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// MockStorer_RestoreUser_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'RestoreUser'
+type MockStorer_RestoreUser_Call struct {
+	*mock.Call
+}
+
+// RestoreUser is a helper method to define mock.On call
+//   - ctx context.Context
+//   - id int64
+func (_e *MockStorer_Expecter) RestoreUser(ctx interface{}, id interface{}) *MockStorer_RestoreUser_Call {
+	return &MockStorer_RestoreUser_Call{Call: _e.mock.On("RestoreUser", ctx, id)}
+}
+
+func (_c *MockStorer_RestoreUser_Call) Run(run func(ctx context.Context, id int64)) *MockStorer_RestoreUser_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(int64))
+	})
+	return _c
+}
+
+func (_c *MockStorer_RestoreUser_Call) Return(_a0 error) *MockStorer_RestoreUser_Call {
+	_c.Call.Return(_a0)
+	return _c
+}
+
+func (_c *MockStorer_RestoreUser_Call) RunAndReturn(run func(context.Context, int64) error) *MockStorer_RestoreUser_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// SearchUsers provides a mock function with given fields: ctx, filter, offset, limit, opts
+func (_m *MockStorer) SearchUsers(ctx context.Context, filter storage.UserFilter, offset int, limit int, opts ...storage.ReadOption) ([]domain.User, int, error) {
+	_va := make([]interface{}, len(opts))
+	for _i := range opts {
+		_va[_i] = opts[_i]
+	}
+	var _ca []interface{}
+	_ca = append(_ca, ctx, filter, offset, limit)
+	_ca = append(_ca, _va...)
+	ret := _m.Called(_ca...)
+
+	if len(ret) == 0 {
+		panic("no return value specified for SearchUsers")
+	}
+
+	var r0 []domain.User
+	var r1 int
+	var r2 error
+	if rf, ok := ret.Get(0).(func(context.Context, storage.UserFilter, int, int, ...storage.ReadOption) ([]domain.User, int, error)); ok {
+		return rf(ctx, filter, offset, limit, opts...)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, storage.UserFilter, int, int, ...storage.ReadOption) []domain.User); ok {
+		r0 = rf(ctx, filter, offset, limit, opts...)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]domain.User)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, storage.UserFilter, int, int, ...storage.ReadOption) int); ok {
+		r1 = rf(ctx, filter, offset, limit, opts...)
+	} else {
+		r1 = ret.Get(1).(int)
+	}
+
+	if rf, ok := ret.Get(2).(func(context.Context, storage.UserFilter, int, int, ...storage.ReadOption) error); ok {
+		r2 = rf(ctx, filter, offset, limit, opts...)
+	} else {
+		r2 = ret.Error(2)
+	}
+
+	return r0, r1, r2
+}
+
+// MockStorer_SearchUsers_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'SearchUsers'
+type MockStorer_SearchUsers_Call struct {
+	*mock.Call
+}
+
+// SearchUsers is a helper method to define mock.On call
+//   - ctx context.Context
+//   - filter storage.UserFilter
+//   - offset int
+//   - limit int
+//   - opts ...storage.ReadOption
+func (_e *MockStorer_Expecter) SearchUsers(ctx interface{}, filter interface{}, offset interface{}, limit interface{}, opts ...interface{}) *MockStorer_SearchUsers_Call {
+	return &MockStorer_SearchUsers_Call{Call: _e.mock.On("SearchUsers",
+		append([]interface{}{ctx, filter, offset, limit}, opts...)...)}
+}
+
+func (_c *MockStorer_SearchUsers_Call) Run(run func(ctx context.Context, filter storage.UserFilter, offset int, limit int, opts ...storage.ReadOption)) *MockStorer_SearchUsers_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		variadicArgs := make([]storage.ReadOption, len(args)-4)
+		for i, a := range args[4:] {
+			if a != nil {
+				variadicArgs[i] = a.(storage.ReadOption)
+			}
+		}
+		run(args[0].(context.Context), args[1].(storage.UserFilter), args[2].(int), args[3].(int), variadicArgs...)
+	})
+	return _c
+}
+
+func (_c *MockStorer_SearchUsers_Call) Return(_a0 []domain.User, _a1 int, _a2 error) *MockStorer_SearchUsers_Call {
+	_c.Call.Return(_a0, _a1, _a2)
+	return _c
+}
+
+func (_c *MockStorer_SearchUsers_Call) RunAndReturn(run func(context.Context, storage.UserFilter, int, int, ...storage.ReadOption) ([]domain.User, int, error)) *MockStorer_SearchUsers_Call {
 	_c.Call.Return(run)
 	return _c
 }
@@ -345,6 +1073,66 @@ func (_c *MockStorer_UpdateUser_Call) RunAndReturn(run func(context.Context, int
 	return _c
 }
 
+// UpdateUsers provides a mock function with given fields: ctx, updates, atomic
+func (_m *MockStorer) UpdateUsers(ctx context.Context, updates []domain.UserBatchUpdate, atomic bool) ([]storage.BatchResult, error) {
+	ret := _m.Called(ctx, updates, atomic)
+
+	if len(ret) == 0 {
+		panic("no return value specified for UpdateUsers")
+	}
+
+	var r0 []storage.BatchResult
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, []domain.UserBatchUpdate, bool) ([]storage.BatchResult, error)); ok {
+		return rf(ctx, updates, atomic)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, []domain.UserBatchUpdate, bool) []storage.BatchResult); ok {
+		r0 = rf(ctx, updates, atomic)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]storage.BatchResult)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, []domain.UserBatchUpdate, bool) error); ok {
+		r1 = rf(ctx, updates, atomic)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// MockStorer_UpdateUsers_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'UpdateUsers'
+type MockStorer_UpdateUsers_Call struct {
+	*mock.Call
+}
+
+// UpdateUsers is a helper method to define mock.On call
+//   - ctx context.Context
+//   - updates []domain.UserBatchUpdate
+//   - atomic bool
+func (_e *MockStorer_Expecter) UpdateUsers(ctx interface{}, updates interface{}, atomic interface{}) *MockStorer_UpdateUsers_Call {
+	return &MockStorer_UpdateUsers_Call{Call: _e.mock.On("UpdateUsers", ctx, updates, atomic)}
+}
+
+func (_c *MockStorer_UpdateUsers_Call) Run(run func(ctx context.Context, updates []domain.UserBatchUpdate, atomic bool)) *MockStorer_UpdateUsers_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].([]domain.UserBatchUpdate), args[2].(bool))
+	})
+	return _c
+}
+
+func (_c *MockStorer_UpdateUsers_Call) Return(_a0 []storage.BatchResult, _a1 error) *MockStorer_UpdateUsers_Call {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
+func (_c *MockStorer_UpdateUsers_Call) RunAndReturn(run func(context.Context, []domain.UserBatchUpdate, bool) ([]storage.BatchResult, error)) *MockStorer_UpdateUsers_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
 // NewMockStorer creates a new instance of MockStorer. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
 // The first argument is typically a *testing.T value.
 func NewMockStorer(t interface {
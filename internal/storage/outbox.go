@@ -0,0 +1,175 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/huberts90/restful-api/internal/domain"
+	"github.com/lib/pq"
+	"go.uber.org/zap"
+)
+
+// outboxLockID is the advisory lock held while relaying events
+// A single relay at a time keeps the events of a user in order across instances
+const outboxLockID = 72_310_034
+
+const (
+	// The payload is built from the row itself so that it matches the committed state
+	sqlInsertEvent = `INSERT INTO user_outbox (user_id, event_type, payload, created_at) ` +
+		`SELECT id, $2::varchar, jsonb_build_object('id', id, 'email', email, 'first_name', first_name, 'last_name', last_name, ` +
+		`'created_at', created_at, 'updated_at', updated_at, 'deleted_at', deleted_at), NOW() FROM users WHERE id = $1`
+	sqlInsertEvents = `INSERT INTO user_outbox (user_id, event_type, payload, created_at) ` +
+		`SELECT id, $2::varchar, jsonb_build_object('id', id, 'email', email, 'first_name', first_name, 'last_name', last_name, ` +
+		`'created_at', created_at, 'updated_at', updated_at, 'deleted_at', deleted_at), NOW() FROM users WHERE id = ANY($1) ORDER BY id`
+	sqlLockOutbox        = `SELECT pg_try_advisory_xact_lock($1)`
+	sqlListPendingEvents = `SELECT id, user_id, event_type, payload, created_at FROM user_outbox WHERE published_at IS NULL ORDER BY id LIMIT $1`
+	sqlListEventsAfter   = `SELECT id, user_id, event_type, payload, created_at FROM user_outbox WHERE id > $1 AND id <= ` +
+		`(SELECT MAX(id) FROM user_outbox WHERE id > $1 AND horizon <= txid_snapshot_xmin(txid_current_snapshot())) ORDER BY id LIMIT $2`
+	sqlMarkEventsPublished = `UPDATE user_outbox SET published_at = NOW() WHERE id = ANY($1)`
+	sqlPruneOutbox         = `DELETE FROM user_outbox WHERE published_at < $1`
+	// Without a publisher events are never marked published, they are only kept for the event stream
+	sqlPruneUnpublishedOutbox = `DELETE FROM user_outbox WHERE COALESCE(published_at, created_at) < $1`
+)
+
+// auditEvents maps the audited operations to the events they emit
+var auditEvents = map[domain.AuditOperation]domain.EventType{
+	domain.AuditCreate:  domain.EventUserCreated,
+	domain.AuditUpdate:  domain.EventUserUpdated,
+	domain.AuditDelete:  domain.EventUserDeleted,
+	domain.AuditRestore: domain.EventUserRestored,
+}
+
+// recordChange appends the audit record and the outbox event of a mutation within its transaction
+func (s *PostgresStore) recordChange(ctx context.Context, tx *sql.Tx, userID int64, op domain.AuditOperation, changes map[string]domain.FieldChange) error {
+	if err := s.writeAudit(ctx, tx, userID, op, changes); err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, sqlInsertEvent, userID, string(auditEvents[op])); err != nil {
+		return s.handleError(err, "failed to write outbox event", zap.Int64("id", userID))
+	}
+	return nil
+}
+
+// recordChanges appends the audit records and the outbox events of a batch within its transaction
+// Nothing is written for an empty batch
+func (s *PostgresStore) recordChanges(ctx context.Context, tx *sql.Tx, op domain.AuditOperation, userIDs []int64, changes []map[string]domain.FieldChange) error {
+	if len(userIDs) == 0 {
+		return nil
+	}
+	if err := s.writeAudits(ctx, tx, op, userIDs, changes); err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, sqlInsertEvents, pq.Array(userIDs), string(auditEvents[op])); err != nil {
+		return s.handleError(err, "failed to write outbox events", zap.Int("count", len(userIDs)))
+	}
+	return nil
+}
+
+// PublishOutbox hands up to limit pending events to publish, oldest first, and marks the published ones
+// Publishing stops at the first failure so that later events of the same user are not sent ahead of it
+// It returns the number of published events, and the publish error if any
+func (s *PostgresStore) PublishOutbox(ctx context.Context, limit int, publish func(context.Context, domain.Event) error) (int, error) {
+	var published []int64
+	var publishErr error
+
+	err := s.withTx(ctx, false, func(tx *sql.Tx) error {
+		var locked bool
+		if err := tx.QueryRowContext(ctx, sqlLockOutbox, outboxLockID).Scan(&locked); err != nil {
+			return s.handleError(err, "failed to lock outbox")
+		}
+		if !locked {
+			// Another instance is relaying
+			return nil
+		}
+
+		events, err := s.pendingEvents(ctx, tx, limit)
+		if err != nil {
+			return err
+		}
+
+		for _, event := range events {
+			if publishErr = publish(ctx, event); publishErr != nil {
+				break
+			}
+			published = append(published, event.ID)
+		}
+
+		if len(published) == 0 {
+			return nil
+		}
+		if _, err := tx.ExecContext(ctx, sqlMarkEventsPublished, pq.Array(published)); err != nil {
+			return s.handleError(err, "failed to mark events as published")
+		}
+		return nil
+	})
+	if err != nil {
+		// Events published before the failure will be published again, which at-least-once delivery allows
+		return 0, err
+	}
+
+	return len(published), publishErr
+}
+
+// pendingEvents retrieves up to limit unpublished events, oldest first
+func (s *PostgresStore) pendingEvents(ctx context.Context, tx *sql.Tx, limit int) ([]domain.Event, error) {
+	rows, err := tx.QueryContext(ctx, sqlListPendingEvents, limit)
+	if err != nil {
+		return nil, s.handleError(err, "failed to query outbox")
+	}
+	return s.scanEvents(rows, limit)
+}
+
+// EventsAfter retrieves up to limit events written after the event with the given ID, oldest first
+// Only events still kept in the outbox can be retrieved. Events are held back while a transaction that may
+// still commit a lower ID is in flight, since a client resuming after them would never get that one
+func (s *PostgresStore) EventsAfter(ctx context.Context, afterID int64, limit int) ([]domain.Event, error) {
+	rows, err := s.db.QueryContext(ctx, sqlListEventsAfter, afterID, limit)
+	if err != nil {
+		return nil, s.handleError(err, "failed to query outbox")
+	}
+	return s.scanEvents(rows, limit)
+}
+
+func (s *PostgresStore) scanEvents(rows *sql.Rows, limit int) ([]domain.Event, error) {
+	defer rows.Close()
+
+	events := make([]domain.Event, 0, limit)
+	for rows.Next() {
+		var event domain.Event
+		var eventType string
+		var payload []byte
+		if err := rows.Scan(&event.ID, &event.UserID, &eventType, &payload, &event.OccurredAt); err != nil {
+			return nil, s.handleError(err, "failed to scan outbox row")
+		}
+		event.Type = domain.EventType(eventType)
+		event.Data = payload
+		events = append(events, event)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, s.handleError(err, "error iterating outbox rows")
+	}
+	return events, nil
+}
+
+// PruneOutbox deletes the events published before the given time
+// With unpublished, the events written before it that were never published are deleted as well
+func (s *PostgresStore) PruneOutbox(ctx context.Context, before time.Time, unpublished bool) (int64, error) {
+	query := sqlPruneOutbox
+	if unpublished {
+		query = sqlPruneUnpublishedOutbox
+	}
+	result, err := s.db.ExecContext(ctx, query, before)
+	if err != nil {
+		return 0, s.handleError(err, "failed to prune outbox")
+	}
+
+	pruned, err := result.RowsAffected()
+	if err != nil {
+		return 0, s.handleError(err, "failed to get affected rows")
+	}
+	return pruned, nil
+}
@@ -0,0 +1,163 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/huberts90/restful-api/internal/domain"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPublishOutbox(t *testing.T) {
+	f := setupTest(t)
+	defer f.cleanup()
+
+	eventRows := func() *sqlmock.Rows {
+		return sqlmock.NewRows([]string{"id", "user_id", "event_type", "payload", "created_at"}).
+			AddRow(10, 1, "user.created", []byte(`{"id":1}`), f.now).
+			AddRow(11, 2, "user.created", []byte(`{"id":2}`), f.now).
+			AddRow(12, 1, "user.updated", []byte(`{"id":1}`), f.now)
+	}
+	errPublish := errors.New("broker unavailable")
+
+	tests := []struct {
+		name       string
+		setup      func(sqlmock.Sqlmock)
+		failOn     int64
+		want       int
+		wantIDs    []int64
+		wantErr    error
+		wantAnyErr bool
+	}{
+		{
+			name: "publishes pending events in order",
+			setup: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(sqlLockOutbox).WithArgs(outboxLockID).
+					WillReturnRows(sqlmock.NewRows([]string{"locked"}).AddRow(true))
+				mock.ExpectQuery(sqlListPendingEvents).WithArgs(10).WillReturnRows(eventRows())
+				mock.ExpectExec(sqlMarkEventsPublished).WithArgs(pq.Array([]int64{10, 11, 12})).
+					WillReturnResult(sqlmock.NewResult(0, 3))
+				mock.ExpectCommit()
+			},
+			want:    3,
+			wantIDs: []int64{10, 11, 12},
+		},
+		{
+			name: "stops at the first failure and keeps the progress",
+			setup: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(sqlLockOutbox).WithArgs(outboxLockID).
+					WillReturnRows(sqlmock.NewRows([]string{"locked"}).AddRow(true))
+				mock.ExpectQuery(sqlListPendingEvents).WithArgs(10).WillReturnRows(eventRows())
+				mock.ExpectExec(sqlMarkEventsPublished).WithArgs(pq.Array([]int64{10})).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectCommit()
+			},
+			failOn:  11,
+			want:    1,
+			wantIDs: []int64{10, 11},
+			wantErr: errPublish,
+		},
+		{
+			name: "another relay holds the lock",
+			setup: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(sqlLockOutbox).WithArgs(outboxLockID).
+					WillReturnRows(sqlmock.NewRows([]string{"locked"}).AddRow(false))
+				mock.ExpectCommit()
+			},
+			want: 0,
+		},
+		{
+			name: "marking fails",
+			setup: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(sqlLockOutbox).WithArgs(outboxLockID).
+					WillReturnRows(sqlmock.NewRows([]string{"locked"}).AddRow(true))
+				mock.ExpectQuery(sqlListPendingEvents).WithArgs(10).WillReturnRows(eventRows())
+				mock.ExpectExec(sqlMarkEventsPublished).WithArgs(pq.Array([]int64{10, 11, 12})).
+					WillReturnError(errors.New("connection reset"))
+				mock.ExpectRollback()
+			},
+			want:       0,
+			wantIDs:    []int64{10, 11, 12},
+			wantAnyErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.setup(f.mock)
+
+			var ids []int64
+			got, err := f.store.PublishOutbox(context.Background(), 10, func(_ context.Context, event domain.Event) error {
+				ids = append(ids, event.ID)
+				if event.ID == tt.failOn {
+					return errPublish
+				}
+				return nil
+			})
+
+			switch {
+			case tt.wantErr != nil:
+				assert.Equal(t, tt.wantErr, err)
+			case tt.wantAnyErr:
+				assert.ErrorIs(t, err, ErrDatabaseInternal)
+			default:
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tt.want, got)
+			assert.Equal(t, tt.wantIDs, ids)
+
+			assert.NoError(t, f.mock.ExpectationsWereMet(), "SQL expectations not met")
+		})
+	}
+}
+
+func TestEventsAfter(t *testing.T) {
+	f := setupTest(t)
+	defer f.cleanup()
+
+	f.mock.ExpectQuery(sqlListEventsAfter).
+		WithArgs(int64(10), 100).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "event_type", "payload", "created_at"}).
+			AddRow(11, 1, "user.deleted", []byte(`{"id":1}`), f.now))
+
+	got, err := f.store.EventsAfter(context.Background(), 10, 100)
+	assert.NoError(t, err)
+	assert.Equal(t, []domain.Event{{
+		ID:         11,
+		Type:       domain.EventUserDeleted,
+		UserID:     1,
+		OccurredAt: f.now,
+		Data:       []byte(`{"id":1}`),
+	}}, got)
+	assert.NoError(t, f.mock.ExpectationsWereMet())
+}
+
+func TestPruneOutbox(t *testing.T) {
+	f := setupTest(t)
+	defer f.cleanup()
+
+	t.Run("published", func(t *testing.T) {
+		f.mock.ExpectExec(sqlPruneOutbox).WithArgs(f.now).WillReturnResult(sqlmock.NewResult(0, 3))
+
+		pruned, err := f.store.PruneOutbox(context.Background(), f.now, false)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(3), pruned)
+		assert.NoError(t, f.mock.ExpectationsWereMet())
+	})
+
+	t.Run("unpublished", func(t *testing.T) {
+		f.mock.ExpectExec(sqlPruneUnpublishedOutbox).WithArgs(f.now).WillReturnResult(sqlmock.NewResult(0, 5))
+
+		pruned, err := f.store.PruneOutbox(context.Background(), f.now, true)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(5), pruned)
+		assert.NoError(t, f.mock.ExpectationsWereMet())
+	})
+}
@@ -23,18 +23,28 @@ var (
 )
 
 // SQL queries - ensure they have no extra whitespace for exact matching in tests
+// Soft deleted users are excluded unless the query name says otherwise
 const (
-	sqlCreateUser  = `INSERT INTO users (email, first_name, last_name, created_at, updated_at) VALUES ($1, $2, $3, NOW(), NOW()) RETURNING id`
-	sqlGetUserByID = `SELECT id, email, first_name, last_name, created_at, updated_at FROM users WHERE id = $1`
-	sqlDeleteUser  = `DELETE FROM users WHERE id = $1`
-	sqlCountUsers  = `SELECT COUNT(*) FROM users`
-	sqlListUsers   = `SELECT id, email, first_name, last_name, created_at, updated_at FROM users ORDER BY id LIMIT $1 OFFSET $2`
+	sqlCreateUser             = `INSERT INTO users (email, first_name, last_name, created_at, updated_at) VALUES ($1, $2, $3, NOW(), NOW()) RETURNING id`
+	sqlGetUserByID            = `SELECT id, email, first_name, last_name, created_at, updated_at, deleted_at FROM users WHERE id = $1 AND deleted_at IS NULL`
+	sqlGetUserByIDWithDeleted = `SELECT id, email, first_name, last_name, created_at, updated_at, deleted_at FROM users WHERE id = $1`
+	sqlLockUser               = `SELECT id, email, first_name, last_name, created_at, updated_at, deleted_at FROM users WHERE id = $1 AND deleted_at IS NULL FOR UPDATE`
+	sqlLockUserWithDeleted    = `SELECT id, email, first_name, last_name, created_at, updated_at, deleted_at FROM users WHERE id = $1 FOR UPDATE`
+	sqlDeleteUser             = `UPDATE users SET deleted_at = NOW(), updated_at = NOW() WHERE id = $1 AND deleted_at IS NULL RETURNING deleted_at`
+	sqlRestoreUser            = `UPDATE users SET deleted_at = NULL, updated_at = NOW() WHERE id = $1 AND deleted_at IS NOT NULL`
+	sqlPurgeUsers             = `DELETE FROM users WHERE deleted_at < $1`
+	sqlCountUsers             = `SELECT COUNT(*) FROM users WHERE deleted_at IS NULL`
+	sqlCountUsersWithDeleted  = `SELECT COUNT(*) FROM users`
+	sqlListUsers              = `SELECT id, email, first_name, last_name, created_at, updated_at, deleted_at FROM users WHERE deleted_at IS NULL ORDER BY id LIMIT $1 OFFSET $2`
+	sqlListUsersWithDeleted   = `SELECT id, email, first_name, last_name, created_at, updated_at, deleted_at FROM users ORDER BY id LIMIT $1 OFFSET $2`
 )
 
 // PostgresStore implements the Storer interface using PostgreSQL
+// Writes go to the primary while reads are spread over the replicas, if any
 type PostgresStore struct {
-	db     *sql.DB
-	logger *zap.Logger
+	db       *sql.DB
+	replicas *replicaSet
+	logger   *zap.Logger
 }
 
 // NewPostgresStore creates a new PostgreSQL store
@@ -48,6 +58,7 @@ func NewPostgresStore(cfg PostgresConfig, logger *zap.Logger) (*PostgresStore, e
 	if err != nil {
 		return nil, fmt.Errorf("failed to open database connection: %w", err)
 	}
+	configurePool(db, cfg)
 
 	// Test the connection
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
@@ -63,17 +74,65 @@ func NewPostgresStore(cfg PostgresConfig, logger *zap.Logger) (*PostgresStore, e
 		return nil, fmt.Errorf("failed to ping database: %w", err)
 	}
 
-	return &PostgresStore{
+	store := &PostgresStore{
 		db:     db,
 		logger: logger,
-	}, nil
+	}
+
+	if len(cfg.ReplicaDSNs) > 0 {
+		store.replicas, err = newReplicaSet(cfg, logger)
+		if err != nil {
+			_ = db.Close()
+			return nil, err
+		}
+	}
+
+	return store, nil
 }
 
-// Close closes the database connection
+// configurePool applies the connection pool settings
+func configurePool(db *sql.DB, cfg PostgresConfig) {
+	db.SetMaxOpenConns(cfg.MaxOpenConns)
+	db.SetMaxIdleConns(cfg.MaxIdleConns)
+	db.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+	db.SetConnMaxIdleTime(cfg.ConnMaxIdleTime)
+}
+
+// Close closes the database connections
 func (s *PostgresStore) Close() error {
+	if s.replicas != nil {
+		if err := s.replicas.close(); err != nil {
+			s.logger.Warn("failed to close replica connections", zap.Error(err))
+		}
+	}
 	return s.db.Close()
 }
 
+// reader returns the connection pool to read from
+// The primary is used when there is no healthy replica, the client wrote recently or ctx asks for it
+func (s *PostgresStore) reader(ctx context.Context) *sql.DB {
+	if s.replicas == nil || primaryReadsFromContext(ctx) || s.replicas.pinned(ctx) {
+		return s.db
+	}
+	if r := s.replicas.pick(); r != nil {
+		return r.db
+	}
+	return s.db
+}
+
+// wrote records a successful write for read-your-writes consistency
+func (s *PostgresStore) wrote(ctx context.Context) {
+	if s.replicas != nil {
+		s.replicas.pin(ctx)
+	}
+}
+
+// DB returns the underlying connection pool
+// It lets components outside of the user storage, like the rate limiter, share the pool
+func (s *PostgresStore) DB() *sql.DB {
+	return s.db
+}
+
 // handleError is a helper method to handle database errors
 func (s *PostgresStore) handleError(err error, msg string, fields ...zap.Field) error {
 	// Check for duplicate email error
@@ -97,17 +156,28 @@ func (s *PostgresStore) handleError(err error, msg string, fields ...zap.Field)
 }
 
 // withTx executes a function within a transaction
+// Read-only transactions may run on a replica
 func (s *PostgresStore) withTx(ctx context.Context, readOnly bool, fn func(*sql.Tx) error) error {
+	return s.withTxOptions(ctx, &sql.TxOptions{ReadOnly: readOnly}, fn)
+}
+
+// withTxOptions executes a function within a transaction with the given options
+func (s *PostgresStore) withTxOptions(ctx context.Context, opts *sql.TxOptions, fn func(*sql.Tx) error) error {
+	db := s.db
+	if opts.ReadOnly {
+		db = s.reader(ctx)
+	}
+
 	// @MENTION_ME:
 	// - wrap operation within transaction
 	// - always pass context
-	tx, err := s.db.BeginTx(ctx, &sql.TxOptions{ReadOnly: readOnly})
+	tx, err := db.BeginTx(ctx, opts)
 	if err != nil {
 		return s.handleError(err, "failed to begin transaction")
 	}
 	defer func() {
 		// @MENTION_ME: transaction in idle
-		if txErr := tx.Rollback(); txErr != nil {
+		if txErr := tx.Rollback(); txErr != nil && !errors.Is(txErr, sql.ErrTxDone) {
 			s.logger.Error("failed to rollback transaction", zap.Error(txErr))
 		}
 	}()
@@ -125,49 +195,54 @@ func (s *PostgresStore) withTx(ctx context.Context, readOnly bool, fn func(*sql.
 
 // scanUser scans a user from a row
 func (s *PostgresStore) scanUser(row interface{ Scan(...interface{}) error }) (*domain.User, error) {
-	user := &domain.User{}
-	err := row.Scan(
-		&user.ID,
-		&user.Email,
-		&user.FirstName,
-		&user.LastName,
-		&user.CreatedAt,
-		&user.UpdatedAt,
-	)
-	if err != nil {
-		return nil, err
-	}
-	return user, nil
+	return scanUserColumns(row, UserColumns)
 }
 
 // CreateUser inserts a new user into the database
 func (s *PostgresStore) CreateUser(ctx context.Context, userCreate domain.UserCreate) (int64, error) {
-	// @MENTION_ME: pq does not support the LastInsertId() method of the Result type in database/sql. To return the identifier of an INSERT (or UPDATE or DELETE), use the Postgres RETURNING clause with a standard Query or QueryRow call
-	row := s.db.QueryRowContext(
-		ctx,
-		sqlCreateUser,
-		userCreate.Email,
-		userCreate.FirstName,
-		userCreate.LastName,
-	)
 	var userID int64
-	err := row.Scan(&userID)
 
+	err := s.withTx(ctx, false, func(tx *sql.Tx) error {
+		// @MENTION_ME: pq does not support the LastInsertId() method of the Result type in database/sql. To return the identifier of an INSERT (or UPDATE or DELETE), use the Postgres RETURNING clause with a standard Query or QueryRow call
+		row := tx.QueryRowContext(
+			ctx,
+			sqlCreateUser,
+			userCreate.Email,
+			userCreate.FirstName,
+			userCreate.LastName,
+		)
+		if err := row.Scan(&userID); err != nil {
+			return s.handleError(err, "failed to retrieve last inserted id")
+		}
+
+		return s.recordChange(ctx, tx, userID, domain.AuditCreate, createChanges(userCreate))
+	})
 	if err != nil {
-		return 0, s.handleError(err, "failed to retrieve last inserted id")
+		return 0, err
 	}
+	s.wrote(ctx)
 
 	return userID, nil
 }
 
 // GetUserByID retrieves a user by their ID
-func (s *PostgresStore) GetUserByID(ctx context.Context, id int64) (*domain.User, error) {
+func (s *PostgresStore) GetUserByID(ctx context.Context, id int64, opts ...ReadOption) (*domain.User, error) {
 	if id <= 0 {
 		return nil, ErrInvalidID
 	}
 
-	row := s.db.QueryRowContext(ctx, sqlGetUserByID, id)
-	user, err := s.scanUser(row)
+	o := NewReadOptions(opts...)
+	query := sqlGetUserByID
+	if o.IncludeDeleted {
+		query = sqlGetUserByIDWithDeleted
+	}
+	query, columns, err := projectUserQuery(query, o.Columns)
+	if err != nil {
+		return nil, err
+	}
+
+	row := s.reader(ctx).QueryRowContext(ctx, query, id)
+	user, err := scanUserColumns(row, columns)
 	if err != nil {
 		return nil, s.handleError(err, "failed to get user by ID", zap.Int64("id", id))
 	}
@@ -199,7 +274,7 @@ func (s *PostgresStore) buildUpdateQuery(userUpdate domain.UserUpdate, id int64)
 
 	updates = append(updates, "updated_at = NOW()")
 	query := "UPDATE users SET " + strings.Join(updates, ", ") +
-		fmt.Sprintf(" WHERE id = $%d RETURNING id", argPosition)
+		fmt.Sprintf(" WHERE id = $%d AND deleted_at IS NULL RETURNING id", argPosition)
 	args = append(args, id)
 
 	return query, args
@@ -207,55 +282,163 @@ func (s *PostgresStore) buildUpdateQuery(userUpdate domain.UserUpdate, id int64)
 
 // UpdateUser updates an existing user
 func (s *PostgresStore) UpdateUser(ctx context.Context, id int64, userUpdate domain.UserUpdate) error {
+	return s.mutateUser(ctx, id, domain.AuditUpdate, sqlLockUser, s.updateUser(ctx, id, userUpdate))
+}
+
+// DeleteUser soft deletes a user, it can be restored until it is purged
+func (s *PostgresStore) DeleteUser(ctx context.Context, id int64) error {
+	return s.mutateUser(ctx, id, domain.AuditDelete, sqlLockUser, s.deleteUser(ctx, id))
+}
+
+// RestoreUser brings back a soft deleted user
+// ErrDuplicateEmail is returned if a live user took over the email in the meantime
+func (s *PostgresStore) RestoreUser(ctx context.Context, id int64) error {
+	return s.mutateUser(ctx, id, domain.AuditRestore, sqlLockUserWithDeleted, s.restoreUser(ctx, id))
+}
+
+// ReplaceUser applies an update and restores or deletes a user as active tells, all or nothing
+// Deleted users are restored before they are updated, and deleted after, since only live users can be updated.
+// Each step is audited on its own, an update without fields skips the update
+func (s *PostgresStore) ReplaceUser(ctx context.Context, id int64, userUpdate domain.UserUpdate, active bool) error {
 	if id <= 0 {
 		return ErrInvalidID
 	}
 
-	// Build and execute update query
-	query, args := s.buildUpdateQuery(userUpdate, id)
-	result, err := s.db.ExecContext(ctx, query, args...)
-	zapFieldID := zap.Int64("id", id)
+	err := s.withTx(ctx, false, func(tx *sql.Tx) error {
+		user, err := s.scanUser(tx.QueryRowContext(ctx, sqlLockUserWithDeleted, id))
+		if err != nil {
+			return s.handleError(err, "failed to lock user", zap.Int64("id", id))
+		}
+
+		wasActive := user.DeletedAt == nil
+		if active && !wasActive {
+			if err := s.mutateUserTx(ctx, tx, id, domain.AuditRestore, sqlLockUserWithDeleted, s.restoreUser(ctx, id)); err != nil {
+				return err
+			}
+		}
+		if userUpdate != (domain.UserUpdate{}) {
+			if err := s.mutateUserTx(ctx, tx, id, domain.AuditUpdate, sqlLockUser, s.updateUser(ctx, id, userUpdate)); err != nil {
+				return err
+			}
+		}
+		if wasActive && !active {
+			return s.mutateUserTx(ctx, tx, id, domain.AuditDelete, sqlLockUser, s.deleteUser(ctx, id))
+		}
+		return nil
+	})
 	if err != nil {
-		return s.handleError(err, "failed to update user", zapFieldID)
+		return err
 	}
+	s.wrote(ctx)
 
-	rowsAffected, err := result.RowsAffected()
-	if err != nil {
-		return s.handleError(err, "failed to retrieve rows affected", zapFieldID)
+	return nil
+}
+
+// updateUser returns the change of UpdateUser
+func (s *PostgresStore) updateUser(ctx context.Context, id int64, userUpdate domain.UserUpdate) userMutation {
+	return func(tx *sql.Tx, before *domain.User) (map[string]domain.FieldChange, error) {
+		// Build and execute update query
+		query, args := s.buildUpdateQuery(userUpdate, id)
+		if _, err := tx.ExecContext(ctx, query, args...); err != nil {
+			return nil, s.handleError(err, "failed to update user", zap.Int64("id", id))
+		}
+
+		return updateChanges(before, userUpdate), nil
 	}
+}
 
-	if rowsAffected == 0 {
-		return ErrUserNotFound
+// deleteUser returns the change of DeleteUser
+func (s *PostgresStore) deleteUser(ctx context.Context, id int64) userMutation {
+	return func(tx *sql.Tx, before *domain.User) (map[string]domain.FieldChange, error) {
+		var deletedAt time.Time
+		if err := tx.QueryRowContext(ctx, sqlDeleteUser, id).Scan(&deletedAt); err != nil {
+			return nil, s.handleError(err, "failed to delete user", zap.Int64("id", id))
+		}
+
+		return deletedAtChange(before.DeletedAt, &deletedAt), nil
 	}
+}
 
-	return nil
+// restoreUser returns the change of RestoreUser
+func (s *PostgresStore) restoreUser(ctx context.Context, id int64) userMutation {
+	return func(tx *sql.Tx, before *domain.User) (map[string]domain.FieldChange, error) {
+		if before.DeletedAt == nil {
+			return nil, ErrUserNotFound
+		}
+		if _, err := tx.ExecContext(ctx, sqlRestoreUser, id); err != nil {
+			return nil, s.handleError(err, "failed to restore user", zap.Int64("id", id))
+		}
+
+		return deletedAtChange(before.DeletedAt, nil), nil
+	}
 }
 
-// DeleteUser removes a user from the database
-func (s *PostgresStore) DeleteUser(ctx context.Context, id int64) error {
+// userMutation applies a change to a locked user and returns the changes to audit
+type userMutation func(tx *sql.Tx, before *domain.User) (map[string]domain.FieldChange, error)
+
+// mutateUser locks a user, applies a change and records it in the audit trail and the outbox within one transaction
+// fn returns the changes to audit, ErrUserNotFound is returned if lockQuery finds no user
+func (s *PostgresStore) mutateUser(
+	ctx context.Context,
+	id int64,
+	op domain.AuditOperation,
+	lockQuery string,
+	fn userMutation,
+) error {
 	if id <= 0 {
 		return ErrInvalidID
 	}
 
-	result, err := s.db.ExecContext(ctx, sqlDeleteUser, id)
+	err := s.withTx(ctx, false, func(tx *sql.Tx) error {
+		return s.mutateUserTx(ctx, tx, id, op, lockQuery, fn)
+	})
 	if err != nil {
-		return s.handleError(err, "failed to delete user", zap.Int64("id", id))
+		return err
 	}
+	s.wrote(ctx)
+
+	return nil
+}
 
-	rowsAffected, err := result.RowsAffected()
+// mutateUserTx is mutateUser within a transaction of the caller
+func (s *PostgresStore) mutateUserTx(
+	ctx context.Context,
+	tx *sql.Tx,
+	id int64,
+	op domain.AuditOperation,
+	lockQuery string,
+	fn userMutation,
+) error {
+	before, err := s.scanUser(tx.QueryRowContext(ctx, lockQuery, id))
 	if err != nil {
-		return s.handleError(err, "failed to get rows affected")
+		return s.handleError(err, "failed to lock user", zap.Int64("id", id))
 	}
 
-	if rowsAffected == 0 {
-		return ErrUserNotFound
+	changes, err := fn(tx, before)
+	if err != nil {
+		return err
 	}
 
-	return nil
+	return s.recordChange(ctx, tx, id, op, changes)
+}
+
+// PurgeDeletedUsers permanently removes users soft deleted before the given time
+func (s *PostgresStore) PurgeDeletedUsers(ctx context.Context, deletedBefore time.Time) (int64, error) {
+	result, err := s.db.ExecContext(ctx, sqlPurgeUsers, deletedBefore)
+	if err != nil {
+		return 0, s.handleError(err, "failed to purge deleted users")
+	}
+
+	purged, err := result.RowsAffected()
+	if err != nil {
+		return 0, s.handleError(err, "failed to get rows affected")
+	}
+
+	return purged, nil
 }
 
 // ListUsers retrieves a paginated list of users
-func (s *PostgresStore) ListUsers(ctx context.Context, page, pageSize int) ([]domain.User, int, error) {
+func (s *PostgresStore) ListUsers(ctx context.Context, page, pageSize int, opts ...ReadOption) ([]domain.User, int, error) {
 	if page < 1 {
 		return nil, 0, ErrInvalidPage
 	}
@@ -263,19 +446,29 @@ func (s *PostgresStore) ListUsers(ctx context.Context, page, pageSize int) ([]do
 		return nil, 0, ErrInvalidPageSize
 	}
 
+	o := NewReadOptions(opts...)
+	countQuery, listQuery := sqlCountUsers, sqlListUsers
+	if o.IncludeDeleted {
+		countQuery, listQuery = sqlCountUsersWithDeleted, sqlListUsersWithDeleted
+	}
+	listQuery, columns, err := projectUserQuery(listQuery, o.Columns)
+	if err != nil {
+		return nil, 0, err
+	}
+
 	var users []domain.User
 	var totalCount int
 
-	err := s.withTx(ctx, true, func(tx *sql.Tx) error {
+	err = s.withTx(ctx, true, func(tx *sql.Tx) error {
 		// Get total count
-		err := tx.QueryRowContext(ctx, sqlCountUsers).Scan(&totalCount)
+		err := tx.QueryRowContext(ctx, countQuery).Scan(&totalCount)
 		if err != nil {
 			return s.handleError(err, "failed to count users")
 		}
 
 		// Get users for the current page
 		offset := (page - 1) * pageSize
-		rows, err := tx.QueryContext(ctx, sqlListUsers, pageSize, offset)
+		rows, err := tx.QueryContext(ctx, listQuery, pageSize, offset)
 		if err != nil {
 			return s.handleError(err, "failed to query users")
 		}
@@ -283,7 +476,7 @@ func (s *PostgresStore) ListUsers(ctx context.Context, page, pageSize int) ([]do
 
 		users = make([]domain.User, 0, pageSize)
 		for rows.Next() {
-			user, err := s.scanUser(rows)
+			user, err := scanUserColumns(rows, columns)
 			if err != nil {
 				return s.handleError(err, "failed to scan user row")
 			}
@@ -61,9 +61,9 @@ func setupTest(t *testing.T) *testFixture {
 	}
 
 	// Create reusable user rows
-	userRows := sqlmock.NewRows([]string{"id", "email", "first_name", "last_name", "created_at", "updated_at"})
+	userRows := sqlmock.NewRows([]string{"id", "email", "first_name", "last_name", "created_at", "updated_at", "deleted_at"})
 	for _, u := range users {
-		userRows.AddRow(u.ID, u.Email, u.FirstName, u.LastName, u.CreatedAt, u.UpdatedAt)
+		userRows.AddRow(u.ID, u.Email, u.FirstName, u.LastName, u.CreatedAt, u.UpdatedAt, nil)
 	}
 
 	return &testFixture{
@@ -79,6 +79,27 @@ func setupTest(t *testing.T) *testFixture {
 	}
 }
 
+// userRow returns the row of a user as selected by the user queries
+func userRow(u domain.User, deletedAt interface{}) *sqlmock.Rows {
+	return sqlmock.NewRows([]string{"id", "email", "first_name", "last_name", "created_at", "updated_at", "deleted_at"}).
+		AddRow(u.ID, u.Email, u.FirstName, u.LastName, u.CreatedAt, u.UpdatedAt, deletedAt)
+}
+
+// expectChange expects the audit record and the outbox event of a mutation made without audit information in the context
+func expectChange(mock sqlmock.Sqlmock, id int64, op domain.AuditOperation) {
+	mock.ExpectExec(sqlInsertAudit).
+		WithArgs(id, "system", "", string(op), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	expectEvent(mock, id, auditEvents[op])
+}
+
+// expectEvent expects the outbox event of a mutation
+func expectEvent(mock sqlmock.Sqlmock, id int64, eventType domain.EventType) {
+	mock.ExpectExec(sqlInsertEvent).
+		WithArgs(id, string(eventType)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+}
+
 func TestCreateUser(t *testing.T) {
 	f := setupTest(t)
 	defer f.cleanup()
@@ -100,10 +121,13 @@ func TestCreateUser(t *testing.T) {
 			name:  "success",
 			input: userCreate,
 			setup: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
 				// Use the exact SQL query from constants
 				mock.ExpectQuery(strings.TrimSpace(sqlCreateUser)).
 					WithArgs(userCreate.Email, userCreate.FirstName, userCreate.LastName).
 					WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(3))
+				expectChange(mock, 3, domain.AuditCreate)
+				mock.ExpectCommit()
 			},
 			want:    int64(3),
 			wantErr: nil,
@@ -112,9 +136,11 @@ func TestCreateUser(t *testing.T) {
 			name:  "duplicate email",
 			input: userCreate,
 			setup: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
 				mock.ExpectQuery(strings.TrimSpace(sqlCreateUser)).
 					WithArgs(userCreate.Email, userCreate.FirstName, userCreate.LastName).
 					WillReturnError(&pq.Error{Code: "23505", Constraint: "users_email_key"})
+				mock.ExpectRollback()
 			},
 			want:    0,
 			wantErr: ErrDuplicateEmail,
@@ -155,8 +181,8 @@ func TestGetUserByID(t *testing.T) {
 			name: "success",
 			id:   1,
 			setup: func(mock sqlmock.Sqlmock) {
-				rows := sqlmock.NewRows([]string{"id", "email", "first_name", "last_name", "created_at", "updated_at"}).
-					AddRow(f.users[0].ID, f.users[0].Email, f.users[0].FirstName, f.users[0].LastName, f.users[0].CreatedAt, f.users[0].UpdatedAt)
+				rows := sqlmock.NewRows([]string{"id", "email", "first_name", "last_name", "created_at", "updated_at", "deleted_at"}).
+					AddRow(f.users[0].ID, f.users[0].Email, f.users[0].FirstName, f.users[0].LastName, f.users[0].CreatedAt, f.users[0].UpdatedAt, nil)
 
 				mock.ExpectQuery(sqlGetUserByID).
 					WithArgs(f.users[0].ID).
@@ -208,6 +234,8 @@ func TestDeleteUser(t *testing.T) {
 	f := setupTest(t)
 	defer f.cleanup()
 
+	deletedAt := f.now.Add(time.Hour)
+
 	tests := []struct {
 		name    string
 		id      int64
@@ -218,9 +246,15 @@ func TestDeleteUser(t *testing.T) {
 			name: "success",
 			id:   1,
 			setup: func(mock sqlmock.Sqlmock) {
-				mock.ExpectExec(sqlDeleteUser).
+				mock.ExpectBegin()
+				mock.ExpectQuery(sqlLockUser).
 					WithArgs(int64(1)).
-					WillReturnResult(sqlmock.NewResult(0, 1))
+					WillReturnRows(userRow(f.users[0], nil))
+				mock.ExpectQuery(sqlDeleteUser).
+					WithArgs(int64(1)).
+					WillReturnRows(sqlmock.NewRows([]string{"deleted_at"}).AddRow(deletedAt))
+				expectChange(mock, 1, domain.AuditDelete)
+				mock.ExpectCommit()
 			},
 			wantErr: nil,
 		},
@@ -234,9 +268,11 @@ func TestDeleteUser(t *testing.T) {
 			name: "not found",
 			id:   999,
 			setup: func(mock sqlmock.Sqlmock) {
-				mock.ExpectExec(sqlDeleteUser).
+				mock.ExpectBegin()
+				mock.ExpectQuery(sqlLockUser).
 					WithArgs(int64(999)).
-					WillReturnResult(sqlmock.NewResult(0, 0))
+					WillReturnError(sql.ErrNoRows)
+				mock.ExpectRollback()
 			},
 			wantErr: ErrUserNotFound,
 		},
@@ -281,9 +317,9 @@ func TestListUsers(t *testing.T) {
 				mock.ExpectQuery(sqlCountUsers).
 					WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(len(f.users)))
 
-				rows := sqlmock.NewRows([]string{"id", "email", "first_name", "last_name", "created_at", "updated_at"})
+				rows := sqlmock.NewRows([]string{"id", "email", "first_name", "last_name", "created_at", "updated_at", "deleted_at"})
 				for _, u := range f.users {
-					rows.AddRow(u.ID, u.Email, u.FirstName, u.LastName, u.CreatedAt, u.UpdatedAt)
+					rows.AddRow(u.ID, u.Email, u.FirstName, u.LastName, u.CreatedAt, u.UpdatedAt, nil)
 				}
 				mock.ExpectQuery(sqlListUsers).
 					WithArgs(10, 0).
@@ -348,10 +384,38 @@ func TestUpdateUser(t *testing.T) {
 		assert.Equal(t, ErrInvalidID, err)
 	})
 
+	t.Run("success", func(t *testing.T) {
+		ctx := WithAuditInfo(context.Background(), AuditInfo{Actor: "ip:192.0.2.1", RequestID: "req-1"})
+
+		f.mock.ExpectBegin()
+		f.mock.ExpectQuery(sqlLockUser).
+			WithArgs(int64(1)).
+			WillReturnRows(userRow(f.users[0], nil))
+		f.mock.ExpectExec("UPDATE users SET email = $1, last_name = $2, updated_at = NOW() WHERE id = $3 AND deleted_at IS NULL RETURNING id").
+			WithArgs(email, "Doe", int64(1)).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		// Unchanged fields are left out of the audit record
+		f.mock.ExpectExec(sqlInsertAudit).
+			WithArgs(int64(1), "ip:192.0.2.1", "req-1", "update", `{"email":{"old":"user1@example.com","new":"updated@example.com"}}`).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		expectEvent(f.mock, 1, domain.EventUserUpdated)
+		f.mock.ExpectCommit()
+
+		err := f.store.UpdateUser(ctx, 1, domain.UserUpdate{
+			Email:    email,
+			LastName: "Doe",
+		})
+
+		assert.NoError(t, err)
+		assert.NoError(t, f.mock.ExpectationsWereMet())
+	})
+
 	t.Run("user not found", func(t *testing.T) {
-		f.mock.ExpectExec("UPDATE users SET email = $1, updated_at = NOW() WHERE id = $2 RETURNING id").
-			WithArgs(email, int64(999)).
-			WillReturnResult(sqlmock.NewResult(0, 0))
+		f.mock.ExpectBegin()
+		f.mock.ExpectQuery(sqlLockUser).
+			WithArgs(int64(999)).
+			WillReturnError(sql.ErrNoRows)
+		f.mock.ExpectRollback()
 
 		err := f.store.UpdateUser(context.Background(), 999, domain.UserUpdate{
 			Email: email,
@@ -361,3 +425,197 @@ func TestUpdateUser(t *testing.T) {
 		assert.NoError(t, f.mock.ExpectationsWereMet())
 	})
 }
+
+func TestReplaceUser(t *testing.T) {
+	f := setupTest(t)
+	defer f.cleanup()
+
+	user := f.users[0]
+
+	t.Run("restore and update", func(t *testing.T) {
+		f.mock.ExpectBegin()
+		f.mock.ExpectQuery(sqlLockUserWithDeleted).WithArgs(int64(1)).WillReturnRows(userRow(user, f.now))
+		f.mock.ExpectQuery(sqlLockUserWithDeleted).WithArgs(int64(1)).WillReturnRows(userRow(user, f.now))
+		f.mock.ExpectExec(sqlRestoreUser).WithArgs(int64(1)).WillReturnResult(sqlmock.NewResult(0, 1))
+		expectChange(f.mock, 1, domain.AuditRestore)
+		f.mock.ExpectQuery(sqlLockUser).WithArgs(int64(1)).WillReturnRows(userRow(user, nil))
+		f.mock.ExpectExec("UPDATE users SET first_name = $1, updated_at = NOW() WHERE id = $2 AND deleted_at IS NULL RETURNING id").
+			WithArgs("Ann", int64(1)).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		expectChange(f.mock, 1, domain.AuditUpdate)
+		f.mock.ExpectCommit()
+
+		assert.NoError(t, f.store.ReplaceUser(context.Background(), 1, domain.UserUpdate{FirstName: "Ann"}, true))
+		assert.NoError(t, f.mock.ExpectationsWereMet())
+	})
+
+	t.Run("restore rolled back when the update fails", func(t *testing.T) {
+		f.mock.ExpectBegin()
+		f.mock.ExpectQuery(sqlLockUserWithDeleted).WithArgs(int64(1)).WillReturnRows(userRow(user, f.now))
+		f.mock.ExpectQuery(sqlLockUserWithDeleted).WithArgs(int64(1)).WillReturnRows(userRow(user, f.now))
+		f.mock.ExpectExec(sqlRestoreUser).WithArgs(int64(1)).WillReturnResult(sqlmock.NewResult(0, 1))
+		expectChange(f.mock, 1, domain.AuditRestore)
+		f.mock.ExpectQuery(sqlLockUser).WithArgs(int64(1)).WillReturnRows(userRow(user, nil))
+		f.mock.ExpectExec("UPDATE users SET email = $1, updated_at = NOW() WHERE id = $2 AND deleted_at IS NULL RETURNING id").
+			WithArgs("user2@example.com", int64(1)).
+			WillReturnError(&pq.Error{Code: "23505", Constraint: "users_email_key"})
+		f.mock.ExpectRollback()
+
+		err := f.store.ReplaceUser(context.Background(), 1, domain.UserUpdate{Email: "user2@example.com"}, true)
+		assert.Equal(t, ErrDuplicateEmail, err)
+		assert.NoError(t, f.mock.ExpectationsWereMet())
+	})
+}
+
+func TestReadReplicaRouting(t *testing.T) {
+	f := setupTest(t)
+	defer f.cleanup()
+
+	replicaDB, replicaMock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	require.NoError(t, err)
+	defer replicaDB.Close()
+
+	r := &replica{name: "0", db: replicaDB}
+	r.healthy.Store(true)
+	f.store.replicas = &replicaSet{
+		replicas: []*replica{r},
+		cfg:      PostgresConfig{ReadYourWritesWindow: time.Minute},
+		pins:     make(map[string]time.Time),
+	}
+
+	user := f.users[0]
+	ctx := WithClient(context.Background(), "client")
+
+	t.Run("reads go to the replica", func(t *testing.T) {
+		replicaMock.ExpectQuery(sqlGetUserByID).WithArgs(user.ID).WillReturnRows(userRow(user, nil))
+
+		_, err := f.store.GetUserByID(ctx, user.ID)
+		assert.NoError(t, err)
+		assert.NoError(t, replicaMock.ExpectationsWereMet())
+	})
+
+	t.Run("reads asking for the primary skip the replica", func(t *testing.T) {
+		f.mock.ExpectQuery(sqlGetUserByID).WithArgs(user.ID).WillReturnRows(userRow(user, nil))
+
+		_, err := f.store.GetUserByID(WithPrimaryReads(context.Background()), user.ID)
+		assert.NoError(t, err)
+		assert.NoError(t, f.mock.ExpectationsWereMet())
+	})
+
+	t.Run("writes pin the client to the primary", func(t *testing.T) {
+		f.mock.ExpectBegin()
+		f.mock.ExpectQuery(sqlLockUser).WithArgs(int64(2)).WillReturnRows(userRow(f.users[1], nil))
+		f.mock.ExpectQuery(sqlDeleteUser).WithArgs(int64(2)).
+			WillReturnRows(sqlmock.NewRows([]string{"deleted_at"}).AddRow(f.now))
+		expectChange(f.mock, 2, domain.AuditDelete)
+		f.mock.ExpectCommit()
+		f.mock.ExpectQuery(sqlGetUserByID).WithArgs(user.ID).WillReturnRows(userRow(user, nil))
+
+		assert.NoError(t, f.store.DeleteUser(ctx, 2))
+		_, err := f.store.GetUserByID(ctx, user.ID)
+		assert.NoError(t, err)
+		assert.NoError(t, f.mock.ExpectationsWereMet())
+	})
+
+	t.Run("unhealthy replicas fall back to the primary", func(t *testing.T) {
+		r.healthy.Store(false)
+		f.mock.ExpectQuery(sqlGetUserByID).WithArgs(user.ID).WillReturnRows(userRow(user, nil))
+
+		_, err := f.store.GetUserByID(context.Background(), user.ID)
+		assert.NoError(t, err)
+		assert.NoError(t, f.mock.ExpectationsWereMet())
+	})
+}
+
+func TestReplicaCheck(t *testing.T) {
+	replicaDB, replicaMock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	require.NoError(t, err)
+	defer replicaDB.Close()
+
+	r := &replica{name: "0", db: replicaDB}
+	rs := &replicaSet{
+		replicas: []*replica{r},
+		cfg:      PostgresConfig{ReplicaCheckInterval: time.Second, MaxReplicaLag: 5 * time.Second},
+		logger:   zap.NewNop(),
+	}
+
+	tests := []struct {
+		name      string
+		streaming bool
+		lag       float64
+		want      bool
+	}{
+		{name: "caught up", streaming: true, lag: 0, want: true},
+		{name: "lagging", streaming: true, lag: 30, want: false},
+		// A replica cut off from the primary has nothing left to replay, yet serves ever staler reads
+		{name: "receiver gone", streaming: false, lag: 0, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			replicaMock.ExpectQuery(sqlReplicaLag).
+				WillReturnRows(sqlmock.NewRows([]string{"streaming", "lag"}).AddRow(tt.streaming, tt.lag))
+
+			assert.Equal(t, tt.want, rs.check(r))
+			assert.NoError(t, replicaMock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestSoftDelete(t *testing.T) {
+	f := setupTest(t)
+	defer f.cleanup()
+
+	t.Run("get including deleted", func(t *testing.T) {
+		deletedAt := f.now.Add(time.Hour)
+		u := f.users[0]
+		f.mock.ExpectQuery(sqlGetUserByIDWithDeleted).
+			WithArgs(u.ID).
+			WillReturnRows(userRow(u, deletedAt))
+
+		got, err := f.store.GetUserByID(context.Background(), u.ID, IncludeDeleted())
+		require.NoError(t, err)
+		require.NotNil(t, got.DeletedAt)
+		assert.Equal(t, deletedAt, *got.DeletedAt)
+		assert.NoError(t, f.mock.ExpectationsWereMet())
+	})
+
+	t.Run("restore", func(t *testing.T) {
+		f.mock.ExpectBegin()
+		f.mock.ExpectQuery(sqlLockUserWithDeleted).WithArgs(int64(1)).WillReturnRows(userRow(f.users[0], f.now))
+		f.mock.ExpectExec(sqlRestoreUser).WithArgs(int64(1)).WillReturnResult(sqlmock.NewResult(0, 1))
+		f.mock.ExpectExec(sqlInsertAudit).
+			WithArgs(int64(1), "system", "", "restore", `{"deleted_at":{"old":"2023-01-01T12:00:00Z","new":null}}`).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		expectEvent(f.mock, 1, domain.EventUserRestored)
+		f.mock.ExpectCommit()
+		assert.NoError(t, f.store.RestoreUser(context.Background(), 1))
+		assert.NoError(t, f.mock.ExpectationsWereMet())
+	})
+
+	t.Run("restore live user", func(t *testing.T) {
+		f.mock.ExpectBegin()
+		f.mock.ExpectQuery(sqlLockUserWithDeleted).WithArgs(int64(2)).WillReturnRows(userRow(f.users[1], nil))
+		f.mock.ExpectRollback()
+		assert.Equal(t, ErrUserNotFound, f.store.RestoreUser(context.Background(), 2))
+		assert.NoError(t, f.mock.ExpectationsWereMet())
+	})
+
+	t.Run("restore with email taken", func(t *testing.T) {
+		f.mock.ExpectBegin()
+		f.mock.ExpectQuery(sqlLockUserWithDeleted).WithArgs(int64(3)).WillReturnRows(userRow(f.users[0], f.now))
+		f.mock.ExpectExec(sqlRestoreUser).WithArgs(int64(3)).
+			WillReturnError(&pq.Error{Code: "23505", Constraint: "users_email_key"})
+		f.mock.ExpectRollback()
+		assert.Equal(t, ErrDuplicateEmail, f.store.RestoreUser(context.Background(), 3))
+		assert.NoError(t, f.mock.ExpectationsWereMet())
+	})
+
+	t.Run("purge", func(t *testing.T) {
+		f.mock.ExpectExec(sqlPurgeUsers).WithArgs(f.now).WillReturnResult(sqlmock.NewResult(0, 5))
+		purged, err := f.store.PurgeDeletedUsers(context.Background(), f.now)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(5), purged)
+		assert.NoError(t, f.mock.ExpectationsWereMet())
+	})
+}
@@ -0,0 +1,83 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/huberts90/restful-api/internal/domain"
+)
+
+// ErrInvalidColumn is returned when a read selects a column users do not have
+var ErrInvalidColumn = errors.New("invalid column")
+
+// UserColumns are the columns of a user, in the order they are selected
+var UserColumns = []string{"id", "email", "first_name", "last_name", "created_at", "updated_at", "deleted_at"}
+
+// userColumnList is the select list of the user queries reading every column
+var userColumnList = strings.Join(UserColumns, ", ")
+
+// projectUserQuery narrows the select list of a user query to the given columns, the ID is always read
+// Returns the query along with the columns it selects, in the order they are scanned
+func projectUserQuery(query string, columns []string) (string, []string, error) {
+	if len(columns) == 0 {
+		return query, UserColumns, nil
+	}
+
+	wanted := map[string]bool{"id": true}
+	for _, column := range columns {
+		if !isUserColumn(column) {
+			return "", nil, fmt.Errorf("%w: %s", ErrInvalidColumn, column)
+		}
+		wanted[column] = true
+	}
+
+	// Keep the order of UserColumns, so that the same columns always make the same query
+	selected := make([]string, 0, len(wanted))
+	for _, column := range UserColumns {
+		if wanted[column] {
+			selected = append(selected, column)
+		}
+	}
+	return strings.Replace(query, userColumnList, strings.Join(selected, ", "), 1), selected, nil
+}
+
+func isUserColumn(column string) bool {
+	for _, c := range UserColumns {
+		if c == column {
+			return true
+		}
+	}
+	return false
+}
+
+// scanUserColumns scans the given columns of a user from a row, the other fields are left empty
+func scanUserColumns(row interface{ Scan(...interface{}) error }, columns []string) (*domain.User, error) {
+	user := &domain.User{}
+	dest := make([]interface{}, len(columns))
+	for i, column := range columns {
+		switch column {
+		case "id":
+			dest[i] = &user.ID
+		case "email":
+			dest[i] = &user.Email
+		case "first_name":
+			dest[i] = &user.FirstName
+		case "last_name":
+			dest[i] = &user.LastName
+		case "created_at":
+			dest[i] = &user.CreatedAt
+		case "updated_at":
+			dest[i] = &user.UpdatedAt
+		case "deleted_at":
+			dest[i] = &user.DeletedAt
+		default:
+			return nil, fmt.Errorf("%w: %s", ErrInvalidColumn, column)
+		}
+	}
+
+	if err := row.Scan(dest...); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
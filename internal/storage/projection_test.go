@@ -0,0 +1,61 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/huberts90/restful-api/internal/domain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetUserByID_SelectColumns(t *testing.T) {
+	f := setupTest(t)
+	defer f.cleanup()
+
+	user := f.users[0]
+	f.mock.ExpectQuery(`SELECT id, email, first_name FROM users WHERE id = $1 AND deleted_at IS NULL`).
+		WithArgs(user.ID).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "email", "first_name"}).AddRow(user.ID, user.Email, user.FirstName))
+
+	// The ID is always read, and the columns are selected in the order of the table whatever the order asked for
+	got, err := f.store.GetUserByID(context.Background(), user.ID, SelectColumns("first_name", "email", "first_name"))
+
+	require.NoError(t, err)
+	assert.Equal(t, &domain.User{ID: user.ID, Email: user.Email, FirstName: user.FirstName}, got)
+	assert.NoError(t, f.mock.ExpectationsWereMet())
+}
+
+func TestListUsers_SelectColumns(t *testing.T) {
+	f := setupTest(t)
+	defer f.cleanup()
+
+	f.mock.ExpectBegin()
+	f.mock.ExpectQuery(sqlCountUsersWithDeleted).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(len(f.users)))
+	rows := sqlmock.NewRows([]string{"id", "deleted_at"})
+	for _, u := range f.users {
+		rows.AddRow(u.ID, nil)
+	}
+	f.mock.ExpectQuery(`SELECT id, deleted_at FROM users ORDER BY id LIMIT $1 OFFSET $2`).WithArgs(10, 0).WillReturnRows(rows)
+	f.mock.ExpectCommit()
+
+	got, totalCount, err := f.store.ListUsers(context.Background(), 1, 10, IncludeDeleted(), SelectColumns("deleted_at"))
+
+	require.NoError(t, err)
+	assert.Equal(t, len(f.users), totalCount)
+	require.Len(t, got, len(f.users))
+	assert.Equal(t, domain.User{ID: f.users[0].ID}, got[0])
+	assert.NoError(t, f.mock.ExpectationsWereMet())
+}
+
+func TestSelectColumns_Invalid(t *testing.T) {
+	f := setupTest(t)
+	defer f.cleanup()
+
+	_, err := f.store.GetUserByID(context.Background(), 1, SelectColumns("email; DROP TABLE users"))
+
+	assert.True(t, errors.Is(err, ErrInvalidColumn), "got %v", err)
+	assert.NoError(t, f.mock.ExpectationsWereMet())
+}
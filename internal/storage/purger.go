@@ -0,0 +1,57 @@
+package storage
+
+import (
+	"context"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Purger periodically hard deletes users that have been soft deleted for longer than the retention period
+type Purger struct {
+	store     Storer
+	retention time.Duration
+	interval  time.Duration
+	logger    *zap.Logger
+}
+
+// NewPurger creates a new Purger
+func NewPurger(store Storer, retention, interval time.Duration, logger *zap.Logger) *Purger {
+	return &Purger{
+		store:     store,
+		retention: retention,
+		interval:  interval,
+		logger:    logger,
+	}
+}
+
+// Run purges deleted users every interval until ctx is done
+func (p *Purger) Run(ctx context.Context) {
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			p.PurgeOnce(ctx)
+		}
+	}
+}
+
+// PurgeOnce runs a single purge and returns the number of purged users
+func (p *Purger) PurgeOnce(ctx context.Context) int64 {
+	ctx, cancel := context.WithTimeout(ctx, p.interval)
+	defer cancel()
+
+	purged, err := p.store.PurgeDeletedUsers(ctx, time.Now().Add(-p.retention))
+	if err != nil {
+		p.logger.Error("failed to purge deleted users", zap.Error(err))
+		return 0
+	}
+	if purged > 0 {
+		p.logger.Info("purged deleted users", zap.Int64("count", purged), zap.Duration("retention", p.retention))
+	}
+	return purged
+}
@@ -0,0 +1,226 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"go.uber.org/zap"
+)
+
+// sqlReplicaLag measures how far behind the primary a replica is, and whether it still streams WAL from it
+// A replica that replayed all it received is caught up, however long ago the primary last wrote, so only
+// a replica with WAL left to replay lags by the age of its last replayed transaction. Zero is returned when
+// nothing has been replayed yet. A replica whose WAL receiver is gone has replayed all it will ever receive,
+// so it is only caught up while streaming. Reading the status takes the pg_read_all_stats role
+const sqlReplicaLag = `SELECT
+	EXISTS (SELECT 1 FROM pg_stat_wal_receiver WHERE status = 'streaming'),
+	CASE
+		WHEN pg_last_wal_receive_lsn() = pg_last_wal_replay_lsn() THEN 0
+		ELSE COALESCE(EXTRACT(EPOCH FROM NOW() - pg_last_xact_replay_timestamp()), 0)
+	END`
+
+var (
+	replicaHealthy = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "storage_replica_healthy",
+		Help: "Whether a read replica receives queries: 1 healthy, 0 unhealthy",
+	}, []string{"replica"})
+	replicaLagSeconds = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "storage_replica_lag_seconds",
+		Help: "Replication lag of a read replica",
+	}, []string{"replica"})
+)
+
+type clientKey struct{}
+
+// WithClient stores the key identifying the client in the context
+// Reads of a client that wrote recently are routed to the primary so that it sees its own writes
+func WithClient(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, clientKey{}, key)
+}
+
+func clientFromContext(ctx context.Context) string {
+	key, _ := ctx.Value(clientKey{}).(string)
+	return key
+}
+
+type primaryReadsKey struct{}
+
+// WithPrimaryReads routes the reads made with the context to the primary, whatever the client
+// Reads whose result outlives the request, such as cache fills, must not come from a lagging replica
+func WithPrimaryReads(ctx context.Context) context.Context {
+	return context.WithValue(ctx, primaryReadsKey{}, true)
+}
+
+func primaryReadsFromContext(ctx context.Context) bool {
+	primary, _ := ctx.Value(primaryReadsKey{}).(bool)
+	return primary
+}
+
+// replica is a read-only database connection pool
+type replica struct {
+	name    string
+	db      *sql.DB
+	healthy atomic.Bool
+}
+
+// replicaSet routes reads to healthy replicas in round robin
+type replicaSet struct {
+	replicas []*replica
+	next     atomic.Uint64
+	cfg      PostgresConfig
+	logger   *zap.Logger
+	stop     chan struct{}
+
+	// pins holds the time until which a client reads from the primary
+	mu   sync.Mutex
+	pins map[string]time.Time
+}
+
+// newReplicaSet opens the replica connection pools and starts monitoring them
+// Replicas that cannot be reached yet are marked unhealthy rather than failing the start up
+func newReplicaSet(cfg PostgresConfig, logger *zap.Logger) (*replicaSet, error) {
+	set := &replicaSet{
+		cfg:    cfg,
+		logger: logger,
+		stop:   make(chan struct{}),
+		pins:   make(map[string]time.Time),
+	}
+
+	for i, dsn := range cfg.ReplicaDSNs {
+		db, err := sql.Open("postgres", dsn)
+		if err != nil {
+			_ = set.close()
+			return nil, fmt.Errorf("failed to open replica connection: %w", err)
+		}
+		configurePool(db, cfg)
+		set.replicas = append(set.replicas, &replica{name: strconv.Itoa(i), db: db})
+	}
+
+	set.checkAll()
+	go set.monitor()
+
+	return set, nil
+}
+
+// pick returns the next healthy replica, or nil if there is none
+func (rs *replicaSet) pick() *replica {
+	n := uint64(len(rs.replicas))
+	start := rs.next.Add(1)
+	for i := uint64(0); i < n; i++ {
+		if r := rs.replicas[(start+i)%n]; r.healthy.Load() {
+			return r
+		}
+	}
+	return nil
+}
+
+// pin routes the reads of the client in ctx to the primary for the read-your-writes window
+func (rs *replicaSet) pin(ctx context.Context) {
+	key := clientFromContext(ctx)
+	if key == "" {
+		return
+	}
+
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	rs.pins[key] = time.Now().Add(rs.cfg.ReadYourWritesWindow)
+}
+
+// pinned reports whether the client in ctx must read from the primary
+func (rs *replicaSet) pinned(ctx context.Context) bool {
+	key := clientFromContext(ctx)
+	if key == "" {
+		return false
+	}
+
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	until, ok := rs.pins[key]
+	return ok && time.Now().Before(until)
+}
+
+// monitor periodically checks the replicas and forgets expired pins
+func (rs *replicaSet) monitor() {
+	ticker := time.NewTicker(rs.cfg.ReplicaCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-rs.stop:
+			return
+		case <-ticker.C:
+			rs.checkAll()
+			rs.sweepPins()
+		}
+	}
+}
+
+// checkAll marks replicas unhealthy when they are unreachable or lag beyond the threshold
+func (rs *replicaSet) checkAll() {
+	for _, r := range rs.replicas {
+		healthy := rs.check(r)
+		if r.healthy.Swap(healthy) != healthy {
+			rs.logger.Warn("replica health changed", zap.String("replica", r.name), zap.Bool("healthy", healthy))
+		}
+		if healthy {
+			replicaHealthy.WithLabelValues(r.name).Set(1)
+		} else {
+			replicaHealthy.WithLabelValues(r.name).Set(0)
+		}
+	}
+}
+
+func (rs *replicaSet) check(r *replica) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), rs.cfg.ReplicaCheckInterval)
+	defer cancel()
+
+	var streaming bool
+	var lag float64
+	if err := r.db.QueryRowContext(ctx, sqlReplicaLag).Scan(&streaming, &lag); err != nil {
+		rs.logger.Debug("failed to check replica", zap.String("replica", r.name), zap.Error(err))
+		return false
+	}
+	replicaLagSeconds.WithLabelValues(r.name).Set(lag)
+
+	if !streaming {
+		rs.logger.Debug("replica is not streaming from the primary", zap.String("replica", r.name))
+		return false
+	}
+	return lag <= rs.cfg.MaxReplicaLag.Seconds()
+}
+
+func (rs *replicaSet) sweepPins() {
+	now := time.Now()
+
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	for key, until := range rs.pins {
+		if now.After(until) {
+			delete(rs.pins, key)
+		}
+	}
+}
+
+// close stops the monitoring and closes the replica connection pools
+func (rs *replicaSet) close() error {
+	select {
+	case <-rs.stop:
+	default:
+		close(rs.stop)
+	}
+
+	var firstErr error
+	for _, r := range rs.replicas {
+		if err := r.db.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
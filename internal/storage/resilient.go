@@ -0,0 +1,398 @@
+package storage
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"math/rand/v2"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/huberts90/restful-api/internal/domain"
+	"github.com/lib/pq"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"go.uber.org/zap"
+)
+
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+// CircuitState is the state of the circuit breaker
+type CircuitState int
+
+const (
+	CircuitClosed CircuitState = iota
+	CircuitHalfOpen
+	CircuitOpen
+)
+
+// String returns the human-readable name of the state
+func (s CircuitState) String() string {
+	switch s {
+	case CircuitClosed:
+		return "closed"
+	case CircuitHalfOpen:
+		return "half-open"
+	case CircuitOpen:
+		return "open"
+	default:
+		return "unknown"
+	}
+}
+
+var (
+	circuitStateGauge = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "storage_circuit_state",
+		Help: "State of the storage circuit breaker: 0 closed, 1 half-open, 2 open",
+	})
+	storageRetriesTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "storage_retries_total",
+		Help: "Number of retried storage operations",
+	}, []string{"operation"})
+)
+
+// ResilientStore decorates a Storer with retries and a circuit breaker
+// Idempotent reads are retried with jittered backoff on transient errors, and all
+// operations fail fast with ErrCircuitOpen after repeated failures
+type ResilientStore struct {
+	Storer
+	cfg    ResilienceConfig
+	logger *zap.Logger
+	now    func() time.Time
+
+	mu       sync.Mutex
+	state    CircuitState
+	failures int
+	openedAt time.Time
+	probing  bool
+}
+
+// NewResilientStore creates a new ResilientStore wrapping the given store
+func NewResilientStore(store Storer, cfg ResilienceConfig, logger *zap.Logger) *ResilientStore {
+	circuitStateGauge.Set(float64(CircuitClosed))
+	return &ResilientStore{
+		Storer: store,
+		cfg:    cfg,
+		logger: logger,
+		now:    time.Now,
+	}
+}
+
+// CreateUser implements the Storer interface
+func (s *ResilientStore) CreateUser(ctx context.Context, user domain.UserCreate) (int64, error) {
+	var id int64
+	err := s.call(ctx, "CreateUser", false, func() error {
+		var err error
+		id, err = s.Storer.CreateUser(ctx, user)
+		return err
+	})
+	return id, err
+}
+
+// GetUserByID implements the Storer interface
+func (s *ResilientStore) GetUserByID(ctx context.Context, id int64, opts ...ReadOption) (*domain.User, error) {
+	var user *domain.User
+	err := s.call(ctx, "GetUserByID", true, func() error {
+		var err error
+		user, err = s.Storer.GetUserByID(ctx, id, opts...)
+		return err
+	})
+	return user, err
+}
+
+// GetUsersByIDs implements the Storer interface
+func (s *ResilientStore) GetUsersByIDs(ctx context.Context, ids []int64, opts ...ReadOption) ([]domain.User, error) {
+	var users []domain.User
+	err := s.call(ctx, "GetUsersByIDs", true, func() error {
+		var err error
+		users, err = s.Storer.GetUsersByIDs(ctx, ids, opts...)
+		return err
+	})
+	return users, err
+}
+
+// UpdateUser implements the Storer interface
+func (s *ResilientStore) UpdateUser(ctx context.Context, id int64, user domain.UserUpdate) error {
+	return s.call(ctx, "UpdateUser", false, func() error {
+		return s.Storer.UpdateUser(ctx, id, user)
+	})
+}
+
+// DeleteUser implements the Storer interface
+func (s *ResilientStore) DeleteUser(ctx context.Context, id int64) error {
+	return s.call(ctx, "DeleteUser", false, func() error {
+		return s.Storer.DeleteUser(ctx, id)
+	})
+}
+
+// RestoreUser implements the Storer interface
+func (s *ResilientStore) RestoreUser(ctx context.Context, id int64) error {
+	return s.call(ctx, "RestoreUser", false, func() error {
+		return s.Storer.RestoreUser(ctx, id)
+	})
+}
+
+// ReplaceUser implements the Storer interface
+func (s *ResilientStore) ReplaceUser(ctx context.Context, id int64, user domain.UserUpdate, active bool) error {
+	return s.call(ctx, "ReplaceUser", false, func() error {
+		return s.Storer.ReplaceUser(ctx, id, user, active)
+	})
+}
+
+// CreateUsers implements the Storer interface
+func (s *ResilientStore) CreateUsers(ctx context.Context, users []domain.UserCreate, atomic bool) ([]BatchResult, error) {
+	var results []BatchResult
+	err := s.call(ctx, "CreateUsers", false, func() error {
+		var err error
+		results, err = s.Storer.CreateUsers(ctx, users, atomic)
+		return err
+	})
+	return results, err
+}
+
+// UpdateUsers implements the Storer interface
+func (s *ResilientStore) UpdateUsers(ctx context.Context, updates []domain.UserBatchUpdate, atomic bool) ([]BatchResult, error) {
+	var results []BatchResult
+	err := s.call(ctx, "UpdateUsers", false, func() error {
+		var err error
+		results, err = s.Storer.UpdateUsers(ctx, updates, atomic)
+		return err
+	})
+	return results, err
+}
+
+// DeleteUsers implements the Storer interface
+func (s *ResilientStore) DeleteUsers(ctx context.Context, ids []int64, atomic bool) ([]BatchResult, error) {
+	var results []BatchResult
+	err := s.call(ctx, "DeleteUsers", false, func() error {
+		var err error
+		results, err = s.Storer.DeleteUsers(ctx, ids, atomic)
+		return err
+	})
+	return results, err
+}
+
+// ImportUsers implements the Storer interface
+func (s *ResilientStore) ImportUsers(ctx context.Context, users []domain.UserCreate, policy domain.DuplicatePolicy, dryRun bool) ([]ImportResult, error) {
+	var results []ImportResult
+	err := s.call(ctx, "ImportUsers", false, func() error {
+		var err error
+		results, err = s.Storer.ImportUsers(ctx, users, policy, dryRun)
+		return err
+	})
+	return results, err
+}
+
+// ExportUsers implements the Storer interface
+// It is not retried, as fn may already have been called with some of the users
+func (s *ResilientStore) ExportUsers(ctx context.Context, fn func(domain.User) error, opts ...ReadOption) error {
+	return s.call(ctx, "ExportUsers", false, func() error {
+		return s.Storer.ExportUsers(ctx, fn, opts...)
+	})
+}
+
+// PurgeDeletedUsers implements the Storer interface
+func (s *ResilientStore) PurgeDeletedUsers(ctx context.Context, deletedBefore time.Time) (int64, error) {
+	var purged int64
+	err := s.call(ctx, "PurgeDeletedUsers", false, func() error {
+		var err error
+		purged, err = s.Storer.PurgeDeletedUsers(ctx, deletedBefore)
+		return err
+	})
+	return purged, err
+}
+
+// ListUsers implements the Storer interface
+func (s *ResilientStore) ListUsers(ctx context.Context, page, pageSize int, opts ...ReadOption) ([]domain.User, int, error) {
+	var users []domain.User
+	var totalCount int
+	err := s.call(ctx, "ListUsers", true, func() error {
+		var err error
+		users, totalCount, err = s.Storer.ListUsers(ctx, page, pageSize, opts...)
+		return err
+	})
+	return users, totalCount, err
+}
+
+// ListUsersAfter implements the Storer interface
+func (s *ResilientStore) ListUsersAfter(ctx context.Context, afterID int64, limit int, opts ...ReadOption) (*UserPage, error) {
+	var page *UserPage
+	err := s.call(ctx, "ListUsersAfter", true, func() error {
+		var err error
+		page, err = s.Storer.ListUsersAfter(ctx, afterID, limit, opts...)
+		return err
+	})
+	return page, err
+}
+
+// SearchUsers implements the Storer interface
+func (s *ResilientStore) SearchUsers(ctx context.Context, filter UserFilter, offset, limit int, opts ...ReadOption) ([]domain.User, int, error) {
+	var users []domain.User
+	var totalCount int
+	err := s.call(ctx, "SearchUsers", true, func() error {
+		var err error
+		users, totalCount, err = s.Storer.SearchUsers(ctx, filter, offset, limit, opts...)
+		return err
+	})
+	return users, totalCount, err
+}
+
+// ListUserHistory implements the Storer interface
+func (s *ResilientStore) ListUserHistory(ctx context.Context, userID int64, page, pageSize int) ([]domain.AuditRecord, int, error) {
+	var records []domain.AuditRecord
+	var totalCount int
+	err := s.call(ctx, "ListUserHistory", true, func() error {
+		var err error
+		records, totalCount, err = s.Storer.ListUserHistory(ctx, userID, page, pageSize)
+		return err
+	})
+	return records, totalCount, err
+}
+
+// State returns the current state of the circuit breaker
+func (s *ResilientStore) State() CircuitState {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.currentState()
+}
+
+// call runs fn through the circuit breaker, retrying it on transient errors if it is idempotent
+func (s *ResilientStore) call(ctx context.Context, op string, idempotent bool, fn func() error) error {
+	if !s.allow() {
+		return ErrCircuitOpen
+	}
+
+	// fn is called at least once whatever the configuration, so that no call succeeds without running
+	attempts := 1
+	if idempotent {
+		attempts = max(1, s.cfg.RetryAttempts)
+	}
+
+	var err error
+	for attempt := 0; attempt < attempts; attempt++ {
+		if attempt > 0 {
+			storageRetriesTotal.WithLabelValues(op).Inc()
+			if !s.sleep(ctx, attempt) {
+				break
+			}
+		}
+		if err = fn(); err == nil || !isTransient(err) {
+			break
+		}
+		s.logger.Warn("transient storage error", zap.String("operation", op), zap.Int("attempt", attempt+1), zap.Error(err))
+	}
+
+	s.record(ctx, err)
+	return err
+}
+
+// sleep waits before the given retry attempt using exponential backoff with full jitter
+// It returns false if the context is done first
+func (s *ResilientStore) sleep(ctx context.Context, attempt int) bool {
+	// Doubling in a loop up to the maximum cannot overflow like a shift by the attempt would
+	backoff := s.cfg.RetryBaseDelay
+	for i := 1; i < attempt && backoff < s.cfg.RetryMaxDelay; i++ {
+		backoff *= 2
+	}
+	backoff = max(0, min(backoff, s.cfg.RetryMaxDelay))
+	delay := time.Duration(rand.Int64N(int64(backoff) + 1))
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+// allow reports whether a call may go through the circuit breaker
+// In the half-open state a single probe is let through to test the database
+func (s *ResilientStore) allow() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch s.currentState() {
+	case CircuitOpen:
+		return false
+	case CircuitHalfOpen:
+		if s.probing {
+			return false
+		}
+		s.probing = true
+	}
+	return true
+}
+
+// record updates the circuit breaker with the outcome of a call
+func (s *ResilientStore) record(ctx context.Context, err error) {
+	// Business errors and cancellations by the client say nothing about the database health
+	if err != nil && (!errors.Is(err, ErrDatabaseInternal) || errors.Is(ctx.Err(), context.Canceled)) {
+		err = nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	previous := s.currentState()
+	s.probing = false
+	if err == nil {
+		s.failures = 0
+		s.setState(previous, CircuitClosed)
+		return
+	}
+
+	s.failures++
+	if previous == CircuitHalfOpen || s.failures >= s.cfg.FailureThreshold {
+		s.openedAt = s.now()
+		s.setState(previous, CircuitOpen)
+	}
+}
+
+// currentState returns the state, moving from open to half-open once the open timeout elapsed
+// The caller must hold the lock
+func (s *ResilientStore) currentState() CircuitState {
+	if s.state == CircuitOpen && s.now().Sub(s.openedAt) >= s.cfg.OpenTimeout {
+		s.setState(CircuitOpen, CircuitHalfOpen)
+	}
+	return s.state
+}
+
+// setState changes the state and reports the transition
+// The caller must hold the lock
+func (s *ResilientStore) setState(from, to CircuitState) {
+	s.state = to
+	if from == to {
+		return
+	}
+	circuitStateGauge.Set(float64(to))
+	s.logger.Warn("circuit breaker state changed", zap.Stringer("from", from), zap.Stringer("to", to))
+}
+
+// isTransient reports whether an error is likely to go away when the operation is retried
+func isTransient(err error) bool {
+	if errors.Is(err, driver.ErrBadConn) || errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return true
+	}
+
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		switch pqErr.Code {
+		case "40001", // serialization_failure
+			"40P01", // deadlock_detected
+			"57P01", // admin_shutdown
+			"57P02", // crash_shutdown
+			"57P03": // cannot_connect_now
+			return true
+		}
+		// Class 08 - connection exception
+		return pqErr.Code.Class() == "08"
+	}
+
+	return false
+}
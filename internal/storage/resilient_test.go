@@ -0,0 +1,82 @@
+package storage_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/huberts90/restful-api/internal/domain"
+	"github.com/huberts90/restful-api/internal/storage"
+	storagemocks "github.com/huberts90/restful-api/internal/storage/mocks"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.uber.org/zap"
+)
+
+func testResilienceConfig() storage.ResilienceConfig {
+	return storage.ResilienceConfig{
+		RetryAttempts:    3,
+		RetryBaseDelay:   time.Millisecond,
+		RetryMaxDelay:    time.Millisecond,
+		FailureThreshold: 2,
+		OpenTimeout:      20 * time.Millisecond,
+	}
+}
+
+func transientErr() error {
+	return fmt.Errorf("%w: %w", storage.ErrDatabaseInternal, &pq.Error{Code: "57P01"})
+}
+
+func TestResilientStore_RetriesIdempotentReads(t *testing.T) {
+	mockStore := storagemocks.NewMockStorer(t)
+	store := storage.NewResilientStore(mockStore, testResilienceConfig(), zap.NewNop())
+
+	user := &domain.User{ID: 1}
+	mockStore.On("GetUserByID", mock.Anything, int64(1)).Return(nil, transientErr()).Twice()
+	mockStore.On("GetUserByID", mock.Anything, int64(1)).Return(user, nil).Once()
+
+	got, err := store.GetUserByID(context.Background(), 1)
+	assert.NoError(t, err)
+	assert.Equal(t, user, got)
+	assert.Equal(t, storage.CircuitClosed, store.State())
+}
+
+func TestResilientStore_DoesNotRetryWrites(t *testing.T) {
+	mockStore := storagemocks.NewMockStorer(t)
+	store := storage.NewResilientStore(mockStore, testResilienceConfig(), zap.NewNop())
+
+	mockStore.On("DeleteUser", mock.Anything, int64(1)).Return(transientErr()).Once()
+
+	err := store.DeleteUser(context.Background(), 1)
+	assert.ErrorIs(t, err, storage.ErrDatabaseInternal)
+}
+
+func TestResilientStore_CircuitBreaker(t *testing.T) {
+	mockStore := storagemocks.NewMockStorer(t)
+	store := storage.NewResilientStore(mockStore, testResilienceConfig(), zap.NewNop())
+	ctx := context.Background()
+
+	// Business errors do not count as failures
+	mockStore.On("UpdateUser", mock.Anything, int64(1), mock.Anything).Return(storage.ErrUserNotFound).Times(3)
+	for i := 0; i < 3; i++ {
+		assert.ErrorIs(t, store.UpdateUser(ctx, 1, domain.UserUpdate{}), storage.ErrUserNotFound)
+	}
+	assert.Equal(t, storage.CircuitClosed, store.State())
+
+	// Repeated failures open the circuit
+	mockStore.On("DeleteUser", mock.Anything, int64(1)).Return(transientErr()).Twice()
+	for i := 0; i < 2; i++ {
+		assert.ErrorIs(t, store.DeleteUser(ctx, 1), storage.ErrDatabaseInternal)
+	}
+	assert.Equal(t, storage.CircuitOpen, store.State())
+	assert.ErrorIs(t, store.DeleteUser(ctx, 1), storage.ErrCircuitOpen)
+
+	// After the timeout a successful probe closes it again
+	time.Sleep(30 * time.Millisecond)
+	assert.Equal(t, storage.CircuitHalfOpen, store.State())
+	mockStore.On("DeleteUser", mock.Anything, int64(2)).Return(nil).Once()
+	assert.NoError(t, store.DeleteUser(ctx, 2))
+	assert.Equal(t, storage.CircuitClosed, store.State())
+}
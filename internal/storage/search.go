@@ -0,0 +1,289 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/huberts90/restful-api/internal/domain"
+)
+
+const sqlSearchUsers = `SELECT id, email, first_name, last_name, created_at, updated_at, deleted_at FROM users WHERE `
+
+// UserField is a field of users that filters can test
+type UserField string
+
+const (
+	UserFieldID        UserField = "id"
+	UserFieldEmail     UserField = "email"
+	UserFieldFirstName UserField = "first_name"
+	UserFieldLastName  UserField = "last_name"
+	UserFieldCreatedAt UserField = "created_at"
+	UserFieldUpdatedAt UserField = "updated_at"
+)
+
+// textField reports whether a field holds text, text is compared case-insensitively
+func (f UserField) textField() bool {
+	return f == UserFieldEmail || f == UserFieldFirstName || f == UserFieldLastName
+}
+
+// FilterOp is a comparison made by a filter
+// Contains, StartsWith and EndsWith only apply to text fields
+type FilterOp string
+
+const (
+	FilterEq         FilterOp = "eq"
+	FilterNe         FilterOp = "ne"
+	FilterContains   FilterOp = "co"
+	FilterStartsWith FilterOp = "sw"
+	FilterEndsWith   FilterOp = "ew"
+	FilterGt         FilterOp = "gt"
+	FilterGe         FilterOp = "ge"
+	FilterLt         FilterOp = "lt"
+	FilterLe         FilterOp = "le"
+)
+
+var filterOperators = map[FilterOp]string{
+	FilterEq: "=",
+	FilterNe: "<>",
+	FilterGt: ">",
+	FilterGe: ">=",
+	FilterLt: "<",
+	FilterLe: "<=",
+}
+
+// UserFilter is a condition on users
+// The SQL it is turned into only ever refers to known columns, values are passed as arguments
+type UserFilter interface {
+	// Matches reports whether a user satisfies the filter, the same way the database does
+	Matches(user domain.User) bool
+	where(args *[]interface{}) string
+}
+
+// FilterCompare returns a filter comparing a field to a value
+// Values of text fields are strings, of ID fields int64 and of time fields time.Time
+func FilterCompare(field UserField, op FilterOp, value interface{}) UserFilter {
+	return compareFilter{field: field, op: op, value: value}
+}
+
+// FilterActive returns a filter on whether users are live or soft deleted
+func FilterActive(active bool) UserFilter {
+	return activeFilter(active)
+}
+
+// FilterAnd returns a filter matching users both filters match
+func FilterAnd(a, b UserFilter) UserFilter {
+	return logicalFilter{and: true, a: a, b: b}
+}
+
+// FilterOr returns a filter matching users either filter matches
+func FilterOr(a, b UserFilter) UserFilter {
+	return logicalFilter{a: a, b: b}
+}
+
+// FilterNot returns a filter matching users the filter does not match
+func FilterNot(f UserFilter) UserFilter {
+	return notFilter{f: f}
+}
+
+type compareFilter struct {
+	field UserField
+	op    FilterOp
+	value interface{}
+}
+
+func (f compareFilter) where(args *[]interface{}) string {
+	column := string(f.field)
+
+	if operator, ok := filterOperators[f.op]; ok {
+		*args = append(*args, f.value)
+		if f.field.textField() {
+			return fmt.Sprintf("lower(%s) %s lower($%d)", column, operator, len(*args))
+		}
+		return fmt.Sprintf("%s %s $%d", column, operator, len(*args))
+	}
+
+	pattern := escapeLike(fmt.Sprint(f.value))
+	switch f.op {
+	case FilterContains:
+		pattern = "%" + pattern + "%"
+	case FilterStartsWith:
+		pattern += "%"
+	default:
+		pattern = "%" + pattern
+	}
+	*args = append(*args, pattern)
+	return fmt.Sprintf("%s::text ILIKE $%d", column, len(*args))
+}
+
+func (f compareFilter) Matches(user domain.User) bool {
+	switch f.field {
+	case UserFieldID:
+		id, _ := f.value.(int64)
+		return compareOrdered(f.op, user.ID, id)
+	case UserFieldCreatedAt, UserFieldUpdatedAt:
+		t, _ := f.value.(time.Time)
+		actual := user.CreatedAt
+		if f.field == UserFieldUpdatedAt {
+			actual = user.UpdatedAt
+		}
+		return compareOrdered(f.op, actual.UnixNano(), t.UnixNano())
+	}
+
+	actual := user.Email
+	switch f.field {
+	case UserFieldFirstName:
+		actual = user.FirstName
+	case UserFieldLastName:
+		actual = user.LastName
+	}
+	actual, value := strings.ToLower(actual), strings.ToLower(fmt.Sprint(f.value))
+
+	switch f.op {
+	case FilterContains:
+		return strings.Contains(actual, value)
+	case FilterStartsWith:
+		return strings.HasPrefix(actual, value)
+	case FilterEndsWith:
+		return strings.HasSuffix(actual, value)
+	default:
+		return compareOrdered(f.op, actual, value)
+	}
+}
+
+func compareOrdered[T int64 | string](op FilterOp, actual, value T) bool {
+	switch op {
+	case FilterEq:
+		return actual == value
+	case FilterNe:
+		return actual != value
+	case FilterGt:
+		return actual > value
+	case FilterGe:
+		return actual >= value
+	case FilterLt:
+		return actual < value
+	case FilterLe:
+		return actual <= value
+	default:
+		return false
+	}
+}
+
+// escapeLike escapes the wildcards of a LIKE pattern
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
+
+type activeFilter bool
+
+func (f activeFilter) where(*[]interface{}) string {
+	if f {
+		return "deleted_at IS NULL"
+	}
+	return "deleted_at IS NOT NULL"
+}
+
+func (f activeFilter) Matches(user domain.User) bool {
+	return (user.DeletedAt == nil) == bool(f)
+}
+
+type logicalFilter struct {
+	and  bool
+	a, b UserFilter
+}
+
+func (f logicalFilter) where(args *[]interface{}) string {
+	operator := " OR "
+	if f.and {
+		operator = " AND "
+	}
+	return "(" + f.a.where(args) + operator + f.b.where(args) + ")"
+}
+
+func (f logicalFilter) Matches(user domain.User) bool {
+	if f.and {
+		return f.a.Matches(user) && f.b.Matches(user)
+	}
+	return f.a.Matches(user) || f.b.Matches(user)
+}
+
+type notFilter struct {
+	f UserFilter
+}
+
+func (f notFilter) where(args *[]interface{}) string {
+	return "NOT " + f.f.where(args)
+}
+
+func (f notFilter) Matches(user domain.User) bool {
+	return !f.f.Matches(user)
+}
+
+// SearchUsers retrieves up to limit users matching the filter after skipping offset of them, in ID order,
+// along with the number of users matching. A nil filter matches every user
+func (s *PostgresStore) SearchUsers(ctx context.Context, filter UserFilter, offset, limit int, opts ...ReadOption) ([]domain.User, int, error) {
+	if offset < 0 {
+		return nil, 0, ErrInvalidPage
+	}
+	if limit < 0 || limit > 100 {
+		return nil, 0, ErrInvalidPageSize
+	}
+
+	if !NewReadOptions(opts...).IncludeDeleted {
+		filter = andFilter(FilterActive(true), filter)
+	}
+	var args []interface{}
+	where := "TRUE"
+	if filter != nil {
+		where = filter.where(&args)
+	}
+
+	var users []domain.User
+	var totalCount int
+
+	err := s.withTx(ctx, true, func(tx *sql.Tx) error {
+		if err := tx.QueryRowContext(ctx, "SELECT COUNT(*) FROM users WHERE "+where, args...).Scan(&totalCount); err != nil {
+			return s.handleError(err, "failed to count users")
+		}
+		if limit == 0 {
+			return nil
+		}
+
+		query := sqlSearchUsers + where + fmt.Sprintf(" ORDER BY id LIMIT $%d OFFSET $%d", len(args)+1, len(args)+2)
+		rows, err := tx.QueryContext(ctx, query, append(args, limit, offset)...)
+		if err != nil {
+			return s.handleError(err, "failed to search users")
+		}
+		defer rows.Close()
+
+		users = make([]domain.User, 0, limit)
+		for rows.Next() {
+			user, err := s.scanUser(rows)
+			if err != nil {
+				return s.handleError(err, "failed to scan user row")
+			}
+			users = append(users, *user)
+		}
+
+		if err := rows.Err(); err != nil {
+			return s.handleError(err, "error iterating user rows")
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return users, totalCount, nil
+}
+
+// andFilter combines filters that may be nil
+func andFilter(a, b UserFilter) UserFilter {
+	if b == nil {
+		return a
+	}
+	return FilterAnd(a, b)
+}
@@ -0,0 +1,81 @@
+package storage
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/huberts90/restful-api/internal/domain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSearchUsers(t *testing.T) {
+	f := setupTest(t)
+	defer f.cleanup()
+
+	filter := FilterOr(
+		FilterCompare(UserFieldEmail, FilterEq, "Ann@Example.com"),
+		FilterNot(FilterCompare(UserFieldLastName, FilterStartsWith, "o_b%")),
+	)
+	where := `deleted_at IS NULL AND (lower(email) = lower($1) OR NOT last_name::text ILIKE $2)`
+
+	f.mock.ExpectBegin()
+	f.mock.ExpectQuery(`SELECT COUNT(*) FROM users WHERE (`+where+`)`).
+		WithArgs("Ann@Example.com", `o\_b\%%`).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(3))
+	f.mock.ExpectQuery(sqlSearchUsers+`(`+where+`) ORDER BY id LIMIT $3 OFFSET $4`).
+		WithArgs("Ann@Example.com", `o\_b\%%`, 1, 2).
+		WillReturnRows(userRow(f.users[0], nil))
+	f.mock.ExpectCommit()
+
+	users, totalCount, err := f.store.SearchUsers(context.Background(), filter, 2, 1)
+
+	require.NoError(t, err)
+	assert.Equal(t, 3, totalCount)
+	assert.Len(t, users, 1)
+	assert.NoError(t, f.mock.ExpectationsWereMet(), "SQL expectations not met")
+}
+
+func TestSearchUsers_CountOnly(t *testing.T) {
+	f := setupTest(t)
+	defer f.cleanup()
+
+	f.mock.ExpectBegin()
+	f.mock.ExpectQuery(`SELECT COUNT(*) FROM users WHERE TRUE`).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(7))
+	f.mock.ExpectCommit()
+
+	users, totalCount, err := f.store.SearchUsers(context.Background(), nil, 0, 0, IncludeDeleted())
+
+	require.NoError(t, err)
+	assert.Equal(t, 7, totalCount)
+	assert.Empty(t, users)
+	assert.NoError(t, f.mock.ExpectationsWereMet(), "SQL expectations not met")
+}
+
+func TestUserFilter_Matches(t *testing.T) {
+	created := time.Date(2023, 1, 1, 12, 0, 0, 0, time.UTC)
+	user := domain.User{ID: 7, Email: "ann.lee@example.com", FirstName: "Ann", LastName: "Lee", CreatedAt: created}
+
+	tests := []struct {
+		name   string
+		filter UserFilter
+		want   bool
+	}{
+		{name: "email is case insensitive", filter: FilterCompare(UserFieldEmail, FilterEq, "ANN.LEE@example.com"), want: true},
+		{name: "contains", filter: FilterCompare(UserFieldEmail, FilterContains, "lee@"), want: true},
+		{name: "ends with", filter: FilterCompare(UserFieldFirstName, FilterEndsWith, "x"), want: false},
+		{name: "ID greater than", filter: FilterCompare(UserFieldID, FilterGt, int64(7)), want: false},
+		{name: "created before", filter: FilterCompare(UserFieldCreatedAt, FilterLt, created.Add(time.Second)), want: true},
+		{name: "and", filter: FilterAnd(FilterActive(true), FilterCompare(UserFieldLastName, FilterNe, "lee")), want: false},
+		{name: "not inactive", filter: FilterNot(FilterActive(false)), want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.filter.Matches(user))
+		})
+	}
+}
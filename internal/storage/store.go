@@ -2,6 +2,7 @@ package storage
 
 import (
 	"context"
+	"time"
 
 	"github.com/huberts90/restful-api/internal/domain"
 )
@@ -11,9 +12,109 @@ import (
 // @MENTION_ME
 type Storer interface {
 	CreateUser(ctx context.Context, user domain.UserCreate) (int64, error)
-	GetUserByID(ctx context.Context, id int64) (*domain.User, error)
+	GetUserByID(ctx context.Context, id int64, opts ...ReadOption) (*domain.User, error)
+	GetUsersByIDs(ctx context.Context, ids []int64, opts ...ReadOption) ([]domain.User, error)
 	UpdateUser(ctx context.Context, id int64, user domain.UserUpdate) error
 	DeleteUser(ctx context.Context, id int64) error
-	ListUsers(ctx context.Context, page, pageSize int) ([]domain.User, int, error)
+	RestoreUser(ctx context.Context, id int64) error
+	ReplaceUser(ctx context.Context, id int64, user domain.UserUpdate, active bool) error
+	CreateUsers(ctx context.Context, users []domain.UserCreate, atomic bool) ([]BatchResult, error)
+	UpdateUsers(ctx context.Context, updates []domain.UserBatchUpdate, atomic bool) ([]BatchResult, error)
+	DeleteUsers(ctx context.Context, ids []int64, atomic bool) ([]BatchResult, error)
+	ImportUsers(ctx context.Context, users []domain.UserCreate, policy domain.DuplicatePolicy, dryRun bool) ([]ImportResult, error)
+	PurgeDeletedUsers(ctx context.Context, deletedBefore time.Time) (int64, error)
+	ListUsers(ctx context.Context, page, pageSize int, opts ...ReadOption) ([]domain.User, int, error)
+	ListUsersAfter(ctx context.Context, afterID int64, limit int, opts ...ReadOption) (*UserPage, error)
+	SearchUsers(ctx context.Context, filter UserFilter, offset, limit int, opts ...ReadOption) ([]domain.User, int, error)
+	ExportUsers(ctx context.Context, fn func(domain.User) error, opts ...ReadOption) error
+	ListUserHistory(ctx context.Context, userID int64, page, pageSize int) ([]domain.AuditRecord, int, error)
 	Close() error
 }
+
+// ReadOptions tune which users the read methods return
+type ReadOptions struct {
+	IncludeDeleted bool
+	Columns        []string // the columns to read, all of them if empty
+}
+
+// ReadOption configures ReadOptions
+type ReadOption func(*ReadOptions)
+
+// IncludeDeleted makes reads return soft deleted users as well
+func IncludeDeleted() ReadOption {
+	return func(o *ReadOptions) {
+		o.IncludeDeleted = true
+	}
+}
+
+// SelectColumns makes reads fill in only the given columns of the users, along with their ID
+// The columns are those of UserColumns
+func SelectColumns(columns ...string) ReadOption {
+	return func(o *ReadOptions) {
+		o.Columns = columns
+	}
+}
+
+// NewReadOptions applies the given options to the defaults
+func NewReadOptions(opts ...ReadOption) ReadOptions {
+	var o ReadOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// WebhookStorer defines the contract for storing webhook subscriptions and their deliveries
+type WebhookStorer interface {
+	CreateWebhook(ctx context.Context, webhook domain.WebhookCreate) (*domain.Webhook, error)
+	GetWebhook(ctx context.Context, id int64) (*domain.Webhook, error)
+	ListWebhooks(ctx context.Context) ([]domain.Webhook, error)
+	DeleteWebhook(ctx context.Context, id int64) error
+	EnqueueDeliveries(ctx context.Context, event domain.Event) (int64, error)
+	ClaimDeliveries(ctx context.Context, limit int, lease time.Duration) ([]ClaimedDelivery, error)
+	FinishDelivery(ctx context.Context, id int64, attempt int, result DeliveryResult) error
+	ListDeliveries(ctx context.Context, webhookID int64, page, pageSize int) ([]domain.WebhookDelivery, int, error)
+	Redeliver(ctx context.Context, webhookID, deliveryID int64) error
+}
+
+// EventStorer defines the contract for reading back past user lifecycle events
+type EventStorer interface {
+	EventsAfter(ctx context.Context, afterID int64, limit int) ([]domain.Event, error)
+}
+
+// JobStorer defines the contract for queueing background jobs and recording their progress
+// A claim is identified by the attempt it made, so that a worker whose lease expired cannot overwrite the next one
+type JobStorer interface {
+	EnqueueJob(ctx context.Context, jobType string, payload interface{}) (*domain.Job, error)
+	GetJob(ctx context.Context, id int64) (*domain.Job, error)
+	CancelJob(ctx context.Context, id int64) (*domain.Job, error)
+	ClaimJob(ctx context.Context, types []string, lease time.Duration) (*domain.Job, error)
+	HeartbeatJob(ctx context.Context, id int64, attempt int, progress domain.JobProgress, lease time.Duration) (bool, error)
+	FinishJob(ctx context.Context, id int64, attempt int, outcome JobOutcome) error
+	ReleaseJob(ctx context.Context, id int64, attempt int) error
+	PruneJobs(ctx context.Context, before time.Time) ([]int64, error)
+}
+
+// JobOutcome is how a claimed job ended
+type JobOutcome struct {
+	Status   domain.JobStatus
+	Progress domain.JobProgress
+	Result   interface{} // marshalled to JSON, nil if there is none
+	Error    string
+}
+
+// ClaimedDelivery is a due delivery along with the webhook it is sent to
+type ClaimedDelivery struct {
+	domain.WebhookDelivery
+	URL    string
+	Secret string
+}
+
+// DeliveryResult is the outcome of a delivery attempt
+// NextAttemptAt is only used when Status is pending
+type DeliveryResult struct {
+	Status        domain.DeliveryStatus
+	StatusCode    int // 0 if no response was received
+	Error         string
+	NextAttemptAt time.Time
+}
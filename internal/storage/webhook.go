@@ -0,0 +1,303 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/huberts90/restful-api/internal/domain"
+	"github.com/lib/pq"
+	"go.uber.org/zap"
+)
+
+var (
+	ErrWebhookNotFound  = errors.New("webhook not found")
+	ErrDeliveryNotFound = errors.New("delivery not found")
+	// ErrDeliveryLeaseLost is returned when a delivery was claimed again before its attempt was recorded
+	ErrDeliveryLeaseLost = errors.New("delivery lease lost")
+)
+
+const (
+	sqlCreateWebhook = `INSERT INTO webhook_subscriptions (url, events, secret, created_at) VALUES ($1, $2, $3, NOW()) RETURNING id, created_at`
+	sqlGetWebhook    = `SELECT id, url, events, secret, created_at FROM webhook_subscriptions WHERE id = $1`
+	sqlListWebhooks  = `SELECT id, url, events, secret, created_at FROM webhook_subscriptions ORDER BY id`
+	sqlDeleteWebhook = `DELETE FROM webhook_subscriptions WHERE id = $1`
+	// Subscriptions without an event filter receive all events
+	sqlEnqueueDeliveries = `INSERT INTO webhook_deliveries (subscription_id, event_id, event_type, payload) ` +
+		`SELECT id, $1, $2::varchar, $3 FROM webhook_subscriptions WHERE cardinality(events) = 0 OR $2::varchar = ANY(events) ` +
+		`ON CONFLICT (subscription_id, event_id) DO NOTHING`
+	// Claimed deliveries are leased by pushing their next attempt back, so that no transaction stays open while sending
+	sqlClaimDeliveries = `WITH claimed AS (` +
+		`UPDATE webhook_deliveries SET attempts = attempts + 1, next_attempt_at = NOW() + make_interval(secs => $2) ` +
+		`WHERE id IN (SELECT id FROM webhook_deliveries WHERE status = 'pending' AND next_attempt_at <= NOW() ORDER BY next_attempt_at, id LIMIT $1 FOR UPDATE SKIP LOCKED) ` +
+		`RETURNING id, subscription_id, event_id, event_type, payload, attempts, created_at) ` +
+		`SELECT c.id, c.subscription_id, c.event_id, c.event_type, c.payload, c.attempts, c.created_at, s.url, s.secret ` +
+		`FROM claimed c JOIN webhook_subscriptions s ON s.id = c.subscription_id ORDER BY c.id`
+	// Only the attempt holding the lease may record its outcome, a stale one must not overwrite a newer attempt
+	sqlFinishDelivery = `UPDATE webhook_deliveries SET status = $3, last_status_code = $4, last_error = $5, next_attempt_at = $6, ` +
+		`delivered_at = CASE WHEN $3 = 'delivered' THEN NOW() END WHERE id = $1 AND attempts = $2 AND status = 'pending'`
+	sqlCountDeliveries = `SELECT COUNT(*) FROM webhook_deliveries WHERE subscription_id = $1`
+	sqlListDeliveries  = `SELECT id, subscription_id, event_id, event_type, payload, status, attempts, next_attempt_at, last_status_code, last_error, created_at, delivered_at ` +
+		`FROM webhook_deliveries WHERE subscription_id = $1 ORDER BY id DESC LIMIT $2 OFFSET $3`
+	// Redelivering starts over with a fresh set of retries
+	sqlRedeliver = `UPDATE webhook_deliveries SET status = 'pending', attempts = 0, next_attempt_at = NOW(), delivered_at = NULL WHERE id = $1 AND subscription_id = $2`
+)
+
+// CreateWebhook registers a new webhook subscription
+func (s *PostgresStore) CreateWebhook(ctx context.Context, create domain.WebhookCreate) (*domain.Webhook, error) {
+	webhook := &domain.Webhook{
+		URL:    create.URL,
+		Events: create.Events,
+		Secret: create.Secret,
+	}
+	if webhook.Events == nil {
+		webhook.Events = []domain.EventType{}
+	}
+
+	err := s.db.QueryRowContext(ctx, sqlCreateWebhook, webhook.URL, pq.Array(eventTypeStrings(webhook.Events)), webhook.Secret).
+		Scan(&webhook.ID, &webhook.CreatedAt)
+	if err != nil {
+		return nil, s.handleError(err, "failed to create webhook")
+	}
+	return webhook, nil
+}
+
+// GetWebhook retrieves a webhook subscription by its ID
+func (s *PostgresStore) GetWebhook(ctx context.Context, id int64) (*domain.Webhook, error) {
+	if id <= 0 {
+		return nil, ErrInvalidID
+	}
+
+	webhook, err := scanWebhook(s.db.QueryRowContext(ctx, sqlGetWebhook, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrWebhookNotFound
+	}
+	if err != nil {
+		return nil, s.handleError(err, "failed to get webhook", zap.Int64("id", id))
+	}
+	return webhook, nil
+}
+
+// ListWebhooks retrieves all webhook subscriptions
+func (s *PostgresStore) ListWebhooks(ctx context.Context) ([]domain.Webhook, error) {
+	rows, err := s.db.QueryContext(ctx, sqlListWebhooks)
+	if err != nil {
+		return nil, s.handleError(err, "failed to query webhooks")
+	}
+	defer rows.Close()
+
+	webhooks := make([]domain.Webhook, 0)
+	for rows.Next() {
+		webhook, err := scanWebhook(rows)
+		if err != nil {
+			return nil, s.handleError(err, "failed to scan webhook row")
+		}
+		webhooks = append(webhooks, *webhook)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, s.handleError(err, "error iterating webhook rows")
+	}
+	return webhooks, nil
+}
+
+// DeleteWebhook removes a webhook subscription along with its deliveries
+func (s *PostgresStore) DeleteWebhook(ctx context.Context, id int64) error {
+	if id <= 0 {
+		return ErrInvalidID
+	}
+
+	result, err := s.db.ExecContext(ctx, sqlDeleteWebhook, id)
+	if err != nil {
+		return s.handleError(err, "failed to delete webhook", zap.Int64("id", id))
+	}
+	return s.expectAffected(result, ErrWebhookNotFound)
+}
+
+// EnqueueDeliveries schedules the delivery of an event to every webhook subscribed to it
+// Enqueueing the same event again is a no-op, it returns the number of new deliveries
+func (s *PostgresStore) EnqueueDeliveries(ctx context.Context, event domain.Event) (int64, error) {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return 0, s.handleError(err, "failed to marshal event", zap.Int64("event_id", event.ID))
+	}
+
+	result, err := s.db.ExecContext(ctx, sqlEnqueueDeliveries, event.ID, string(event.Type), string(payload))
+	if err != nil {
+		return 0, s.handleError(err, "failed to enqueue deliveries", zap.Int64("event_id", event.ID))
+	}
+
+	enqueued, err := result.RowsAffected()
+	if err != nil {
+		return 0, s.handleError(err, "failed to get affected rows")
+	}
+	return enqueued, nil
+}
+
+// ClaimDeliveries claims up to limit due deliveries for the given lease
+// A claimed delivery that is not finished within the lease is claimed again, as a new attempt
+func (s *PostgresStore) ClaimDeliveries(ctx context.Context, limit int, lease time.Duration) ([]ClaimedDelivery, error) {
+	rows, err := s.db.QueryContext(ctx, sqlClaimDeliveries, limit, lease.Seconds())
+	if err != nil {
+		return nil, s.handleError(err, "failed to claim deliveries")
+	}
+	defer rows.Close()
+
+	claimed := make([]ClaimedDelivery, 0, limit)
+	for rows.Next() {
+		var d ClaimedDelivery
+		var eventType string
+		var payload []byte
+		err := rows.Scan(&d.ID, &d.WebhookID, &d.EventID, &eventType, &payload, &d.Attempts, &d.CreatedAt, &d.URL, &d.Secret)
+		if err != nil {
+			return nil, s.handleError(err, "failed to scan claimed delivery row")
+		}
+		d.EventType = domain.EventType(eventType)
+		d.Payload = payload
+		d.Status = domain.DeliveryPending
+		claimed = append(claimed, d)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, s.handleError(err, "error iterating claimed delivery rows")
+	}
+	return claimed, nil
+}
+
+// FinishDelivery records the outcome of a delivery attempt, it returns ErrDeliveryLeaseLost if it was claimed again
+func (s *PostgresStore) FinishDelivery(ctx context.Context, id int64, attempt int, result DeliveryResult) error {
+	var statusCode sql.NullInt64
+	if result.StatusCode != 0 {
+		statusCode = sql.NullInt64{Int64: int64(result.StatusCode), Valid: true}
+	}
+	var lastError sql.NullString
+	if result.Error != "" {
+		lastError = sql.NullString{String: result.Error, Valid: true}
+	}
+	nextAttemptAt := result.NextAttemptAt
+	if result.Status != domain.DeliveryPending {
+		nextAttemptAt = time.Now()
+	}
+
+	res, err := s.db.ExecContext(ctx, sqlFinishDelivery, id, attempt, string(result.Status), statusCode, lastError, nextAttemptAt)
+	if err != nil {
+		return s.handleError(err, "failed to finish delivery", zap.Int64("id", id))
+	}
+	return s.expectAffected(res, ErrDeliveryLeaseLost)
+}
+
+// ListDeliveries retrieves a paginated delivery log of a webhook, newest first
+func (s *PostgresStore) ListDeliveries(ctx context.Context, webhookID int64, page, pageSize int) ([]domain.WebhookDelivery, int, error) {
+	if webhookID <= 0 {
+		return nil, 0, ErrInvalidID
+	}
+	if page < 1 {
+		return nil, 0, ErrInvalidPage
+	}
+	if pageSize < 1 || pageSize > 100 {
+		return nil, 0, ErrInvalidPageSize
+	}
+
+	var deliveries []domain.WebhookDelivery
+	var totalCount int
+
+	err := s.withTx(ctx, true, func(tx *sql.Tx) error {
+		if err := tx.QueryRowContext(ctx, sqlCountDeliveries, webhookID).Scan(&totalCount); err != nil {
+			return s.handleError(err, "failed to count deliveries")
+		}
+
+		rows, err := tx.QueryContext(ctx, sqlListDeliveries, webhookID, pageSize, (page-1)*pageSize)
+		if err != nil {
+			return s.handleError(err, "failed to query deliveries")
+		}
+		defer rows.Close()
+
+		deliveries = make([]domain.WebhookDelivery, 0, pageSize)
+		for rows.Next() {
+			var d domain.WebhookDelivery
+			var eventType, status string
+			var payload []byte
+			var statusCode sql.NullInt64
+			var lastError sql.NullString
+			var deliveredAt sql.NullTime
+			err := rows.Scan(&d.ID, &d.WebhookID, &d.EventID, &eventType, &payload, &status, &d.Attempts,
+				&d.NextAttemptAt, &statusCode, &lastError, &d.CreatedAt, &deliveredAt)
+			if err != nil {
+				return s.handleError(err, "failed to scan delivery row")
+			}
+			d.EventType = domain.EventType(eventType)
+			d.Status = domain.DeliveryStatus(status)
+			d.Payload = payload
+			if statusCode.Valid {
+				code := int(statusCode.Int64)
+				d.LastStatusCode = &code
+			}
+			if lastError.Valid {
+				d.LastError = &lastError.String
+			}
+			if deliveredAt.Valid {
+				d.DeliveredAt = &deliveredAt.Time
+			}
+			deliveries = append(deliveries, d)
+		}
+
+		if err = rows.Err(); err != nil {
+			return s.handleError(err, "error iterating delivery rows")
+		}
+		return nil
+	})
+
+	if err != nil {
+		return nil, 0, err
+	}
+	return deliveries, totalCount, nil
+}
+
+// Redeliver schedules a delivery of a webhook to be sent again right away, whatever its state
+func (s *PostgresStore) Redeliver(ctx context.Context, webhookID, deliveryID int64) error {
+	if webhookID <= 0 || deliveryID <= 0 {
+		return ErrInvalidID
+	}
+
+	result, err := s.db.ExecContext(ctx, sqlRedeliver, deliveryID, webhookID)
+	if err != nil {
+		return s.handleError(err, "failed to redeliver", zap.Int64("id", deliveryID))
+	}
+	return s.expectAffected(result, ErrDeliveryNotFound)
+}
+
+// expectAffected returns notFound if the statement affected no rows
+func (s *PostgresStore) expectAffected(result sql.Result, notFound error) error {
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return s.handleError(err, "failed to get affected rows")
+	}
+	if affected == 0 {
+		return notFound
+	}
+	return nil
+}
+
+func scanWebhook(row interface{ Scan(...interface{}) error }) (*domain.Webhook, error) {
+	var webhook domain.Webhook
+	var events pq.StringArray
+	if err := row.Scan(&webhook.ID, &webhook.URL, &events, &webhook.Secret, &webhook.CreatedAt); err != nil {
+		return nil, err
+	}
+
+	webhook.Events = make([]domain.EventType, len(events))
+	for i, e := range events {
+		webhook.Events[i] = domain.EventType(e)
+	}
+	return &webhook, nil
+}
+
+func eventTypeStrings(types []domain.EventType) []string {
+	out := make([]string, len(types))
+	for i, t := range types {
+		out[i] = string(t)
+	}
+	return out
+}
@@ -0,0 +1,109 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/huberts90/restful-api/internal/domain"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCreateWebhook(t *testing.T) {
+	f := setupTest(t)
+	defer f.cleanup()
+
+	f.mock.ExpectQuery(sqlCreateWebhook).
+		WithArgs("https://partner.example.com/hooks", pq.Array([]string{}), "0123456789abcdef").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow(4, f.now))
+
+	got, err := f.store.CreateWebhook(context.Background(), domain.WebhookCreate{
+		URL:    "https://partner.example.com/hooks",
+		Secret: "0123456789abcdef",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, int64(4), got.ID)
+	assert.Empty(t, got.Events)
+	assert.NoError(t, f.mock.ExpectationsWereMet())
+}
+
+func TestEnqueueDeliveries(t *testing.T) {
+	f := setupTest(t)
+	defer f.cleanup()
+
+	event := domain.Event{ID: 7, Type: domain.EventUserCreated, UserID: 1, OccurredAt: f.now, Data: json.RawMessage(`{"id":1}`)}
+	payload, err := json.Marshal(event)
+	require.NoError(t, err)
+
+	f.mock.ExpectExec(sqlEnqueueDeliveries).
+		WithArgs(int64(7), "user.created", string(payload)).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+
+	enqueued, err := f.store.EnqueueDeliveries(context.Background(), event)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), enqueued)
+	assert.NoError(t, f.mock.ExpectationsWereMet())
+}
+
+func TestClaimDeliveries(t *testing.T) {
+	f := setupTest(t)
+	defer f.cleanup()
+
+	f.mock.ExpectQuery(sqlClaimDeliveries).
+		WithArgs(10, float64(15)).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "subscription_id", "event_id", "event_type", "payload", "attempts", "created_at", "url", "secret"}).
+			AddRow(42, 1, 7, "user.created", []byte(`{"id":7}`), 1, f.now, "https://partner.example.com/hooks", "0123456789abcdef"))
+
+	claimed, err := f.store.ClaimDeliveries(context.Background(), 10, 15*time.Second)
+	require.NoError(t, err)
+	require.Len(t, claimed, 1)
+	assert.Equal(t, int64(42), claimed[0].ID)
+	assert.Equal(t, domain.DeliveryPending, claimed[0].Status)
+	assert.Equal(t, "https://partner.example.com/hooks", claimed[0].URL)
+	assert.NoError(t, f.mock.ExpectationsWereMet())
+}
+
+func TestFinishDelivery(t *testing.T) {
+	f := setupTest(t)
+	defer f.cleanup()
+
+	result := DeliveryResult{Status: domain.DeliveryPending, StatusCode: 503, Error: "receiver returned 503 Service Unavailable", NextAttemptAt: f.now}
+
+	t.Run("success", func(t *testing.T) {
+		f.mock.ExpectExec(sqlFinishDelivery).
+			WithArgs(int64(42), 2, "pending", sql.NullInt64{Int64: 503, Valid: true}, sql.NullString{String: result.Error, Valid: true}, f.now).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		assert.NoError(t, f.store.FinishDelivery(context.Background(), 42, 2, result))
+		assert.NoError(t, f.mock.ExpectationsWereMet())
+	})
+
+	t.Run("claimed again", func(t *testing.T) {
+		f.mock.ExpectExec(sqlFinishDelivery).
+			WithArgs(int64(42), 2, "pending", sql.NullInt64{Int64: 503, Valid: true}, sql.NullString{String: result.Error, Valid: true}, f.now).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+		assert.Equal(t, ErrDeliveryLeaseLost, f.store.FinishDelivery(context.Background(), 42, 2, result))
+		assert.NoError(t, f.mock.ExpectationsWereMet())
+	})
+}
+
+func TestRedeliver(t *testing.T) {
+	f := setupTest(t)
+	defer f.cleanup()
+
+	t.Run("success", func(t *testing.T) {
+		f.mock.ExpectExec(sqlRedeliver).WithArgs(int64(5), int64(1)).WillReturnResult(sqlmock.NewResult(0, 1))
+		assert.NoError(t, f.store.Redeliver(context.Background(), 1, 5))
+		assert.NoError(t, f.mock.ExpectationsWereMet())
+	})
+
+	t.Run("delivery of another webhook", func(t *testing.T) {
+		f.mock.ExpectExec(sqlRedeliver).WithArgs(int64(5), int64(2)).WillReturnResult(sqlmock.NewResult(0, 0))
+		assert.Equal(t, ErrDeliveryNotFound, f.store.Redeliver(context.Background(), 2, 5))
+		assert.NoError(t, f.mock.ExpectationsWereMet())
+	})
+}
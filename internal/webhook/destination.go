@@ -0,0 +1,65 @@
+package webhook
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"net/url"
+	"strings"
+	"syscall"
+)
+
+// ErrPrivateDestination is returned for webhook URLs that point into a private network
+var ErrPrivateDestination = errors.New("webhook destination is not a public address")
+
+// nonPublicPrefixes are the ranges netip has no predicate for that must not be reached either
+var nonPublicPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),     // "this network", reaches the host itself on Linux
+	netip.MustParsePrefix("100.64.0.0/10"), // carrier-grade NAT
+}
+
+// CheckURL rejects webhook URLs whose host is a loopback, private, link-local or unspecified address
+// Host names are only resolved when a delivery is sent, and the address they resolve to is checked then
+func CheckURL(rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return err
+	}
+
+	host := strings.ToLower(strings.TrimSuffix(u.Hostname(), "."))
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return fmt.Errorf("%w: %s", ErrPrivateDestination, host)
+	}
+	if addr, err := netip.ParseAddr(host); err == nil {
+		return checkAddr(addr)
+	}
+	return nil
+}
+
+// Helper function to reject an address that is not reachable on the public internet
+func checkAddr(addr netip.Addr) error {
+	addr = addr.Unmap().WithZone("")
+	public := addr.IsGlobalUnicast() && !addr.IsPrivate()
+	for _, prefix := range nonPublicPrefixes {
+		public = public && !prefix.Contains(addr)
+	}
+	if !public {
+		return fmt.Errorf("%w: %s", ErrPrivateDestination, addr)
+	}
+	return nil
+}
+
+// dialControl checks the address a connection is about to be made to, after the host name is resolved,
+// so that a name resolving to a private address cannot be used to reach the internal network
+func dialControl(_, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return err
+	}
+	return checkAddr(addr)
+}
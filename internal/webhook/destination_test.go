@@ -0,0 +1,80 @@
+package webhook
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/huberts90/restful-api/internal/domain"
+	"github.com/huberts90/restful-api/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestCheckURL(t *testing.T) {
+	tests := []struct {
+		url     string
+		wantErr bool
+	}{
+		{url: "https://partner.example.com/hooks"},
+		{url: "https://93.184.215.14/hooks"},
+		{url: "https://[2606:2800:21f:cb07:6820:80da:af6b:8b2c]/hooks"},
+		{url: "http://localhost:8080/hooks", wantErr: true},
+		{url: "http://api.localhost./hooks", wantErr: true},
+		{url: "http://127.0.0.1/hooks", wantErr: true},
+		{url: "http://[::1]/hooks", wantErr: true},
+		{url: "http://[::ffff:10.0.0.1]/hooks", wantErr: true},
+		{url: "http://10.1.2.3/hooks", wantErr: true},
+		{url: "http://192.168.0.10/hooks", wantErr: true},
+		{url: "http://169.254.169.254/latest/meta-data", wantErr: true},
+		{url: "http://[fe80::1%25eth0]/hooks", wantErr: true},
+		{url: "http://0.0.0.0/hooks", wantErr: true},
+		{url: "http://100.64.0.1/hooks", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.url, func(t *testing.T) {
+			err := CheckURL(tt.url)
+			if tt.wantErr {
+				assert.ErrorIs(t, err, ErrPrivateDestination)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestSend_Destinations(t *testing.T) {
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/redirect" {
+			http.Redirect(w, r, "/hooks", http.StatusTemporaryRedirect)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer receiver.Close()
+
+	delivery := storage.ClaimedDelivery{
+		WebhookDelivery: domain.WebhookDelivery{ID: 1, EventType: domain.EventUserCreated, Payload: []byte(`{}`)},
+		Secret:          "top-secret-value",
+	}
+
+	t.Run("private address refused when dialing", func(t *testing.T) {
+		d := NewDispatcher(nil, Config{Timeout: time.Second}, zap.NewNop())
+		delivery.URL = receiver.URL + "/hooks"
+		_, err := d.send(context.Background(), delivery)
+		assert.ErrorIs(t, err, ErrPrivateDestination)
+	})
+
+	t.Run("redirect not followed", func(t *testing.T) {
+		d := NewDispatcher(nil, Config{Timeout: time.Second, AllowPrivateNetworks: true}, zap.NewNop())
+		delivery.URL = receiver.URL + "/redirect"
+		statusCode, err := d.send(context.Background(), delivery)
+		require.Error(t, err)
+		assert.Equal(t, http.StatusTemporaryRedirect, statusCode)
+		assert.Equal(t, "receiver returned 307 Temporary Redirect", err.Error())
+	})
+}
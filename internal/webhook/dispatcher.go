@@ -0,0 +1,218 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/huberts90/restful-api/internal/domain"
+	"github.com/huberts90/restful-api/internal/storage"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"go.uber.org/zap"
+)
+
+const (
+	SignatureHeader = "X-Webhook-Signature"
+	TimestampHeader = "X-Webhook-Timestamp"
+	EventHeader     = "X-Webhook-Event"
+	DeliveryHeader  = "X-Webhook-Delivery"
+)
+
+var deliveryAttemptsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "webhook_delivery_attempts_total",
+	Help: "Number of webhook delivery attempts by outcome: delivered, retry or dead",
+}, []string{"outcome"})
+
+// Config holds the webhook delivery configuration
+type Config struct {
+	Enabled      bool
+	PollInterval time.Duration
+	BatchSize    int
+	Timeout      time.Duration // per delivery attempt
+	MaxAttempts  int           // attempts before a delivery is dead-lettered
+	BaseDelay    time.Duration // delay before the first retry, doubled for each further one
+	MaxDelay     time.Duration
+	// AllowPrivateNetworks lets webhooks be sent to loopback and private addresses, for local development only
+	AllowPrivateNetworks bool
+}
+
+// Dispatcher sends due webhook deliveries and schedules retries of failed ones
+type Dispatcher struct {
+	store  storage.WebhookStorer
+	client *http.Client
+	cfg    Config
+	logger *zap.Logger
+	now    func() time.Time
+}
+
+// NewDispatcher creates a new Dispatcher
+func NewDispatcher(store storage.WebhookStorer, cfg Config, logger *zap.Logger) *Dispatcher {
+	return &Dispatcher{
+		store:  store,
+		client: newClient(cfg),
+		cfg:    cfg,
+		logger: logger,
+		now:    time.Now,
+	}
+}
+
+// Helper function to create the client deliveries are sent with
+// Unless private networks are allowed, it only connects to public addresses and bypasses any proxy, whose
+// own address would otherwise be the one checked. Redirects are not followed, a 3xx response is a failure
+func newClient(cfg Config) *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if !cfg.AllowPrivateNetworks {
+		dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second, Control: dialControl}
+		transport.DialContext = dialer.DialContext
+		transport.Proxy = nil
+	}
+	return &http.Client{
+		Timeout:   cfg.Timeout,
+		Transport: transport,
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// HandleEvent enqueues the deliveries of an event to the subscribed webhooks
+// It is meant to be subscribed to the in-process event publisher
+func (d *Dispatcher) HandleEvent(ctx context.Context, event domain.Event) error {
+	_, err := d.store.EnqueueDeliveries(ctx, event)
+	return err
+}
+
+// Run sends due deliveries every poll interval until ctx is done
+func (d *Dispatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(d.cfg.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			d.DispatchOnce(ctx)
+		}
+	}
+}
+
+// DispatchOnce sends a batch of due deliveries and returns the number of attempts made
+func (d *Dispatcher) DispatchOnce(ctx context.Context) int {
+	// A delivery is leased for a bit longer than an attempt may take, so it is not sent twice at once
+	lease := d.cfg.Timeout + 5*time.Second
+	deliveries, err := d.store.ClaimDeliveries(ctx, d.cfg.BatchSize, lease)
+	if err != nil {
+		d.logger.Error("failed to claim webhook deliveries", zap.Error(err))
+		return 0
+	}
+
+	// The batch is sent at once, one slow receiver after another would outlast the lease of the rest
+	var wg sync.WaitGroup
+	for _, delivery := range deliveries {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			d.deliver(ctx, delivery)
+		}()
+	}
+	wg.Wait()
+	return len(deliveries)
+}
+
+// deliver makes an attempt of a claimed delivery and records its outcome
+func (d *Dispatcher) deliver(ctx context.Context, delivery storage.ClaimedDelivery) {
+	result := d.attempt(ctx, delivery)
+
+	// The outcome must be recorded even if ctx ended during the attempt
+	finishCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 5*time.Second)
+	defer cancel()
+	err := d.store.FinishDelivery(finishCtx, delivery.ID, delivery.Attempts, result)
+	switch {
+	case errors.Is(err, storage.ErrDeliveryLeaseLost):
+		d.logger.Warn("webhook delivery was claimed again before its attempt was recorded", zap.Int64("id", delivery.ID))
+	case err != nil:
+		d.logger.Error("failed to record webhook delivery", zap.Error(err), zap.Int64("id", delivery.ID))
+	}
+}
+
+// attempt sends a delivery and decides what happens next
+func (d *Dispatcher) attempt(ctx context.Context, delivery storage.ClaimedDelivery) storage.DeliveryResult {
+	statusCode, err := d.send(ctx, delivery)
+	if err == nil {
+		deliveryAttemptsTotal.WithLabelValues("delivered").Inc()
+		return storage.DeliveryResult{Status: domain.DeliveryDelivered, StatusCode: statusCode}
+	}
+
+	result := storage.DeliveryResult{
+		Status:     domain.DeliveryPending,
+		StatusCode: statusCode,
+		Error:      err.Error(),
+	}
+	if delivery.Attempts >= d.cfg.MaxAttempts {
+		deliveryAttemptsTotal.WithLabelValues("dead").Inc()
+		result.Status = domain.DeliveryDead
+		d.logger.Warn("webhook delivery dead-lettered", zap.Int64("id", delivery.ID), zap.Int64("webhook_id", delivery.WebhookID), zap.Error(err))
+		return result
+	}
+
+	deliveryAttemptsTotal.WithLabelValues("retry").Inc()
+	result.NextAttemptAt = d.now().Add(d.backoff(delivery.Attempts))
+	return result
+}
+
+// send posts the payload of a delivery, any status other than 2xx is a failure
+func (d *Dispatcher) send(ctx context.Context, delivery storage.ClaimedDelivery) (int, error) {
+	timestamp := strconv.FormatInt(d.now().Unix(), 10)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, delivery.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(TimestampHeader, timestamp)
+	req.Header.Set(SignatureHeader, Sign(delivery.Secret, timestamp, delivery.Payload))
+	req.Header.Set(EventHeader, string(delivery.EventType))
+	req.Header.Set(DeliveryHeader, strconv.FormatInt(delivery.ID, 10))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	// The body is not kept, the delivery log is readable through the API and must not echo what the receiver says
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("receiver returned %s", resp.Status)
+	}
+	return resp.StatusCode, nil
+}
+
+// backoff returns the delay before the retry following the given attempt
+func (d *Dispatcher) backoff(attempts int) time.Duration {
+	delay := d.cfg.BaseDelay
+	for i := 1; i < attempts && delay < d.cfg.MaxDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, d.cfg.MaxDelay)
+}
+
+// Sign computes the signature header value of a payload sent at the given Unix timestamp
+// Receivers recompute it as hex(HMAC-SHA256(secret, timestamp + "." + body)) and should reject stale timestamps
+func Sign(secret, timestamp string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(payload)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,137 @@
+package webhook
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/huberts90/restful-api/internal/domain"
+	"github.com/huberts90/restful-api/internal/storage"
+	storagemocks "github.com/huberts90/restful-api/internal/storage/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestSign(t *testing.T) {
+	// Reference value computed with: printf '1700000000.{"id":1}' | openssl dgst -sha256 -hmac secret
+	assert.Equal(t,
+		"sha256=3dd1b9aef568d75f6790a84bd2e5dfa1f44409eef3cbdbd3f10b837376100c11",
+		Sign("secret", "1700000000", []byte(`{"id":1}`)),
+	)
+}
+
+func TestBackoff(t *testing.T) {
+	d := NewDispatcher(nil, Config{BaseDelay: time.Second, MaxDelay: 10 * time.Second}, zap.NewNop())
+
+	assert.Equal(t, time.Second, d.backoff(1))
+	assert.Equal(t, 2*time.Second, d.backoff(2))
+	assert.Equal(t, 8*time.Second, d.backoff(4))
+	assert.Equal(t, 10*time.Second, d.backoff(5))
+	assert.Equal(t, 10*time.Second, d.backoff(50))
+}
+
+func TestDispatchOnce(t *testing.T) {
+	now := time.Date(2023, 1, 1, 12, 0, 0, 0, time.UTC)
+	payload := []byte(`{"id":7,"type":"user.created","user_id":1}`)
+
+	tests := []struct {
+		name       string
+		status     int
+		attempts   int
+		wantResult storage.DeliveryResult
+	}{
+		{
+			name:       "delivered",
+			status:     http.StatusNoContent,
+			attempts:   1,
+			wantResult: storage.DeliveryResult{Status: domain.DeliveryDelivered, StatusCode: http.StatusNoContent},
+		},
+		{
+			name:     "retried with backoff",
+			status:   http.StatusServiceUnavailable,
+			attempts: 2,
+			wantResult: storage.DeliveryResult{
+				Status:        domain.DeliveryPending,
+				StatusCode:    http.StatusServiceUnavailable,
+				Error:         "receiver returned 503 Service Unavailable",
+				NextAttemptAt: now.Add(2 * time.Minute),
+			},
+		},
+		{
+			name:     "dead-lettered after the last attempt",
+			status:   http.StatusInternalServerError,
+			attempts: 3,
+			wantResult: storage.DeliveryResult{
+				Status:     domain.DeliveryDead,
+				StatusCode: http.StatusInternalServerError,
+				Error:      "receiver returned 500 Internal Server Error",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// The receiver checks the signature like a partner would
+			receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, err := io.ReadAll(r.Body)
+				require.NoError(t, err)
+				assert.Equal(t, payload, body)
+				assert.Equal(t, "user.created", r.Header.Get(EventHeader))
+				assert.Equal(t, "42", r.Header.Get(DeliveryHeader))
+				assert.Equal(t, Sign("top-secret-value", r.Header.Get(TimestampHeader), body), r.Header.Get(SignatureHeader))
+
+				if tt.status != http.StatusNoContent {
+					http.Error(w, "busy", tt.status)
+					return
+				}
+				w.WriteHeader(tt.status)
+			}))
+			defer receiver.Close()
+
+			store := storagemocks.NewMockWebhookStorer(t)
+			d := NewDispatcher(store, Config{
+				BatchSize:   10,
+				Timeout:     time.Second,
+				MaxAttempts: 3,
+				BaseDelay:   time.Minute,
+				MaxDelay:    time.Hour,
+				// The receiver listens on the loopback interface
+				AllowPrivateNetworks: true,
+			}, zap.NewNop())
+			d.now = func() time.Time { return now }
+
+			delivery := storage.ClaimedDelivery{
+				WebhookDelivery: domain.WebhookDelivery{
+					ID:        42,
+					WebhookID: 1,
+					EventID:   7,
+					EventType: domain.EventUserCreated,
+					Payload:   payload,
+					Attempts:  tt.attempts,
+				},
+				URL:    receiver.URL,
+				Secret: "top-secret-value",
+			}
+			store.On("ClaimDeliveries", mock.Anything, 10, 6*time.Second).Return([]storage.ClaimedDelivery{delivery}, nil)
+			store.On("FinishDelivery", mock.Anything, int64(42), tt.attempts, tt.wantResult).Return(nil)
+
+			assert.Equal(t, 1, d.DispatchOnce(context.Background()))
+			store.AssertExpectations(t)
+		})
+	}
+}
+
+func TestHandleEvent(t *testing.T) {
+	store := storagemocks.NewMockWebhookStorer(t)
+	d := NewDispatcher(store, Config{}, zap.NewNop())
+
+	event := domain.Event{ID: 1, Type: domain.EventUserDeleted, UserID: 3}
+	store.On("EnqueueDeliveries", mock.Anything, event).Return(int64(2), nil)
+
+	assert.NoError(t, d.HandleEvent(context.Background(), event))
+}
@@ -0,0 +1,10 @@
+-- Add soft delete marker to users
+ALTER TABLE users ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP WITH TIME ZONE;
+
+-- Email uniqueness only applies to live users
+-- The index keeps the name of the former constraint so that violations are reported the same way
+ALTER TABLE users DROP CONSTRAINT IF EXISTS users_email_key;
+CREATE UNIQUE INDEX IF NOT EXISTS users_email_key ON users(email) WHERE deleted_at IS NULL;
+
+-- Create index on deleted_at for the purge job
+CREATE INDEX IF NOT EXISTS idx_users_deleted_at ON users(deleted_at) WHERE deleted_at IS NOT NULL;
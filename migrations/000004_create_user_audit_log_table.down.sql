@@ -0,0 +1,3 @@
+-- Drop audit trail (will cascade to triggers and indexes)
+DROP TABLE IF EXISTS user_audit_log CASCADE;
+DROP FUNCTION IF EXISTS user_audit_log_append_only();
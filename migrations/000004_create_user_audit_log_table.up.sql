@@ -0,0 +1,29 @@
+-- Create audit trail of user mutations
+-- There is no foreign key on purpose, the history outlives purged users
+CREATE TABLE IF NOT EXISTS user_audit_log (
+    id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT NOT NULL,
+    actor VARCHAR(255) NOT NULL,
+    request_id VARCHAR(128) NOT NULL,
+    operation VARCHAR(16) NOT NULL,
+    changes JSONB NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+-- Create index on user_id for paging through the history of a user
+CREATE INDEX IF NOT EXISTS idx_user_audit_log_user_id ON user_audit_log(user_id, id);
+
+-- Enforce the audit trail to be append-only
+CREATE OR REPLACE FUNCTION user_audit_log_append_only() RETURNS TRIGGER AS $$
+BEGIN
+    RAISE EXCEPTION 'user_audit_log is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER user_audit_log_no_update_delete
+    BEFORE UPDATE OR DELETE ON user_audit_log
+    FOR EACH ROW EXECUTE FUNCTION user_audit_log_append_only();
+
+CREATE TRIGGER user_audit_log_no_truncate
+    BEFORE TRUNCATE ON user_audit_log
+    FOR EACH STATEMENT EXECUTE FUNCTION user_audit_log_append_only();
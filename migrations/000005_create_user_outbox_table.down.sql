@@ -0,0 +1,2 @@
+-- Drop outbox (will cascade to indexes)
+DROP TABLE IF EXISTS user_outbox CASCADE;
@@ -0,0 +1,16 @@
+-- Create transactional outbox of user lifecycle events
+-- Events are written along with the change and published by the relay afterwards
+CREATE TABLE IF NOT EXISTS user_outbox (
+    id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT NOT NULL,
+    event_type VARCHAR(32) NOT NULL,
+    payload JSONB NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    published_at TIMESTAMP WITH TIME ZONE
+);
+
+-- Create index on the pending events for the relay
+CREATE INDEX IF NOT EXISTS idx_user_outbox_pending ON user_outbox(id) WHERE published_at IS NULL;
+
+-- Create index on published_at for pruning published events
+CREATE INDEX IF NOT EXISTS idx_user_outbox_published_at ON user_outbox(published_at);
@@ -0,0 +1,3 @@
+-- Drop webhook tables (will cascade to indexes)
+DROP TABLE IF EXISTS webhook_deliveries CASCADE;
+DROP TABLE IF EXISTS webhook_subscriptions CASCADE;
@@ -0,0 +1,3 @@
+-- Drop outbox notifications
+DROP TRIGGER IF EXISTS user_outbox_notify ON user_outbox;
+DROP FUNCTION IF EXISTS user_outbox_notify();
@@ -0,0 +1,18 @@
+-- Notify listeners of every event written to the outbox, once the transaction commits
+-- The payload has the same shape as the events published by the relay
+CREATE OR REPLACE FUNCTION user_outbox_notify() RETURNS TRIGGER AS $$
+BEGIN
+    PERFORM pg_notify('user_events', json_build_object(
+        'id', NEW.id,
+        'type', NEW.event_type,
+        'user_id', NEW.user_id,
+        'occurred_at', NEW.created_at,
+        'data', NEW.payload
+    )::text);
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER user_outbox_notify
+    AFTER INSERT ON user_outbox
+    FOR EACH ROW EXECUTE FUNCTION user_outbox_notify();
@@ -0,0 +1,2 @@
+-- Drop jobs table (will cascade to indexes)
+DROP TABLE IF EXISTS jobs CASCADE;
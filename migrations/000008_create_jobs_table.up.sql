@@ -0,0 +1,24 @@
+-- Create queue of background jobs
+-- A running job is leased by its worker until locked_until, and claimed again once the lease expires
+CREATE TABLE IF NOT EXISTS jobs (
+    id BIGSERIAL PRIMARY KEY,
+    type VARCHAR(64) NOT NULL,
+    payload JSONB NOT NULL,
+    status VARCHAR(16) NOT NULL DEFAULT 'queued',
+    progress_done BIGINT NOT NULL DEFAULT 0,
+    progress_total BIGINT NOT NULL DEFAULT 0,
+    result JSONB,
+    error TEXT,
+    attempts INT NOT NULL DEFAULT 0,
+    cancel_requested BOOLEAN NOT NULL DEFAULT FALSE,
+    locked_until TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    started_at TIMESTAMP WITH TIME ZONE,
+    finished_at TIMESTAMP WITH TIME ZONE
+);
+
+-- Create index on the jobs waiting for a worker
+CREATE INDEX IF NOT EXISTS idx_jobs_claimable ON jobs(id) WHERE status IN ('queued', 'running');
+
+-- Create index on finished_at for pruning finished jobs
+CREATE INDEX IF NOT EXISTS idx_jobs_finished_at ON jobs(finished_at);
@@ -0,0 +1,5 @@
+-- Emails are unique regardless of case, the way they are looked up
+-- Live users whose emails only differ by case must be fixed before the index can be created
+-- The index keeps the name of the former one so that violations are reported the same way
+DROP INDEX IF EXISTS users_email_key;
+CREATE UNIQUE INDEX IF NOT EXISTS users_email_key ON users (lower(email)) WHERE deleted_at IS NULL;
@@ -0,0 +1,5 @@
+-- Drop the horizon of outbox events
+DROP TRIGGER IF EXISTS user_outbox_horizon ON user_outbox;
+DROP FUNCTION IF EXISTS user_outbox_horizon();
+ALTER TABLE user_outbox ALTER COLUMN id SET DEFAULT nextval('user_outbox_id_seq');
+ALTER TABLE user_outbox DROP COLUMN IF EXISTS horizon;
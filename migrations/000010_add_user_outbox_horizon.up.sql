@@ -0,0 +1,22 @@
+-- Record on each event the horizon of the transactions that may still hold a lower ID
+-- Events are replayed by ID, so an event is only replayed once every transaction below its horizon has ended,
+-- otherwise a lower ID committing later would be skipped. Events written before the column count as settled
+ALTER TABLE user_outbox ADD COLUMN IF NOT EXISTS horizon BIGINT NOT NULL DEFAULT 0;
+
+-- The ID is drawn by the trigger, once the transaction has an ID of its own. The horizon is then read from a
+-- fresh snapshot, which read committed takes for every statement, so it is above every transaction that drew
+-- a lower ID
+ALTER TABLE user_outbox ALTER COLUMN id DROP DEFAULT;
+
+CREATE OR REPLACE FUNCTION user_outbox_horizon() RETURNS TRIGGER AS $$
+BEGIN
+    PERFORM txid_current();
+    NEW.id := nextval('user_outbox_id_seq');
+    NEW.horizon := txid_snapshot_xmax(txid_current_snapshot());
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER user_outbox_horizon
+    BEFORE INSERT ON user_outbox
+    FOR EACH ROW EXECUTE FUNCTION user_outbox_horizon();